@@ -0,0 +1,96 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+type Assignment struct {
+	ID          int        `json:"id"`
+	CourseID    int        `json:"courseID"`
+	Course      *Course    `json:"course"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	MaxPoints   int        `json:"maxPoints"`
+	DueAt       *time.Time `json:"dueAt"`
+
+	// If set, the assignment is submitted once per group of the set.
+	GroupSetID *int `json:"groupSetID"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (a *Assignment) Validate() error {
+	if a.CourseID == 0 {
+		return Errorf(EINVALID, "Course required.")
+	} else if a.Title == "" {
+		return Errorf(EINVALID, "Title required.")
+	} else if a.MaxPoints <= 0 {
+		return Errorf(EINVALID, "Max points must be positive.")
+	}
+	return nil
+}
+
+type Submission struct {
+	ID           int         `json:"id"`
+	AssignmentID int         `json:"assignmentID"`
+	Assignment   *Assignment `json:"assignment"`
+	StudentID    int         `json:"studentID"`
+	Student      *Student    `json:"student"`
+	Body         string      `json:"body"`
+	Grade        *int        `json:"grade"`
+	Feedback     string      `json:"feedback"`
+	GradedAt     *time.Time  `json:"gradedAt"`
+
+	// Set for submissions to group assignments. The grade applies to
+	// MemberIDs, the group's members when the work was submitted.
+	GroupID   *int  `json:"groupID"`
+	MemberIDs []int `json:"memberIDs"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (s *Submission) Validate() error {
+	if s.AssignmentID == 0 {
+		return Errorf(EINVALID, "Assignment required.")
+	} else if s.StudentID == 0 {
+		return Errorf(EINVALID, "Student required.")
+	}
+	return nil
+}
+
+type AssignmentService interface {
+	FindAssignmentByID(ctx context.Context, id int) (*Assignment, error)
+	FindAssignments(ctx context.Context, filter AssignmentFilter) ([]*Assignment, int, error)
+	CreateAssignment(ctx context.Context, assignment *Assignment) error
+
+	FindSubmissionByID(ctx context.Context, id int) (*Submission, error)
+	FindSubmissions(ctx context.Context, filter SubmissionFilter) ([]*Submission, int, error)
+	CreateSubmission(ctx context.Context, submission *Submission) error
+	GradeSubmission(ctx context.Context, id int, grade SubmissionGrade) (*Submission, error)
+}
+
+type AssignmentFilter struct {
+	ID       *int `json:"id"`
+	CourseID *int `json:"courseID"`
+	Offset   int  `json:"offset"`
+	Limit    int  `json:"limit"`
+}
+
+type SubmissionFilter struct {
+	ID           *int `json:"id"`
+	AssignmentID *int `json:"assignmentID"`
+
+	// Matches submissions by the student and by groups they belonged to.
+	StudentID *int `json:"studentID"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+type SubmissionGrade struct {
+	Grade    int    `json:"grade"`
+	Feedback string `json:"feedback"`
+}
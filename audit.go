@@ -0,0 +1,100 @@
+package ocs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+	AuditActionErase   = "erase"
+)
+
+// AuditEntry records a change made to an entity. Entries are written with the
+// change and never modified, except to blank the IP. Each entry's Hash covers its content and the
+// hash of the entry before it, so that an edited or removed entry breaks the
+// chain from that point on.
+type AuditEntry struct {
+	ID         int    `json:"id"`
+	ActorID    int    `json:"actorID"`
+	Action     string `json:"action"`
+	EntityType string `json:"entityType"`
+	EntityID   int    `json:"entityID"`
+
+	// The fields that changed. Created entities have no "from" values and
+	// deleted entities have no "to" values.
+	Changes map[string]AuditChange `json:"changes"`
+
+	RequestID string `json:"requestID"`
+
+	// The actor's IP, blanked when the actor's account is erased. It is
+	// not covered by the hash so that it can be.
+	IP string `json:"ip"`
+
+	PrevHash  string    `json:"prevHash"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type AuditChange struct {
+	From json.RawMessage `json:"from,omitempty"`
+	To   json.RawMessage `json:"to,omitempty"`
+}
+
+// ComputeHash returns the hex SHA-256 of PrevHash and the entry's content.
+func (e *AuditEntry) ComputeHash() (string, error) {
+	buf, err := json.Marshal(struct {
+		ActorID    int                    `json:"actorID"`
+		Action     string                 `json:"action"`
+		EntityType string                 `json:"entityType"`
+		EntityID   int                    `json:"entityID"`
+		Changes    map[string]AuditChange `json:"changes"`
+		RequestID  string                 `json:"requestID"`
+		CreatedAt  string                 `json:"createdAt"`
+	}{e.ActorID, e.Action, e.EntityType, e.EntityID, e.Changes, e.RequestID, e.CreatedAt.UTC().Format(time.RFC3339)})
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(e.PrevHash))
+	h.Write([]byte("\n"))
+	h.Write(buf)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// AuditVerification is the result of checking the audit log's hash chain.
+type AuditVerification struct {
+	Valid   bool `json:"valid"`
+	Entries int  `json:"entries"`
+
+	// The first entry whose hash does not match, if the chain is broken.
+	BrokenEntryID int `json:"brokenEntryID,omitempty"`
+}
+
+// AuditService lets admins read the audit log. Entries are only ever written
+// by the other services, in the transaction of the change they record.
+type AuditService interface {
+	FindAuditEntries(ctx context.Context, filter AuditFilter) ([]*AuditEntry, int, error)
+	VerifyAuditLog(ctx context.Context) (*AuditVerification, error)
+}
+
+type AuditFilter struct {
+	ID         *int       `json:"id"`
+	ActorID    *int       `json:"actorID"`
+	Action     *string    `json:"action"`
+	EntityType *string    `json:"entityType"`
+	EntityID   *int       `json:"entityID"`
+	RequestID  *string    `json:"requestID"`
+	Since      *time.Time `json:"since"`
+	Until      *time.Time `json:"until"`
+	Offset     int        `json:"offset"`
+	Limit      int        `json:"limit"`
+}
@@ -0,0 +1,190 @@
+package ocs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"image"
+	"image/color"
+	_ "image/gif"  // register GIF decoding
+	_ "image/jpeg" // register JPEG decoding
+	"image/png"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// AvatarSizes are the sizes, in pixels, that avatars are served at. Other
+// sizes are rounded up to the next one, so that only a few resized versions
+// need to be cached.
+var AvatarSizes = []int{32, 64, 128, 256}
+
+// DefaultAvatarSize is the size served when none is requested.
+const DefaultAvatarSize = 64
+
+// Limits on uploaded avatars.
+const (
+	MaxAvatarUploadSize = 5 << 20 // bytes
+	MaxAvatarDimension  = 4096    // pixels, in either direction
+)
+
+// AvatarSize rounds size up to the nearest of AvatarSizes.
+func AvatarSize(size int) int {
+	if size <= 0 {
+		return DefaultAvatarSize
+	}
+	for _, n := range AvatarSizes {
+		if size <= n {
+			return n
+		}
+	}
+	return AvatarSizes[len(AvatarSizes)-1]
+}
+
+// AvatarImage is an avatar encoded at one size.
+type AvatarImage struct {
+	ContentType string
+	Data        []byte
+	UpdatedAt   time.Time
+}
+
+type AvatarService interface {
+	// FindAvatar returns a student's avatar at the given size, rounded by
+	// AvatarSize. Students who have not uploaded an avatar get an SVG of
+	// their initials. No student needs to be logged in.
+	FindAvatar(ctx context.Context, studentID, size int) (*AvatarImage, error)
+
+	// UploadAvatar replaces a student's avatar with a PNG, JPEG or GIF image
+	// read from r. Students may only change their own avatar.
+	UploadAvatar(ctx context.Context, studentID int, r io.Reader) (*Student, error)
+
+	// DeleteAvatar removes a student's uploaded avatar.
+	DeleteAvatar(ctx context.Context, studentID int) (*Student, error)
+}
+
+// DecodeAvatar decodes an uploaded avatar, center-crops it to a square and
+// resizes it to the largest of AvatarSizes. The result is encoded as PNG.
+func DecodeAvatar(r io.Reader) ([]byte, error) {
+	buf, err := io.ReadAll(io.LimitReader(r, MaxAvatarUploadSize+1))
+	if err != nil {
+		return nil, err
+	} else if len(buf) > MaxAvatarUploadSize {
+		return nil, Errorf(EINVALID, "Avatar must be at most %d MB.", MaxAvatarUploadSize>>20)
+	}
+
+	// Check the dimensions before decoding, so a small file cannot make us
+	// allocate a huge image.
+	config, _, err := image.DecodeConfig(bytes.NewReader(buf))
+	if err != nil {
+		return nil, Errorf(EINVALID, "Avatar must be a PNG, JPEG or GIF image.")
+	} else if config.Width > MaxAvatarDimension || config.Height > MaxAvatarDimension {
+		return nil, Errorf(EINVALID, "Avatar must be at most %dx%d pixels.", MaxAvatarDimension, MaxAvatarDimension)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(buf))
+	if err != nil {
+		return nil, Errorf(EINVALID, "Avatar must be a PNG, JPEG or GIF image.")
+	}
+	return EncodeAvatar(img, AvatarSizes[len(AvatarSizes)-1])
+}
+
+// EncodeAvatar resizes the center square of img to size x size pixels and
+// encodes it as PNG.
+func EncodeAvatar(img image.Image, size int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, resizeSquare(img, size)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resizeSquare scales the center square of src to size x size pixels. Each
+// destination pixel is the average of the source pixels it covers, which
+// gives a smooth result when shrinking; enlarging repeats pixels.
+func resizeSquare(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	x0, y0 := b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	if side == 0 {
+		return dst
+	}
+	for dy := 0; dy < size; dy++ {
+		sy0, sy1 := y0+dy*side/size, y0+(dy+1)*side/size
+		if sy1 == sy0 {
+			sy1++
+		}
+		for dx := 0; dx < size; dx++ {
+			sx0, sx1 := x0+dx*side/size, x0+(dx+1)*side/size
+			if sx1 == sx0 {
+				sx1++
+			}
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			dst.Set(dx, dy, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// avatarColors are the backgrounds of initials avatars.
+var avatarColors = []string{
+	"#1abc9c", "#2ecc71", "#3498db", "#9b59b6", "#34495e",
+	"#16a085", "#27ae60", "#2980b9", "#8e44ad", "#e67e22",
+	"#e74c3c", "#c0392b", "#d35400", "#7f8c8d",
+}
+
+// InitialsAvatar returns an SVG avatar showing the initials of the
+// student's display name, or name if not set. The background color depends
+// only on the student's ID, so it stays the same if they change their name.
+func InitialsAvatar(s *Student, size int) []byte {
+	name := s.DisplayName
+	if name == "" {
+		name = s.Name
+	}
+
+	var initials []rune
+	for _, word := range strings.Fields(name) {
+		for _, r := range word {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				initials = append(initials, unicode.ToUpper(r))
+				break
+			}
+		}
+		if len(initials) == 2 {
+			break
+		}
+	}
+	if len(initials) == 0 {
+		initials = []rune{'?'}
+	}
+
+	h := fnv.New32a()
+	fmt.Fprint(h, s.ID)
+	bg := avatarColors[h.Sum32()%uint32(len(avatarColors))]
+
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="%[1]d" viewBox="0 0 %[1]d %[1]d">`+
+		`<rect width="%[1]d" height="%[1]d" fill="%[2]s"/>`+
+		`<text x="50%%" y="50%%" dy=".35em" text-anchor="middle" font-family="sans-serif" font-size="%[3]d" fill="#fff">%[4]s</text>`+
+		`</svg>`,
+		size, bg, size*2/5, html.EscapeString(string(initials)),
+	))
+}
@@ -0,0 +1,109 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+type Badge struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// BadgeRule declares when a badge is earned. Each published event of
+// EventType accepted by Match counts as one activity. The badge is awarded
+// once the student has Count activities or, if StreakDays is set, activity
+// on that many consecutive days. Events are counted at most once however
+// often they are replayed.
+type BadgeRule struct {
+	Badge      Badge
+	EventType  string
+	Match      func(payload interface{}) bool
+	Count      int
+	StreakDays int
+}
+
+// Matches reports whether the event counts towards the rule.
+func (r *BadgeRule) Matches(event Event) bool {
+	if event.Type != r.EventType {
+		return false
+	}
+	return r.Match == nil || r.Match(event.Payload)
+}
+
+// DefaultBadgeRules are the badges awarded out of the box. There is no forum,
+// so no badge is awarded for helping peers in one.
+var DefaultBadgeRules = []BadgeRule{
+	{
+		Badge: Badge{
+			Code:        "first-submission",
+			Name:        "First Submission",
+			Description: "Submitted your first assignment.",
+		},
+		EventType: EventTypeSubmissionCreated,
+		Count:     1,
+	},
+	{
+		Badge: Badge{
+			Code:        "first-assignment-passed",
+			Name:        "First Pass",
+			Description: "Scored at least half the points on an assignment.",
+		},
+		EventType: EventTypeSubmissionGraded,
+		Match: func(payload interface{}) bool {
+			p, ok := payload.(*SubmissionGradedPayload)
+			return ok && p.Grade*2 >= p.MaxPoints
+		},
+		Count: 1,
+	},
+	{
+		Badge: Badge{
+			Code:        "5-day-streak",
+			Name:        "5-Day Streak",
+			Description: "Submitted work on five days in a row.",
+		},
+		EventType:  EventTypeSubmissionCreated,
+		StreakDays: 5,
+	},
+	{
+		Badge: Badge{
+			Code:        "10-submissions",
+			Name:        "Prolific",
+			Description: "Submitted ten assignments.",
+		},
+		EventType: EventTypeSubmissionCreated,
+		Count:     10,
+	},
+	{
+		Badge: Badge{
+			Code:        "path-completed",
+			Name:        "Pathfinder",
+			Description: "Completed a learning path.",
+		},
+		EventType: EventTypeLearningPathCompleted,
+		Count:     1,
+	},
+}
+
+// BadgeAward records a badge earned by a student. The badge is copied at the
+// time of the award so that it still reads the same if the rules change.
+type BadgeAward struct {
+	ID        int       `json:"id"`
+	StudentID int       `json:"studentID"`
+	Badge     Badge     `json:"badge"`
+	AwardedAt time.Time `json:"awardedAt"`
+}
+
+type BadgeService interface {
+	// FindBadges returns every badge that can be earned.
+	FindBadges(ctx context.Context) ([]*Badge, error)
+	FindBadgeAwards(ctx context.Context, filter BadgeAwardFilter) ([]*BadgeAward, int, error)
+}
+
+type BadgeAwardFilter struct {
+	StudentID *int    `json:"studentID"`
+	BadgeCode *string `json:"badgeCode"`
+	Offset    int     `json:"offset"`
+	Limit     int     `json:"limit"`
+}
@@ -6,7 +6,9 @@ type contextKey int
 
 const (
 	studentContextKey = contextKey(iota + 1)
+	organizationContextKey
 	flashContextKey
+	requestContextKey
 )
 
 func NewContextWithStudent(ctx context.Context, student *Student) context.Context {
@@ -25,6 +27,33 @@ func StudentIDFromContext(ctx context.Context) int {
 	return 0
 }
 
+// NewContextWithOrganization returns a context scoped to the organization
+// the request was made to. A nil organization scopes it to the default site,
+// whose catalog is the courses that belong to no organization. Storage reads
+// no tenant data through a context that was never scoped.
+func NewContextWithOrganization(ctx context.Context, org *Organization) context.Context {
+	return context.WithValue(ctx, organizationContextKey, org)
+}
+
+// HasOrganizationScope reports whether the context was scoped with
+// NewContextWithOrganization, to an organization or to the default site.
+func HasOrganizationScope(ctx context.Context) bool {
+	_, ok := ctx.Value(organizationContextKey).(*Organization)
+	return ok
+}
+
+func OrganizationFromContext(ctx context.Context) *Organization {
+	org, _ := ctx.Value(organizationContextKey).(*Organization)
+	return org
+}
+
+func OrganizationIDFromContext(ctx context.Context) int {
+	if org := OrganizationFromContext(ctx); org != nil {
+		return org.ID
+	}
+	return 0
+}
+
 func NewContextWithFlash(ctx context.Context, v string) context.Context {
 	return context.WithValue(ctx, flashContextKey, v)
 }
@@ -33,3 +62,18 @@ func FlashFromContext(ctx context.Context) string {
 	v, _ := ctx.Value(flashContextKey).(string)
 	return v
 }
+
+// RequestInfo identifies the request a change is made in, for the audit log.
+type RequestInfo struct {
+	ID string
+	IP string
+}
+
+func NewContextWithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestContextKey, info)
+}
+
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestContextKey).(RequestInfo)
+	return info
+}
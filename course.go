@@ -0,0 +1,94 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+type Course struct {
+	ID           int      `json:"id"`
+	InstructorID int      `json:"instructorID"`
+	Instructor   *Student `json:"instructor"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+
+	// Price in the smallest unit of Currency, e.g. cents. Courses with a
+	// price must be bought through checkout rather than joined directly.
+	Price    int    `json:"price"`
+	Currency string `json:"currency"`
+
+	// The organization the course belongs to, or zero for none. It is set
+	// from the context the course is created in.
+	OrganizationID int `json:"organizationID"`
+
+	// If set, students may only message each other in conversations that
+	// include a member of the course staff.
+	StudentMessagingDisabled bool `json:"studentMessagingDisabled"`
+
+	// Hours before an assignment is due at which students who have not
+	// submitted it are reminded. New courses use DefaultReminderHours.
+	ReminderHours     []int `json:"reminderHours"`
+	RemindersDisabled bool  `json:"remindersDisabled"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// Set when the course is deleted. Deleted courses can be restored by
+	// an admin until they are purged.
+	DeletedAt *time.Time `json:"deletedAt"`
+}
+
+func (c *Course) Validate() error {
+	if c.InstructorID == 0 {
+		return Errorf(EINVALID, "Instructor required.")
+	} else if c.Title == "" {
+		return Errorf(EINVALID, "Title required.")
+	} else if c.Price < 0 {
+		return Errorf(EINVALID, "Price must not be negative.")
+	} else if c.Price > 0 && c.Currency == "" {
+		return Errorf(EINVALID, "Currency required.")
+	}
+	for _, h := range c.ReminderHours {
+		if h <= 0 || h > MaxReminderHours {
+			return Errorf(EINVALID, "Reminder hours must be between 1 and %d.", MaxReminderHours)
+		}
+	}
+	return nil
+}
+
+// DefaultReminderHours remind students two days and two hours before an
+// assignment is due.
+var DefaultReminderHours = []int{48, 2}
+
+// MaxReminderHours is the earliest a reminder can be sent before a due date.
+const MaxReminderHours = 14 * 24
+
+type CourseService interface {
+	FindCourseByID(ctx context.Context, id int) (*Course, error)
+	FindCourses(ctx context.Context, filter CourseFilter) ([]*Course, int, error)
+	CreateCourse(ctx context.Context, course *Course) error
+	UpdateCourse(ctx context.Context, id int, upd CourseUpdate) (*Course, error)
+	DeleteCourse(ctx context.Context, id int) error
+
+	// RestoreCourse undoes a deletion that has not been purged yet.
+	RestoreCourse(ctx context.Context, id int) (*Course, error)
+}
+
+type CourseFilter struct {
+	ID           *int `json:"id"`
+	InstructorID *int `json:"instructorID"`
+	Offset       int  `json:"offset"`
+	Limit        int  `json:"limit"`
+}
+
+type CourseUpdate struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Price       *int    `json:"price"`
+	Currency    *string `json:"currency"`
+
+	StudentMessagingDisabled *bool `json:"studentMessagingDisabled"`
+
+	ReminderHours     *[]int `json:"reminderHours"`
+	RemindersDisabled *bool  `json:"remindersDisabled"`
+}
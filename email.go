@@ -0,0 +1,241 @@
+package ocs
+
+import (
+	"bytes"
+	"context"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+)
+
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+	EmailStatusFailed  = "failed"
+)
+
+// Email is a rendered email in the outbox. Pending emails are retried until
+// they are sent or run out of attempts, at which point they are failed.
+type Email struct {
+	ID            int        `json:"id"`
+	StudentID     int        `json:"studentID"`
+	Template      string     `json:"template"`
+	To            string     `json:"to"`
+	Subject       string     `json:"subject"`
+	Text          string     `json:"text"`
+	HTML          string     `json:"html"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"lastError"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt"`
+	SentAt        *time.Time `json:"sentAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// EmailSender delivers emails, e.g. through an SMTP server.
+type EmailSender interface {
+	SendEmail(ctx context.Context, email *Email) error
+}
+
+// EmailData is passed to email templates. Fields that do not apply to a
+// template are nil.
+type EmailData struct {
+	Student    *Student
+	Course     *Course
+	Assignment *Assignment
+
+	// Notifications collected in a digest and the digest's period, e.g.
+	// "daily".
+	Notifications []*Notification
+	Period        string
+
+	// An export that is ready and the link to download it.
+	Export *Export
+	URL    string
+
+	ErasureRequest *ErasureRequest
+
+	// A verification sent to Student.Email; its link is in URL.
+	EmailVerification *EmailVerification
+
+	// When a password reset or sign-in link in URL expires.
+	ExpiresAt time.Time
+}
+
+// EmailTemplate renders one kind of email. The subject and plain-text part
+// are text templates; the HTML part is an HTML template so that data is
+// escaped.
+type EmailTemplate struct {
+	Name    string
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// NewEmailTemplate parses the parts of an email template. It panics on
+// invalid templates, which are program errors.
+func NewEmailTemplate(name, subject, text, html string) *EmailTemplate {
+	return &EmailTemplate{
+		Name:    name,
+		subject: texttemplate.Must(texttemplate.New(name).Parse(subject)),
+		text:    texttemplate.Must(texttemplate.New(name).Parse(text)),
+		html:    htmltemplate.Must(htmltemplate.New(name).Parse(html)),
+	}
+}
+
+// Render executes the template and returns an email addressed to the
+// data's student.
+func (t *EmailTemplate) Render(data EmailData) (*Email, error) {
+	var subject, text, html bytes.Buffer
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return nil, err
+	} else if err := t.text.Execute(&text, data); err != nil {
+		return nil, err
+	} else if err := t.html.Execute(&html, data); err != nil {
+		return nil, err
+	}
+
+	return &Email{
+		StudentID: data.Student.ID,
+		Template:  t.Name,
+		To:        data.Student.Email,
+		Subject:   subject.String(),
+		Text:      text.String(),
+		HTML:      html.String(),
+	}, nil
+}
+
+var WelcomeEmail = NewEmailTemplate("welcome",
+	`Welcome, {{.Student.Name}}`,
+	`Hi {{.Student.Name}},
+
+Welcome aboard! Browse the course catalog to find your first course.
+`,
+	`<p>Hi {{.Student.Name}},</p>
+<p>Welcome aboard! Browse the course catalog to find your first course.</p>
+`)
+
+var EnrollmentEmail = NewEmailTemplate("enrollment",
+	`You are enrolled in {{.Course.Title}}`,
+	`Hi {{.Student.Name}},
+
+You are now enrolled in {{.Course.Title}}.
+`,
+	`<p>Hi {{.Student.Name}},</p>
+<p>You are now enrolled in <strong>{{.Course.Title}}</strong>.</p>
+`)
+
+var DeadlineReminderEmail = NewEmailTemplate("deadline_reminder",
+	`Reminder: {{.Assignment.Title}} is due soon`,
+	`Hi {{.Student.Name}},
+
+{{.Assignment.Title}} in {{.Course.Title}} is due on {{.Assignment.DueAt.Format "Mon Jan 2 15:04 MST"}} and you have not submitted it yet.
+`,
+	`<p>Hi {{.Student.Name}},</p>
+<p><strong>{{.Assignment.Title}}</strong> in {{.Course.Title}} is due on {{.Assignment.DueAt.Format "Mon Jan 2 15:04 MST"}} and you have not submitted it yet.</p>
+`)
+
+var NotificationEmail = NewEmailTemplate("notification",
+	`{{(index .Notifications 0).Summary}}`,
+	`Hi {{.Student.Name}},
+
+{{(index .Notifications 0).Summary}}
+`,
+	`<p>Hi {{.Student.Name}},</p>
+<p>{{(index .Notifications 0).Summary}}</p>
+`)
+
+var DigestEmail = NewEmailTemplate("digest",
+	`Your {{.Period}} digest: {{len .Notifications}} new notification{{if ne (len .Notifications) 1}}s{{end}}`,
+	`Hi {{.Student.Name}},
+
+Here is what happened since your last digest:
+{{range .Notifications}}
+- {{.Summary}}{{end}}
+`,
+	`<p>Hi {{.Student.Name}},</p>
+<p>Here is what happened since your last digest:</p>
+<ul>{{range .Notifications}}
+<li>{{.Summary}}</li>{{end}}
+</ul>
+`)
+
+var ExportReadyEmail = NewEmailTemplate("export_ready",
+	`Your data export is ready`,
+	`Hi {{.Student.Name}},
+
+Your data export is ready. Download it here:
+
+{{.URL}}
+
+The link expires on {{.Export.ExpiresAt.Format "Mon Jan 2 15:04 MST"}}.
+`,
+	`<p>Hi {{.Student.Name}},</p>
+<p>Your data export is ready. <a href="{{.URL}}">Download it here</a>.</p>
+<p>The link expires on {{.Export.ExpiresAt.Format "Mon Jan 2 15:04 MST"}}.</p>
+`)
+
+var ErasureRequestedEmail = NewEmailTemplate("erasure_requested",
+	`Your account will be erased`,
+	`Hi {{.Student.Name}},
+
+We received your request to erase your account. It will be erased on {{.ErasureRequest.EraseAt.Format "Mon Jan 2 15:04 MST"}}. Until then you can cancel the request from your account settings.
+`,
+	`<p>Hi {{.Student.Name}},</p>
+<p>We received your request to erase your account. It will be erased on {{.ErasureRequest.EraseAt.Format "Mon Jan 2 15:04 MST"}}. Until then you can cancel the request from your account settings.</p>
+`)
+
+var ErasureCompletedEmail = NewEmailTemplate("erasure_completed",
+	`Your account has been erased`,
+	`Hi {{.Student.Name}},
+
+Your account has been erased as you requested. This is the last email we will send you.
+`,
+	`<p>Hi {{.Student.Name}},</p>
+<p>Your account has been erased as you requested. This is the last email we will send you.</p>
+`)
+
+var VerifyEmailEmail = NewEmailTemplate("verify_email",
+	`Confirm your email address`,
+	`Hi {{.Student.Name}},
+
+Please confirm that this is your email address by opening this link:
+
+{{.URL}}
+
+The link can be used once and expires on {{.EmailVerification.ExpiresAt.Format "Mon Jan 2 15:04 MST"}}. If you did not ask for this, you can ignore this email.
+`,
+	`<p>Hi {{.Student.Name}},</p>
+<p>Please <a href="{{.URL}}">confirm that this is your email address</a>.</p>
+<p>The link can be used once and expires on {{.EmailVerification.ExpiresAt.Format "Mon Jan 2 15:04 MST"}}. If you did not ask for this, you can ignore this email.</p>
+`)
+
+var PasswordResetEmail = NewEmailTemplate("password_reset",
+	`Reset your password`,
+	`Hi {{.Student.Name}},
+
+Someone asked to reset the password for your account. If it was you, choose a new password here:
+
+{{.URL}}
+
+The link can be used once and expires on {{.ExpiresAt.Format "Mon Jan 2 15:04 MST"}}. If you did not ask for this, you can ignore this email; your password has not changed.
+`,
+	`<p>Hi {{.Student.Name}},</p>
+<p>Someone asked to reset the password for your account. If it was you, <a href="{{.URL}}">choose a new password</a>.</p>
+<p>The link can be used once and expires on {{.ExpiresAt.Format "Mon Jan 2 15:04 MST"}}. If you did not ask for this, you can ignore this email; your password has not changed.</p>
+`)
+
+var MagicLinkEmail = NewEmailTemplate("magic_link",
+	`Your sign-in link`,
+	`Hi {{.Student.Name}},
+
+Open this link in the same browser you asked for it from to sign in:
+
+{{.URL}}
+
+The link can be used once and expires on {{.ExpiresAt.Format "Mon Jan 2 15:04 MST"}}. If you did not ask for this, you can ignore this email.
+`,
+	`<p>Hi {{.Student.Name}},</p>
+<p><a href="{{.URL}}">Sign in</a> by opening this link in the same browser you asked for it from.</p>
+<p>The link can be used once and expires on {{.ExpiresAt.Format "Mon Jan 2 15:04 MST"}}. If you did not ask for this, you can ignore this email.</p>
+`)
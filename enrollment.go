@@ -0,0 +1,66 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	EnrollmentRoleStudent = "student"
+	EnrollmentRoleTA      = "ta"
+
+	// Auditors may follow the course but not submit work.
+	EnrollmentRoleAuditor = "auditor"
+)
+
+type Enrollment struct {
+	ID          int        `json:"id"`
+	CourseID    int        `json:"courseID"`
+	Course      *Course    `json:"course"`
+	StudentID   int        `json:"studentID"`
+	Student     *Student   `json:"student"`
+	Role        string     `json:"role"`
+	CompletedAt *time.Time `json:"completedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+func (e *Enrollment) Validate() error {
+	if e.CourseID == 0 {
+		return Errorf(EINVALID, "Course required.")
+	} else if e.StudentID == 0 {
+		return Errorf(EINVALID, "Student required.")
+	} else if !IsValidEnrollmentRole(e.Role) {
+		return Errorf(EINVALID, "Invalid enrollment role.")
+	}
+	return nil
+}
+
+func IsValidEnrollmentRole(role string) bool {
+	switch role {
+	case EnrollmentRoleStudent, EnrollmentRoleTA, EnrollmentRoleAuditor:
+		return true
+	default:
+		return false
+	}
+}
+
+type EnrollmentService interface {
+	FindEnrollmentByID(ctx context.Context, id int) (*Enrollment, error)
+	FindEnrollments(ctx context.Context, filter EnrollmentFilter) ([]*Enrollment, int, error)
+	CreateEnrollment(ctx context.Context, enrollment *Enrollment) error
+	DeleteEnrollment(ctx context.Context, id int) error
+
+	// CompleteEnrollment marks a student as having completed the course.
+	// Only the course staff may complete an enrollment.
+	CompleteEnrollment(ctx context.Context, id int) (*Enrollment, error)
+}
+
+type EnrollmentFilter struct {
+	ID        *int    `json:"id"`
+	CourseID  *int    `json:"courseID"`
+	StudentID *int    `json:"studentID"`
+	Role      *string `json:"role"`
+	Offset    int     `json:"offset"`
+	Limit     int     `json:"limit"`
+}
@@ -0,0 +1,56 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	ErasureStatusPending   = "pending"
+	ErasureStatusCanceled  = "canceled"
+	ErasureStatusCompleted = "completed"
+)
+
+// ErasureRequest is a student's request to have their account erased. The
+// account is erased at EraseAt unless the request is canceled first. Requests
+// are kept after the account is erased as a record that it was done.
+//
+// Erasing an account removes what identifies the student: their name and
+// email are replaced, and their sign-in accounts, password, API key, avatar,
+// notes, bookmarks, notifications and exports are removed. Enrollments,
+// grades and points are kept, unlinked from the person, so that course
+// statistics do not change. Messages and submissions are then shown as the
+// work of an erased student. The audit log never holds personal details, so
+// it needs no erasing.
+type ErasureRequest struct {
+	ID          int        `json:"id"`
+	StudentID   int        `json:"studentID"`
+	Status      string     `json:"status"`
+	EraseAt     time.Time  `json:"eraseAt"`
+	CanceledAt  *time.Time `json:"canceledAt"`
+	CompletedAt *time.Time `json:"completedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+type ErasureService interface {
+	// FindErasureRequests returns the current student's requests, or every
+	// request for admins.
+	FindErasureRequests(ctx context.Context, filter ErasureRequestFilter) ([]*ErasureRequest, int, error)
+
+	// RequestErasure asks for the current student's account to be erased
+	// once the grace period is over.
+	RequestErasure(ctx context.Context) (*ErasureRequest, error)
+
+	// CancelErasure cancels a pending request. Students may cancel their
+	// own requests; admins may cancel any.
+	CancelErasure(ctx context.Context, id int) (*ErasureRequest, error)
+}
+
+type ErasureRequestFilter struct {
+	ID        *int    `json:"id"`
+	StudentID *int    `json:"studentID"`
+	Status    *string `json:"status"`
+	Offset    int     `json:"offset"`
+	Limit     int     `json:"limit"`
+}
@@ -7,11 +7,13 @@ import (
 
 const (
 	ECONFLICT       = "conflict"
+	EEXPIRED        = "expired"
 	EINTERNAL       = "internal"
 	EINVALID        = "invalid"
 	ENOTFOUND       = "not_found"
 	ENOTIMPLEMENTED = "not_implemented"
 	EUNAUTHORIZED   = "unauthorized"
+	EUNVERIFIED     = "unverified"
 )
 
 type Error struct {
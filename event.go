@@ -1,16 +1,140 @@
 package ocs
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // define event type constraints
+const (
+	EventTypeRegradeRequestChanged = "regrade_request:changed"
+	EventTypeLearningPathCompleted = "learning_path:completed"
+	EventTypeSubmissionCreated     = "submission:created"
+	EventTypeSubmissionGraded      = "submission:graded"
+	EventTypeBadgeEarned           = "badge:earned"
+	EventTypeEnrollmentCompleted   = "enrollment:completed"
+	EventTypeLeaderboardChanged    = "leaderboard:changed"
+	EventTypeOrderPaid             = "order:paid"
+	EventTypeRefundIssued          = "refund:issued"
+	EventTypeMessageCreated        = "message:created"
+	EventTypeConversationRead      = "conversation:read"
+	EventTypeDeadlineReminder      = "assignment:deadline_reminder"
+	EventTypeExportReady           = "export:ready"
+	EventTypeWebhookDisabled       = "webhook:disabled"
+
+	EventTypeNotificationUnreadCount = "notification:unread_count"
+)
 
 type Event struct {
 	Type    string      `json:"type"`
 	Payload interface{} `json:"payload"`
+
+	// When the change the event reports happened. Handlers that care about
+	// time use it rather than the time they process the event, which may be
+	// much later.
+	OccurredAt time.Time `json:"occurredAt"`
 }
 
 // define structs here...
 
+type RegradeRequestChangedPayload struct {
+	ID         int    `json:"id"`
+	CourseID   int    `json:"courseID"`
+	FromStatus string `json:"fromStatus"`
+	Status     string `json:"status"`
+	Reply      string `json:"reply"`
+}
+
+type LearningPathCompletedPayload struct {
+	LearningPathID int          `json:"learningPathID"`
+	Certificate    *Certificate `json:"certificate"`
+}
+
+type SubmissionCreatedPayload struct {
+	ID           int `json:"id"`
+	AssignmentID int `json:"assignmentID"`
+	CourseID     int `json:"courseID"`
+}
+
+// DeadlineReminderPayload is sent to a student who has not submitted an
+// assignment that is due in HoursBefore hours or less.
+type DeadlineReminderPayload struct {
+	AssignmentID int       `json:"assignmentID"`
+	CourseID     int       `json:"courseID"`
+	Title        string    `json:"title"`
+	DueAt        time.Time `json:"dueAt"`
+	HoursBefore  int       `json:"hoursBefore"`
+}
+
+// ExportReadyPayload is sent to a student when their data export can be
+// downloaded. The download link itself is only emailed.
+type ExportReadyPayload struct {
+	ExportID  int       `json:"exportID"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type SubmissionGradedPayload struct {
+	ID           int `json:"id"`
+	AssignmentID int `json:"assignmentID"`
+	CourseID     int `json:"courseID"`
+	Grade        int `json:"grade"`
+	MaxPoints    int `json:"maxPoints"`
+}
+
+type BadgeEarnedPayload struct {
+	Award *BadgeAward `json:"award"`
+}
+
+type EnrollmentCompletedPayload struct {
+	ID       int `json:"id"`
+	CourseID int `json:"courseID"`
+}
+
+// LeaderboardChangedPayload is sent to the members of a course when one of
+// them earns points, or only to the student for points outside a course.
+type LeaderboardChangedPayload struct {
+	CourseID  *int `json:"courseID"`
+	StudentID int  `json:"studentID"`
+	Points    int  `json:"points"`
+}
+
+type OrderPaidPayload struct {
+	ID       int    `json:"id"`
+	CourseID int    `json:"courseID"`
+	Total    int    `json:"total"`
+	Currency string `json:"currency"`
+}
+
+type RefundIssuedPayload struct {
+	Refund *Refund `json:"refund"`
+}
+
+type MessageCreatedPayload struct {
+	Message *Message `json:"message"`
+}
+
+// ConversationReadPayload is a read receipt, sent to every participant of
+// the conversation.
+type ConversationReadPayload struct {
+	ConversationID    int       `json:"conversationID"`
+	StudentID         int       `json:"studentID"`
+	LastReadMessageID int       `json:"lastReadMessageID"`
+	ReadAt            time.Time `json:"readAt"`
+}
+
+// WebhookDisabledPayload is sent to every admin when a webhook is disabled
+// after failing too many times in a row.
+type WebhookDisabledPayload struct {
+	WebhookID    int       `json:"webhookID"`
+	URL          string    `json:"url"`
+	FailureCount int       `json:"failureCount"`
+	DisabledAt   time.Time `json:"disabledAt"`
+}
+
+type NotificationUnreadCountPayload struct {
+	UnreadCount int `json:"unreadCount"`
+}
+
 type EventService interface {
 	PublishEvent(studentID int, event Event)
 	Subscribe(ctx context.Context) (Subscription, error)
@@ -26,6 +150,13 @@ func (*nopEventService) Subscribe(ctx context.Context) (Subscription, error) {
 	panic("not implemented")
 }
 
+// EventHandler receives every published event along with the student it was
+// published to. It is implemented by background consumers of the event
+// stream, such as the badge engine.
+type EventHandler interface {
+	HandleEvent(ctx context.Context, studentID int, event Event) error
+}
+
 type Subscription interface {
 	C() <-chan Event
 	Close() error
@@ -0,0 +1,160 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	ExportStatusPending = "pending"
+	ExportStatusReady   = "ready"
+	ExportStatusFailed  = "failed"
+)
+
+// Export is an archive of the personal data held about a student, built in
+// the background at their request. When it is ready the student is notified
+// and emailed a download link, which works until ExpiresAt.
+type Export struct {
+	ID        int        `json:"id"`
+	StudentID int        `json:"studentID"`
+	Status    string     `json:"status"`
+	LastError string     `json:"lastError"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// Expired reports whether the export's download link has expired at t.
+func (e *Export) Expired(t time.Time) bool {
+	return e.ExpiresAt != nil && !t.Before(*e.ExpiresAt)
+}
+
+// ExportService builds exports of the current student's data.
+type ExportService interface {
+	FindExports(ctx context.Context, filter ExportFilter) ([]*Export, int, error)
+
+	// CreateExport requests an export of the current student's data. Only
+	// one export may be pending at a time, and the student's email must be
+	// verified since the download link is emailed.
+	CreateExport(ctx context.Context) (*Export, error)
+
+	// OpenExport returns the zip archive of a ready export, given the token
+	// of its download link. No student needs to be logged in.
+	OpenExport(ctx context.Context, id int, token string) ([]byte, error)
+}
+
+type ExportFilter struct {
+	ID     *int `json:"id"`
+	Offset int  `json:"offset"`
+	Limit  int  `json:"limit"`
+}
+
+// ExportFormatVersion is the version of the export archive format, recorded
+// in its manifest. Fields may be added to any file without changing the
+// version; it only changes if a file or field is removed, renamed, or changes
+// meaning.
+//
+// An archive is a zip of the following JSON files. Each file other than the
+// manifest and student.json holds an array, which is empty if there is
+// nothing to export. Times are RFC 3339 in UTC.
+//
+//	manifest.json     ExportManifest
+//	student.json      ExportStudent
+//	auths.json        []ExportAuth, the linked sign-in accounts. Access and
+//	                  refresh tokens are never exported.
+//	enrollments.json  []ExportEnrollment
+//	submissions.json  []ExportSubmission, including group submissions made
+//	                  while the student was a member of the group.
+//	grades.json       []ExportGrade, one per graded submission.
+//	messages.json     []ExportMessage, the messages the student sent.
+//	notes.json        []ExportNote
+const ExportFormatVersion = 1
+
+// Names of the files in an export archive.
+const (
+	ExportManifestFile    = "manifest.json"
+	ExportStudentFile     = "student.json"
+	ExportAuthsFile       = "auths.json"
+	ExportEnrollmentsFile = "enrollments.json"
+	ExportSubmissionsFile = "submissions.json"
+	ExportGradesFile      = "grades.json"
+	ExportMessagesFile    = "messages.json"
+	ExportNotesFile       = "notes.json"
+)
+
+type ExportManifest struct {
+	Version   int       `json:"version"`
+	StudentID int       `json:"studentID"`
+	Files     []string  `json:"files"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ExportStudent struct {
+	ID                int               `json:"id"`
+	Name              string            `json:"name"`
+	Email             string            `json:"email"`
+	PendingEmail      string            `json:"pendingEmail"`
+	TimeZone          string            `json:"timeZone"`
+	Locale            string            `json:"locale"`
+	DisplayName       string            `json:"displayName"`
+	Bio               string            `json:"bio"`
+	Pronouns          string            `json:"pronouns"`
+	Links             []string          `json:"links"`
+	Visibility        map[string]string `json:"visibility"`
+	EmailDigest       string            `json:"emailDigest"`
+	LeaderboardOptOut bool              `json:"leaderboardOptOut"`
+	CreatedAt         time.Time         `json:"createdAt"`
+	UpdatedAt         time.Time         `json:"updatedAt"`
+}
+
+type ExportAuth struct {
+	Source    string    `json:"source"`
+	SourceID  string    `json:"sourceID"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ExportEnrollment struct {
+	CourseID    int        `json:"courseID"`
+	CourseTitle string     `json:"courseTitle"`
+	Role        string     `json:"role"`
+	CompletedAt *time.Time `json:"completedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+type ExportSubmission struct {
+	ID              int       `json:"id"`
+	AssignmentID    int       `json:"assignmentID"`
+	AssignmentTitle string    `json:"assignmentTitle"`
+	CourseID        int       `json:"courseID"`
+	GroupID         *int      `json:"groupID"`
+	Body            string    `json:"body"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+type ExportGrade struct {
+	SubmissionID int       `json:"submissionID"`
+	AssignmentID int       `json:"assignmentID"`
+	Grade        int       `json:"grade"`
+	MaxPoints    int       `json:"maxPoints"`
+	Feedback     string    `json:"feedback"`
+	GradedAt     time.Time `json:"gradedAt"`
+}
+
+type ExportMessage struct {
+	ID             int       `json:"id"`
+	ConversationID int       `json:"conversationID"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type ExportNote struct {
+	ID             int       `json:"id"`
+	LessonID       int       `json:"lessonID"`
+	Body           string    `json:"body"`
+	AnchorStart    *int      `json:"anchorStart"`
+	AnchorEnd      *int      `json:"anchorEnd"`
+	VideoTimestamp *int      `json:"videoTimestamp"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
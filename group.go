@@ -0,0 +1,119 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+// GroupSet is one way of splitting a course's roster into groups, such as
+// project teams. Group assignments are submitted by the groups of a set.
+// A zero MaxSize leaves groups uncapped.
+type GroupSet struct {
+	ID         int       `json:"id"`
+	CourseID   int       `json:"courseID"`
+	Name       string    `json:"name"`
+	SelfSignup bool      `json:"selfSignup"`
+	MaxSize    int       `json:"maxSize"`
+	Groups     []*Group  `json:"groups"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+func (s *GroupSet) Validate() error {
+	if s.CourseID == 0 {
+		return Errorf(EINVALID, "Course required.")
+	} else if s.Name == "" {
+		return Errorf(EINVALID, "Name required.")
+	} else if s.MaxSize < 0 {
+		return Errorf(EINVALID, "Max size must not be negative.")
+	}
+	return nil
+}
+
+type Group struct {
+	ID         int            `json:"id"`
+	GroupSetID int            `json:"groupSetID"`
+	Name       string         `json:"name"`
+	Members    []*GroupMember `json:"members"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+}
+
+func (g *Group) Validate() error {
+	if g.GroupSetID == 0 {
+		return Errorf(EINVALID, "Group set required.")
+	} else if g.Name == "" {
+		return Errorf(EINVALID, "Name required.")
+	}
+	return nil
+}
+
+// GroupMember is a student's stay in a group. Memberships are never
+// deleted; leaving a group sets LeftAt, so it is always known who belonged
+// to a group when it submitted its work.
+type GroupMember struct {
+	ID        int        `json:"id"`
+	GroupID   int        `json:"groupID"`
+	StudentID int        `json:"studentID"`
+	Student   *Student   `json:"student"`
+	JoinedAt  time.Time  `json:"joinedAt"`
+	LeftAt    *time.Time `json:"leftAt"`
+}
+
+const (
+	GroupAssignmentRandom   = "random"
+	GroupAssignmentBalanced = "balanced"
+)
+
+// GroupAssignment places every enrolled student who has no group in the set
+// into one. If the set has no groups yet, GroupCount groups are created.
+// Random assignment keeps group sizes even. Balanced assignment also spreads
+// students with the same value of Attributes, keyed by student ID, evenly
+// across the groups.
+type GroupAssignment struct {
+	Method     string         `json:"method"`
+	GroupCount int            `json:"groupCount"`
+	Attributes map[int]string `json:"attributes"`
+}
+
+type GroupService interface {
+	FindGroupSetByID(ctx context.Context, id int) (*GroupSet, error)
+	FindGroupSets(ctx context.Context, filter GroupSetFilter) ([]*GroupSet, int, error)
+	CreateGroupSet(ctx context.Context, set *GroupSet) error
+	DeleteGroupSet(ctx context.Context, id int) error
+
+	CreateGroup(ctx context.Context, group *Group) error
+
+	// DeleteGroup deletes an empty group. Groups that submitted work are
+	// kept to explain their grades.
+	DeleteGroup(ctx context.Context, id int) error
+
+	// AssignGroups places the set's ungrouped students into groups. Only the
+	// course staff may assign groups.
+	AssignGroups(ctx context.Context, setID int, assignment GroupAssignment) ([]*Group, error)
+
+	// FindGroupMembers returns current members unless filter.History is set.
+	FindGroupMembers(ctx context.Context, filter GroupMemberFilter) ([]*GroupMember, int, error)
+
+	// AddGroupMember moves a student into a group, out of any other group of
+	// the same set. The staff may move any enrolled student; students may
+	// move themselves in sets that allow self-signup.
+	AddGroupMember(ctx context.Context, groupID, studentID int) (*GroupMember, error)
+	RemoveGroupMember(ctx context.Context, groupID, studentID int) error
+}
+
+type GroupSetFilter struct {
+	ID       *int `json:"id"`
+	CourseID *int `json:"courseID"`
+	Offset   int  `json:"offset"`
+	Limit    int  `json:"limit"`
+}
+
+type GroupMemberFilter struct {
+	GroupID    *int `json:"groupID"`
+	GroupSetID *int `json:"groupSetID"`
+	StudentID  *int `json:"studentID"`
+	History    bool `json:"history"`
+	Offset     int  `json:"offset"`
+	Limit      int  `json:"limit"`
+}
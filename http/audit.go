@@ -0,0 +1,75 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerAuditRoutes(r *mux.Router) {
+	r.HandleFunc("/audit-log", s.handleAuditLogIndex).Methods("GET")
+	r.HandleFunc("/audit-log/verify", s.handleAuditLogVerify).Methods("GET")
+}
+
+type findAuditEntriesResponse struct {
+	AuditEntries []*ocs.AuditEntry `json:"auditEntries"`
+	N            int               `json:"n"`
+}
+
+// handleAuditLogIndex lists audit entries, filtered by actor, action,
+// entity, request, and a time range given as RFC 3339 times.
+func (s *Server) handleAuditLogIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.AuditFilter
+	if v, err := queryInt(r, "actorID"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.ActorID = &v
+	}
+	if v, err := queryInt(r, "entityID"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.EntityID = &v
+	}
+	if v := r.URL.Query().Get("action"); v != "" {
+		filter.Action = &v
+	}
+	if v := r.URL.Query().Get("entityType"); v != "" {
+		filter.EntityType = &v
+	}
+	if v := r.URL.Query().Get("requestID"); v != "" {
+		filter.RequestID = &v
+	}
+
+	var err error
+	if filter.Since, err = queryTime(r, "since"); err != nil {
+		Error(w, r, err)
+		return
+	} else if filter.Until, err = queryTime(r, "until"); err != nil {
+		Error(w, r, err)
+		return
+	}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	entries, n, err := s.AuditService.FindAuditEntries(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findAuditEntriesResponse{AuditEntries: entries, N: n})
+}
+
+func (s *Server) handleAuditLogVerify(w http.ResponseWriter, r *http.Request) {
+	v, err := s.AuditService.VerifyAuditLog(r.Context())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, v)
+}
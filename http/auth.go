@@ -17,7 +17,12 @@ import (
 )
 
 func (s *Server) registerAuthRoutes(r *mux.Router) {
-	// r.HandleFunc("/login", s.handleLogin).Methods("GET")
+	r.HandleFunc("/signup", s.handleSignUp).Methods("POST")
+	r.HandleFunc("/login", s.handleLogin).Methods("POST")
+	r.HandleFunc("/password-reset", s.handlePasswordResetRequest).Methods("POST")
+	r.HandleFunc("/password-reset/confirm", s.handlePasswordResetConfirm).Methods("POST")
+	r.HandleFunc("/login/magic-link", s.handleMagicLinkRequest).Methods("POST")
+	r.HandleFunc("/login/magic-link/callback", s.handleMagicLinkCallback).Methods("GET")
 	r.HandleFunc("/logout", s.handleLogout).Methods("DELETE")
 	r.HandleFunc("/oauth/github", s.handleOAuthGithub).Methods("GET")
 	r.HandleFunc("/oauth/github/callback", s.handleOAuthGithubCallback).Methods("GET")
@@ -106,10 +111,18 @@ func (s *Server) handleOAuthGithubCallback(w http.ResponseWriter, r *http.Reques
 		name = *u.Login
 	}
 
-	// WARNING: Email is always required by the db
-	var email string
-	if u.Email != nil {
-		email = *u.Email
+	// The public email is often empty, so prefer the primary address, which
+	// GitHub reports as verified once the user has confirmed it.
+	email, verified := u.GetEmail(), false
+	if emails, _, err := client.Users.ListEmails(r.Context(), nil); err != nil {
+		log.Printf("http: cannot list github emails: %s", err)
+	} else {
+		for _, e := range emails {
+			if e.GetPrimary() && e.GetVerified() {
+				email, verified = e.GetEmail(), true
+				break
+			}
+		}
 	}
 
 	auth := &ocs.Auth{
@@ -118,8 +131,9 @@ func (s *Server) handleOAuthGithubCallback(w http.ResponseWriter, r *http.Reques
 		AccessToken:  tok.AccessToken,
 		RefreshToken: tok.RefreshToken,
 		Student: &ocs.Student{
-			Name:  name,
-			Email: email,
+			Name:          name,
+			Email:         email,
+			EmailVerified: verified,
 		},
 	}
 	if !tok.Expiry.IsZero() {
@@ -127,24 +141,34 @@ func (s *Server) handleOAuthGithubCallback(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := s.AuthService.CreateAuth(r.Context(), auth); err != nil {
-		Error(w, r, fmt.Errorf("cannot create auth: %s", err))
+		Error(w, r, fmt.Errorf("cannot create auth: %w", err))
 		return
 	}
 
-	redirectURL := session.RedirectURL
-
-	session.StudentID = auth.ID
-	session.RedirectURL = ""
-	session.State = ""
-	if err := s.setSession(w, session); err != nil {
+	redirectURL, err := s.setSessionStudent(w, session, auth.StudentID)
+	if err != nil {
 		Error(w, r, fmt.Errorf("cannot set session cookie: %s", err))
 		return
 	}
 
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// setSessionStudent logs the student in by storing their ID in the session,
+// clearing any login in progress. It returns where to send them next: the
+// page they were on before logging in, or "/".
+func (s *Server) setSessionStudent(w http.ResponseWriter, session Session, studentID int) (string, error) {
+	redirectURL := session.RedirectURL
 	if redirectURL == "" {
 		redirectURL = "/"
 	}
 
-	w.WriteHeader(http.StatusFound)
-	sendJsonResponseMessage(w, "Logged in successfully")
+	session.StudentID = studentID
+	session.RedirectURL = ""
+	session.State = ""
+	session.MagicLinkNonce = ""
+	if err := s.setSession(w, session); err != nil {
+		return "", err
+	}
+	return redirectURL, nil
 }
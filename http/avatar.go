@@ -0,0 +1,90 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+// AvatarMaxAge is how long clients may cache an avatar fetched without a
+// version. Versioned URLs, as returned by Student.AvatarURL for uploaded
+// avatars, change whenever the avatar does and are cached for a year.
+const AvatarMaxAge = 3600
+
+func (s *Server) registerAvatarRoutes(r *mux.Router) {
+	r.HandleFunc("/students/{id}/avatar", s.handleAvatarUpload).Methods("PUT")
+	r.HandleFunc("/students/{id}/avatar", s.handleAvatarDelete).Methods("DELETE")
+}
+
+// handleAvatar serves a student's avatar at the requested size.
+func (s *Server) handleAvatar(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	size, err := queryInt(r, "size")
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	img, err := s.AvatarService.FindAvatar(r.Context(), id, size)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	sum := sha256.Sum256(img.Data)
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	if r.URL.Query().Get("v") != "" {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(AvatarMaxAge))
+	}
+
+	// Handles conditional requests against the ETag and modification time.
+	http.ServeContent(w, r, "", img.UpdatedAt, bytes.NewReader(img.Data))
+}
+
+// handleAvatarUpload replaces the student's avatar with the image in the
+// request body.
+func (s *Server) handleAvatarUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	student, err := s.AvatarService.UploadAvatar(r.Context(), id, r.Body)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, student)
+}
+
+// handleAvatarDelete removes the student's uploaded avatar.
+func (s *Server) handleAvatarDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	student, err := s.AvatarService.DeleteAvatar(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, student)
+}
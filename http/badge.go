@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerBadgeRoutes(r *mux.Router) {
+	r.HandleFunc("/badges", s.handleBadgeIndex).Methods("GET")
+	r.HandleFunc("/students/{id}/badges", s.handleStudentBadgeIndex).Methods("GET")
+}
+
+type findBadgesResponse struct {
+	Badges []*ocs.Badge `json:"badges"`
+}
+
+// handleBadgeIndex lists every badge that can be earned.
+func (s *Server) handleBadgeIndex(w http.ResponseWriter, r *http.Request) {
+	badges, err := s.BadgeService.FindBadges(r.Context())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findBadgesResponse{Badges: badges})
+}
+
+type findBadgeAwardsResponse struct {
+	BadgeAwards []*ocs.BadgeAward `json:"badgeAwards"`
+	N           int               `json:"n"`
+}
+
+// handleStudentBadgeIndex lists the badges a student has earned.
+func (s *Server) handleStudentBadgeIndex(w http.ResponseWriter, r *http.Request) {
+	studentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	filter := ocs.BadgeAwardFilter{StudentID: &studentID}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	awards, n, err := s.BadgeService.FindBadgeAwards(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findBadgeAwardsResponse{BadgeAwards: awards, N: n})
+}
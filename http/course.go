@@ -0,0 +1,30 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerCourseRoutes(r *mux.Router) {
+	r.HandleFunc("/courses/{id}/restore", s.handleCourseRestore).Methods("POST")
+}
+
+// handleCourseRestore lets an admin restore a deleted course.
+func (s *Server) handleCourseRestore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	course, err := s.CourseService.RestoreCourse(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, course)
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerErasureRoutes(r *mux.Router) {
+	r.HandleFunc("/erasure-requests", s.handleErasureRequestIndex).Methods("GET")
+	r.HandleFunc("/erasure-requests", s.handleErasureRequestCreate).Methods("POST")
+	r.HandleFunc("/erasure-requests/{id}/cancel", s.handleErasureRequestCancel).Methods("POST")
+}
+
+type findErasureRequestsResponse struct {
+	ErasureRequests []*ocs.ErasureRequest `json:"erasureRequests"`
+	N               int                   `json:"n"`
+}
+
+func (s *Server) handleErasureRequestIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.ErasureRequestFilter
+	if v, err := queryInt(r, "studentID"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.StudentID = &v
+	}
+	if v := r.URL.Query().Get("status"); v != "" {
+		filter.Status = &v
+	}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	reqs, n, err := s.ErasureService.FindErasureRequests(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findErasureRequestsResponse{ErasureRequests: reqs, N: n})
+}
+
+// handleErasureRequestCreate schedules the current student's account to be
+// erased after the grace period.
+func (s *Server) handleErasureRequestCreate(w http.ResponseWriter, r *http.Request) {
+	req, err := s.ErasureService.RequestErasure(r.Context())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, req)
+}
+
+func (s *Server) handleErasureRequestCancel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	req, err := s.ErasureService.CancelErasure(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, req)
+}
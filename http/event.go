@@ -7,6 +7,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"github.com/maliByatzes/ocs"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -50,6 +51,21 @@ func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 	}
 	defer sub.Close()
 
+	// Start the stream with the current unread count; later changes arrive
+	// as events.
+	n, err := s.NotificationService.CountUnreadNotifications(r.Context())
+	if err != nil {
+		LogError(r, err)
+		return
+	}
+	if err := writeEvent(conn, ocs.Event{
+		Type:    ocs.EventTypeNotificationUnreadCount,
+		Payload: &ocs.NotificationUnreadCountPayload{UnreadCount: n},
+	}); err != nil {
+		LogError(r, err)
+		return
+	}
+
 	for {
 		select {
 		case <-r.Context().Done():
@@ -60,13 +76,7 @@ func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			buf, err := json.Marshal(event)
-			if err != nil {
-				LogError(r, err)
-				return
-			}
-
-			if err := conn.WriteMessage(websocket.TextMessage, buf); err != nil {
+			if err := writeEvent(conn, event); err != nil {
 				LogError(r, err)
 				return
 			}
@@ -74,6 +84,14 @@ func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func writeEvent(conn *websocket.Conn, event ocs.Event) error {
+	buf, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, buf)
+}
+
 func ignoreWebSocketReaders(conn *websocket.Conn) {
 	for {
 		if _, _, err := conn.NextReader(); err != nil {
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerExportRoutes(r *mux.Router) {
+	r.HandleFunc("/exports", s.handleExportIndex).Methods("GET")
+	r.HandleFunc("/exports", s.handleExportCreate).Methods("POST")
+}
+
+type findExportsResponse struct {
+	Exports []*ocs.Export `json:"exports"`
+	N       int           `json:"n"`
+}
+
+// handleExportIndex lists the current student's exports.
+func (s *Server) handleExportIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.ExportFilter
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	exports, n, err := s.ExportService.FindExports(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findExportsResponse{Exports: exports, N: n})
+}
+
+// handleExportCreate requests an export of the current student's data. The
+// export is built in the background.
+func (s *Server) handleExportCreate(w http.ResponseWriter, r *http.Request) {
+	export, err := s.ExportService.CreateExport(r.Context())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusAccepted, export)
+}
+
+// handleExportDownload serves an export's archive to whoever holds the token
+// from its download link.
+func (s *Server) handleExportDownload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	data, err := s.ExportService.OpenExport(r.Context(), id, r.URL.Query().Get("token"))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="ocs-export-%d.zip"`, id))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
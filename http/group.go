@@ -0,0 +1,225 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerGroupRoutes(r *mux.Router) {
+	r.HandleFunc("/group-sets", s.handleGroupSetIndex).Methods("GET")
+	r.HandleFunc("/group-sets", s.handleGroupSetCreate).Methods("POST")
+	r.HandleFunc("/group-sets/{id}", s.handleGroupSetView).Methods("GET")
+	r.HandleFunc("/group-sets/{id}", s.handleGroupSetDelete).Methods("DELETE")
+	r.HandleFunc("/group-sets/{id}/assign", s.handleGroupSetAssign).Methods("POST")
+	r.HandleFunc("/groups", s.handleGroupCreate).Methods("POST")
+	r.HandleFunc("/groups/{id}", s.handleGroupDelete).Methods("DELETE")
+	r.HandleFunc("/groups/{id}/members", s.handleGroupMemberIndex).Methods("GET")
+	r.HandleFunc("/groups/{id}/members", s.handleGroupMemberCreate).Methods("POST")
+	r.HandleFunc("/groups/{id}/members/{studentID}", s.handleGroupMemberDelete).Methods("DELETE")
+}
+
+type findGroupSetsResponse struct {
+	GroupSets []*ocs.GroupSet `json:"groupSets"`
+	N         int             `json:"n"`
+}
+
+func (s *Server) handleGroupSetIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.GroupSetFilter
+	if v, err := queryInt(r, "courseID"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.CourseID = &v
+	}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	sets, n, err := s.GroupService.FindGroupSets(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findGroupSetsResponse{GroupSets: sets, N: n})
+}
+
+func (s *Server) handleGroupSetView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	set, err := s.GroupService.FindGroupSetByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, set)
+}
+
+func (s *Server) handleGroupSetCreate(w http.ResponseWriter, r *http.Request) {
+	var set ocs.GroupSet
+	if err := json.NewDecoder(r.Body).Decode(&set); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.GroupService.CreateGroupSet(r.Context(), &set); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, &set)
+}
+
+func (s *Server) handleGroupSetDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.GroupService.DeleteGroupSet(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGroupSetAssign places the set's ungrouped students into groups,
+// randomly or balanced by an attribute.
+func (s *Server) handleGroupSetAssign(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	var assignment ocs.GroupAssignment
+	if err := json.NewDecoder(r.Body).Decode(&assignment); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	groups, err := s.GroupService.AssignGroups(r.Context(), id, assignment)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, groups)
+}
+
+func (s *Server) handleGroupCreate(w http.ResponseWriter, r *http.Request) {
+	var group ocs.Group
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.GroupService.CreateGroup(r.Context(), &group); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, &group)
+}
+
+func (s *Server) handleGroupDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.GroupService.DeleteGroup(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type findGroupMembersResponse struct {
+	GroupMembers []*ocs.GroupMember `json:"groupMembers"`
+	N            int                `json:"n"`
+}
+
+// handleGroupMemberIndex lists the current members of a group, or every past
+// and present member if the history parameter is set.
+func (s *Server) handleGroupMemberIndex(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	filter := ocs.GroupMemberFilter{GroupID: &groupID}
+	filter.History, _ = strconv.ParseBool(r.URL.Query().Get("history"))
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	members, n, err := s.GroupService.FindGroupMembers(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findGroupMembersResponse{GroupMembers: members, N: n})
+}
+
+// handleGroupMemberCreate moves a student into the group. Without a student
+// ID the current student signs themselves up.
+func (s *Server) handleGroupMemberCreate(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	var req struct {
+		StudentID int `json:"studentID"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+	if req.StudentID == 0 {
+		req.StudentID = ocs.StudentIDFromContext(r.Context())
+	}
+
+	member, err := s.GroupService.AddGroupMember(r.Context(), groupID, req.StudentID)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, member)
+}
+
+func (s *Server) handleGroupMemberDelete(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+	studentID, err := strconv.Atoi(mux.Vars(r)["studentID"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.GroupService.RemoveGroupMember(r.Context(), groupID, studentID); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -6,7 +6,9 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/maliByatzes/ocs"
 	"github.com/prometheus/client_golang/prometheus"
@@ -50,6 +52,9 @@ type Session struct {
 	StudentID   int    `json:"studentID"`
 	RedirectURL string `json:"redirectURL"`
 	State       string `json:"state"`
+
+	// MagicLinkNonce binds a requested sign-in link to this browser.
+	MagicLinkNonce string `json:"magicLinkNonce"`
 }
 
 func SetFlash(w http.ResponseWriter, s string) {
@@ -83,6 +88,44 @@ func Error(w http.ResponseWriter, r *http.Request, err error) {
 	}
 }
 
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		LogError(r, err)
+	}
+}
+
+// queryInt parses an optional integer query parameter. Zero is returned if
+// the parameter is missing.
+func queryInt(r *http.Request, name string) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, ocs.Errorf(ocs.EINVALID, "Invalid %s format", name)
+	}
+	return i, nil
+}
+
+// queryTime parses an optional RFC 3339 time query parameter. Nil is
+// returned if the parameter is missing.
+func queryTime(r *http.Request, name string) (*time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, ocs.Errorf(ocs.EINVALID, "Invalid %s format", name)
+	}
+	return &t, nil
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
@@ -112,10 +155,12 @@ func LogError(r *http.Request, err error) {
 
 var codes = map[string]int{
 	ocs.ECONFLICT:       http.StatusConflict,
+	ocs.EEXPIRED:        http.StatusGone,
 	ocs.EINVALID:        http.StatusBadRequest,
 	ocs.ENOTFOUND:       http.StatusNotFound,
 	ocs.ENOTIMPLEMENTED: http.StatusNotImplemented,
 	ocs.EUNAUTHORIZED:   http.StatusUnauthorized,
+	ocs.EUNVERIFIED:     http.StatusForbidden,
 	ocs.EINTERNAL:       http.StatusInternalServerError,
 }
 
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerLeaderboardRoutes(r *mux.Router) {
+	r.HandleFunc("/leaderboard", s.handleLeaderboardView).Methods("GET")
+	r.HandleFunc("/courses/{id}/leaderboard", s.handleCourseLeaderboardView).Methods("GET")
+	r.HandleFunc("/points", s.handlePointEntryIndex).Methods("GET")
+}
+
+// handleLeaderboardView returns the global leaderboard. The "period"
+// parameter is either "week" or "all-time", the default.
+func (s *Server) handleLeaderboardView(w http.ResponseWriter, r *http.Request) {
+	filter := ocs.LeaderboardFilter{Period: r.URL.Query().Get("period")}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	board, err := s.LeaderboardService.FindLeaderboard(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, board)
+}
+
+func (s *Server) handleCourseLeaderboardView(w http.ResponseWriter, r *http.Request) {
+	courseID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	filter := ocs.LeaderboardFilter{CourseID: &courseID, Period: r.URL.Query().Get("period")}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	board, err := s.LeaderboardService.FindLeaderboard(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, board)
+}
+
+type findPointEntriesResponse struct {
+	PointEntries []*ocs.PointEntry `json:"pointEntries"`
+	N            int               `json:"n"`
+}
+
+// handlePointEntryIndex lists the points earned by the current student.
+func (s *Server) handlePointEntryIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.PointEntryFilter
+	if v, err := queryInt(r, "courseID"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.CourseID = &v
+	}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	entries, n, err := s.LeaderboardService.FindPointEntries(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findPointEntriesResponse{PointEntries: entries, N: n})
+}
@@ -0,0 +1,171 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerLearningPathRoutes(r *mux.Router) {
+	r.HandleFunc("/paths", s.handleLearningPathIndex).Methods("GET")
+	r.HandleFunc("/paths", s.handleLearningPathCreate).Methods("POST")
+	r.HandleFunc("/paths/{id}", s.handleLearningPathView).Methods("GET")
+	r.HandleFunc("/paths/{id}", s.handleLearningPathDelete).Methods("DELETE")
+	r.HandleFunc("/paths/{id}/enroll", s.handleLearningPathEnroll).Methods("POST")
+	r.HandleFunc("/path-enrollments", s.handlePathEnrollmentIndex).Methods("GET")
+
+	r.HandleFunc("/certificates", s.handleCertificateIndex).Methods("GET")
+
+	r.HandleFunc("/enrollments/{id}/complete", s.handleEnrollmentComplete).Methods("POST")
+}
+
+type findLearningPathsResponse struct {
+	LearningPaths []*ocs.LearningPath `json:"learningPaths"`
+	N             int                 `json:"n"`
+}
+
+func (s *Server) handleLearningPathIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.LearningPathFilter
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	paths, n, err := s.LearningPathService.FindLearningPaths(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findLearningPathsResponse{LearningPaths: paths, N: n})
+}
+
+func (s *Server) handleLearningPathView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	path, err := s.LearningPathService.FindLearningPathByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, path)
+}
+
+func (s *Server) handleLearningPathCreate(w http.ResponseWriter, r *http.Request) {
+	var path ocs.LearningPath
+	if err := json.NewDecoder(r.Body).Decode(&path); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.LearningPathService.CreateLearningPath(r.Context(), &path); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, &path)
+}
+
+func (s *Server) handleLearningPathDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.LearningPathService.DeleteLearningPath(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleLearningPathEnroll(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	pe, err := s.LearningPathService.EnrollInLearningPath(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, pe)
+}
+
+type findPathEnrollmentsResponse struct {
+	PathEnrollments []*ocs.PathEnrollment `json:"pathEnrollments"`
+	N               int                   `json:"n"`
+}
+
+// handlePathEnrollmentIndex lists path enrollments with their progress. Path
+// owners may pass "pathID" to see everyone enrolled in their path.
+func (s *Server) handlePathEnrollmentIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.PathEnrollmentFilter
+	if v, err := queryInt(r, "pathID"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.LearningPathID = &v
+	}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	pes, n, err := s.LearningPathService.FindPathEnrollments(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findPathEnrollmentsResponse{PathEnrollments: pes, N: n})
+}
+
+type findCertificatesResponse struct {
+	Certificates []*ocs.Certificate `json:"certificates"`
+	N            int                `json:"n"`
+}
+
+// handleCertificateIndex lists the current student's certificates. Passing
+// "code" verifies a shared certificate code instead.
+func (s *Server) handleCertificateIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.CertificateFilter
+	if v := r.URL.Query().Get("code"); v != "" {
+		filter.Code = &v
+	}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	certs, n, err := s.LearningPathService.FindCertificates(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findCertificatesResponse{Certificates: certs, N: n})
+}
+
+func (s *Server) handleEnrollmentComplete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	enrollment, err := s.EnrollmentService.CompleteEnrollment(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, enrollment)
+}
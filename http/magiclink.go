@@ -0,0 +1,70 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/maliByatzes/ocs"
+)
+
+type magicLinkRequest struct {
+	Email string `json:"email"`
+}
+
+// handleMagicLinkRequest emails a sign-in link if the email belongs to a
+// student. The link only works in this browser, which keeps a nonce for it in
+// its session. The response is the same either way.
+//
+// The nonce is kept until the student logs in. A repeated request may not
+// send a new link, so replacing the nonce would break the one already sent.
+func (s *Server) handleMagicLinkRequest(w http.ResponseWriter, r *http.Request) {
+	var req magicLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	session, _ := s.session(r)
+
+	if session.MagicLinkNonce == "" {
+		nonce := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			Error(w, r, err)
+			return
+		}
+		session.MagicLinkNonce = hex.EncodeToString(nonce)
+	}
+
+	if err := s.MagicLinkService.RequestMagicLink(r.Context(), req.Email, session.MagicLinkNonce); err != nil {
+		Error(w, r, err)
+		return
+	} else if err := s.setSession(w, session); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleMagicLinkCallback logs the student in with the token from a sign-in
+// link, then sends them to where they were before logging in.
+func (s *Server) handleMagicLinkCallback(w http.ResponseWriter, r *http.Request) {
+	session, _ := s.session(r)
+
+	student, err := s.MagicLinkService.RedeemMagicLink(r.Context(), r.URL.Query().Get("token"), session.MagicLinkNonce)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	redirectURL, err := s.setSessionStudent(w, session, student.ID)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
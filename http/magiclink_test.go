@@ -0,0 +1,82 @@
+package http_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"testing"
+
+	"github.com/maliByatzes/ocs"
+)
+
+func TestMagicLink(t *testing.T) {
+	// A second request, such as a double-click, does not break the link
+	// sent by the first.
+	t.Run("RequestTwice", func(t *testing.T) {
+		s := MustOpenServer(t)
+		defer MustCloseServer(t, s)
+
+		s.OrganizationService.FindOrganizationsFn = func(ctx context.Context, filter ocs.OrganizationFilter) ([]*ocs.Organization, int, error) {
+			return nil, 0, nil
+		}
+
+		// Only the first request sends a link, as a second one within a
+		// minute would.
+		var nonces []string
+		s.MagicLinkService.RequestMagicLinkFn = func(ctx context.Context, email, nonce string) error {
+			if email != "jane@email.com" {
+				t.Fatalf("unexpected email: %s", email)
+			}
+			nonces = append(nonces, nonce)
+			return nil
+		}
+		s.MagicLinkService.RedeemMagicLinkFn = func(ctx context.Context, token, nonce string) (*ocs.Student, error) {
+			if token != "TOKEN" {
+				t.Fatalf("unexpected token: %s", token)
+			} else if nonce != nonces[0] {
+				return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "Invalid or expired link.")
+			}
+			return &ocs.Student{ID: 1, Name: "jane", Email: "jane@email.com"}, nil
+		}
+
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{
+			Jar: jar,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+
+		for i := 0; i < 2; i++ {
+			resp, err := client.Do(s.MustNewRequest(t, context.Background(), "POST", "/login/magic-link", strings.NewReader(`{"email":"jane@email.com"}`)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusAccepted {
+				t.Fatalf("unexpected status: %d", resp.StatusCode)
+			}
+		}
+
+		if len(nonces) != 2 {
+			t.Fatalf("unexpected requests: %d", len(nonces))
+		} else if nonces[0] == "" || nonces[1] != nonces[0] {
+			t.Fatalf("nonce changed: %q, %q", nonces[0], nonces[1])
+		}
+
+		resp, err := client.Do(s.MustNewRequest(t, context.Background(), "GET", "/login/magic-link/callback?token=TOKEN", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusFound {
+			t.Fatalf("unexpected status: %d", resp.StatusCode)
+		} else if got := resp.Header.Get("Location"); got != "/" {
+			t.Fatalf("unexpected redirect: %s", got)
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerMessageRoutes(r *mux.Router) {
+	r.HandleFunc("/conversations", s.handleConversationIndex).Methods("GET")
+	r.HandleFunc("/conversations", s.handleConversationCreate).Methods("POST")
+	r.HandleFunc("/conversations/{id}", s.handleConversationView).Methods("GET")
+	r.HandleFunc("/conversations/{id}/messages", s.handleMessageIndex).Methods("GET")
+	r.HandleFunc("/conversations/{id}/messages", s.handleMessageCreate).Methods("POST")
+	r.HandleFunc("/conversations/{id}/read", s.handleConversationRead).Methods("POST")
+	r.HandleFunc("/messages/unread-count", s.handleMessageUnreadCount).Methods("GET")
+}
+
+type findConversationsResponse struct {
+	Conversations []*ocs.Conversation `json:"conversations"`
+	N             int                 `json:"n"`
+}
+
+func (s *Server) handleConversationIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.ConversationFilter
+	if v, err := queryInt(r, "courseID"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.CourseID = &v
+	}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	conversations, n, err := s.MessageService.FindConversations(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findConversationsResponse{Conversations: conversations, N: n})
+}
+
+func (s *Server) handleConversationView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	conversation, err := s.MessageService.FindConversationByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, conversation)
+}
+
+func (s *Server) handleConversationCreate(w http.ResponseWriter, r *http.Request) {
+	var conversation ocs.Conversation
+	if err := json.NewDecoder(r.Body).Decode(&conversation); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.MessageService.CreateConversation(r.Context(), &conversation); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, &conversation)
+}
+
+type findMessagesResponse struct {
+	Messages []*ocs.Message `json:"messages"`
+	N        int            `json:"n"`
+}
+
+// handleMessageIndex returns the latest messages of a conversation. Clients
+// backfill older history by passing the oldest message ID they have as the
+// before parameter.
+func (s *Server) handleMessageIndex(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	filter := ocs.MessageFilter{ConversationID: id}
+	if v, err := queryInt(r, "before"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.BeforeID = &v
+	}
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	messages, n, err := s.MessageService.FindMessages(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findMessagesResponse{Messages: messages, N: n})
+}
+
+func (s *Server) handleMessageCreate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	var message ocs.Message
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+	message.ConversationID = id
+
+	if err := s.MessageService.SendMessage(r.Context(), &message); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, &message)
+}
+
+func (s *Server) handleConversationRead(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	participant, err := s.MessageService.MarkConversationRead(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, participant)
+}
+
+type unreadCountResponse struct {
+	UnreadCount int `json:"unreadCount"`
+}
+
+func (s *Server) handleMessageUnreadCount(w http.ResponseWriter, r *http.Request) {
+	n, err := s.MessageService.CountUnreadMessages(r.Context())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, unreadCountResponse{UnreadCount: n})
+}
@@ -0,0 +1,266 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerNoteRoutes(r *mux.Router) {
+	r.HandleFunc("/notes", s.handleNoteIndex).Methods("GET")
+	r.HandleFunc("/notes", s.handleNoteCreate).Methods("POST")
+	r.HandleFunc("/notes/{id}", s.handleNoteView).Methods("GET")
+	r.HandleFunc("/notes/{id}", s.handleNoteUpdate).Methods("PATCH")
+	r.HandleFunc("/notes/{id}", s.handleNoteDelete).Methods("DELETE")
+	r.HandleFunc("/courses/{id}/notes/export", s.handleCourseNotesExport).Methods("GET")
+
+	r.HandleFunc("/bookmarks", s.handleBookmarkIndex).Methods("GET")
+	r.HandleFunc("/bookmarks", s.handleBookmarkCreate).Methods("POST")
+	r.HandleFunc("/bookmarks/{id}", s.handleBookmarkDelete).Methods("DELETE")
+}
+
+type findNotesResponse struct {
+	Notes []*ocs.Note `json:"notes"`
+	N     int         `json:"n"`
+}
+
+// handleNoteIndex lists the current student's notes. The "q" parameter
+// performs a full-text search over the note bodies.
+func (s *Server) handleNoteIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.NoteFilter
+	if v, err := queryInt(r, "lessonID"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.LessonID = &v
+	}
+	if v, err := queryInt(r, "courseID"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.CourseID = &v
+	}
+	if v := r.URL.Query().Get("q"); v != "" {
+		filter.Query = &v
+	}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	notes, n, err := s.NoteService.FindNotes(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findNotesResponse{Notes: notes, N: n})
+}
+
+func (s *Server) handleNoteView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	note, err := s.NoteService.FindNoteByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, note)
+}
+
+func (s *Server) handleNoteCreate(w http.ResponseWriter, r *http.Request) {
+	var note ocs.Note
+	if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.NoteService.CreateNote(r.Context(), &note); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, &note)
+}
+
+func (s *Server) handleNoteUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	var upd ocs.NoteUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	note, err := s.NoteService.UpdateNote(r.Context(), id, upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, note)
+}
+
+func (s *Server) handleNoteDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.NoteService.DeleteNote(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCourseNotesExport returns all of the current student's notes for a
+// course as a single Markdown document, grouped by lesson.
+func (s *Server) handleCourseNotesExport(w http.ResponseWriter, r *http.Request) {
+	courseID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	course, err := s.CourseService.FindCourseByID(r.Context(), courseID)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	notes, _, err := s.NoteService.FindNotes(r.Context(), ocs.NoteFilter{CourseID: &courseID})
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="course-%d-notes.md"`, course.ID))
+	if err := writeNotesMarkdown(w, course, notes); err != nil {
+		LogError(r, err)
+	}
+}
+
+type findBookmarksResponse struct {
+	Bookmarks []*ocs.Bookmark `json:"bookmarks"`
+	N         int             `json:"n"`
+}
+
+func (s *Server) handleBookmarkIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.BookmarkFilter
+	if v, err := queryInt(r, "courseID"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.CourseID = &v
+	}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	bookmarks, n, err := s.NoteService.FindBookmarks(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findBookmarksResponse{Bookmarks: bookmarks, N: n})
+}
+
+func (s *Server) handleBookmarkCreate(w http.ResponseWriter, r *http.Request) {
+	var bookmark ocs.Bookmark
+	if err := json.NewDecoder(r.Body).Decode(&bookmark); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.NoteService.CreateBookmark(r.Context(), &bookmark); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, &bookmark)
+}
+
+func (s *Server) handleBookmarkDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.NoteService.DeleteBookmark(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeNotesMarkdown renders notes as Markdown, one section per lesson in
+// course order. Notes must have their Lesson attached.
+func writeNotesMarkdown(w io.Writer, course *ocs.Course, notes []*ocs.Note) error {
+	notes = append([]*ocs.Note(nil), notes...)
+	sort.SliceStable(notes, func(i, j int) bool {
+		if a, b := notes[i].Lesson, notes[j].Lesson; a.Position != b.Position {
+			return a.Position < b.Position
+		} else if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return notes[i].ID < notes[j].ID
+	})
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "# %s\n", course.Title)
+
+	lessonID := 0
+	for _, note := range notes {
+		if note.LessonID != lessonID {
+			lessonID = note.LessonID
+			fmt.Fprintf(&buf, "\n## %s\n", note.Lesson.Title)
+		}
+
+		buf.WriteString("\n")
+		if note.VideoTimestamp != nil {
+			fmt.Fprintf(&buf, "**[%s]**\n\n", formatVideoTimestamp(*note.VideoTimestamp))
+		}
+		if note.AnchorStart != nil && note.AnchorEnd != nil {
+			if body := []rune(note.Lesson.Body); *note.AnchorEnd <= len(body) {
+				excerpt := string(body[*note.AnchorStart:*note.AnchorEnd])
+				for _, line := range strings.Split(excerpt, "\n") {
+					fmt.Fprintf(&buf, "> %s\n", line)
+				}
+				buf.WriteString("\n")
+			}
+		}
+		fmt.Fprintf(&buf, "%s\n", strings.TrimSpace(note.Body))
+	}
+
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+// formatVideoTimestamp formats seconds as m:ss, or h:mm:ss past the hour.
+func formatVideoTimestamp(seconds int) string {
+	h, m, s := seconds/3600, seconds/60%60, seconds%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
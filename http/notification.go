@@ -0,0 +1,71 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerNotificationRoutes(r *mux.Router) {
+	r.HandleFunc("/notifications", s.handleNotificationIndex).Methods("GET")
+	r.HandleFunc("/notifications/unread-count", s.handleNotificationUnreadCount).Methods("GET")
+	r.HandleFunc("/notifications/read", s.handleNotificationReadAll).Methods("POST")
+	r.HandleFunc("/notifications/{id}/read", s.handleNotificationRead).Methods("POST")
+}
+
+type findNotificationsResponse struct {
+	Notifications []*ocs.Notification `json:"notifications"`
+	N             int                 `json:"n"`
+}
+
+func (s *Server) handleNotificationIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.NotificationFilter
+	filter.Unread, _ = strconv.ParseBool(r.URL.Query().Get("unread"))
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	notifications, n, err := s.NotificationService.FindNotifications(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findNotificationsResponse{Notifications: notifications, N: n})
+}
+
+func (s *Server) handleNotificationUnreadCount(w http.ResponseWriter, r *http.Request) {
+	n, err := s.NotificationService.CountUnreadNotifications(r.Context())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, unreadCountResponse{UnreadCount: n})
+}
+
+func (s *Server) handleNotificationRead(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	notification, err := s.NotificationService.MarkNotificationRead(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, notification)
+}
+
+func (s *Server) handleNotificationReadAll(w http.ResponseWriter, r *http.Request) {
+	if err := s.NotificationService.MarkAllNotificationsRead(r.Context()); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,164 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+// PaymentSignatureHeader carries the payment provider's webhook signature.
+const PaymentSignatureHeader = "Payment-Signature"
+
+func (s *Server) registerOrderRoutes(r *mux.Router) {
+	r.HandleFunc("/orders", s.handleOrderIndex).Methods("GET")
+	r.HandleFunc("/orders", s.handleOrderCreate).Methods("POST")
+	r.HandleFunc("/orders/{id}", s.handleOrderView).Methods("GET")
+
+	r.HandleFunc("/coupons", s.handleCouponIndex).Methods("GET")
+	r.HandleFunc("/coupons", s.handleCouponCreate).Methods("POST")
+	r.HandleFunc("/coupons/{id}", s.handleCouponDelete).Methods("DELETE")
+}
+
+type findOrdersResponse struct {
+	Orders []*ocs.Order `json:"orders"`
+	N      int          `json:"n"`
+}
+
+func (s *Server) handleOrderIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.OrderFilter
+	if v, err := queryInt(r, "courseID"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.CourseID = &v
+	}
+	if v := r.URL.Query().Get("status"); v != "" {
+		filter.Status = &v
+	}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	orders, n, err := s.OrderService.FindOrders(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findOrdersResponse{Orders: orders, N: n})
+}
+
+func (s *Server) handleOrderView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	order, err := s.OrderService.FindOrderByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, order)
+}
+
+// handleOrderCreate checks out a course. The client sends the student to the
+// order's payment URL to pay.
+func (s *Server) handleOrderCreate(w http.ResponseWriter, r *http.Request) {
+	var checkout ocs.Checkout
+	if err := json.NewDecoder(r.Body).Decode(&checkout); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	order, err := s.OrderService.Checkout(r.Context(), checkout)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, order)
+}
+
+// handlePaymentWebhook receives payment callbacks from the provider. It is
+// authenticated by the payload's signature rather than a session.
+func (s *Server) handlePaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid body"))
+		return
+	}
+
+	n, err := s.PaymentProvider.VerifyWebhook(payload, r.Header.Get(PaymentSignatureHeader))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if _, err := s.OrderService.CompletePayment(r.Context(), n); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type findCouponsResponse struct {
+	Coupons []*ocs.Coupon `json:"coupons"`
+	N       int           `json:"n"`
+}
+
+func (s *Server) handleCouponIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.CouponFilter
+	if v, err := queryInt(r, "courseID"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.CourseID = &v
+	}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	coupons, n, err := s.OrderService.FindCoupons(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findCouponsResponse{Coupons: coupons, N: n})
+}
+
+func (s *Server) handleCouponCreate(w http.ResponseWriter, r *http.Request) {
+	var coupon ocs.Coupon
+	if err := json.NewDecoder(r.Body).Decode(&coupon); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.OrderService.CreateCoupon(r.Context(), &coupon); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, &coupon)
+}
+
+func (s *Server) handleCouponDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.OrderService.DeleteCoupon(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+// OrganizationHeader selects the organization of a request by its slug,
+// for clients that cannot use the organization's domain.
+const OrganizationHeader = "X-Organization"
+
+func (s *Server) registerOrganizationRoutes(r *mux.Router) {
+	r.HandleFunc("/organizations", s.handleOrganizationIndex).Methods("GET")
+	r.HandleFunc("/organizations", s.handleOrganizationCreate).Methods("POST")
+	r.HandleFunc("/organizations/{id}", s.handleOrganizationView).Methods("GET")
+	r.HandleFunc("/organizations/{id}/members", s.handleOrganizationMemberIndex).Methods("GET")
+	r.HandleFunc("/organizations/{id}/members", s.handleOrganizationMemberCreate).Methods("POST")
+	r.HandleFunc("/organization-members/{id}", s.handleOrganizationMemberDelete).Methods("DELETE")
+}
+
+type findOrganizationsResponse struct {
+	Organizations []*ocs.Organization `json:"organizations"`
+	N             int                 `json:"n"`
+}
+
+// handleOrganizationIndex lists the organizations of the current student.
+func (s *Server) handleOrganizationIndex(w http.ResponseWriter, r *http.Request) {
+	studentID := ocs.StudentIDFromContext(r.Context())
+	filter := ocs.OrganizationFilter{StudentID: &studentID}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	orgs, n, err := s.OrganizationService.FindOrganizations(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findOrganizationsResponse{Organizations: orgs, N: n})
+}
+
+func (s *Server) handleOrganizationView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	org, err := s.OrganizationService.FindOrganizationByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, org)
+}
+
+func (s *Server) handleOrganizationCreate(w http.ResponseWriter, r *http.Request) {
+	var org ocs.Organization
+	if err := json.NewDecoder(r.Body).Decode(&org); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.OrganizationService.CreateOrganization(r.Context(), &org); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, &org)
+}
+
+type findOrganizationMembersResponse struct {
+	OrganizationMembers []*ocs.OrganizationMember `json:"organizationMembers"`
+	N                   int                       `json:"n"`
+}
+
+func (s *Server) handleOrganizationMemberIndex(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	filter := ocs.OrganizationMemberFilter{OrganizationID: &orgID}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	members, n, err := s.OrganizationService.FindOrganizationMembers(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findOrganizationMembersResponse{OrganizationMembers: members, N: n})
+}
+
+// handleOrganizationMemberCreate adds a student, given by email or ID, to
+// the organization.
+func (s *Server) handleOrganizationMemberCreate(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	var member ocs.OrganizationMember
+	if err := json.NewDecoder(r.Body).Decode(&member); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+	member.OrganizationID = orgID
+
+	if err := s.OrganizationService.CreateOrganizationMember(r.Context(), &member); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, &member)
+}
+
+func (s *Server) handleOrganizationMemberDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.OrganizationService.DeleteOrganizationMember(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerPasswordRoutes(r *mux.Router) {
+	r.HandleFunc("/password", s.handlePasswordChange).Methods("PUT")
+}
+
+type signUpRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Student     *ocs.Student `json:"student"`
+	RedirectURL string       `json:"redirectURL"`
+}
+
+// handleSignUp creates a student with a password and logs them in.
+func (s *Server) handleSignUp(w http.ResponseWriter, r *http.Request) {
+	var req signUpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	student := &ocs.Student{Name: req.Name, Email: req.Email}
+	if err := s.PasswordService.SignUp(r.Context(), student, req.Password); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	s.login(w, r, http.StatusCreated, student)
+}
+
+// handleLogin logs a student in with their email and password.
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	student, err := s.PasswordService.Login(r.Context(), req.Email, req.Password)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	s.login(w, r, http.StatusOK, student)
+}
+
+// login starts a session for the student and returns where the client should
+// go next, as saved in the session before logging in.
+func (s *Server) login(w http.ResponseWriter, r *http.Request, status int, student *ocs.Student) {
+	session, _ := s.session(r)
+	redirectURL, err := s.setSessionStudent(w, session, student.ID)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, status, loginResponse{Student: student, RedirectURL: redirectURL})
+}
+
+type passwordChangeRequest struct {
+	Current  string `json:"current"`
+	Password string `json:"password"`
+}
+
+// handlePasswordChange sets the current student's password.
+func (s *Server) handlePasswordChange(w http.ResponseWriter, r *http.Request) {
+	var req passwordChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.PasswordService.ChangePassword(r.Context(), req.Current, req.Password); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type passwordResetRequest struct {
+	Email string `json:"email"`
+}
+
+// handlePasswordResetRequest emails a reset link if the email belongs to a
+// student. The response is the same either way.
+func (s *Server) handlePasswordResetRequest(w http.ResponseWriter, r *http.Request) {
+	var req passwordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.PasswordService.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type passwordResetConfirmRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// handlePasswordResetConfirm sets a new password given the token from a
+// reset link. The student logs in with the new password afterwards.
+func (s *Server) handlePasswordResetConfirm(w http.ResponseWriter, r *http.Request) {
+	var req passwordResetConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.PasswordService.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,92 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerRefundRoutes(r *mux.Router) {
+	r.HandleFunc("/refunds", s.handleRefundIndex).Methods("GET")
+	r.HandleFunc("/orders/{id}/refund", s.handleRefundRequest).Methods("POST")
+
+	r.HandleFunc("/admin/refunds", s.handleRefundIssue).Methods("POST")
+}
+
+type findRefundsResponse struct {
+	Refunds []*ocs.Refund `json:"refunds"`
+	N       int           `json:"n"`
+}
+
+func (s *Server) handleRefundIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.RefundFilter
+	if v, err := queryInt(r, "orderID"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.OrderID = &v
+	}
+	if v, err := queryInt(r, "courseID"); err != nil {
+		Error(w, r, err)
+		return
+	} else if v != 0 {
+		filter.CourseID = &v
+	}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	refunds, n, err := s.RefundService.FindRefunds(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findRefundsResponse{Refunds: refunds, N: n})
+}
+
+type refundRequest struct {
+	Reason string `json:"reason"`
+}
+
+// handleRefundRequest refunds the student's own order under the refund policy.
+func (s *Server) handleRefundRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	var req refundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	refund, err := s.RefundService.RequestRefund(r.Context(), id, req.Reason)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, refund)
+}
+
+// handleRefundIssue lets an admin refund any order with a reason. The body
+// holds the order ID, the amount, or zero for a full refund, and the reason.
+func (s *Server) handleRefundIssue(w http.ResponseWriter, r *http.Request) {
+	var refund ocs.Refund
+	if err := json.NewDecoder(r.Body).Decode(&refund); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.RefundService.IssueRefund(r.Context(), &refund); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, &refund)
+}
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerRegradeRoutes(r *mux.Router) {
+	r.HandleFunc("/regrades", s.handleRegradeIndex).Methods("GET")
+	r.HandleFunc("/regrades", s.handleRegradeCreate).Methods("POST")
+	r.HandleFunc("/regrades/{id}", s.handleRegradeView).Methods("GET")
+	r.HandleFunc("/regrades/{id}", s.handleRegradeResolve).Methods("PATCH")
+	r.HandleFunc("/courses/{id}/regrades", s.handleCourseRegradeQueue).Methods("GET")
+}
+
+type findRegradeRequestsResponse struct {
+	RegradeRequests []*ocs.RegradeRequest `json:"regradeRequests"`
+	N               int                   `json:"n"`
+}
+
+// handleRegradeIndex lists the regrade requests filed by the current student.
+func (s *Server) handleRegradeIndex(w http.ResponseWriter, r *http.Request) {
+	studentID := ocs.StudentIDFromContext(r.Context())
+	filter := ocs.RegradeRequestFilter{StudentID: &studentID}
+	if v := r.URL.Query().Get("status"); v != "" {
+		filter.Status = &v
+	}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	reqs, n, err := s.RegradeService.FindRegradeRequests(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findRegradeRequestsResponse{RegradeRequests: reqs, N: n})
+}
+
+// handleCourseRegradeQueue lists a course's regrade requests for its staff.
+// Open requests are returned unless another status is requested. Students
+// who are not on the course staff only ever see their own requests.
+func (s *Server) handleCourseRegradeQueue(w http.ResponseWriter, r *http.Request) {
+	courseID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	status := ocs.RegradeStatusOpen
+	if v := r.URL.Query().Get("status"); v != "" {
+		status = v
+	}
+
+	filter := ocs.RegradeRequestFilter{CourseID: &courseID, Status: &status}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	reqs, n, err := s.RegradeService.FindRegradeRequests(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findRegradeRequestsResponse{RegradeRequests: reqs, N: n})
+}
+
+func (s *Server) handleRegradeView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	req, err := s.RegradeService.FindRegradeRequestByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, req)
+}
+
+func (s *Server) handleRegradeCreate(w http.ResponseWriter, r *http.Request) {
+	var req ocs.RegradeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.RegradeService.CreateRegradeRequest(r.Context(), &req); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, &req)
+}
+
+func (s *Server) handleRegradeResolve(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	var res ocs.RegradeResolution
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	req, err := s.RegradeService.ResolveRegradeRequest(r.Context(), id, res)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, req)
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -37,20 +38,48 @@ var (
 
 const ShutdownTimeout = 1 * time.Second
 
+// RequestIDHeader carries the ID of a request, which is recorded with any
+// change the request makes. A valid ID sent by the client is kept so that a
+// request can be traced through a proxy; otherwise one is generated. The ID
+// is returned on every response.
+const RequestIDHeader = "X-Request-ID"
+
 type Server struct {
-	ln                 net.Listener
-	server             *http.Server
-	router             *mux.Router
-	sc                 *securecookie.SecureCookie
-	Addr               string
-	Domain             string
-	HashKey            string
-	BlockKey           string
-	GithubClientID     string
-	GithubClientSecret string
-	AuthService        ocs.AuthService
-	EventService       ocs.EventService
-	StudentService     ocs.StudentService
+	ln                       net.Listener
+	server                   *http.Server
+	router                   *mux.Router
+	sc                       *securecookie.SecureCookie
+	Addr                     string
+	Domain                   string
+	HashKey                  string
+	BlockKey                 string
+	GithubClientID           string
+	GithubClientSecret       string
+	AuditService             ocs.AuditService
+	AuthService              ocs.AuthService
+	AvatarService            ocs.AvatarService
+	BadgeService             ocs.BadgeService
+	CourseService            ocs.CourseService
+	EmailVerificationService ocs.EmailVerificationService
+	EnrollmentService        ocs.EnrollmentService
+	ErasureService           ocs.ErasureService
+	EventService             ocs.EventService
+	ExportService            ocs.ExportService
+	GroupService             ocs.GroupService
+	LeaderboardService       ocs.LeaderboardService
+	LearningPathService      ocs.LearningPathService
+	MessageService           ocs.MessageService
+	NoteService              ocs.NoteService
+	NotificationService      ocs.NotificationService
+	OrderService             ocs.OrderService
+	OrganizationService      ocs.OrganizationService
+	PasswordService          ocs.PasswordService
+	MagicLinkService         ocs.MagicLinkService
+	PaymentProvider          ocs.PaymentProvider
+	RefundService            ocs.RefundService
+	RegradeService           ocs.RegradeService
+	StudentService           ocs.StudentService
+	WebhookService           ocs.WebhookService
 }
 
 func NewServer() *Server {
@@ -60,6 +89,7 @@ func NewServer() *Server {
 	}
 
 	s.router.Use(reportPanic)
+	s.router.Use(trackRequest)
 	s.server.Handler = http.HandlerFunc(s.serveHTTP)
 	s.router.NotFoundHandler = http.HandlerFunc(s.handleNotFound)
 
@@ -69,6 +99,18 @@ func NewServer() *Server {
 	router := s.router.PathPrefix("/").Subrouter()
 	router.Use(s.authenticate)
 
+	// Provider callbacks, authenticated by their signature
+	router.HandleFunc("/payments/webhook", s.handlePaymentWebhook).Methods("POST")
+
+	// Export downloads, authenticated by the token in the link
+	router.HandleFunc("/exports/{id}/download", s.handleExportDownload).Methods("GET")
+
+	// Email verification links, authenticated by their token
+	router.HandleFunc("/verify-email", s.handleVerifyEmail).Methods("GET")
+
+	// Avatars, public so that they can be shown anywhere
+	router.HandleFunc("/students/{id}/avatar", s.handleAvatar).Methods("GET")
+
 	// Non-auth routes
 	{
 		r := router.PathPrefix("/").Subrouter()
@@ -81,6 +123,26 @@ func NewServer() *Server {
 		r := router.PathPrefix("/").Subrouter()
 		r.Use(s.requireAuth)
 		s.registerEventRoutes(r)
+		s.registerRegradeRoutes(r)
+		s.registerNoteRoutes(r)
+		s.registerLearningPathRoutes(r)
+		s.registerBadgeRoutes(r)
+		s.registerLeaderboardRoutes(r)
+		s.registerStudentRoutes(r)
+		s.registerAvatarRoutes(r)
+		s.registerCourseRoutes(r)
+		s.registerOrderRoutes(r)
+		s.registerRefundRoutes(r)
+		s.registerOrganizationRoutes(r)
+		s.registerGroupRoutes(r)
+		s.registerMessageRoutes(r)
+		s.registerNotificationRoutes(r)
+		s.registerWebhookRoutes(r)
+		s.registerAuditRoutes(r)
+		s.registerExportRoutes(r)
+		s.registerErasureRoutes(r)
+		s.registerEmailVerificationRoutes(r)
+		s.registerPasswordRoutes(r)
 	}
 
 	return s
@@ -177,7 +239,7 @@ func (s *Server) OAuth2Config() *oauth2.Config {
 	return &oauth2.Config{
 		ClientID:     s.GithubClientID,
 		ClientSecret: s.GithubClientSecret,
-		Scopes:       []string{},
+		Scopes:       []string{"user:email"},
 		Endpoint:     github.Endpoint,
 	}
 }
@@ -205,6 +267,16 @@ func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Resolve the organization first so that students are looked up
+		// within it and non-members are never authenticated. Requests to a
+		// host of no organization are scoped to the default site.
+		org, err := s.findRequestOrganization(r)
+		if err != nil {
+			Error(w, r, err)
+			return
+		}
+		r = r.WithContext(ocs.NewContextWithOrganization(r.Context(), org))
+
 		if v := r.Header.Get("Authorization"); strings.HasPrefix(v, "Bearer ") {
 			apiKey := strings.TrimPrefix(v, "Bearer ")
 
@@ -215,6 +287,11 @@ func (s *Server) authenticate(next http.Handler) http.Handler {
 			} else if len(students) == 0 {
 				Error(w, r, ocs.Errorf(ocs.EUNAUTHORIZED, "Invalid API key"))
 				return
+			} else if !students[0].EmailVerified {
+				// Keys act for the student without a session, so they
+				// are gated like other sensitive actions.
+				Error(w, r, ocs.Errorf(ocs.EUNVERIFIED, "Please verify your email address before using an API key."))
+				return
 			}
 
 			r = r.WithContext(ocs.NewContextWithStudent(r.Context(), students[0]))
@@ -237,6 +314,33 @@ func (s *Server) authenticate(next http.Handler) http.Handler {
 	})
 }
 
+// findRequestOrganization resolves the organization a request is made to,
+// by the OrganizationHeader if given, or else by the request's host. A host
+// that belongs to no organization resolves to nil.
+func (s *Server) findRequestOrganization(r *http.Request) (*ocs.Organization, error) {
+	var filter ocs.OrganizationFilter
+	if slug := r.Header.Get(OrganizationHeader); slug != "" {
+		filter.Slug = &slug
+	} else {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		filter.Domain = &host
+	}
+
+	orgs, _, err := s.OrganizationService.FindOrganizations(r.Context(), filter)
+	if err != nil {
+		return nil, err
+	} else if len(orgs) == 0 {
+		if filter.Slug != nil {
+			return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Organization not found."}
+		}
+		return nil, nil
+	}
+	return orgs[0], nil
+}
+
 func (s *Server) requireNoAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if studentID := ocs.StudentIDFromContext(r.Context()); studentID != 0 {
@@ -282,6 +386,41 @@ func loadFlash(next http.Handler) http.Handler {
 	})
 }
 
+// trackRequest adds the request's ID and the client's IP to the context.
+func trackRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !isValidRequestID(id) {
+			buf := make([]byte, 16)
+			rand.Read(buf)
+			id = hex.EncodeToString(buf)
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+
+		r = r.WithContext(ocs.NewContextWithRequestInfo(r.Context(), ocs.RequestInfo{ID: id, IP: ip}))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isValidRequestID reports whether a client's request ID is short and only
+// uses characters that are safe to log.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
 func reportPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -22,9 +22,73 @@ type Server struct {
 	*ocshttp.Server
 
 	// Mock services
-	AuthService    mock.AuthService
-	EventService   mock.EventService
-	StudentService mock.StudentService
+	AuditService             mock.AuditService
+	AuthService              mock.AuthService
+	AvatarService            mock.AvatarService
+	BadgeService             mock.BadgeService
+	CourseService            mock.CourseService
+	EmailVerificationService mock.EmailVerificationService
+	EnrollmentService        mock.EnrollmentService
+	ErasureService           mock.ErasureService
+	EventService             mock.EventService
+	ExportService            mock.ExportService
+	GroupService             mock.GroupService
+	LeaderboardService       mock.LeaderboardService
+	LearningPathService      mock.LearningPathService
+	MessageService           mock.MessageService
+	NoteService              mock.NoteService
+	NotificationService      mock.NotificationService
+	OrderService             mock.OrderService
+	OrganizationService      mock.OrganizationService
+	PasswordService          mock.PasswordService
+	MagicLinkService         mock.MagicLinkService
+	PaymentProvider          mock.PaymentProvider
+	RefundService            mock.RefundService
+	RegradeService           mock.RegradeService
+	StudentService           mock.StudentService
+	WebhookService           mock.WebhookService
+}
+
+// Only students who verified their email may use their API key.
+func TestServer_APIKey(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		verified bool
+		status   int
+	}{
+		{"OK", true, http.StatusOK},
+		{"ErrUnverified", false, http.StatusForbidden},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := MustOpenServer(t)
+			defer MustCloseServer(t, s)
+
+			s.OrganizationService.FindOrganizationsFn = func(ctx context.Context, filter ocs.OrganizationFilter) ([]*ocs.Organization, int, error) {
+				return nil, 0, nil
+			}
+			s.StudentService.FindStudentsFn = func(ctx context.Context, filter ocs.StudentFilter) ([]*ocs.Student, int, error) {
+				if filter.APIKey == nil || *filter.APIKey != "KEY" {
+					t.Fatalf("unexpected filter: %#v", filter)
+				}
+				return []*ocs.Student{{ID: 1, Name: "jane", Email: "jane@email.com", EmailVerified: tt.verified}}, 1, nil
+			}
+			s.BadgeService.FindBadgesFn = func(ctx context.Context) ([]*ocs.Badge, error) {
+				return nil, nil
+			}
+
+			r := s.MustNewRequest(t, context.Background(), "GET", "/badges", nil)
+			r.Header.Set("Authorization", "Bearer KEY")
+			r.Header.Set("Accept", "application/json")
+			resp, err := http.DefaultClient.Do(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("unexpected status: %d", resp.StatusCode)
+			}
+		})
+	}
 }
 
 func MustOpenServer(tb testing.TB) *Server {
@@ -36,9 +100,31 @@ func MustOpenServer(tb testing.TB) *Server {
 	s.GithubClientID = TestGithunClientID
 	s.GithubClientSecret = TestGithubClientSecret
 
+	s.Server.AuditService = &s.AuditService
 	s.Server.AuthService = &s.AuthService
+	s.Server.AvatarService = &s.AvatarService
+	s.Server.BadgeService = &s.BadgeService
+	s.Server.CourseService = &s.CourseService
+	s.Server.EmailVerificationService = &s.EmailVerificationService
+	s.Server.EnrollmentService = &s.EnrollmentService
+	s.Server.ErasureService = &s.ErasureService
 	s.Server.EventService = &s.EventService
+	s.Server.ExportService = &s.ExportService
+	s.Server.GroupService = &s.GroupService
+	s.Server.LeaderboardService = &s.LeaderboardService
+	s.Server.LearningPathService = &s.LearningPathService
+	s.Server.MessageService = &s.MessageService
+	s.Server.NoteService = &s.NoteService
+	s.Server.NotificationService = &s.NotificationService
+	s.Server.OrderService = &s.OrderService
+	s.Server.OrganizationService = &s.OrganizationService
+	s.Server.PasswordService = &s.PasswordService
+	s.Server.MagicLinkService = &s.MagicLinkService
+	s.Server.PaymentProvider = &s.PaymentProvider
+	s.Server.RefundService = &s.RefundService
+	s.Server.RegradeService = &s.RegradeService
 	s.Server.StudentService = &s.StudentService
+	s.Server.WebhookService = &s.WebhookService
 
 	if err := s.Open(); err != nil {
 		tb.Fatal(err)
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerStudentRoutes(r *mux.Router) {
+	r.HandleFunc("/students/{id}", s.handleStudentUpdate).Methods("PATCH")
+	r.HandleFunc("/students/{id}/profile", s.handleStudentProfile).Methods("GET")
+	r.HandleFunc("/students/{id}/restore", s.handleStudentRestore).Methods("POST")
+}
+
+// handleStudentUpdate updates a student's own account settings, such as
+// opting out of public leaderboards.
+func (s *Server) handleStudentUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	var upd ocs.StudentUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	student, err := s.StudentService.UpdateStudent(r.Context(), id, upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, student)
+}
+
+// handleStudentProfile returns a student's profile with the fields the
+// current student may see.
+func (s *Server) handleStudentProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	profile, err := s.StudentService.FindStudentProfile(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, profile)
+}
+
+// handleStudentRestore lets an admin restore a deleted student.
+func (s *Server) handleStudentRestore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	student, err := s.StudentService.RestoreStudent(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, student)
+}
@@ -0,0 +1,34 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) registerEmailVerificationRoutes(r *mux.Router) {
+	r.HandleFunc("/email-verification", s.handleEmailVerificationSend).Methods("POST")
+}
+
+// handleEmailVerificationSend emails the current student a new verification
+// link. The email is sent in the background.
+func (s *Server) handleEmailVerificationSend(w http.ResponseWriter, r *http.Request) {
+	if err := s.EmailVerificationService.SendEmailVerification(r.Context()); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleVerifyEmail verifies the address a verification link was sent to.
+// The link works whether or not the student is logged in.
+func (s *Server) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	student, err := s.EmailVerificationService.VerifyEmail(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, student)
+}
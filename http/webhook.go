@@ -0,0 +1,153 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/maliByatzes/ocs"
+)
+
+func (s *Server) registerWebhookRoutes(r *mux.Router) {
+	r.HandleFunc("/webhooks", s.handleWebhookIndex).Methods("GET")
+	r.HandleFunc("/webhooks", s.handleWebhookCreate).Methods("POST")
+	r.HandleFunc("/webhooks/{id}", s.handleWebhookView).Methods("GET")
+	r.HandleFunc("/webhooks/{id}", s.handleWebhookUpdate).Methods("PATCH")
+	r.HandleFunc("/webhooks/{id}", s.handleWebhookDelete).Methods("DELETE")
+	r.HandleFunc("/webhooks/{id}/deliveries", s.handleWebhookDeliveryIndex).Methods("GET")
+	r.HandleFunc("/webhook-deliveries/{id}/redeliver", s.handleWebhookDeliveryRedeliver).Methods("POST")
+}
+
+type findWebhooksResponse struct {
+	Webhooks []*ocs.Webhook `json:"webhooks"`
+	N        int            `json:"n"`
+}
+
+func (s *Server) handleWebhookIndex(w http.ResponseWriter, r *http.Request) {
+	var filter ocs.WebhookFilter
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	webhooks, n, err := s.WebhookService.FindWebhooks(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findWebhooksResponse{Webhooks: webhooks, N: n})
+}
+
+func (s *Server) handleWebhookView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	webhook, err := s.WebhookService.FindWebhookByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, webhook)
+}
+
+func (s *Server) handleWebhookCreate(w http.ResponseWriter, r *http.Request) {
+	var webhook ocs.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	if err := s.WebhookService.CreateWebhook(r.Context(), &webhook); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, &webhook)
+}
+
+func (s *Server) handleWebhookUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	var upd ocs.WebhookUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid JSON body"))
+		return
+	}
+
+	webhook, err := s.WebhookService.UpdateWebhook(r.Context(), id, upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, webhook)
+}
+
+func (s *Server) handleWebhookDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	if err := s.WebhookService.DeleteWebhook(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type findWebhookDeliveriesResponse struct {
+	WebhookDeliveries []*ocs.WebhookDelivery `json:"webhookDeliveries"`
+	N                 int                    `json:"n"`
+}
+
+// handleWebhookDeliveryIndex lists a webhook's deliveries, newest first,
+// optionally filtered by status.
+func (s *Server) handleWebhookDeliveryIndex(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	filter := ocs.WebhookDeliveryFilter{WebhookID: &webhookID}
+	if v := r.URL.Query().Get("status"); v != "" {
+		filter.Status = &v
+	}
+	filter.Offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	filter.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+
+	deliveries, n, err := s.WebhookService.FindWebhookDeliveries(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, findWebhookDeliveriesResponse{WebhookDeliveries: deliveries, N: n})
+}
+
+func (s *Server) handleWebhookDeliveryRedeliver(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, ocs.Errorf(ocs.EINVALID, "Invalid ID format"))
+		return
+	}
+
+	delivery, err := s.WebhookService.RedeliverWebhookDelivery(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusAccepted, delivery)
+}
@@ -0,0 +1,181 @@
+package inmem
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/maliByatzes/ocs"
+)
+
+// EventBufferSize is the buffer size of each subscription's channel.
+const EventBufferSize = 16
+
+var _ ocs.EventService = (*EventService)(nil)
+
+// EventService fans published events out to the subscriptions of the
+// student they were published to and to every registered handler.
+type EventService struct {
+	mu       sync.Mutex
+	m        map[int]map[*Subscription]struct{}
+	handlers []*eventHandler
+
+	ctx    context.Context
+	cancel func()
+	wg     sync.WaitGroup
+}
+
+func NewEventService() *EventService {
+	s := &EventService{m: make(map[int]map[*Subscription]struct{})}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// Close stops every handler. Events still queued are dropped.
+func (s *EventService) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
+
+// AddHandler registers a handler that receives every event published after
+// this call. Each handler runs on its own goroutine and sees events in the
+// order they were published.
+func (s *EventService) AddHandler(h ocs.EventHandler) {
+	eh := &eventHandler{h: h, ready: make(chan struct{}, 1)}
+
+	s.mu.Lock()
+	s.handlers = append(s.handlers, eh)
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() { defer s.wg.Done(); eh.run(s.ctx) }()
+}
+
+func (s *EventService) PublishEvent(studentID int, event ocs.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, eh := range s.handlers {
+		eh.push(studentID, event)
+	}
+
+	for sub := range s.m[studentID] {
+		select {
+		case sub.c <- event:
+		default:
+			// Slow subscribers are disconnected rather than blocking publishers.
+			s.unsubscribe(sub)
+		}
+	}
+}
+
+func (s *EventService) Subscribe(ctx context.Context) (ocs.Subscription, error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+	if studentID == 0 {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "Must be logged in to subscribe to events.")
+	}
+
+	sub := &Subscription{
+		service:   s,
+		studentID: studentID,
+		c:         make(chan ocs.Event, EventBufferSize),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs, ok := s.m[studentID]
+	if !ok {
+		subs = make(map[*Subscription]struct{})
+		s.m[studentID] = subs
+	}
+	subs[sub] = struct{}{}
+
+	return sub, nil
+}
+
+// Unsubscribe disconnects sub from the service.
+func (s *EventService) Unsubscribe(sub *Subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unsubscribe(sub)
+}
+
+func (s *EventService) unsubscribe(sub *Subscription) {
+	sub.once.Do(func() { close(sub.c) })
+
+	subs, ok := s.m[sub.studentID]
+	if !ok {
+		return
+	}
+	delete(subs, sub)
+
+	if len(subs) == 0 {
+		delete(s.m, sub.studentID)
+	}
+}
+
+var _ ocs.Subscription = (*Subscription)(nil)
+
+type Subscription struct {
+	service   *EventService
+	studentID int
+	c         chan ocs.Event
+	once      sync.Once
+}
+
+func (s *Subscription) Close() error {
+	s.service.Unsubscribe(s)
+	return nil
+}
+
+func (s *Subscription) C() <-chan ocs.Event {
+	return s.c
+}
+
+// eventHandler queues events for a handler without bounding the queue, so
+// that a handler publishing events of its own can never deadlock.
+type eventHandler struct {
+	h     ocs.EventHandler
+	mu    sync.Mutex
+	queue []queuedEvent
+	ready chan struct{}
+}
+
+type queuedEvent struct {
+	studentID int
+	event     ocs.Event
+}
+
+func (eh *eventHandler) push(studentID int, event ocs.Event) {
+	eh.mu.Lock()
+	eh.queue = append(eh.queue, queuedEvent{studentID: studentID, event: event})
+	eh.mu.Unlock()
+
+	select {
+	case eh.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (eh *eventHandler) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-eh.ready:
+		}
+
+		eh.mu.Lock()
+		queue := eh.queue
+		eh.queue = nil
+		eh.mu.Unlock()
+
+		for _, e := range queue {
+			if err := eh.h.HandleEvent(ctx, e.studentID, e.event); err != nil {
+				log.Printf("event handler error: type=%s err=%s", e.event.Type, err)
+			}
+		}
+	}
+}
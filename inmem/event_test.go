@@ -0,0 +1,70 @@
+package inmem_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/inmem"
+)
+
+func TestEventService_Subscribe(t *testing.T) {
+	s := inmem.NewEventService()
+	defer s.Close()
+
+	ctx0 := ocs.NewContextWithStudent(context.Background(), &ocs.Student{ID: 1})
+	sub0, err := s.Subscribe(ctx0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub0.Close()
+
+	// Events are only delivered to the student they were published to.
+	s.PublishEvent(2, ocs.Event{Type: "test:other"})
+	s.PublishEvent(1, ocs.Event{Type: "test:mine"})
+
+	select {
+	case event := <-sub0.C():
+		if got, want := event.Type, "test:mine"; got != want {
+			t.Fatalf("Type=%v, want %v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+
+	if _, err := s.Subscribe(context.Background()); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+}
+
+func TestEventService_AddHandler(t *testing.T) {
+	s := inmem.NewEventService()
+	defer s.Close()
+
+	ch := make(chan int, 2)
+	s.AddHandler(handlerFunc(func(ctx context.Context, studentID int, event ocs.Event) error {
+		ch <- studentID
+		return nil
+	}))
+
+	s.PublishEvent(1, ocs.Event{Type: "test"})
+	s.PublishEvent(2, ocs.Event{Type: "test"})
+
+	for _, want := range []int{1, 2} {
+		select {
+		case got := <-ch:
+			if got != want {
+				t.Fatalf("studentID=%v, want %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+type handlerFunc func(ctx context.Context, studentID int, event ocs.Event) error
+
+func (fn handlerFunc) HandleEvent(ctx context.Context, studentID int, event ocs.Event) error {
+	return fn(ctx, studentID, event)
+}
@@ -0,0 +1,66 @@
+package inmem
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.PaymentProvider = (*PaymentProvider)(nil)
+
+// PaymentProvider is a stand-in payment provider for development and tests.
+// Payments are never taken; callbacks are simulated with Webhook and signed
+// with an HMAC-SHA256 of the payload keyed by Secret.
+type PaymentProvider struct {
+	Secret string
+}
+
+func NewPaymentProvider(secret string) *PaymentProvider {
+	return &PaymentProvider{Secret: secret}
+}
+
+func (p *PaymentProvider) CreatePayment(ctx context.Context, order *ocs.Order) (*ocs.Payment, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	ref := "fake_" + hex.EncodeToString(b)
+	return &ocs.Payment{ProviderRef: ref, URL: "/fake-pay/" + ref}, nil
+}
+
+func (p *PaymentProvider) RefundPayment(ctx context.Context, order *ocs.Order, refund *ocs.Refund) error {
+	return nil
+}
+
+func (p *PaymentProvider) VerifyWebhook(payload []byte, signature string) (*ocs.PaymentNotification, error) {
+	if !hmac.Equal([]byte(p.sign(payload)), []byte(signature)) {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "Invalid webhook signature.")
+	}
+
+	var n ocs.PaymentNotification
+	if err := json.Unmarshal(payload, &n); err != nil {
+		return nil, ocs.Errorf(ocs.EINVALID, "Invalid webhook payload.")
+	}
+	return &n, nil
+}
+
+// Webhook returns the payload and signature of a callback for n, as the
+// provider would send it.
+func (p *PaymentProvider) Webhook(n ocs.PaymentNotification) (payload []byte, signature string, err error) {
+	if payload, err = json.Marshal(n); err != nil {
+		return nil, "", err
+	}
+	return payload, p.sign(payload), nil
+}
+
+func (p *PaymentProvider) sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.Secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
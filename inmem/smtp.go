@@ -0,0 +1,142 @@
+package inmem
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// SMTPMessage is a message received by an SMTPServer.
+type SMTPMessage struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// SMTPServer is an in-process SMTP server for tests. It speaks just enough
+// SMTP for net/smtp clients and keeps every message it accepts in memory.
+type SMTPServer struct {
+	// Addr is the address the server listens on, set by Open.
+	Addr string
+
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	messages []*SMTPMessage
+	failN    int
+}
+
+func NewSMTPServer() *SMTPServer {
+	return &SMTPServer{}
+}
+
+// Open starts listening on a random local port.
+func (s *SMTPServer) Open() (err error) {
+	if s.ln, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		return err
+	}
+	s.Addr = s.ln.Addr().String()
+
+	s.wg.Add(1)
+	go func() { defer s.wg.Done(); s.serve() }()
+	return nil
+}
+
+func (s *SMTPServer) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+// Messages returns the messages received so far.
+func (s *SMTPServer) Messages() []*SMTPMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*SMTPMessage(nil), s.messages...)
+}
+
+// FailNext rejects the next n messages with a temporary error.
+func (s *SMTPServer) FailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failN = n
+}
+
+func (s *SMTPServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() { defer s.wg.Done(); s.handle(conn) }()
+	}
+}
+
+func (s *SMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	tc := textproto.NewConn(conn)
+	tc.PrintfLine("220 localhost ESMTP")
+
+	msg := &SMTPMessage{}
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tc.PrintfLine("250 localhost")
+		case "MAIL":
+			msg = &SMTPMessage{From: parseSMTPPath(arg)}
+			tc.PrintfLine("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, parseSMTPPath(arg))
+			tc.PrintfLine("250 OK")
+		case "DATA":
+			if s.shouldFail() {
+				tc.PrintfLine("451 Try again later")
+				continue
+			}
+			tc.PrintfLine("354 Go ahead")
+			if msg.Data, err = tc.ReadDotBytes(); err != nil {
+				return
+			}
+
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			tc.PrintfLine("250 OK")
+		case "RSET", "NOOP":
+			tc.PrintfLine("250 OK")
+		case "QUIT":
+			tc.PrintfLine("221 Bye")
+			return
+		default:
+			tc.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func (s *SMTPServer) shouldFail() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failN > 0 {
+		s.failN--
+		return true
+	}
+	return false
+}
+
+// parseSMTPPath returns the address of a "FROM:<addr>" or "TO:<addr>" argument.
+func parseSMTPPath(arg string) string {
+	_, path, _ := strings.Cut(arg, ":")
+	path, _, _ = strings.Cut(strings.TrimSpace(path), " ")
+	return strings.Trim(path, "<>")
+}
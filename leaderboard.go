@@ -0,0 +1,92 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	LeaderboardPeriodWeek    = "week"
+	LeaderboardPeriodAllTime = "all-time"
+)
+
+// PointRule awards Points to a student for each published event of EventType
+// accepted by Match.
+type PointRule struct {
+	EventType string
+	Match     func(payload interface{}) bool
+	Points    int
+}
+
+// Matches reports whether the event earns points under the rule.
+func (r *PointRule) Matches(event Event) bool {
+	if event.Type != r.EventType {
+		return false
+	}
+	return r.Match == nil || r.Match(event.Payload)
+}
+
+// DefaultPointRules are the actions that earn points out of the box.
+var DefaultPointRules = []PointRule{
+	{EventType: EventTypeSubmissionCreated, Points: 10},
+	{
+		EventType: EventTypeSubmissionGraded,
+		Match: func(payload interface{}) bool {
+			p, ok := payload.(*SubmissionGradedPayload)
+			return ok && p.Grade*2 >= p.MaxPoints
+		},
+		Points: 20,
+	},
+	{EventType: EventTypeEnrollmentCompleted, Points: 50},
+	{EventType: EventTypeLearningPathCompleted, Points: 100},
+}
+
+// PointEntry is one award of points to a student. CourseID is nil for
+// points earned outside of a course; those only count globally.
+type PointEntry struct {
+	ID        int       `json:"id"`
+	StudentID int       `json:"studentID"`
+	CourseID  *int      `json:"courseID"`
+	Reason    string    `json:"reason"`
+	Points    int       `json:"points"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Leaderboard ranks students by points earned within a course, or across
+// all courses if CourseID is nil, since the start of the period. Students
+// who opted out are never listed, but always see their own standing in Me.
+type Leaderboard struct {
+	CourseID *int                `json:"courseID"`
+	Period   string              `json:"period"`
+	Since    *time.Time          `json:"since"`
+	Entries  []*LeaderboardEntry `json:"entries"`
+	N        int                 `json:"n"`
+	Me       *LeaderboardEntry   `json:"me"`
+}
+
+type LeaderboardEntry struct {
+	Rank      int    `json:"rank"`
+	StudentID int    `json:"studentID"`
+	Name      string `json:"name"`
+	Points    int    `json:"points"`
+}
+
+type LeaderboardService interface {
+	FindLeaderboard(ctx context.Context, filter LeaderboardFilter) (*Leaderboard, error)
+
+	// FindPointEntries returns the current student's point history.
+	FindPointEntries(ctx context.Context, filter PointEntryFilter) ([]*PointEntry, int, error)
+}
+
+type LeaderboardFilter struct {
+	CourseID *int   `json:"courseID"`
+	Period   string `json:"period"`
+	Offset   int    `json:"offset"`
+	Limit    int    `json:"limit"`
+}
+
+type PointEntryFilter struct {
+	CourseID *int `json:"courseID"`
+	Offset   int  `json:"offset"`
+	Limit    int  `json:"limit"`
+}
@@ -0,0 +1,129 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+// LearningPath bundles several courses into a program. A student completes
+// the path by completing every required course and at least
+// ElectivesRequired of the elective ones.
+type LearningPath struct {
+	ID                int                   `json:"id"`
+	OwnerID           int                   `json:"ownerID"`
+	Title             string                `json:"title"`
+	Description       string                `json:"description"`
+	ElectivesRequired int                   `json:"electivesRequired"`
+	IssuesCertificate bool                  `json:"issuesCertificate"`
+	Courses           []*LearningPathCourse `json:"courses"`
+
+	// The organization the path belongs to, or zero for none. It is set
+	// from the context the path is created in.
+	OrganizationID int `json:"organizationID"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (p *LearningPath) Validate() error {
+	if p.OwnerID == 0 {
+		return Errorf(EINVALID, "Owner required.")
+	} else if p.Title == "" {
+		return Errorf(EINVALID, "Title required.")
+	} else if len(p.Courses) == 0 {
+		return Errorf(EINVALID, "At least one course required.")
+	}
+
+	seen, electives := make(map[int]bool), 0
+	for _, c := range p.Courses {
+		if c.CourseID == 0 {
+			return Errorf(EINVALID, "Course required.")
+		} else if seen[c.CourseID] {
+			return Errorf(EINVALID, "Course %d appears more than once.", c.CourseID)
+		}
+		seen[c.CourseID] = true
+
+		if !c.Required {
+			electives++
+		}
+	}
+
+	if p.ElectivesRequired < 0 || p.ElectivesRequired > electives {
+		return Errorf(EINVALID, "Electives required must be between 0 and %d.", electives)
+	}
+	return nil
+}
+
+// LearningPathCourse is a course slot within a learning path.
+type LearningPathCourse struct {
+	CourseID int     `json:"courseID"`
+	Course   *Course `json:"course"`
+	Position int     `json:"position"`
+	Required bool    `json:"required"`
+}
+
+// PathEnrollment is a student's enrollment in a whole learning path.
+type PathEnrollment struct {
+	ID                 int           `json:"id"`
+	LearningPathID     int           `json:"learningPathID"`
+	LearningPath       *LearningPath `json:"learningPath"`
+	StudentID          int           `json:"studentID"`
+	RequiredCompleted  int           `json:"requiredCompleted"`
+	ElectivesCompleted int           `json:"electivesCompleted"`
+	CompletedAt        *time.Time    `json:"completedAt"`
+	Certificate        *Certificate  `json:"certificate"`
+	CreatedAt          time.Time     `json:"createdAt"`
+	UpdatedAt          time.Time     `json:"updatedAt"`
+}
+
+// Certificate is issued to a student on completing a learning path. Code is
+// a random, unguessable identifier that can be shared for verification.
+type Certificate struct {
+	ID             int       `json:"id"`
+	StudentID      int       `json:"studentID"`
+	LearningPathID int       `json:"learningPathID"`
+	Code           string    `json:"code"`
+	IssuedAt       time.Time `json:"issuedAt"`
+}
+
+type LearningPathService interface {
+	FindLearningPathByID(ctx context.Context, id int) (*LearningPath, error)
+	FindLearningPaths(ctx context.Context, filter LearningPathFilter) ([]*LearningPath, int, error)
+	CreateLearningPath(ctx context.Context, path *LearningPath) error
+	DeleteLearningPath(ctx context.Context, id int) error
+
+	// EnrollInLearningPath enrolls the current student in the path and in
+	// every free member course they are not enrolled in yet. Paid courses
+	// must be bought separately; buying one enrolls the student in it.
+	EnrollInLearningPath(ctx context.Context, id int) (*PathEnrollment, error)
+	FindPathEnrollments(ctx context.Context, filter PathEnrollmentFilter) ([]*PathEnrollment, int, error)
+
+	// FindCertificates returns the caller's certificates and those issued by
+	// paths they own. Filtering by Code looks up any certificate, so that a
+	// shared code can be verified by anyone.
+	FindCertificates(ctx context.Context, filter CertificateFilter) ([]*Certificate, int, error)
+}
+
+type LearningPathFilter struct {
+	ID      *int `json:"id"`
+	OwnerID *int `json:"ownerID"`
+	Offset  int  `json:"offset"`
+	Limit   int  `json:"limit"`
+}
+
+type PathEnrollmentFilter struct {
+	ID             *int `json:"id"`
+	LearningPathID *int `json:"learningPathID"`
+	StudentID      *int `json:"studentID"`
+	Offset         int  `json:"offset"`
+	Limit          int  `json:"limit"`
+}
+
+type CertificateFilter struct {
+	ID             *int    `json:"id"`
+	StudentID      *int    `json:"studentID"`
+	LearningPathID *int    `json:"learningPathID"`
+	Code           *string `json:"code"`
+	Offset         int     `json:"offset"`
+	Limit          int     `json:"limit"`
+}
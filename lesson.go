@@ -0,0 +1,40 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+type Lesson struct {
+	ID        int       `json:"id"`
+	CourseID  int       `json:"courseID"`
+	Course    *Course   `json:"course"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	VideoURL  string    `json:"videoURL"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (l *Lesson) Validate() error {
+	if l.CourseID == 0 {
+		return Errorf(EINVALID, "Course required.")
+	} else if l.Title == "" {
+		return Errorf(EINVALID, "Title required.")
+	}
+	return nil
+}
+
+type LessonService interface {
+	FindLessonByID(ctx context.Context, id int) (*Lesson, error)
+	FindLessons(ctx context.Context, filter LessonFilter) ([]*Lesson, int, error)
+	CreateLesson(ctx context.Context, lesson *Lesson) error
+}
+
+type LessonFilter struct {
+	ID       *int `json:"id"`
+	CourseID *int `json:"courseID"`
+	Offset   int  `json:"offset"`
+	Limit    int  `json:"limit"`
+}
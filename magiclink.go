@@ -0,0 +1,19 @@
+package ocs
+
+import "context"
+
+// MagicLinkService logs students in with single-use links emailed to them.
+// A link only works in the browser that asked for it: the request carries a
+// random nonce, kept in that browser's session, which must be given again to
+// redeem the link.
+type MagicLinkService interface {
+	// RequestMagicLink emails a sign-in link to the student with the given
+	// email. It succeeds whether or not there is such a student, so that it
+	// cannot be used to find out who has an account.
+	RequestMagicLink(ctx context.Context, email, nonce string) error
+
+	// RedeemMagicLink returns the student a link was sent to, given its
+	// token and the nonce it was requested with. Expired tokens return
+	// EEXPIRED and tokens that were already used return ECONFLICT.
+	RedeemMagicLink(ctx context.Context, token, nonce string) (*Student, error)
+}
@@ -0,0 +1,105 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+// MaxConversationParticipants limits group conversations to a small group,
+// counting the student who starts the conversation.
+const MaxConversationParticipants = 8
+
+// Conversation is a private exchange of messages between students of a
+// course and its staff. UnreadCount is the number of messages the current
+// student has not read yet.
+type Conversation struct {
+	ID       int    `json:"id"`
+	CourseID int    `json:"courseID"`
+	Subject  string `json:"subject"`
+
+	// The students to start the conversation with. The current student is
+	// always added.
+	ParticipantIDs []int `json:"participantIDs"`
+
+	Participants []*ConversationParticipant `json:"participants"`
+	UnreadCount  int                        `json:"unreadCount"`
+	CreatedAt    time.Time                  `json:"createdAt"`
+	UpdatedAt    time.Time                  `json:"updatedAt"`
+}
+
+func (c *Conversation) Validate() error {
+	if c.CourseID == 0 {
+		return Errorf(EINVALID, "Course required.")
+	} else if len(c.ParticipantIDs) < 2 {
+		return Errorf(EINVALID, "At least one other participant required.")
+	} else if len(c.ParticipantIDs) > MaxConversationParticipants {
+		return Errorf(EINVALID, "Conversations are limited to %d participants.", MaxConversationParticipants)
+	}
+	return nil
+}
+
+// ConversationParticipant is a student's place in a conversation.
+// LastReadMessageID is their read receipt.
+type ConversationParticipant struct {
+	StudentID         int        `json:"studentID"`
+	Student           *Student   `json:"student"`
+	LastReadMessageID int        `json:"lastReadMessageID"`
+	LastReadAt        *time.Time `json:"lastReadAt"`
+}
+
+type Message struct {
+	ID             int       `json:"id"`
+	ConversationID int       `json:"conversationID"`
+	SenderID       int       `json:"senderID"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+func (m *Message) Validate() error {
+	if m.ConversationID == 0 {
+		return Errorf(EINVALID, "Conversation required.")
+	} else if m.SenderID == 0 {
+		return Errorf(EINVALID, "Sender required.")
+	} else if m.Body == "" {
+		return Errorf(EINVALID, "Body required.")
+	}
+	return nil
+}
+
+// MessageService manages the current student's conversations. New messages
+// and read receipts are published to every participant.
+type MessageService interface {
+	FindConversationByID(ctx context.Context, id int) (*Conversation, error)
+
+	// FindConversations returns the current student's conversations, most
+	// recently active first.
+	FindConversations(ctx context.Context, filter ConversationFilter) ([]*Conversation, int, error)
+	CreateConversation(ctx context.Context, conversation *Conversation) error
+
+	// FindMessages returns a page of a conversation's messages, oldest
+	// first. Pass the ID of the oldest message seen as BeforeID to page
+	// back through the history.
+	FindMessages(ctx context.Context, filter MessageFilter) ([]*Message, int, error)
+	SendMessage(ctx context.Context, message *Message) error
+
+	// MarkConversationRead marks every message in the conversation as read
+	// by the current student.
+	MarkConversationRead(ctx context.Context, conversationID int) (*ConversationParticipant, error)
+
+	// CountUnreadMessages returns the number of unread messages across all
+	// of the current student's conversations.
+	CountUnreadMessages(ctx context.Context) (int, error)
+}
+
+type ConversationFilter struct {
+	ID       *int `json:"id"`
+	CourseID *int `json:"courseID"`
+	Offset   int  `json:"offset"`
+	Limit    int  `json:"limit"`
+}
+
+type MessageFilter struct {
+	ConversationID int  `json:"conversationID"`
+	BeforeID       *int `json:"beforeID"`
+	Limit          int  `json:"limit"`
+}
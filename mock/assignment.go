@@ -0,0 +1,47 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.AssignmentService = (*AssignmentService)(nil)
+
+type AssignmentService struct {
+	FindAssignmentByIDFn func(ctx context.Context, id int) (*ocs.Assignment, error)
+	FindAssignmentsFn    func(ctx context.Context, filter ocs.AssignmentFilter) ([]*ocs.Assignment, int, error)
+	CreateAssignmentFn   func(ctx context.Context, assignment *ocs.Assignment) error
+	FindSubmissionByIDFn func(ctx context.Context, id int) (*ocs.Submission, error)
+	FindSubmissionsFn    func(ctx context.Context, filter ocs.SubmissionFilter) ([]*ocs.Submission, int, error)
+	CreateSubmissionFn   func(ctx context.Context, submission *ocs.Submission) error
+	GradeSubmissionFn    func(ctx context.Context, id int, grade ocs.SubmissionGrade) (*ocs.Submission, error)
+}
+
+func (s *AssignmentService) FindAssignmentByID(ctx context.Context, id int) (*ocs.Assignment, error) {
+	return s.FindAssignmentByIDFn(ctx, id)
+}
+
+func (s *AssignmentService) FindAssignments(ctx context.Context, filter ocs.AssignmentFilter) ([]*ocs.Assignment, int, error) {
+	return s.FindAssignmentsFn(ctx, filter)
+}
+
+func (s *AssignmentService) CreateAssignment(ctx context.Context, assignment *ocs.Assignment) error {
+	return s.CreateAssignmentFn(ctx, assignment)
+}
+
+func (s *AssignmentService) FindSubmissionByID(ctx context.Context, id int) (*ocs.Submission, error) {
+	return s.FindSubmissionByIDFn(ctx, id)
+}
+
+func (s *AssignmentService) FindSubmissions(ctx context.Context, filter ocs.SubmissionFilter) ([]*ocs.Submission, int, error) {
+	return s.FindSubmissionsFn(ctx, filter)
+}
+
+func (s *AssignmentService) CreateSubmission(ctx context.Context, submission *ocs.Submission) error {
+	return s.CreateSubmissionFn(ctx, submission)
+}
+
+func (s *AssignmentService) GradeSubmission(ctx context.Context, id int, grade ocs.SubmissionGrade) (*ocs.Submission, error) {
+	return s.GradeSubmissionFn(ctx, id, grade)
+}
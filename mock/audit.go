@@ -0,0 +1,22 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.AuditService = (*AuditService)(nil)
+
+type AuditService struct {
+	FindAuditEntriesFn func(ctx context.Context, filter ocs.AuditFilter) ([]*ocs.AuditEntry, int, error)
+	VerifyAuditLogFn   func(ctx context.Context) (*ocs.AuditVerification, error)
+}
+
+func (s *AuditService) FindAuditEntries(ctx context.Context, filter ocs.AuditFilter) ([]*ocs.AuditEntry, int, error) {
+	return s.FindAuditEntriesFn(ctx, filter)
+}
+
+func (s *AuditService) VerifyAuditLog(ctx context.Context) (*ocs.AuditVerification, error) {
+	return s.VerifyAuditLogFn(ctx)
+}
@@ -0,0 +1,28 @@
+package mock
+
+import (
+	"context"
+	"io"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.AvatarService = (*AvatarService)(nil)
+
+type AvatarService struct {
+	FindAvatarFn   func(ctx context.Context, studentID, size int) (*ocs.AvatarImage, error)
+	UploadAvatarFn func(ctx context.Context, studentID int, r io.Reader) (*ocs.Student, error)
+	DeleteAvatarFn func(ctx context.Context, studentID int) (*ocs.Student, error)
+}
+
+func (s *AvatarService) FindAvatar(ctx context.Context, studentID, size int) (*ocs.AvatarImage, error) {
+	return s.FindAvatarFn(ctx, studentID, size)
+}
+
+func (s *AvatarService) UploadAvatar(ctx context.Context, studentID int, r io.Reader) (*ocs.Student, error) {
+	return s.UploadAvatarFn(ctx, studentID, r)
+}
+
+func (s *AvatarService) DeleteAvatar(ctx context.Context, studentID int) (*ocs.Student, error) {
+	return s.DeleteAvatarFn(ctx, studentID)
+}
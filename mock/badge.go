@@ -0,0 +1,22 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.BadgeService = (*BadgeService)(nil)
+
+type BadgeService struct {
+	FindBadgesFn      func(ctx context.Context) ([]*ocs.Badge, error)
+	FindBadgeAwardsFn func(ctx context.Context, filter ocs.BadgeAwardFilter) ([]*ocs.BadgeAward, int, error)
+}
+
+func (s *BadgeService) FindBadges(ctx context.Context) ([]*ocs.Badge, error) {
+	return s.FindBadgesFn(ctx)
+}
+
+func (s *BadgeService) FindBadgeAwards(ctx context.Context, filter ocs.BadgeAwardFilter) ([]*ocs.BadgeAward, int, error) {
+	return s.FindBadgeAwardsFn(ctx, filter)
+}
@@ -0,0 +1,42 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.CourseService = (*CourseService)(nil)
+
+type CourseService struct {
+	FindCourseByIDFn func(ctx context.Context, id int) (*ocs.Course, error)
+	FindCoursesFn    func(ctx context.Context, filter ocs.CourseFilter) ([]*ocs.Course, int, error)
+	CreateCourseFn   func(ctx context.Context, course *ocs.Course) error
+	UpdateCourseFn   func(ctx context.Context, id int, upd ocs.CourseUpdate) (*ocs.Course, error)
+	DeleteCourseFn   func(ctx context.Context, id int) error
+	RestoreCourseFn  func(ctx context.Context, id int) (*ocs.Course, error)
+}
+
+func (s *CourseService) FindCourseByID(ctx context.Context, id int) (*ocs.Course, error) {
+	return s.FindCourseByIDFn(ctx, id)
+}
+
+func (s *CourseService) FindCourses(ctx context.Context, filter ocs.CourseFilter) ([]*ocs.Course, int, error) {
+	return s.FindCoursesFn(ctx, filter)
+}
+
+func (s *CourseService) CreateCourse(ctx context.Context, course *ocs.Course) error {
+	return s.CreateCourseFn(ctx, course)
+}
+
+func (s *CourseService) UpdateCourse(ctx context.Context, id int, upd ocs.CourseUpdate) (*ocs.Course, error) {
+	return s.UpdateCourseFn(ctx, id, upd)
+}
+
+func (s *CourseService) DeleteCourse(ctx context.Context, id int) error {
+	return s.DeleteCourseFn(ctx, id)
+}
+
+func (s *CourseService) RestoreCourse(ctx context.Context, id int) (*ocs.Course, error) {
+	return s.RestoreCourseFn(ctx, id)
+}
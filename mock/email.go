@@ -0,0 +1,17 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.EmailSender = (*EmailSender)(nil)
+
+type EmailSender struct {
+	SendEmailFn func(ctx context.Context, email *ocs.Email) error
+}
+
+func (s *EmailSender) SendEmail(ctx context.Context, email *ocs.Email) error {
+	return s.SendEmailFn(ctx, email)
+}
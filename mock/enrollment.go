@@ -0,0 +1,37 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.EnrollmentService = (*EnrollmentService)(nil)
+
+type EnrollmentService struct {
+	FindEnrollmentByIDFn func(ctx context.Context, id int) (*ocs.Enrollment, error)
+	FindEnrollmentsFn    func(ctx context.Context, filter ocs.EnrollmentFilter) ([]*ocs.Enrollment, int, error)
+	CreateEnrollmentFn   func(ctx context.Context, enrollment *ocs.Enrollment) error
+	DeleteEnrollmentFn   func(ctx context.Context, id int) error
+	CompleteEnrollmentFn func(ctx context.Context, id int) (*ocs.Enrollment, error)
+}
+
+func (s *EnrollmentService) FindEnrollmentByID(ctx context.Context, id int) (*ocs.Enrollment, error) {
+	return s.FindEnrollmentByIDFn(ctx, id)
+}
+
+func (s *EnrollmentService) FindEnrollments(ctx context.Context, filter ocs.EnrollmentFilter) ([]*ocs.Enrollment, int, error) {
+	return s.FindEnrollmentsFn(ctx, filter)
+}
+
+func (s *EnrollmentService) CreateEnrollment(ctx context.Context, enrollment *ocs.Enrollment) error {
+	return s.CreateEnrollmentFn(ctx, enrollment)
+}
+
+func (s *EnrollmentService) DeleteEnrollment(ctx context.Context, id int) error {
+	return s.DeleteEnrollmentFn(ctx, id)
+}
+
+func (s *EnrollmentService) CompleteEnrollment(ctx context.Context, id int) (*ocs.Enrollment, error) {
+	return s.CompleteEnrollmentFn(ctx, id)
+}
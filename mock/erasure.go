@@ -0,0 +1,27 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.ErasureService = (*ErasureService)(nil)
+
+type ErasureService struct {
+	FindErasureRequestsFn func(ctx context.Context, filter ocs.ErasureRequestFilter) ([]*ocs.ErasureRequest, int, error)
+	RequestErasureFn      func(ctx context.Context) (*ocs.ErasureRequest, error)
+	CancelErasureFn       func(ctx context.Context, id int) (*ocs.ErasureRequest, error)
+}
+
+func (s *ErasureService) FindErasureRequests(ctx context.Context, filter ocs.ErasureRequestFilter) ([]*ocs.ErasureRequest, int, error) {
+	return s.FindErasureRequestsFn(ctx, filter)
+}
+
+func (s *ErasureService) RequestErasure(ctx context.Context) (*ocs.ErasureRequest, error) {
+	return s.RequestErasureFn(ctx)
+}
+
+func (s *ErasureService) CancelErasure(ctx context.Context, id int) (*ocs.ErasureRequest, error) {
+	return s.CancelErasureFn(ctx, id)
+}
@@ -14,11 +14,11 @@ type EventService struct {
 }
 
 func (s *EventService) PublishEvent(studentID int, event ocs.Event) {
-	s.PublishEvent(studentID, event)
+	s.PublishEventFn(studentID, event)
 }
 
 func (s *EventService) Subscribe(ctx context.Context) (ocs.Subscription, error) {
-	return s.Subscribe(ctx)
+	return s.SubscribeFn(ctx)
 }
 
 type Subscription struct {
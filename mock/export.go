@@ -0,0 +1,27 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.ExportService = (*ExportService)(nil)
+
+type ExportService struct {
+	FindExportsFn  func(ctx context.Context, filter ocs.ExportFilter) ([]*ocs.Export, int, error)
+	CreateExportFn func(ctx context.Context) (*ocs.Export, error)
+	OpenExportFn   func(ctx context.Context, id int, token string) ([]byte, error)
+}
+
+func (s *ExportService) FindExports(ctx context.Context, filter ocs.ExportFilter) ([]*ocs.Export, int, error) {
+	return s.FindExportsFn(ctx, filter)
+}
+
+func (s *ExportService) CreateExport(ctx context.Context) (*ocs.Export, error) {
+	return s.CreateExportFn(ctx)
+}
+
+func (s *ExportService) OpenExport(ctx context.Context, id int, token string) ([]byte, error) {
+	return s.OpenExportFn(ctx, id, token)
+}
@@ -0,0 +1,62 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.GroupService = (*GroupService)(nil)
+
+type GroupService struct {
+	FindGroupSetByIDFn  func(ctx context.Context, id int) (*ocs.GroupSet, error)
+	FindGroupSetsFn     func(ctx context.Context, filter ocs.GroupSetFilter) ([]*ocs.GroupSet, int, error)
+	CreateGroupSetFn    func(ctx context.Context, set *ocs.GroupSet) error
+	DeleteGroupSetFn    func(ctx context.Context, id int) error
+	CreateGroupFn       func(ctx context.Context, group *ocs.Group) error
+	DeleteGroupFn       func(ctx context.Context, id int) error
+	AssignGroupsFn      func(ctx context.Context, setID int, assignment ocs.GroupAssignment) ([]*ocs.Group, error)
+	FindGroupMembersFn  func(ctx context.Context, filter ocs.GroupMemberFilter) ([]*ocs.GroupMember, int, error)
+	AddGroupMemberFn    func(ctx context.Context, groupID, studentID int) (*ocs.GroupMember, error)
+	RemoveGroupMemberFn func(ctx context.Context, groupID, studentID int) error
+}
+
+func (s *GroupService) FindGroupSetByID(ctx context.Context, id int) (*ocs.GroupSet, error) {
+	return s.FindGroupSetByIDFn(ctx, id)
+}
+
+func (s *GroupService) FindGroupSets(ctx context.Context, filter ocs.GroupSetFilter) ([]*ocs.GroupSet, int, error) {
+	return s.FindGroupSetsFn(ctx, filter)
+}
+
+func (s *GroupService) CreateGroupSet(ctx context.Context, set *ocs.GroupSet) error {
+	return s.CreateGroupSetFn(ctx, set)
+}
+
+func (s *GroupService) DeleteGroupSet(ctx context.Context, id int) error {
+	return s.DeleteGroupSetFn(ctx, id)
+}
+
+func (s *GroupService) CreateGroup(ctx context.Context, group *ocs.Group) error {
+	return s.CreateGroupFn(ctx, group)
+}
+
+func (s *GroupService) DeleteGroup(ctx context.Context, id int) error {
+	return s.DeleteGroupFn(ctx, id)
+}
+
+func (s *GroupService) AssignGroups(ctx context.Context, setID int, assignment ocs.GroupAssignment) ([]*ocs.Group, error) {
+	return s.AssignGroupsFn(ctx, setID, assignment)
+}
+
+func (s *GroupService) FindGroupMembers(ctx context.Context, filter ocs.GroupMemberFilter) ([]*ocs.GroupMember, int, error) {
+	return s.FindGroupMembersFn(ctx, filter)
+}
+
+func (s *GroupService) AddGroupMember(ctx context.Context, groupID, studentID int) (*ocs.GroupMember, error) {
+	return s.AddGroupMemberFn(ctx, groupID, studentID)
+}
+
+func (s *GroupService) RemoveGroupMember(ctx context.Context, groupID, studentID int) error {
+	return s.RemoveGroupMemberFn(ctx, groupID, studentID)
+}
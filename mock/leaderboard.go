@@ -0,0 +1,22 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.LeaderboardService = (*LeaderboardService)(nil)
+
+type LeaderboardService struct {
+	FindLeaderboardFn  func(ctx context.Context, filter ocs.LeaderboardFilter) (*ocs.Leaderboard, error)
+	FindPointEntriesFn func(ctx context.Context, filter ocs.PointEntryFilter) ([]*ocs.PointEntry, int, error)
+}
+
+func (s *LeaderboardService) FindLeaderboard(ctx context.Context, filter ocs.LeaderboardFilter) (*ocs.Leaderboard, error) {
+	return s.FindLeaderboardFn(ctx, filter)
+}
+
+func (s *LeaderboardService) FindPointEntries(ctx context.Context, filter ocs.PointEntryFilter) ([]*ocs.PointEntry, int, error) {
+	return s.FindPointEntriesFn(ctx, filter)
+}
@@ -0,0 +1,47 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.LearningPathService = (*LearningPathService)(nil)
+
+type LearningPathService struct {
+	FindLearningPathByIDFn func(ctx context.Context, id int) (*ocs.LearningPath, error)
+	FindLearningPathsFn    func(ctx context.Context, filter ocs.LearningPathFilter) ([]*ocs.LearningPath, int, error)
+	CreateLearningPathFn   func(ctx context.Context, path *ocs.LearningPath) error
+	DeleteLearningPathFn   func(ctx context.Context, id int) error
+	EnrollInLearningPathFn func(ctx context.Context, id int) (*ocs.PathEnrollment, error)
+	FindPathEnrollmentsFn  func(ctx context.Context, filter ocs.PathEnrollmentFilter) ([]*ocs.PathEnrollment, int, error)
+	FindCertificatesFn     func(ctx context.Context, filter ocs.CertificateFilter) ([]*ocs.Certificate, int, error)
+}
+
+func (s *LearningPathService) FindLearningPathByID(ctx context.Context, id int) (*ocs.LearningPath, error) {
+	return s.FindLearningPathByIDFn(ctx, id)
+}
+
+func (s *LearningPathService) FindLearningPaths(ctx context.Context, filter ocs.LearningPathFilter) ([]*ocs.LearningPath, int, error) {
+	return s.FindLearningPathsFn(ctx, filter)
+}
+
+func (s *LearningPathService) CreateLearningPath(ctx context.Context, path *ocs.LearningPath) error {
+	return s.CreateLearningPathFn(ctx, path)
+}
+
+func (s *LearningPathService) DeleteLearningPath(ctx context.Context, id int) error {
+	return s.DeleteLearningPathFn(ctx, id)
+}
+
+func (s *LearningPathService) EnrollInLearningPath(ctx context.Context, id int) (*ocs.PathEnrollment, error) {
+	return s.EnrollInLearningPathFn(ctx, id)
+}
+
+func (s *LearningPathService) FindPathEnrollments(ctx context.Context, filter ocs.PathEnrollmentFilter) ([]*ocs.PathEnrollment, int, error) {
+	return s.FindPathEnrollmentsFn(ctx, filter)
+}
+
+func (s *LearningPathService) FindCertificates(ctx context.Context, filter ocs.CertificateFilter) ([]*ocs.Certificate, int, error) {
+	return s.FindCertificatesFn(ctx, filter)
+}
@@ -0,0 +1,27 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.LessonService = (*LessonService)(nil)
+
+type LessonService struct {
+	FindLessonByIDFn func(ctx context.Context, id int) (*ocs.Lesson, error)
+	FindLessonsFn    func(ctx context.Context, filter ocs.LessonFilter) ([]*ocs.Lesson, int, error)
+	CreateLessonFn   func(ctx context.Context, lesson *ocs.Lesson) error
+}
+
+func (s *LessonService) FindLessonByID(ctx context.Context, id int) (*ocs.Lesson, error) {
+	return s.FindLessonByIDFn(ctx, id)
+}
+
+func (s *LessonService) FindLessons(ctx context.Context, filter ocs.LessonFilter) ([]*ocs.Lesson, int, error) {
+	return s.FindLessonsFn(ctx, filter)
+}
+
+func (s *LessonService) CreateLesson(ctx context.Context, lesson *ocs.Lesson) error {
+	return s.CreateLessonFn(ctx, lesson)
+}
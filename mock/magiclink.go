@@ -0,0 +1,22 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.MagicLinkService = (*MagicLinkService)(nil)
+
+type MagicLinkService struct {
+	RequestMagicLinkFn func(ctx context.Context, email, nonce string) error
+	RedeemMagicLinkFn  func(ctx context.Context, token, nonce string) (*ocs.Student, error)
+}
+
+func (s *MagicLinkService) RequestMagicLink(ctx context.Context, email, nonce string) error {
+	return s.RequestMagicLinkFn(ctx, email, nonce)
+}
+
+func (s *MagicLinkService) RedeemMagicLink(ctx context.Context, token, nonce string) (*ocs.Student, error) {
+	return s.RedeemMagicLinkFn(ctx, token, nonce)
+}
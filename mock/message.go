@@ -0,0 +1,47 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.MessageService = (*MessageService)(nil)
+
+type MessageService struct {
+	FindConversationByIDFn func(ctx context.Context, id int) (*ocs.Conversation, error)
+	FindConversationsFn    func(ctx context.Context, filter ocs.ConversationFilter) ([]*ocs.Conversation, int, error)
+	CreateConversationFn   func(ctx context.Context, conversation *ocs.Conversation) error
+	FindMessagesFn         func(ctx context.Context, filter ocs.MessageFilter) ([]*ocs.Message, int, error)
+	SendMessageFn          func(ctx context.Context, message *ocs.Message) error
+	MarkConversationReadFn func(ctx context.Context, conversationID int) (*ocs.ConversationParticipant, error)
+	CountUnreadMessagesFn  func(ctx context.Context) (int, error)
+}
+
+func (s *MessageService) FindConversationByID(ctx context.Context, id int) (*ocs.Conversation, error) {
+	return s.FindConversationByIDFn(ctx, id)
+}
+
+func (s *MessageService) FindConversations(ctx context.Context, filter ocs.ConversationFilter) ([]*ocs.Conversation, int, error) {
+	return s.FindConversationsFn(ctx, filter)
+}
+
+func (s *MessageService) CreateConversation(ctx context.Context, conversation *ocs.Conversation) error {
+	return s.CreateConversationFn(ctx, conversation)
+}
+
+func (s *MessageService) FindMessages(ctx context.Context, filter ocs.MessageFilter) ([]*ocs.Message, int, error) {
+	return s.FindMessagesFn(ctx, filter)
+}
+
+func (s *MessageService) SendMessage(ctx context.Context, message *ocs.Message) error {
+	return s.SendMessageFn(ctx, message)
+}
+
+func (s *MessageService) MarkConversationRead(ctx context.Context, conversationID int) (*ocs.ConversationParticipant, error) {
+	return s.MarkConversationReadFn(ctx, conversationID)
+}
+
+func (s *MessageService) CountUnreadMessages(ctx context.Context) (int, error) {
+	return s.CountUnreadMessagesFn(ctx)
+}
@@ -0,0 +1,52 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.NoteService = (*NoteService)(nil)
+
+type NoteService struct {
+	FindNoteByIDFn   func(ctx context.Context, id int) (*ocs.Note, error)
+	FindNotesFn      func(ctx context.Context, filter ocs.NoteFilter) ([]*ocs.Note, int, error)
+	CreateNoteFn     func(ctx context.Context, note *ocs.Note) error
+	UpdateNoteFn     func(ctx context.Context, id int, upd ocs.NoteUpdate) (*ocs.Note, error)
+	DeleteNoteFn     func(ctx context.Context, id int) error
+	FindBookmarksFn  func(ctx context.Context, filter ocs.BookmarkFilter) ([]*ocs.Bookmark, int, error)
+	CreateBookmarkFn func(ctx context.Context, bookmark *ocs.Bookmark) error
+	DeleteBookmarkFn func(ctx context.Context, id int) error
+}
+
+func (s *NoteService) FindNoteByID(ctx context.Context, id int) (*ocs.Note, error) {
+	return s.FindNoteByIDFn(ctx, id)
+}
+
+func (s *NoteService) FindNotes(ctx context.Context, filter ocs.NoteFilter) ([]*ocs.Note, int, error) {
+	return s.FindNotesFn(ctx, filter)
+}
+
+func (s *NoteService) CreateNote(ctx context.Context, note *ocs.Note) error {
+	return s.CreateNoteFn(ctx, note)
+}
+
+func (s *NoteService) UpdateNote(ctx context.Context, id int, upd ocs.NoteUpdate) (*ocs.Note, error) {
+	return s.UpdateNoteFn(ctx, id, upd)
+}
+
+func (s *NoteService) DeleteNote(ctx context.Context, id int) error {
+	return s.DeleteNoteFn(ctx, id)
+}
+
+func (s *NoteService) FindBookmarks(ctx context.Context, filter ocs.BookmarkFilter) ([]*ocs.Bookmark, int, error) {
+	return s.FindBookmarksFn(ctx, filter)
+}
+
+func (s *NoteService) CreateBookmark(ctx context.Context, bookmark *ocs.Bookmark) error {
+	return s.CreateBookmarkFn(ctx, bookmark)
+}
+
+func (s *NoteService) DeleteBookmark(ctx context.Context, id int) error {
+	return s.DeleteBookmarkFn(ctx, id)
+}
@@ -0,0 +1,32 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.NotificationService = (*NotificationService)(nil)
+
+type NotificationService struct {
+	FindNotificationsFn        func(ctx context.Context, filter ocs.NotificationFilter) ([]*ocs.Notification, int, error)
+	MarkNotificationReadFn     func(ctx context.Context, id int) (*ocs.Notification, error)
+	MarkAllNotificationsReadFn func(ctx context.Context) error
+	CountUnreadNotificationsFn func(ctx context.Context) (int, error)
+}
+
+func (s *NotificationService) FindNotifications(ctx context.Context, filter ocs.NotificationFilter) ([]*ocs.Notification, int, error) {
+	return s.FindNotificationsFn(ctx, filter)
+}
+
+func (s *NotificationService) MarkNotificationRead(ctx context.Context, id int) (*ocs.Notification, error) {
+	return s.MarkNotificationReadFn(ctx, id)
+}
+
+func (s *NotificationService) MarkAllNotificationsRead(ctx context.Context) error {
+	return s.MarkAllNotificationsReadFn(ctx)
+}
+
+func (s *NotificationService) CountUnreadNotifications(ctx context.Context) (int, error) {
+	return s.CountUnreadNotificationsFn(ctx)
+}
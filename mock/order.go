@@ -0,0 +1,67 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.OrderService = (*OrderService)(nil)
+
+type OrderService struct {
+	FindOrderByIDFn   func(ctx context.Context, id int) (*ocs.Order, error)
+	FindOrdersFn      func(ctx context.Context, filter ocs.OrderFilter) ([]*ocs.Order, int, error)
+	CheckoutFn        func(ctx context.Context, checkout ocs.Checkout) (*ocs.Order, error)
+	CompletePaymentFn func(ctx context.Context, n *ocs.PaymentNotification) (*ocs.Order, error)
+	FindCouponsFn     func(ctx context.Context, filter ocs.CouponFilter) ([]*ocs.Coupon, int, error)
+	CreateCouponFn    func(ctx context.Context, coupon *ocs.Coupon) error
+	DeleteCouponFn    func(ctx context.Context, id int) error
+}
+
+func (s *OrderService) FindOrderByID(ctx context.Context, id int) (*ocs.Order, error) {
+	return s.FindOrderByIDFn(ctx, id)
+}
+
+func (s *OrderService) FindOrders(ctx context.Context, filter ocs.OrderFilter) ([]*ocs.Order, int, error) {
+	return s.FindOrdersFn(ctx, filter)
+}
+
+func (s *OrderService) Checkout(ctx context.Context, checkout ocs.Checkout) (*ocs.Order, error) {
+	return s.CheckoutFn(ctx, checkout)
+}
+
+func (s *OrderService) CompletePayment(ctx context.Context, n *ocs.PaymentNotification) (*ocs.Order, error) {
+	return s.CompletePaymentFn(ctx, n)
+}
+
+func (s *OrderService) FindCoupons(ctx context.Context, filter ocs.CouponFilter) ([]*ocs.Coupon, int, error) {
+	return s.FindCouponsFn(ctx, filter)
+}
+
+func (s *OrderService) CreateCoupon(ctx context.Context, coupon *ocs.Coupon) error {
+	return s.CreateCouponFn(ctx, coupon)
+}
+
+func (s *OrderService) DeleteCoupon(ctx context.Context, id int) error {
+	return s.DeleteCouponFn(ctx, id)
+}
+
+var _ ocs.PaymentProvider = (*PaymentProvider)(nil)
+
+type PaymentProvider struct {
+	CreatePaymentFn func(ctx context.Context, order *ocs.Order) (*ocs.Payment, error)
+	RefundPaymentFn func(ctx context.Context, order *ocs.Order, refund *ocs.Refund) error
+	VerifyWebhookFn func(payload []byte, signature string) (*ocs.PaymentNotification, error)
+}
+
+func (p *PaymentProvider) CreatePayment(ctx context.Context, order *ocs.Order) (*ocs.Payment, error) {
+	return p.CreatePaymentFn(ctx, order)
+}
+
+func (p *PaymentProvider) RefundPayment(ctx context.Context, order *ocs.Order, refund *ocs.Refund) error {
+	return p.RefundPaymentFn(ctx, order, refund)
+}
+
+func (p *PaymentProvider) VerifyWebhook(payload []byte, signature string) (*ocs.PaymentNotification, error) {
+	return p.VerifyWebhookFn(payload, signature)
+}
@@ -0,0 +1,42 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.OrganizationService = (*OrganizationService)(nil)
+
+type OrganizationService struct {
+	FindOrganizationByIDFn     func(ctx context.Context, id int) (*ocs.Organization, error)
+	FindOrganizationsFn        func(ctx context.Context, filter ocs.OrganizationFilter) ([]*ocs.Organization, int, error)
+	CreateOrganizationFn       func(ctx context.Context, org *ocs.Organization) error
+	FindOrganizationMembersFn  func(ctx context.Context, filter ocs.OrganizationMemberFilter) ([]*ocs.OrganizationMember, int, error)
+	CreateOrganizationMemberFn func(ctx context.Context, member *ocs.OrganizationMember) error
+	DeleteOrganizationMemberFn func(ctx context.Context, id int) error
+}
+
+func (s *OrganizationService) FindOrganizationByID(ctx context.Context, id int) (*ocs.Organization, error) {
+	return s.FindOrganizationByIDFn(ctx, id)
+}
+
+func (s *OrganizationService) FindOrganizations(ctx context.Context, filter ocs.OrganizationFilter) ([]*ocs.Organization, int, error) {
+	return s.FindOrganizationsFn(ctx, filter)
+}
+
+func (s *OrganizationService) CreateOrganization(ctx context.Context, org *ocs.Organization) error {
+	return s.CreateOrganizationFn(ctx, org)
+}
+
+func (s *OrganizationService) FindOrganizationMembers(ctx context.Context, filter ocs.OrganizationMemberFilter) ([]*ocs.OrganizationMember, int, error) {
+	return s.FindOrganizationMembersFn(ctx, filter)
+}
+
+func (s *OrganizationService) CreateOrganizationMember(ctx context.Context, member *ocs.OrganizationMember) error {
+	return s.CreateOrganizationMemberFn(ctx, member)
+}
+
+func (s *OrganizationService) DeleteOrganizationMember(ctx context.Context, id int) error {
+	return s.DeleteOrganizationMemberFn(ctx, id)
+}
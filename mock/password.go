@@ -0,0 +1,37 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.PasswordService = (*PasswordService)(nil)
+
+type PasswordService struct {
+	SignUpFn               func(ctx context.Context, student *ocs.Student, password string) error
+	LoginFn                func(ctx context.Context, email, password string) (*ocs.Student, error)
+	ChangePasswordFn       func(ctx context.Context, current, password string) error
+	RequestPasswordResetFn func(ctx context.Context, email string) error
+	ResetPasswordFn        func(ctx context.Context, token, password string) error
+}
+
+func (s *PasswordService) SignUp(ctx context.Context, student *ocs.Student, password string) error {
+	return s.SignUpFn(ctx, student, password)
+}
+
+func (s *PasswordService) Login(ctx context.Context, email, password string) (*ocs.Student, error) {
+	return s.LoginFn(ctx, email, password)
+}
+
+func (s *PasswordService) ChangePassword(ctx context.Context, current, password string) error {
+	return s.ChangePasswordFn(ctx, current, password)
+}
+
+func (s *PasswordService) RequestPasswordReset(ctx context.Context, email string) error {
+	return s.RequestPasswordResetFn(ctx, email)
+}
+
+func (s *PasswordService) ResetPassword(ctx context.Context, token, password string) error {
+	return s.ResetPasswordFn(ctx, token, password)
+}
@@ -0,0 +1,27 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.RefundService = (*RefundService)(nil)
+
+type RefundService struct {
+	FindRefundsFn   func(ctx context.Context, filter ocs.RefundFilter) ([]*ocs.Refund, int, error)
+	RequestRefundFn func(ctx context.Context, orderID int, reason string) (*ocs.Refund, error)
+	IssueRefundFn   func(ctx context.Context, refund *ocs.Refund) error
+}
+
+func (s *RefundService) FindRefunds(ctx context.Context, filter ocs.RefundFilter) ([]*ocs.Refund, int, error) {
+	return s.FindRefundsFn(ctx, filter)
+}
+
+func (s *RefundService) RequestRefund(ctx context.Context, orderID int, reason string) (*ocs.Refund, error) {
+	return s.RequestRefundFn(ctx, orderID, reason)
+}
+
+func (s *RefundService) IssueRefund(ctx context.Context, refund *ocs.Refund) error {
+	return s.IssueRefundFn(ctx, refund)
+}
@@ -0,0 +1,32 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.RegradeService = (*RegradeService)(nil)
+
+type RegradeService struct {
+	FindRegradeRequestByIDFn func(ctx context.Context, id int) (*ocs.RegradeRequest, error)
+	FindRegradeRequestsFn    func(ctx context.Context, filter ocs.RegradeRequestFilter) ([]*ocs.RegradeRequest, int, error)
+	CreateRegradeRequestFn   func(ctx context.Context, req *ocs.RegradeRequest) error
+	ResolveRegradeRequestFn  func(ctx context.Context, id int, res ocs.RegradeResolution) (*ocs.RegradeRequest, error)
+}
+
+func (s *RegradeService) FindRegradeRequestByID(ctx context.Context, id int) (*ocs.RegradeRequest, error) {
+	return s.FindRegradeRequestByIDFn(ctx, id)
+}
+
+func (s *RegradeService) FindRegradeRequests(ctx context.Context, filter ocs.RegradeRequestFilter) ([]*ocs.RegradeRequest, int, error) {
+	return s.FindRegradeRequestsFn(ctx, filter)
+}
+
+func (s *RegradeService) CreateRegradeRequest(ctx context.Context, req *ocs.RegradeRequest) error {
+	return s.CreateRegradeRequestFn(ctx, req)
+}
+
+func (s *RegradeService) ResolveRegradeRequest(ctx context.Context, id int, res ocs.RegradeResolution) (*ocs.RegradeRequest, error) {
+	return s.ResolveRegradeRequestFn(ctx, id, res)
+}
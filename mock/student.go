@@ -12,26 +12,37 @@ type StudentService struct {
 	FindStudentByIDFn func(ctx context.Context, id int) (*ocs.Student, error)
 	FindStudentsFn    func(ctx context.Context, filter ocs.StudentFilter) ([]*ocs.Student, int, error)
 	CreateStudentFn   func(ctx context.Context, student *ocs.Student) error
-	UpdateStudentFn   func(ctx context.Context, id int, upd *ocs.StudentUpdate) (*ocs.Student, error)
+	UpdateStudentFn   func(ctx context.Context, id int, upd ocs.StudentUpdate) (*ocs.Student, error)
 	DeleteStudentFn   func(ctx context.Context, id int) error
+	RestoreStudentFn  func(ctx context.Context, id int) (*ocs.Student, error)
+
+	FindStudentProfileFn func(ctx context.Context, id int) (*ocs.StudentProfile, error)
 }
 
 func (s *StudentService) FindStudentByID(ctx context.Context, id int) (*ocs.Student, error) {
-	return s.FindStudentByID(ctx, id)
+	return s.FindStudentByIDFn(ctx, id)
 }
 
 func (s *StudentService) FindStudents(ctx context.Context, filter ocs.StudentFilter) ([]*ocs.Student, int, error) {
-	return s.FindStudents(ctx, filter)
+	return s.FindStudentsFn(ctx, filter)
 }
 
 func (s *StudentService) CreateStudent(ctx context.Context, student *ocs.Student) error {
-	return s.CreateStudent(ctx, student)
+	return s.CreateStudentFn(ctx, student)
 }
 
 func (s *StudentService) UpdateStudent(ctx context.Context, id int, upd ocs.StudentUpdate) (*ocs.Student, error) {
-	return s.UpdateStudent(ctx, id, upd)
+	return s.UpdateStudentFn(ctx, id, upd)
 }
 
 func (s *StudentService) DeleteStudent(ctx context.Context, id int) error {
-	return s.DeleteStudent(ctx, id)
+	return s.DeleteStudentFn(ctx, id)
+}
+
+func (s *StudentService) RestoreStudent(ctx context.Context, id int) (*ocs.Student, error) {
+	return s.RestoreStudentFn(ctx, id)
+}
+
+func (s *StudentService) FindStudentProfile(ctx context.Context, id int) (*ocs.StudentProfile, error) {
+	return s.FindStudentProfileFn(ctx, id)
 }
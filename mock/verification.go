@@ -0,0 +1,22 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.EmailVerificationService = (*EmailVerificationService)(nil)
+
+type EmailVerificationService struct {
+	SendEmailVerificationFn func(ctx context.Context) error
+	VerifyEmailFn           func(ctx context.Context, token string) (*ocs.Student, error)
+}
+
+func (s *EmailVerificationService) SendEmailVerification(ctx context.Context) error {
+	return s.SendEmailVerificationFn(ctx)
+}
+
+func (s *EmailVerificationService) VerifyEmail(ctx context.Context, token string) (*ocs.Student, error) {
+	return s.VerifyEmailFn(ctx, token)
+}
@@ -0,0 +1,47 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.WebhookService = (*WebhookService)(nil)
+
+type WebhookService struct {
+	FindWebhookByIDFn          func(ctx context.Context, id int) (*ocs.Webhook, error)
+	FindWebhooksFn             func(ctx context.Context, filter ocs.WebhookFilter) ([]*ocs.Webhook, int, error)
+	CreateWebhookFn            func(ctx context.Context, webhook *ocs.Webhook) error
+	UpdateWebhookFn            func(ctx context.Context, id int, upd ocs.WebhookUpdate) (*ocs.Webhook, error)
+	DeleteWebhookFn            func(ctx context.Context, id int) error
+	FindWebhookDeliveriesFn    func(ctx context.Context, filter ocs.WebhookDeliveryFilter) ([]*ocs.WebhookDelivery, int, error)
+	RedeliverWebhookDeliveryFn func(ctx context.Context, id int) (*ocs.WebhookDelivery, error)
+}
+
+func (s *WebhookService) FindWebhookByID(ctx context.Context, id int) (*ocs.Webhook, error) {
+	return s.FindWebhookByIDFn(ctx, id)
+}
+
+func (s *WebhookService) FindWebhooks(ctx context.Context, filter ocs.WebhookFilter) ([]*ocs.Webhook, int, error) {
+	return s.FindWebhooksFn(ctx, filter)
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, webhook *ocs.Webhook) error {
+	return s.CreateWebhookFn(ctx, webhook)
+}
+
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id int, upd ocs.WebhookUpdate) (*ocs.Webhook, error) {
+	return s.UpdateWebhookFn(ctx, id, upd)
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id int) error {
+	return s.DeleteWebhookFn(ctx, id)
+}
+
+func (s *WebhookService) FindWebhookDeliveries(ctx context.Context, filter ocs.WebhookDeliveryFilter) ([]*ocs.WebhookDelivery, int, error) {
+	return s.FindWebhookDeliveriesFn(ctx, filter)
+}
+
+func (s *WebhookService) RedeliverWebhookDelivery(ctx context.Context, id int) (*ocs.WebhookDelivery, error) {
+	return s.RedeliverWebhookDeliveryFn(ctx, id)
+}
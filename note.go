@@ -0,0 +1,85 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+// Note is a private note a student keeps on a lesson. It may be anchored to
+// a range of the lesson body, given in characters, or to a point in the
+// lesson video, given in seconds.
+type Note struct {
+	ID             int       `json:"id"`
+	StudentID      int       `json:"studentID"`
+	LessonID       int       `json:"lessonID"`
+	Lesson         *Lesson   `json:"lesson"`
+	Body           string    `json:"body"`
+	AnchorStart    *int      `json:"anchorStart"`
+	AnchorEnd      *int      `json:"anchorEnd"`
+	VideoTimestamp *int      `json:"videoTimestamp"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+func (n *Note) Validate() error {
+	if n.StudentID == 0 {
+		return Errorf(EINVALID, "Student required.")
+	} else if n.LessonID == 0 {
+		return Errorf(EINVALID, "Lesson required.")
+	} else if n.Body == "" {
+		return Errorf(EINVALID, "Body required.")
+	} else if (n.AnchorStart == nil) != (n.AnchorEnd == nil) {
+		return Errorf(EINVALID, "Anchor requires both a start and an end.")
+	} else if n.AnchorStart != nil && (*n.AnchorStart < 0 || *n.AnchorEnd <= *n.AnchorStart) {
+		return Errorf(EINVALID, "Invalid anchor range.")
+	} else if n.VideoTimestamp != nil && *n.VideoTimestamp < 0 {
+		return Errorf(EINVALID, "Invalid video timestamp.")
+	}
+	return nil
+}
+
+type Bookmark struct {
+	ID        int       `json:"id"`
+	StudentID int       `json:"studentID"`
+	LessonID  int       `json:"lessonID"`
+	Lesson    *Lesson   `json:"lesson"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NoteService manages a student's notes and bookmarks. Every method is scoped
+// to the student in the context; other students' notes are never visible.
+type NoteService interface {
+	FindNoteByID(ctx context.Context, id int) (*Note, error)
+	FindNotes(ctx context.Context, filter NoteFilter) ([]*Note, int, error)
+	CreateNote(ctx context.Context, note *Note) error
+	UpdateNote(ctx context.Context, id int, upd NoteUpdate) (*Note, error)
+	DeleteNote(ctx context.Context, id int) error
+
+	FindBookmarks(ctx context.Context, filter BookmarkFilter) ([]*Bookmark, int, error)
+	CreateBookmark(ctx context.Context, bookmark *Bookmark) error
+	DeleteBookmark(ctx context.Context, id int) error
+}
+
+type NoteFilter struct {
+	ID       *int `json:"id"`
+	LessonID *int `json:"lessonID"`
+	CourseID *int `json:"courseID"`
+
+	// Query restricts results to notes matching all of its words.
+	Query *string `json:"query"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+type NoteUpdate struct {
+	Body *string `json:"body"`
+}
+
+type BookmarkFilter struct {
+	ID       *int `json:"id"`
+	LessonID *int `json:"lessonID"`
+	CourseID *int `json:"courseID"`
+	Offset   int  `json:"offset"`
+	Limit    int  `json:"limit"`
+}
@@ -0,0 +1,79 @@
+package ocs
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// DefaultNotificationTypes are the events kept in a student's inbox. Other
+// events, such as leaderboard changes, are only streamed live.
+var DefaultNotificationTypes = []string{
+	EventTypeRegradeRequestChanged,
+	EventTypeLearningPathCompleted,
+	EventTypeSubmissionGraded,
+	EventTypeBadgeEarned,
+	EventTypeEnrollmentCompleted,
+	EventTypeOrderPaid,
+	EventTypeRefundIssued,
+	EventTypeMessageCreated,
+	EventTypeDeadlineReminder,
+	EventTypeExportReady,
+	EventTypeWebhookDisabled,
+}
+
+// Notification is an event kept in a student's inbox so it is not lost
+// while they are offline. Payload is the event's payload as published.
+type Notification struct {
+	ID        int             `json:"id"`
+	StudentID int             `json:"studentID"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	ReadAt    *time.Time      `json:"readAt"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// Summary returns a one-line description of the notification for emails.
+func (n *Notification) Summary() string {
+	switch n.Type {
+	case EventTypeRegradeRequestChanged:
+		return "A regrade request was updated."
+	case EventTypeLearningPathCompleted:
+		return "You completed a learning path."
+	case EventTypeSubmissionGraded:
+		return "A submission was graded."
+	case EventTypeBadgeEarned:
+		return "You earned a badge."
+	case EventTypeEnrollmentCompleted:
+		return "You completed a course."
+	case EventTypeOrderPaid:
+		return "Your order was paid."
+	case EventTypeRefundIssued:
+		return "A refund was issued."
+	case EventTypeMessageCreated:
+		return "You have a new message."
+	case EventTypeDeadlineReminder:
+		return "An assignment is due soon."
+	case EventTypeExportReady:
+		return "Your data export is ready to download."
+	case EventTypeWebhookDisabled:
+		return "A webhook was disabled after failing repeatedly."
+	}
+	return n.Type
+}
+
+// NotificationService manages the current student's inbox. Changes to the
+// number of unread notifications are published as
+// EventTypeNotificationUnreadCount events.
+type NotificationService interface {
+	FindNotifications(ctx context.Context, filter NotificationFilter) ([]*Notification, int, error)
+	MarkNotificationRead(ctx context.Context, id int) (*Notification, error)
+	MarkAllNotificationsRead(ctx context.Context) error
+	CountUnreadNotifications(ctx context.Context) (int, error)
+}
+
+type NotificationFilter struct {
+	Unread bool `json:"unread"`
+	Offset int  `json:"offset"`
+	Limit  int  `json:"limit"`
+}
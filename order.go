@@ -0,0 +1,166 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	OrderStatusPending           = "pending"
+	OrderStatusPaid              = "paid"
+	OrderStatusFailed            = "failed"
+	OrderStatusPartiallyRefunded = "partially_refunded"
+	OrderStatusRefunded          = "refunded"
+)
+
+// Order is a student's purchase of a course. Amounts are in the smallest
+// unit of Currency. Once paid, EnrollmentID refers to the enrollment that
+// the payment created. Refunded is the sum of the order's refunds that were
+// issued or are pending.
+type Order struct {
+	ID           int        `json:"id"`
+	StudentID    int        `json:"studentID"`
+	CourseID     int        `json:"courseID"`
+	Course       *Course    `json:"course"`
+	CouponID     *int       `json:"couponID"`
+	Subtotal     int        `json:"subtotal"`
+	Discount     int        `json:"discount"`
+	Total        int        `json:"total"`
+	Currency     string     `json:"currency"`
+	Status       string     `json:"status"`
+	Refunded     int        `json:"refunded"`
+	ProviderRef  string     `json:"providerRef"`
+	PaymentURL   string     `json:"paymentURL"`
+	EnrollmentID *int       `json:"enrollmentID"`
+	PaidAt       *time.Time `json:"paidAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+const (
+	CouponKindPercent = "percent"
+	CouponKindFixed   = "fixed"
+)
+
+// Coupon discounts a course by a percentage or a fixed amount. Codes are
+// unique within a course. Uses counts paid orders and orders awaiting
+// payment; a zero MaxUses allows unlimited uses.
+type Coupon struct {
+	ID        int        `json:"id"`
+	CourseID  int        `json:"courseID"`
+	Code      string     `json:"code"`
+	Kind      string     `json:"kind"`
+	Amount    int        `json:"amount"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	MaxUses   int        `json:"maxUses"`
+	Uses      int        `json:"uses"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+func (c *Coupon) Validate() error {
+	if c.CourseID == 0 {
+		return Errorf(EINVALID, "Course required.")
+	} else if c.Code == "" {
+		return Errorf(EINVALID, "Code required.")
+	} else if c.MaxUses < 0 {
+		return Errorf(EINVALID, "Max uses must not be negative.")
+	}
+
+	switch c.Kind {
+	case CouponKindPercent:
+		if c.Amount < 1 || c.Amount > 100 {
+			return Errorf(EINVALID, "Percentage must be between 1 and 100.")
+		}
+	case CouponKindFixed:
+		if c.Amount < 1 {
+			return Errorf(EINVALID, "Amount must be positive.")
+		}
+	default:
+		return Errorf(EINVALID, "Invalid coupon kind.")
+	}
+	return nil
+}
+
+// Discount returns the amount the coupon takes off price, never more than price.
+func (c *Coupon) Discount(price int) int {
+	discount := c.Amount
+	if c.Kind == CouponKindPercent {
+		discount = price * c.Amount / 100
+	}
+	return min(discount, price)
+}
+
+// Checkout is a student's request to buy a course.
+type Checkout struct {
+	CourseID   int    `json:"courseID"`
+	CouponCode string `json:"couponCode"`
+}
+
+const (
+	PaymentStatusSucceeded = "succeeded"
+	PaymentStatusFailed    = "failed"
+)
+
+// Payment is a payment started with a provider. The student completes it
+// by visiting URL.
+type Payment struct {
+	ProviderRef string `json:"providerRef"`
+	URL         string `json:"url"`
+}
+
+// PaymentNotification is a provider's callback about the outcome of a payment.
+type PaymentNotification struct {
+	ProviderRef string `json:"providerRef"`
+	Status      string `json:"status"`
+}
+
+// PaymentProvider takes payments for orders.
+type PaymentProvider interface {
+	CreatePayment(ctx context.Context, order *Order) (*Payment, error)
+
+	// RefundPayment returns refund.Amount of the order's payment to the
+	// student. The refund's ID identifies it to the provider, which must not
+	// return the money twice for the same ID, so that refunds can be retried.
+	RefundPayment(ctx context.Context, order *Order, refund *Refund) error
+
+	// VerifyWebhook checks the signature of a callback payload and returns
+	// the notification it carries. Returns EUNAUTHORIZED if the signature
+	// does not match.
+	VerifyWebhook(payload []byte, signature string) (*PaymentNotification, error)
+}
+
+type OrderService interface {
+	FindOrderByID(ctx context.Context, id int) (*Order, error)
+	FindOrders(ctx context.Context, filter OrderFilter) ([]*Order, int, error)
+
+	// Checkout creates a pending order for the current student and starts
+	// its payment. An order discounted to nothing is paid immediately. The
+	// student's email must be verified.
+	Checkout(ctx context.Context, checkout Checkout) (*Order, error)
+
+	// CompletePayment applies a verified payment notification to its
+	// order. A successful payment enrolls the student in the course.
+	// Repeated notifications are ignored.
+	CompletePayment(ctx context.Context, n *PaymentNotification) (*Order, error)
+
+	FindCoupons(ctx context.Context, filter CouponFilter) ([]*Coupon, int, error)
+	CreateCoupon(ctx context.Context, coupon *Coupon) error
+	DeleteCoupon(ctx context.Context, id int) error
+}
+
+type OrderFilter struct {
+	ID       *int    `json:"id"`
+	CourseID *int    `json:"courseID"`
+	Status   *string `json:"status"`
+	Offset   int     `json:"offset"`
+	Limit    int     `json:"limit"`
+}
+
+type CouponFilter struct {
+	ID       *int    `json:"id"`
+	CourseID *int    `json:"courseID"`
+	Code     *string `json:"code"`
+	Offset   int     `json:"offset"`
+	Limit    int     `json:"limit"`
+}
@@ -0,0 +1,96 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	OrganizationRoleOwner  = "owner"
+	OrganizationRoleMember = "member"
+)
+
+// Organization is a tenant with its own catalog of courses and roster of
+// students. Requests are resolved to an organization by Domain or by Slug.
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	Domain    string    `json:"domain"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (o *Organization) Validate() error {
+	if o.Name == "" {
+		return Errorf(EINVALID, "Name required.")
+	} else if o.Slug == "" {
+		return Errorf(EINVALID, "Slug required.")
+	}
+	return nil
+}
+
+// OrganizationMember grants a student access to an organization. Owners
+// manage the organization's members. When adding a member, the student may
+// be given by Email instead of StudentID.
+type OrganizationMember struct {
+	ID             int       `json:"id"`
+	OrganizationID int       `json:"organizationID"`
+	StudentID      int       `json:"studentID"`
+	Email          string    `json:"email"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+func (m *OrganizationMember) Validate() error {
+	if m.OrganizationID == 0 {
+		return Errorf(EINVALID, "Organization required.")
+	} else if m.StudentID == 0 {
+		return Errorf(EINVALID, "Student required.")
+	}
+
+	switch m.Role {
+	case OrganizationRoleOwner, OrganizationRoleMember:
+		return nil
+	default:
+		return Errorf(EINVALID, "Invalid organization role.")
+	}
+}
+
+type OrganizationService interface {
+	FindOrganizationByID(ctx context.Context, id int) (*Organization, error)
+	FindOrganizations(ctx context.Context, filter OrganizationFilter) ([]*Organization, int, error)
+
+	// CreateOrganization creates an organization owned by the current
+	// student. Only admins may create organizations.
+	CreateOrganization(ctx context.Context, org *Organization) error
+
+	// FindOrganizationMembers returns the members of organizations the
+	// current student belongs to.
+	FindOrganizationMembers(ctx context.Context, filter OrganizationMemberFilter) ([]*OrganizationMember, int, error)
+
+	// CreateOrganizationMember adds a student to an organization. Only
+	// owners of the organization may add members.
+	CreateOrganizationMember(ctx context.Context, member *OrganizationMember) error
+	DeleteOrganizationMember(ctx context.Context, id int) error
+}
+
+type OrganizationFilter struct {
+	ID     *int    `json:"id"`
+	Slug   *string `json:"slug"`
+	Domain *string `json:"domain"`
+
+	// Restricts results to organizations the student is a member of.
+	StudentID *int `json:"studentID"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+type OrganizationMemberFilter struct {
+	ID             *int `json:"id"`
+	OrganizationID *int `json:"organizationID"`
+	StudentID      *int `json:"studentID"`
+	Offset         int  `json:"offset"`
+	Limit          int  `json:"limit"`
+}
@@ -0,0 +1,99 @@
+package ocs
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits on passwords. The maximum keeps hashing cheap enough that long
+// passwords cannot be used to tie up the server.
+const (
+	MinPasswordLen = 10
+	MaxPasswordLen = 128
+)
+
+// commonPasswords are rejected even though they are long enough.
+var commonPasswords = map[string]struct{}{
+	"1234567890":   {},
+	"12345678910":  {},
+	"0987654321":   {},
+	"1q2w3e4r5t":   {},
+	"password1":    {},
+	"password12":   {},
+	"password123":  {},
+	"password1234": {},
+	"passw0rd123":  {},
+	"qwertyuiop":   {},
+	"qwerty12345":  {},
+	"qwerty123456": {},
+	"iloveyou123":  {},
+	"letmein123":   {},
+	"welcome123":   {},
+	"abcdefghij":   {},
+	"asdfghjkl1":   {},
+	"zaq12wsxcde":  {},
+	"football123":  {},
+	"monkey12345":  {},
+}
+
+// ValidatePassword checks a password against the password policy. It must
+// be between MinPasswordLen and MaxPasswordLen characters, not be a common
+// password, use more than a few distinct characters, and not contain the
+// student's name or the name part of their email.
+func ValidatePassword(password string, student *Student) error {
+	if n := utf8.RuneCountInString(password); n < MinPasswordLen {
+		return Errorf(EINVALID, "Password must be at least %d characters.", MinPasswordLen)
+	} else if n > MaxPasswordLen {
+		return Errorf(EINVALID, "Password must be at most %d characters.", MaxPasswordLen)
+	}
+
+	lower := strings.ToLower(password)
+	if _, ok := commonPasswords[lower]; ok {
+		return Errorf(EINVALID, "This password is too common.")
+	}
+
+	distinct := make(map[rune]struct{})
+	for _, r := range lower {
+		distinct[r] = struct{}{}
+	}
+	if len(distinct) < 5 {
+		return Errorf(EINVALID, "Password must use more than a few different characters.")
+	}
+
+	if student != nil {
+		local, _, _ := strings.Cut(student.Email, "@")
+		for _, s := range append(strings.Fields(student.Name), local) {
+			if s = strings.ToLower(s); len(s) >= 3 && strings.Contains(lower, s) {
+				return Errorf(EINVALID, "Password must not contain your name or email.")
+			}
+		}
+	}
+	return nil
+}
+
+// PasswordService manages local accounts, whose students log in with their
+// email and a password. A student may have a password and linked Auth
+// providers at the same time.
+type PasswordService interface {
+	// SignUp creates a student who logs in with a password. Their email is
+	// not verified until they follow the link emailed to them.
+	SignUp(ctx context.Context, student *Student, password string) error
+
+	// Login returns the student with the given email and password.
+	Login(ctx context.Context, email, password string) (*Student, error)
+
+	// ChangePassword sets the current student's password. Students who
+	// already have a password must give it as current.
+	ChangePassword(ctx context.Context, current, password string) error
+
+	// RequestPasswordReset emails a reset link to the student with the given
+	// email. It succeeds whether or not there is such a student, so that it
+	// cannot be used to find out who has an account.
+	RequestPasswordReset(ctx context.Context, email string) error
+
+	// ResetPassword sets a new password given the token from a reset link.
+	// Expired tokens return EEXPIRED and tokens that were already used
+	// return ECONFLICT.
+	ResetPassword(ctx context.Context, token, password string) error
+}
@@ -0,0 +1,97 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// The refund was recorded but the provider has yet to confirm it.
+	RefundStatusPending = "pending"
+
+	// The provider returned the money.
+	RefundStatusIssued = "issued"
+
+	// The provider refused the refund; no money was returned.
+	RefundStatusFailed = "failed"
+)
+
+const (
+	// The enrollment was removed by a full refund.
+	RefundAccessRevoked = "revoked"
+
+	// The enrollment was downgraded to auditing by a partial refund.
+	RefundAccessDowngraded = "downgraded"
+)
+
+// Refund is an entry in the append-only refund ledger. An entry is recorded
+// as pending before the provider is asked for the money, then settled as
+// issued or failed. Settled entries are never changed or removed, so the
+// ledger keeps the course and amounts even after the order is gone.
+type Refund struct {
+	ID         int       `json:"id"`
+	OrderID    int       `json:"orderID"`
+	StudentID  int       `json:"studentID"`
+	CourseID   int       `json:"courseID"`
+	Amount     int       `json:"amount"`
+	Currency   string    `json:"currency"`
+	Reason     string    `json:"reason"`
+	Access     string    `json:"access"`
+	Status     string    `json:"status"`
+	IssuedByID int       `json:"issuedByID"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func (r *Refund) Validate() error {
+	if r.OrderID == 0 {
+		return Errorf(EINVALID, "Order required.")
+	} else if r.Amount < 0 {
+		return Errorf(EINVALID, "Amount must not be negative.")
+	} else if r.Reason == "" {
+		return Errorf(EINVALID, "Reason required.")
+	}
+	return nil
+}
+
+// RefundPolicy decides when students may refund an order themselves. An
+// order is eligible while it is within Window of being paid or while the
+// student has submitted less than MaxProgress of the course's assignments.
+type RefundPolicy struct {
+	Window      time.Duration
+	MaxProgress float64
+}
+
+// DefaultRefundPolicy allows refunds for two weeks, or until a tenth of the
+// course is done.
+var DefaultRefundPolicy = RefundPolicy{
+	Window:      14 * 24 * time.Hour,
+	MaxProgress: 0.1,
+}
+
+// Eligible reports whether an order paid at paidAt with the given progress,
+// between 0 and 1, may be refunded at now.
+func (p *RefundPolicy) Eligible(paidAt time.Time, progress float64, now time.Time) bool {
+	return now.Before(paidAt.Add(p.Window)) || progress < p.MaxProgress
+}
+
+type RefundService interface {
+	// FindRefunds returns refunds of the current student's orders, of
+	// courses they teach, or every refund for admins.
+	FindRefunds(ctx context.Context, filter RefundFilter) ([]*Refund, int, error)
+
+	// RequestRefund fully refunds one of the current student's orders if
+	// the refund policy allows it.
+	RequestRefund(ctx context.Context, orderID int, reason string) (*Refund, error)
+
+	// IssueRefund refunds an order regardless of the refund policy. A zero
+	// amount refunds whatever is left of the order. Only admins may issue
+	// refunds.
+	IssueRefund(ctx context.Context, refund *Refund) error
+}
+
+type RefundFilter struct {
+	OrderID  *int `json:"orderID"`
+	CourseID *int `json:"courseID"`
+	Offset   int  `json:"offset"`
+	Limit    int  `json:"limit"`
+}
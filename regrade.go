@@ -0,0 +1,87 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	RegradeStatusOpen     = "open"
+	RegradeStatusAccepted = "accepted"
+	RegradeStatusRejected = "rejected"
+)
+
+// RegradeRequest is a student's request to have a graded submission
+// reviewed again by the course staff.
+type RegradeRequest struct {
+	ID            int                  `json:"id"`
+	SubmissionID  int                  `json:"submissionID"`
+	Submission    *Submission          `json:"submission"`
+	CourseID      int                  `json:"courseID"`
+	StudentID     int                  `json:"studentID"`
+	Justification string               `json:"justification"`
+	Status        string               `json:"status"`
+	Reply         string               `json:"reply"`
+	ReviewerID    int                  `json:"reviewerID"`
+	Transitions   []*RegradeTransition `json:"transitions"`
+	CreatedAt     time.Time            `json:"createdAt"`
+	UpdatedAt     time.Time            `json:"updatedAt"`
+}
+
+func (r *RegradeRequest) Validate() error {
+	if r.SubmissionID == 0 {
+		return Errorf(EINVALID, "Submission required.")
+	} else if r.StudentID == 0 {
+		return Errorf(EINVALID, "Student required.")
+	} else if r.Justification == "" {
+		return Errorf(EINVALID, "Justification required.")
+	}
+	return nil
+}
+
+// RegradeTransition records a single status change of a regrade request.
+type RegradeTransition struct {
+	ID               int       `json:"id"`
+	RegradeRequestID int       `json:"regradeRequestID"`
+	ActorID          int       `json:"actorID"`
+	FromStatus       string    `json:"fromStatus"`
+	ToStatus         string    `json:"toStatus"`
+	Note             string    `json:"note"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+type RegradeService interface {
+	FindRegradeRequestByID(ctx context.Context, id int) (*RegradeRequest, error)
+	FindRegradeRequests(ctx context.Context, filter RegradeRequestFilter) ([]*RegradeRequest, int, error)
+	CreateRegradeRequest(ctx context.Context, req *RegradeRequest) error
+	ResolveRegradeRequest(ctx context.Context, id int, res RegradeResolution) (*RegradeRequest, error)
+}
+
+type RegradeRequestFilter struct {
+	ID           *int    `json:"id"`
+	CourseID     *int    `json:"courseID"`
+	StudentID    *int    `json:"studentID"`
+	SubmissionID *int    `json:"submissionID"`
+	Status       *string `json:"status"`
+	Offset       int     `json:"offset"`
+	Limit        int     `json:"limit"`
+}
+
+// RegradeResolution moves an open regrade request to accepted or rejected.
+// Grade, if set on an accepted request, replaces the submission's grade.
+type RegradeResolution struct {
+	Status string `json:"status"`
+	Reply  string `json:"reply"`
+	Grade  *int   `json:"grade"`
+}
+
+func (r *RegradeResolution) Validate() error {
+	if r.Status != RegradeStatusAccepted && r.Status != RegradeStatusRejected {
+		return Errorf(EINVALID, "Status must be accepted or rejected.")
+	} else if r.Reply == "" {
+		return Errorf(EINVALID, "Reply required.")
+	} else if r.Grade != nil && r.Status != RegradeStatusAccepted {
+		return Errorf(EINVALID, "Grade can only be changed on an accepted request.")
+	}
+	return nil
+}
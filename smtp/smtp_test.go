@@ -0,0 +1,101 @@
+package smtp_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"testing"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/inmem"
+	"github.com/maliByatzes/ocs/smtp"
+)
+
+func TestSender_SendEmail(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		server := MustOpenSMTPServer(t)
+		s := smtp.NewSender(server.Addr, "noreply@ocs.test")
+
+		if err := s.SendEmail(context.Background(), &ocs.Email{
+			To:      "ann@email.com",
+			Subject: "Héllo",
+			Text:    "Hi Ann",
+			HTML:    "<p>Hi Ann</p>",
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		messages := server.Messages()
+		if got, want := len(messages), 1; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		} else if got, want := messages[0].To, []string{"ann@email.com"}; len(got) != 1 || got[0] != want[0] {
+			t.Fatalf("To=%v, want %v", got, want)
+		}
+
+		msg, err := mail.ReadMessage(bytes.NewReader(messages[0].Data))
+		if err != nil {
+			t.Fatal(err)
+		} else if subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err != nil {
+			t.Fatal(err)
+		} else if got, want := subject, "Héllo"; got != want {
+			t.Fatalf("Subject=%v, want %v", got, want)
+		}
+
+		_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var parts []string
+		mr := multipart.NewReader(msg.Body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			buf, err := io.ReadAll(quotedprintable.NewReader(part))
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts = append(parts, part.Header.Get("Content-Type")+": "+string(buf))
+		}
+
+		if got, want := len(parts), 2; got != want {
+			t.Fatalf("len(parts)=%v, want %v", got, want)
+		} else if got, want := parts[0], "text/plain; charset=utf-8: Hi Ann"; got != want {
+			t.Fatalf("parts[0]=%v, want %v", got, want)
+		} else if got, want := parts[1], "text/html; charset=utf-8: <p>Hi Ann</p>"; got != want {
+			t.Fatalf("parts[1]=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrRejected", func(t *testing.T) {
+		server := MustOpenSMTPServer(t)
+		server.FailNext(1)
+		s := smtp.NewSender(server.Addr, "noreply@ocs.test")
+
+		if err := s.SendEmail(context.Background(), &ocs.Email{To: "ann@email.com"}); err == nil {
+			t.Fatal("expected error")
+		} else if got, want := len(server.Messages()), 0; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		}
+	})
+}
+
+// MustOpenSMTPServer returns an in-process SMTP server that is closed when
+// the test ends.
+func MustOpenSMTPServer(tb testing.TB) *inmem.SMTPServer {
+	tb.Helper()
+	server := inmem.NewSMTPServer()
+	if err := server.Open(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { server.Close() })
+	return server
+}
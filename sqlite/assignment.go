@@ -0,0 +1,609 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.AssignmentService = (*AssignmentService)(nil)
+
+type AssignmentService struct {
+	db *DB
+}
+
+func NewAssignmentService(db *DB) *AssignmentService {
+	return &AssignmentService{db: db}
+}
+
+func (s *AssignmentService) FindAssignmentByID(ctx context.Context, id int) (*ocs.Assignment, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	assignment, err := findAssignmentByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachAssignmentAssociations(ctx, tx, assignment); err != nil {
+		return nil, err
+	}
+
+	return assignment, nil
+}
+
+func (s *AssignmentService) FindAssignments(ctx context.Context, filter ocs.AssignmentFilter) ([]*ocs.Assignment, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	assignments, n, err := findAssignments(ctx, tx, filter)
+	if err != nil {
+		return assignments, n, err
+	}
+
+	for _, assignment := range assignments {
+		if err := attachAssignmentAssociations(ctx, tx, assignment); err != nil {
+			return assignments, n, err
+		}
+	}
+	return assignments, n, nil
+}
+
+func (s *AssignmentService) CreateAssignment(ctx context.Context, assignment *ocs.Assignment) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createAssignment(ctx, tx, assignment); err != nil {
+		return err
+	} else if err := attachAssignmentAssociations(ctx, tx, assignment); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *AssignmentService) FindSubmissionByID(ctx context.Context, id int) (*ocs.Submission, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	submission, err := findSubmissionByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachSubmissionAssociations(ctx, tx, submission); err != nil {
+		return nil, err
+	}
+
+	return submission, nil
+}
+
+func (s *AssignmentService) FindSubmissions(ctx context.Context, filter ocs.SubmissionFilter) ([]*ocs.Submission, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	submissions, n, err := findSubmissions(ctx, tx, filter)
+	if err != nil {
+		return submissions, n, err
+	}
+
+	for _, submission := range submissions {
+		if err := attachSubmissionAssociations(ctx, tx, submission); err != nil {
+			return submissions, n, err
+		}
+	}
+	return submissions, n, nil
+}
+
+func (s *AssignmentService) CreateSubmission(ctx context.Context, submission *ocs.Submission) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createSubmission(ctx, tx, submission); err != nil {
+		return err
+	} else if err := attachSubmissionAssociations(ctx, tx, submission); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *AssignmentService) GradeSubmission(ctx context.Context, id int, grade ocs.SubmissionGrade) (*ocs.Submission, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	submission, err := gradeSubmission(ctx, tx, id, grade)
+	if err != nil {
+		return submission, err
+	} else if err := attachSubmissionAssociations(ctx, tx, submission); err != nil {
+		return submission, err
+	} else if err := tx.Commit(); err != nil {
+		return submission, err
+	}
+
+	return submission, nil
+}
+
+func createAssignment(ctx context.Context, tx *Tx, assignment *ocs.Assignment) error {
+	assignment.CreatedAt = tx.now
+	assignment.UpdatedAt = assignment.CreatedAt
+
+	if err := assignment.Validate(); err != nil {
+		return err
+	}
+
+	if ok, err := isCourseStaff(ctx, tx, assignment.CourseID, ocs.StudentIDFromContext(ctx)); err != nil {
+		return err
+	} else if !ok {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to create assignments for this course.")
+	}
+
+	if v := assignment.GroupSetID; v != nil {
+		if set, err := findGroupSetByID(ctx, tx, *v); err != nil {
+			return err
+		} else if set.CourseID != assignment.CourseID {
+			return ocs.Errorf(ocs.EINVALID, "Group set must belong to the assignment's course.")
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO assignments (
+      course_id,
+      title,
+      description,
+      max_points,
+      due_at,
+      group_set_id,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+  `,
+		assignment.CourseID,
+		assignment.Title,
+		assignment.Description,
+		assignment.MaxPoints,
+		(*NullTime)(assignment.DueAt),
+		assignment.GroupSetID,
+		(*NullTime)(&assignment.CreatedAt),
+		(*NullTime)(&assignment.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	assignment.ID = int(id)
+
+	return audit(ctx, tx, ocs.AuditActionCreate, "assignment", assignment.ID, nil, assignment)
+}
+
+func findAssignmentByID(ctx context.Context, tx *Tx, id int) (*ocs.Assignment, error) {
+	a, _, err := findAssignments(ctx, tx, ocs.AssignmentFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Assignment not found."}
+	}
+	return a[0], nil
+}
+
+func findAssignments(ctx context.Context, tx *Tx, filter ocs.AssignmentFilter) (_ []*ocs.Assignment, n int, err error) {
+	where, args := tx.scope(byCourse, "course_id")
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.CourseID; v != nil {
+		where, args = append(where, "course_id = ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      course_id,
+      title,
+      description,
+      max_points,
+      due_at,
+      group_set_id,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM assignments
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	assignments := make([]*ocs.Assignment, 0)
+	for rows.Next() {
+		var assignment ocs.Assignment
+		var dueAt NullTime
+		var groupSetID sql.NullInt64
+		if err := rows.Scan(
+			&assignment.ID,
+			&assignment.CourseID,
+			&assignment.Title,
+			&assignment.Description,
+			&assignment.MaxPoints,
+			&dueAt,
+			&groupSetID,
+			(*NullTime)(&assignment.CreatedAt),
+			(*NullTime)(&assignment.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		if v := (time.Time)(dueAt); !v.IsZero() {
+			assignment.DueAt = &v
+		}
+		assignment.GroupSetID = nullIntPtr(groupSetID)
+
+		assignments = append(assignments, &assignment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return assignments, n, nil
+}
+
+func attachAssignmentAssociations(ctx context.Context, tx *Tx, assignment *ocs.Assignment) (err error) {
+	if assignment.Course, err = findAnyCourseByID(ctx, tx, assignment.CourseID); err != nil {
+		return fmt.Errorf("attach assignment course: %w", err)
+	}
+	return nil
+}
+
+func createSubmission(ctx context.Context, tx *Tx, submission *ocs.Submission) error {
+	submission.StudentID = ocs.StudentIDFromContext(ctx)
+	if submission.StudentID == 0 {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You must be logged in to submit an assignment.")
+	}
+
+	submission.Grade, submission.GradedAt = nil, nil
+	submission.CreatedAt = tx.now
+	submission.UpdatedAt = submission.CreatedAt
+
+	if err := submission.Validate(); err != nil {
+		return err
+	}
+
+	assignment, err := findAssignmentByID(ctx, tx, submission.AssignmentID)
+	if err != nil {
+		return err
+	}
+	role := ocs.EnrollmentRoleStudent
+	if _, n, err := findEnrollments(ctx, tx, ocs.EnrollmentFilter{
+		CourseID:  &assignment.CourseID,
+		StudentID: &submission.StudentID,
+		Role:      &role,
+	}); err != nil {
+		return err
+	} else if n == 0 {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not enrolled in this course.")
+	}
+
+	// Group assignments take a single submission on behalf of the group.
+	submission.GroupID = nil
+	if v := assignment.GroupSetID; v != nil {
+		groupID, err := findCurrentGroupID(ctx, tx, *v, submission.StudentID)
+		if err != nil {
+			return err
+		} else if groupID == 0 {
+			return ocs.Errorf(ocs.EINVALID, "You must join a group to submit this assignment.")
+		}
+
+		var n int
+		if err := tx.QueryRowContext(ctx, `
+      SELECT COUNT(*) FROM submissions WHERE assignment_id = ? AND group_id = ?
+    `, submission.AssignmentID, groupID).Scan(&n); err != nil {
+			return FormatError(err)
+		} else if n != 0 {
+			return ocs.Errorf(ocs.ECONFLICT, "Your group has already submitted this assignment.")
+		}
+		submission.GroupID = &groupID
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO submissions (
+      assignment_id,
+      student_id,
+      body,
+      feedback,
+      group_id,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?, ?)
+  `,
+		submission.AssignmentID,
+		submission.StudentID,
+		submission.Body,
+		submission.Feedback,
+		submission.GroupID,
+		(*NullTime)(&submission.CreatedAt),
+		(*NullTime)(&submission.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	submission.ID = int(id)
+
+	if err := audit(ctx, tx, ocs.AuditActionCreate, "submission", submission.ID, nil, submission); err != nil {
+		return err
+	}
+
+	tx.publishEvent(submission.StudentID, ocs.Event{
+		Type: ocs.EventTypeSubmissionCreated,
+		Payload: &ocs.SubmissionCreatedPayload{
+			ID:           submission.ID,
+			AssignmentID: submission.AssignmentID,
+			CourseID:     assignment.CourseID,
+		},
+	})
+
+	return nil
+}
+
+func findSubmissionByID(ctx context.Context, tx *Tx, id int) (*ocs.Submission, error) {
+	a, _, err := findSubmissions(ctx, tx, ocs.SubmissionFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Submission not found."}
+	}
+	return a[0], nil
+}
+
+// findSubmissions only returns the caller's own submissions, those of groups
+// they belonged to when the work was submitted, and submissions to courses
+// where the caller is on the staff.
+func findSubmissions(ctx context.Context, tx *Tx, filter ocs.SubmissionFilter) (_ []*ocs.Submission, n int, err error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+
+	where, args := tx.scope(byCourse, "a.course_id")
+	where, args = append(where, `(
+    s.student_id = ? OR
+    s.group_id IN (`+groupMembersAtSubmission+`) OR
+    a.course_id IN (SELECT id FROM courses WHERE instructor_id = ?) OR
+    a.course_id IN (SELECT course_id FROM enrollments WHERE student_id = ? AND role = ?)
+  )`), append(args, studentID, studentID, studentID, studentID, ocs.EnrollmentRoleTA)
+
+	if v := filter.ID; v != nil {
+		where, args = append(where, "s.id = ?"), append(args, *v)
+	}
+	if v := filter.AssignmentID; v != nil {
+		where, args = append(where, "s.assignment_id = ?"), append(args, *v)
+	}
+	if v := filter.StudentID; v != nil {
+		where, args = append(where, `(s.student_id = ? OR s.group_id IN (`+groupMembersAtSubmission+`))`), append(args, *v, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      s.id,
+      s.assignment_id,
+      s.student_id,
+      s.body,
+      s.grade,
+      s.feedback,
+      s.graded_at,
+      s.group_id,
+      s.created_at,
+      s.updated_at,
+      COUNT(*) OVER()
+    FROM submissions s
+    INNER JOIN assignments a ON a.id = s.assignment_id
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY s.id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	submissions := make([]*ocs.Submission, 0)
+	for rows.Next() {
+		var submission ocs.Submission
+		var grade sql.NullInt64
+		var gradedAt NullTime
+		var groupID sql.NullInt64
+		if err := rows.Scan(
+			&submission.ID,
+			&submission.AssignmentID,
+			&submission.StudentID,
+			&submission.Body,
+			&grade,
+			&submission.Feedback,
+			&gradedAt,
+			&groupID,
+			(*NullTime)(&submission.CreatedAt),
+			(*NullTime)(&submission.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		if grade.Valid {
+			v := int(grade.Int64)
+			submission.Grade = &v
+		}
+		if v := (time.Time)(gradedAt); !v.IsZero() {
+			submission.GradedAt = &v
+		}
+		submission.GroupID = nullIntPtr(groupID)
+
+		submissions = append(submissions, &submission)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return submissions, n, nil
+}
+
+func gradeSubmission(ctx context.Context, tx *Tx, id int, grade ocs.SubmissionGrade) (*ocs.Submission, error) {
+	submission, err := findSubmissionByID(ctx, tx, id)
+	if err != nil {
+		return submission, err
+	}
+
+	assignment, err := findAssignmentByID(ctx, tx, submission.AssignmentID)
+	if err != nil {
+		return submission, err
+	} else if ok, err := isCourseStaff(ctx, tx, assignment.CourseID, ocs.StudentIDFromContext(ctx)); err != nil {
+		return submission, err
+	} else if !ok {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to grade this submission.")
+	}
+
+	if grade.Grade < 0 || grade.Grade > assignment.MaxPoints {
+		return submission, ocs.Errorf(ocs.EINVALID, "Grade must be between 0 and %d.", assignment.MaxPoints)
+	}
+
+	prev := *submission
+	gradedAt := tx.now
+	submission.Grade = &grade.Grade
+	submission.Feedback = grade.Feedback
+	submission.GradedAt = &gradedAt
+	submission.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE submissions
+    SET grade = ?,
+        feedback = ?,
+        graded_at = ?,
+        updated_at = ?
+    WHERE id = ?
+    `,
+		*submission.Grade,
+		submission.Feedback,
+		(*NullTime)(submission.GradedAt),
+		(*NullTime)(&submission.UpdatedAt),
+		id,
+	); err != nil {
+		return submission, FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "submission", id, &prev, submission); err != nil {
+		return submission, err
+	}
+
+	// The grade of a group submission applies to everyone who was in the
+	// group when it was submitted, even if they have moved on since.
+	memberIDs, err := findSubmissionMemberIDs(ctx, tx, submission)
+	if err != nil {
+		return submission, err
+	}
+
+	event := ocs.Event{
+		Type: ocs.EventTypeSubmissionGraded,
+		Payload: &ocs.SubmissionGradedPayload{
+			ID:           submission.ID,
+			AssignmentID: submission.AssignmentID,
+			CourseID:     assignment.CourseID,
+			Grade:        *submission.Grade,
+			MaxPoints:    assignment.MaxPoints,
+		},
+	}
+	for _, memberID := range memberIDs {
+		tx.publishEvent(memberID, event)
+	}
+
+	return submission, nil
+}
+
+func attachSubmissionAssociations(ctx context.Context, tx *Tx, submission *ocs.Submission) (err error) {
+	if submission.Assignment, err = findAssignmentByID(ctx, tx, submission.AssignmentID); err != nil {
+		return fmt.Errorf("attach submission assignment: %w", err)
+	} else if submission.Student, err = findAnyStudentByID(ctx, tx, submission.StudentID); err != nil {
+		return fmt.Errorf("attach submission student: %w", err)
+	} else if submission.GroupID != nil {
+		if submission.MemberIDs, err = findSubmissionMemberIDs(ctx, tx, submission); err != nil {
+			return fmt.Errorf("attach submission members: %w", err)
+		}
+	}
+	return nil
+}
+
+// groupMembersAtSubmission selects the groups a student, bound to its
+// placeholder, belonged to when submission s was created.
+const groupMembersAtSubmission = `
+  SELECT group_id FROM group_members
+  WHERE student_id = ? AND joined_at <= s.created_at AND (left_at IS NULL OR left_at > s.created_at)
+`
+
+// findSubmissionMemberIDs returns the students a submission's grade applies
+// to: the submitter, or the members of the group at the time of submission.
+func findSubmissionMemberIDs(ctx context.Context, tx *Tx, submission *ocs.Submission) ([]int, error) {
+	if submission.GroupID == nil {
+		return []int{submission.StudentID}, nil
+	}
+
+	createdAt := submission.CreatedAt
+	rows, err := tx.QueryContext(ctx, `
+    SELECT DISTINCT student_id
+    FROM group_members
+    WHERE group_id = ? AND joined_at <= ? AND (left_at IS NULL OR left_at > ?)
+    ORDER BY student_id ASC
+  `,
+		*submission.GroupID,
+		(*NullTime)(&createdAt),
+		(*NullTime)(&createdAt),
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	memberIDs := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		memberIDs = append(memberIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+	return memberIDs, nil
+}
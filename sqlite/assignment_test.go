@@ -0,0 +1,148 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestAssignmentService_CreateAssignment(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewAssignmentService(db)
+
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		course := MustCreateCourse(t, ctx, db, &ocs.Course{Title: "Go 101"})
+
+		dueAt := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+		assignment := &ocs.Assignment{CourseID: course.ID, Title: "HW1", MaxPoints: 10, DueAt: &dueAt}
+		if err := s.CreateAssignment(ctx, assignment); err != nil {
+			t.Fatal(err)
+		}
+
+		if other, err := s.FindAssignmentByID(ctx, assignment.ID); err != nil {
+			t.Fatal(err)
+		} else if other.DueAt == nil || !other.DueAt.Equal(dueAt) {
+			t.Fatalf("DueAt=%v, want %v", other.DueAt, dueAt)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewAssignmentService(db)
+
+		_, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		_, ctx1 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "Go 101"})
+
+		if err := s.CreateAssignment(ctx1, &ocs.Assignment{CourseID: course.ID, Title: "HW1", MaxPoints: 10}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestAssignmentService_GradeSubmission(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewAssignmentService(db)
+
+		_, instructorCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student, studentCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, instructorCtx, db, &ocs.Course{Title: "Go 101"})
+		MustCreateEnrollment(t, studentCtx, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student.ID})
+		assignment := MustCreateAssignment(t, instructorCtx, db, &ocs.Assignment{CourseID: course.ID, Title: "HW1", MaxPoints: 10})
+		submission := MustCreateSubmission(t, studentCtx, db, &ocs.Submission{AssignmentID: assignment.ID, Body: "answer"})
+
+		if other, err := s.GradeSubmission(instructorCtx, submission.ID, ocs.SubmissionGrade{Grade: 7, Feedback: "ok"}); err != nil {
+			t.Fatal(err)
+		} else if other.Grade == nil || *other.Grade != 7 {
+			t.Fatalf("Grade=%v, want 7", other.Grade)
+		} else if other.GradedAt == nil {
+			t.Fatal("expected graded at")
+		}
+
+		if other, err := s.FindSubmissionByID(studentCtx, submission.ID); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.Feedback, "ok"; got != want {
+			t.Fatalf("Feedback=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrOutOfRange", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewAssignmentService(db)
+
+		_, instructorCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student, studentCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, instructorCtx, db, &ocs.Course{Title: "Go 101"})
+		MustCreateEnrollment(t, studentCtx, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student.ID})
+		assignment := MustCreateAssignment(t, instructorCtx, db, &ocs.Assignment{CourseID: course.ID, Title: "HW1", MaxPoints: 10})
+		submission := MustCreateSubmission(t, studentCtx, db, &ocs.Submission{AssignmentID: assignment.ID})
+
+		if _, err := s.GradeSubmission(instructorCtx, submission.ID, ocs.SubmissionGrade{Grade: 11}); ocs.ErrorCode(err) != ocs.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestAssignmentService_FindSubmissions(t *testing.T) {
+	t.Run("OtherStudentHidden", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewAssignmentService(db)
+
+		_, instructorCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student0, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		student1, ctx1 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "cat", Email: "cat@email.com"})
+		course := MustCreateCourse(t, instructorCtx, db, &ocs.Course{Title: "Go 101"})
+		MustCreateEnrollment(t, ctx0, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student0.ID})
+		MustCreateEnrollment(t, ctx1, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student1.ID})
+		assignment := MustCreateAssignment(t, instructorCtx, db, &ocs.Assignment{CourseID: course.ID, Title: "HW1", MaxPoints: 10})
+		MustCreateSubmission(t, ctx0, db, &ocs.Submission{AssignmentID: assignment.ID})
+		MustCreateSubmission(t, ctx1, db, &ocs.Submission{AssignmentID: assignment.ID})
+
+		if _, n, err := s.FindSubmissions(ctx0, ocs.SubmissionFilter{AssignmentID: &assignment.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		if _, n, err := s.FindSubmissions(instructorCtx, ocs.SubmissionFilter{AssignmentID: &assignment.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 2; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+}
+
+func MustCreateAssignment(tb testing.TB, ctx context.Context, db *sqlite.DB, assignment *ocs.Assignment) *ocs.Assignment {
+	tb.Helper()
+	if err := sqlite.NewAssignmentService(db).CreateAssignment(ctx, assignment); err != nil {
+		tb.Fatal(err)
+	}
+	return assignment
+}
+
+func MustCreateSubmission(tb testing.TB, ctx context.Context, db *sqlite.DB, submission *ocs.Submission) *ocs.Submission {
+	tb.Helper()
+	if err := sqlite.NewAssignmentService(db).CreateSubmission(ctx, submission); err != nil {
+		tb.Fatal(err)
+	}
+	return submission
+}
+
+func MustGradeSubmission(tb testing.TB, ctx context.Context, db *sqlite.DB, id int, grade ocs.SubmissionGrade) *ocs.Submission {
+	tb.Helper()
+	submission, err := sqlite.NewAssignmentService(db).GradeSubmission(ctx, id, grade)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return submission
+}
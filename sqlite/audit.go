@@ -0,0 +1,343 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.AuditService = (*AuditService)(nil)
+
+// auditBatchSize is the number of entries read at a time when verifying the
+// audit log.
+const auditBatchSize = 1000
+
+// auditRedacted replaces the values of redacted fields in audit entries, so
+// the log only shows that they changed.
+var auditRedacted = json.RawMessage(`"[redacted]"`)
+
+// auditRedactedFields are the JSON fields whose values are never logged:
+// secrets, the private text of messages, notes and submissions, and personal
+// details. The log cannot be changed, so personal details logged there would
+// outlive the erasure of the student's account. The source ID of an auth is
+// the student's account elsewhere, and their avatar URL is made from it.
+var auditRedactedFields = map[string]bool{
+	"secret":       true,
+	"body":         true,
+	"email":        true,
+	"pendingEmail": true,
+	"displayName":  true,
+	"bio":          true,
+	"pronouns":     true,
+	"links":        true,
+	"sourceID":     true,
+	"avatarURL":    true,
+}
+
+// auditRedactedStudentFields are also redacted from entries about students.
+// Other entities, such as organizations and groups, have names that are not
+// personal.
+var auditRedactedStudentFields = map[string]bool{
+	"name": true,
+}
+
+// auditIgnoredFields are the JSON fields left out of audit entries because
+// the entry records them itself.
+var auditIgnoredFields = map[string]bool{
+	"id":        true,
+	"createdAt": true,
+	"updatedAt": true,
+}
+
+type AuditService struct {
+	db *DB
+}
+
+func NewAuditService(db *DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+func (s *AuditService) FindAuditEntries(ctx context.Context, filter ocs.AuditFilter) ([]*ocs.AuditEntry, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if err := checkAuditAdmin(ctx, tx); err != nil {
+		return nil, 0, err
+	}
+	return findAuditEntries(ctx, tx, filter)
+}
+
+// VerifyAuditLog recomputes the hash chain over the whole log and reports
+// the first entry that does not match.
+func (s *AuditService) VerifyAuditLog(ctx context.Context) (*ocs.AuditVerification, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkAuditAdmin(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	v := &ocs.AuditVerification{Valid: true}
+	for lastID, prevHash := 0, ""; ; {
+		entries, _, err := queryAuditEntries(ctx, tx, []string{"id > ?"}, []interface{}{lastID}, auditBatchSize, 0)
+		if err != nil {
+			return nil, err
+		} else if len(entries) == 0 {
+			return v, nil
+		}
+
+		for _, entry := range entries {
+			v.Entries++
+			hash, err := entry.ComputeHash()
+			if err != nil {
+				return nil, err
+			} else if entry.PrevHash != prevHash || entry.Hash != hash {
+				v.Valid, v.BrokenEntryID = false, entry.ID
+				return v, nil
+			}
+			lastID, prevHash = entry.ID, entry.Hash
+		}
+	}
+}
+
+func checkAuditAdmin(ctx context.Context, tx *Tx) error {
+	if ok, err := isAdmin(ctx, tx); err != nil {
+		return err
+	} else if !ok {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "Only admins may view the audit log.")
+	}
+	return nil
+}
+
+// audit records a change to an entity in the transaction that makes it.
+// Before is nil for created entities and after is nil for deleted ones. The
+// actor, request ID and IP come from the context. An update that changes
+// nothing is not recorded. Every write to a table is audited, except to the
+// bookkeeping tables, such as jobs and emails, listed by TestAudit_Tables.
+func audit(ctx context.Context, tx *Tx, action, entityType string, entityID int, before, after interface{}) error {
+	changes, err := auditChanges(entityType, before, after)
+	if err != nil {
+		return err
+	} else if action == ocs.AuditActionUpdate && len(changes) == 0 {
+		return nil
+	}
+
+	info := ocs.RequestInfoFromContext(ctx)
+	entry := &ocs.AuditEntry{
+		ActorID:    ocs.StudentIDFromContext(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Changes:    changes,
+		RequestID:  info.ID,
+		IP:         info.IP,
+		CreatedAt:  tx.now,
+	}
+
+	if err := tx.QueryRowContext(ctx, `
+    SELECT hash FROM audit_entries ORDER BY id DESC LIMIT 1
+  `).Scan(&entry.PrevHash); err != nil && err != sql.ErrNoRows {
+		return FormatError(err)
+	}
+	if entry.Hash, err = entry.ComputeHash(); err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+    INSERT INTO audit_entries (
+      actor_id,
+      action,
+      entity_type,
+      entity_id,
+      changes,
+      request_id,
+      ip,
+      prev_hash,
+      hash,
+      created_at
+    )
+    VALUES (NULLIF(?, 0), ?, ?, ?, ?, ?, ?, ?, ?, ?)
+  `,
+		entry.ActorID,
+		entry.Action,
+		entry.EntityType,
+		entry.EntityID,
+		string(buf),
+		entry.RequestID,
+		entry.IP,
+		entry.PrevHash,
+		entry.Hash,
+		(*NullTime)(&entry.CreatedAt),
+	); err != nil {
+		return FormatError(err)
+	}
+
+	return nil
+}
+
+// auditChanges compares the JSON fields of two values of an entity. Related
+// entities, which are JSON objects or arrays of objects, are left out.
+func auditChanges(entityType string, before, after interface{}) (map[string]ocs.AuditChange, error) {
+	from, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]ocs.AuditChange)
+	for _, fields := range []map[string]json.RawMessage{from, to} {
+		for k := range fields {
+			if _, ok := changes[k]; ok || auditIgnoredFields[k] {
+				continue
+			}
+
+			a, b := from[k], to[k]
+			if isAuditAssociation(a) || isAuditAssociation(b) || bytes.Equal(a, b) {
+				continue
+			}
+
+			if auditRedactedFields[k] || (entityType == "student" && auditRedactedStudentFields[k]) {
+				if a != nil {
+					a = auditRedacted
+				}
+				if b != nil {
+					b = auditRedacted
+				}
+			}
+			changes[k] = ocs.AuditChange{From: a, To: b}
+		}
+	}
+	return changes, nil
+}
+
+func auditFields(v interface{}) (map[string]json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func isAuditAssociation(v json.RawMessage) bool {
+	v = bytes.TrimSpace(v)
+	if len(v) > 0 && v[0] == '[' {
+		v = bytes.TrimSpace(v[1:])
+	}
+	return len(v) > 0 && v[0] == '{'
+}
+
+func findAuditEntries(ctx context.Context, tx *Tx, filter ocs.AuditFilter) (_ []*ocs.AuditEntry, n int, err error) {
+	where, args := []string{"1 = 1"}, []interface{}{}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.ActorID; v != nil {
+		where, args = append(where, "actor_id = ?"), append(args, *v)
+	}
+	if v := filter.Action; v != nil {
+		where, args = append(where, "action = ?"), append(args, *v)
+	}
+	if v := filter.EntityType; v != nil {
+		where, args = append(where, "entity_type = ?"), append(args, *v)
+	}
+	if v := filter.EntityID; v != nil {
+		where, args = append(where, "entity_id = ?"), append(args, *v)
+	}
+	if v := filter.RequestID; v != nil {
+		where, args = append(where, "request_id = ?"), append(args, *v)
+	}
+	if v := filter.Since; v != nil {
+		where, args = append(where, "created_at >= ?"), append(args, (*NullTime)(v))
+	}
+	if v := filter.Until; v != nil {
+		where, args = append(where, "created_at < ?"), append(args, (*NullTime)(v))
+	}
+	return queryAuditEntries(ctx, tx, where, args, filter.Limit, filter.Offset)
+}
+
+func queryAuditEntries(ctx context.Context, tx *Tx, where []string, args []interface{}, limit, offset int) (_ []*ocs.AuditEntry, n int, err error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      COALESCE(actor_id, 0),
+      action,
+      entity_type,
+      entity_id,
+      changes,
+      request_id,
+      ip,
+      prev_hash,
+      hash,
+      created_at,
+      COUNT(*) OVER()
+    FROM audit_entries
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(limit, offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	entries := make([]*ocs.AuditEntry, 0)
+	for rows.Next() {
+		var entry ocs.AuditEntry
+		var changes string
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.ActorID,
+			&entry.Action,
+			&entry.EntityType,
+			&entry.EntityID,
+			&changes,
+			&entry.RequestID,
+			&entry.IP,
+			&entry.PrevHash,
+			&entry.Hash,
+			(*NullTime)(&entry.CreatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		if err := json.Unmarshal([]byte(changes), &entry.Changes); err != nil {
+			return nil, 0, err
+		}
+
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return entries, n, nil
+}
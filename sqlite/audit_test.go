@@ -0,0 +1,272 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestAuditService_FindAuditEntries(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewAuditService(db)
+
+		ctx := ocs.NewContextWithRequestInfo(context.Background(), ocs.RequestInfo{ID: "req-1", IP: "10.0.0.1"})
+		admin, ctx := MustCreateStudent(t, ctx, db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+
+		course := MustCreateCourse(t, ctx, db, &ocs.Course{Title: "Go 101"})
+		title := "Go 102"
+		if _, err := sqlite.NewCourseService(db).UpdateCourse(ctx, course.ID, ocs.CourseUpdate{Title: &title}); err != nil {
+			t.Fatal(err)
+		} else if err := sqlite.NewCourseService(db).DeleteCourse(ctx, course.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		entityType := "course"
+		entries, n, err := s.FindAuditEntries(ctx, ocs.AuditFilter{EntityType: &entityType})
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 3; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		for i, action := range []string{ocs.AuditActionCreate, ocs.AuditActionUpdate, ocs.AuditActionDelete} {
+			if got, want := entries[i].Action, action; got != want {
+				t.Fatalf("Action=%v, want %v", got, want)
+			} else if got, want := entries[i].ActorID, admin.ID; got != want {
+				t.Fatalf("ActorID=%v, want %v", got, want)
+			} else if got, want := entries[i].EntityID, course.ID; got != want {
+				t.Fatalf("EntityID=%v, want %v", got, want)
+			} else if got, want := entries[i].RequestID, "req-1"; got != want {
+				t.Fatalf("RequestID=%v, want %v", got, want)
+			} else if got, want := entries[i].IP, "10.0.0.1"; got != want {
+				t.Fatalf("IP=%v, want %v", got, want)
+			}
+		}
+
+		// The update only records the field that changed.
+		if got, want := len(entries[1].Changes), 1; got != want {
+			t.Fatalf("len(Changes)=%v, want %v", got, want)
+		} else if change := entries[1].Changes["title"]; string(change.From) != `"Go 101"` || string(change.To) != `"Go 102"` {
+			t.Fatalf("unexpected change: from=%s to=%s", change.From, change.To)
+		} else if change := entries[2].Changes["deletedAt"]; string(change.From) != `null` || change.To == nil {
+			t.Fatalf("unexpected change: from=%s to=%s", change.From, change.To)
+		}
+	})
+
+	t.Run("Redacted", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		_, ctx := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+		webhook := MustCreateWebhook(t, ctx, sqlite.NewWebhookService(db), &ocs.Webhook{URL: "https://example.com", EventTypes: []string{ocs.EventTypeBadgeEarned}})
+
+		entityType := "webhook"
+		if entries, _, err := sqlite.NewAuditService(db).FindAuditEntries(ctx, ocs.AuditFilter{EntityType: &entityType, EntityID: &webhook.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := string(entries[0].Changes["secret"].To), `"[redacted]"`; got != want {
+			t.Fatalf("secret=%v, want %v", got, want)
+		}
+	})
+
+	// Sign-in links are audited when sent and used, without their tokens.
+	t.Run("LinkTokens", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewMagicLinkService(db)
+		s.URL = "https://ocs.example.com"
+
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+		MustDeliverEmails(t, db)
+		if err := s.RequestMagicLink(DefaultSiteContext(), "adm@email.com", "NONCE"); err != nil {
+			t.Fatal(err)
+		}
+		m := magicLinkURL.FindStringSubmatch(MustDeliverEmails(t, db)[0].Text)
+		if _, err := s.RedeemMagicLink(DefaultSiteContext(), m[1], "NONCE"); err != nil {
+			t.Fatal(err)
+		}
+
+		entityType := "magic_link"
+		entries, _, err := sqlite.NewAuditService(db).FindAuditEntries(ctx, ocs.AuditFilter{EntityType: &entityType})
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := len(entries), 2; got != want {
+			t.Fatalf("len(entries)=%v, want %v", got, want)
+		} else if got, want := entries[0].Action, ocs.AuditActionCreate; got != want {
+			t.Fatalf("Action=%v, want %v", got, want)
+		} else if got, want := entries[1].Action, ocs.AuditActionUpdate; got != want {
+			t.Fatalf("Action=%v, want %v", got, want)
+		} else if entries[1].Changes["usedAt"].To == nil {
+			t.Fatal("expected usedAt")
+		}
+		for _, entry := range entries {
+			for k := range entry.Changes {
+				if strings.Contains(strings.ToLower(k), "token") || strings.Contains(strings.ToLower(k), "hash") {
+					t.Fatalf("unexpected field: %s", k)
+				}
+			}
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		if _, _, err := sqlite.NewAuditService(db).FindAuditEntries(ctx, ocs.AuditFilter{}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+// TestAudit_Tables keeps track of which tables are audited. Every table is
+// either audited, under the entity type its rows are logged as, or exempt
+// for the reason given; a new table must be added to one of the two.
+func TestAudit_Tables(t *testing.T) {
+	audited := map[string]string{
+		"assignments":               "assignment",
+		"auths":                     "auth",
+		"badge_awards":              "badge_award",
+		"bookmarks":                 "bookmark",
+		"certificates":              "certificate",
+		"conversation_participants": "conversation_participant",
+		"conversations":             "conversation",
+		"coupons":                   "coupon",
+		"courses":                   "course",
+		"email_verifications":       "email_verification",
+		"enrollments":               "enrollment",
+		"erasure_requests":          "erasure_request",
+		"exports":                   "export",
+		"group_members":             "group_member",
+		"group_sets":                "group_set",
+		"learning_paths":            "learning_path",
+		"lessons":                   "lesson",
+		"magic_links":               "magic_link",
+		"messages":                  "message",
+		"notes":                     "note",
+		"notifications":             "notification",
+		"orders":                    "order",
+		"organization_members":      "organization_member",
+		"organizations":             "organization",
+		"password_resets":           "password_reset",
+		"path_enrollments":          "path_enrollment",
+		"point_entries":             "point_entry",
+		"refunds":                   "refund",
+		"regrade_requests":          "regrade_request",
+		"student_groups":            "group",
+		"students":                  "student",
+		"submissions":               "submission",
+		"webhooks":                  "webhook",
+	}
+	exempt := map[string]string{
+		"audit_entries":         "the log itself",
+		"migrations":            "schema bookkeeping",
+		"jobs":                  "queue bookkeeping; the changes jobs make are audited",
+		"job_schedules":         "queue bookkeeping",
+		"emails":                "outbox of emails sent by audited changes",
+		"digests":               "records which digests were sent",
+		"deadline_reminders":    "records which reminders were sent",
+		"webhook_deliveries":    "a delivery log of its own, shown to admins",
+		"badge_activities":      "progress toward badges; awards are audited",
+		"avatars":               "image data; changes are audited on the student",
+		"avatar_variants":       "resized copies of avatars",
+		"learning_path_courses": "saved and audited with their learning path",
+		"regrade_transitions":   "a history of regrade requests, which are audited",
+		"notes_fts":             "search index of notes",
+	}
+
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+
+		// Full-text indexes keep their data in tables of their own.
+		if i := strings.Index(name, "_fts_"); i >= 0 {
+			name = name[:i+len("_fts")]
+		}
+		if _, ok := audited[name]; ok {
+			continue
+		} else if _, ok := exempt[name]; !ok {
+			t.Errorf("table %q is neither audited nor exempt", name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditService_VerifyAuditLog(t *testing.T) {
+	// Entries are tampered with through a second connection, so the
+	// database must be a file.
+	dsn := filepath.Join(t.TempDir(), "db")
+	db := sqlite.NewDB(dsn)
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer MustCloseDB(t, db)
+	s := sqlite.NewAuditService(db)
+
+	_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+	course := MustCreateCourse(t, ctx, db, &ocs.Course{Title: "Go 101"})
+	MustCreateCourse(t, ctx, db, &ocs.Course{Title: "Go 102"})
+
+	if v, err := s.VerifyAuditLog(ctx); err != nil {
+		t.Fatal(err)
+	} else if !v.Valid {
+		t.Fatalf("unexpected broken entry: %d", v.BrokenEntryID)
+	} else if got, want := v.Entries, 3; got != want {
+		t.Fatalf("Entries=%v, want %v", got, want)
+	}
+
+	raw, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+
+	// Entries cannot be changed without dropping the triggers first.
+	if _, err := raw.Exec(`UPDATE audit_entries SET entity_id = 0 WHERE entity_id = ?`, course.ID); err == nil {
+		t.Fatal("expected error")
+	} else if _, err := raw.Exec(`UPDATE audit_entries SET ip = '10.0.0.2'`); err == nil {
+		t.Fatal("expected error")
+	}
+
+	var id int
+	if _, err := raw.Exec(`DROP TRIGGER audit_entries_no_update`); err != nil {
+		t.Fatal(err)
+	} else if err := raw.QueryRow(`
+    UPDATE audit_entries SET changes = '{}' WHERE entity_type = 'course' AND entity_id = ? RETURNING id
+  `, course.ID).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := s.VerifyAuditLog(ctx); err != nil {
+		t.Fatal(err)
+	} else if v.Valid {
+		t.Fatal("expected broken chain")
+	} else if got, want := v.BrokenEntryID, id; got != want {
+		t.Fatalf("BrokenEntryID=%v, want %v", got, want)
+	}
+}
@@ -69,6 +69,8 @@ func (a *AuthService) CreateAuth(ctx context.Context, auth *ocs.Auth) error {
 			return fmt.Errorf("cannot update auth: id=%d err=%w", other.ID, err)
 		} else if err := attachAuthAssociations(ctx, tx, other); err != nil {
 			return err
+		} else if other.Student.DeletedAt != nil {
+			return ocs.Errorf(ocs.EUNAUTHORIZED, "This account has been deleted.")
 		}
 
 		*auth = *other
@@ -78,8 +80,23 @@ func (a *AuthService) CreateAuth(ctx context.Context, auth *ocs.Auth) error {
 	}
 
 	if auth.StudentID == 0 && auth.Student != nil {
-		if student, err := findStudentByEmail(ctx, tx, auth.Student.Email); err == nil {
+		// Deleted students keep their email until they are purged.
+		if student, err := findAnyStudentByEmail(ctx, tx, auth.Student.Email); err == nil && student.DeletedAt != nil {
+			return ocs.Errorf(ocs.EUNAUTHORIZED, "This account has been deleted.")
+		} else if err == nil {
+			// Only a verified provider address proves ownership of the
+			// account; otherwise anyone could sign in to it by adding the
+			// address to their provider profile.
+			if !auth.Student.EmailVerified {
+				return ocs.Errorf(ocs.EUNVERIFIED, "An account already uses this email. Please verify the email with your sign-in provider first.")
+			} else if !student.EmailVerified {
+				if err := claimStudentEmail(ctx, tx, student); err != nil {
+					return err
+				}
+			}
 			auth.Student = student
+		} else if ocs.ErrorCode(err) == ocs.ENOTFOUND && tx.orgID != 0 {
+			return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not a member of this organization.")
 		} else if ocs.ErrorCode(err) == ocs.ENOTFOUND {
 			if err := createStudent(ctx, tx, auth.Student); err != nil {
 				return fmt.Errorf("cannot create student: %w", err)
@@ -159,7 +176,7 @@ func createAuth(ctx context.Context, tx *Tx, auth *ocs.Auth) error {
 	}
 	auth.ID = int(id)
 
-	return nil
+	return audit(ctx, tx, ocs.AuditActionCreate, "auth", auth.ID, nil, auth)
 }
 
 func findAuthByID(ctx context.Context, tx *Tx, id int) (*ocs.Auth, error) {
@@ -183,7 +200,7 @@ func findAuthBySourceID(ctx context.Context, tx *Tx, source, sourceID string) (*
 }
 
 func findAuths(ctx context.Context, tx *Tx, filter ocs.AuthFilter) (_ []*ocs.Auth, n int, err error) {
-	where, args := []string{"1 = 1"}, []interface{}{}
+	where, args := tx.scope(byStudent, "student_id")
 	if v := filter.ID; v != nil {
 		where, args = append(where, "id = ?"), append(args, *v)
 	}
@@ -278,10 +295,10 @@ func updateAuth(ctx context.Context, tx *Tx, id int, accessToken, refreshToken s
 	}
 
 	if _, err := tx.ExecContext(ctx, `
-    UPDATE students
-    SET access_token = ?
-        refresh_token = ?
-        expiry = ?
+    UPDATE auths
+    SET access_token = ?,
+        refresh_token = ?,
+        expiry = ?,
         updated_at = ?
     WHERE id = ?
   `,
@@ -298,7 +315,8 @@ func updateAuth(ctx context.Context, tx *Tx, id int, accessToken, refreshToken s
 }
 
 func deleteAuth(ctx context.Context, tx *Tx, id int) error {
-	if auth, err := findAuthByID(ctx, tx, id); err != nil {
+	auth, err := findAuthByID(ctx, tx, id)
+	if err != nil {
 		return err
 	} else if auth.StudentID != ocs.StudentIDFromContext(ctx) {
 		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to delete this auth.")
@@ -308,11 +326,11 @@ func deleteAuth(ctx context.Context, tx *Tx, id int) error {
 		return FormatError(err)
 	}
 
-	return nil
+	return audit(ctx, tx, ocs.AuditActionDelete, "auth", id, auth, nil)
 }
 
 func attachAuthAssociations(ctx context.Context, tx *Tx, auth *ocs.Auth) (err error) {
-	if auth.Student, err = findStudentByID(ctx, tx, auth.StudentID); err != nil {
+	if auth.Student, err = findAnyStudentByID(ctx, tx, auth.StudentID); err != nil {
 		return fmt.Errorf("attach auth user: %w", err)
 	}
 	return nil
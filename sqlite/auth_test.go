@@ -29,7 +29,7 @@ func TestAuthService_CreateAuth(t *testing.T) {
 			},
 		}
 
-		if err := s.CreateAuth(context.Background(), auth); err != nil {
+		if err := s.CreateAuth(DefaultSiteContext(), auth); err != nil {
 			t.Fatal(err)
 		} else if got, want := auth.ID, 1; got != want {
 			t.Fatalf("ID=%v, want %v", got, want)
@@ -39,13 +39,13 @@ func TestAuthService_CreateAuth(t *testing.T) {
 			t.Fatal("expected updated at")
 		}
 
-		if other, err := s.FindAuthByID(context.Background(), 1); err != nil {
+		if other, err := s.FindAuthByID(DefaultSiteContext(), 1); err != nil {
 			t.Fatal(err)
 		} else if !reflect.DeepEqual(other, auth) {
 			t.Fatalf("mismatch: %#v != %#v", auth, other)
 		}
 
-		if student, err := sqlite.NewStudentService(db).FindStudentByID(context.Background(), 1); err != nil {
+		if student, err := sqlite.NewStudentService(db).FindStudentByID(DefaultSiteContext(), 1); err != nil {
 			t.Fatal(err)
 		} else if len(student.Auths) != 1 {
 			t.Fatal("expected auths")
@@ -57,7 +57,7 @@ func TestAuthService_CreateAuth(t *testing.T) {
 	t.Run("ErrSourceRequired", func(t *testing.T) {
 		db := MustOpenDB(t)
 		defer MustCloseDB(t, db)
-		if err := sqlite.NewAuthService(db).CreateAuth(context.Background(), &ocs.Auth{
+		if err := sqlite.NewAuthService(db).CreateAuth(DefaultSiteContext(), &ocs.Auth{
 			Student: &ocs.Student{Name: "NAME", Email: "NAME@EMAIL.COM"},
 		}); err == nil {
 			t.Fatal("expected error")
@@ -69,7 +69,7 @@ func TestAuthService_CreateAuth(t *testing.T) {
 	t.Run("ErrSourceIDRequired", func(t *testing.T) {
 		db := MustOpenDB(t)
 		defer MustCloseDB(t, db)
-		if err := sqlite.NewAuthService(db).CreateAuth(context.Background(), &ocs.Auth{
+		if err := sqlite.NewAuthService(db).CreateAuth(DefaultSiteContext(), &ocs.Auth{
 			Source:  ocs.AuthSourceGithub,
 			Student: &ocs.Student{Name: "NAME", Email: "NAME@EMAIL.COM"},
 		}); err == nil {
@@ -83,7 +83,7 @@ func TestAuthService_CreateAuth(t *testing.T) {
 		db := MustOpenDB(t)
 		defer MustCloseDB(t, db)
 		s := sqlite.NewAuthService(db)
-		if err := s.CreateAuth(context.Background(), &ocs.Auth{
+		if err := s.CreateAuth(DefaultSiteContext(), &ocs.Auth{
 			Source:   ocs.AuthSourceGithub,
 			SourceID: "X",
 			Student:  &ocs.Student{Name: "NAME", Email: "NAME@EMAIL.COM"},
@@ -94,11 +94,37 @@ func TestAuthService_CreateAuth(t *testing.T) {
 		}
 	})
 
+	// A provider address that is not verified does not sign in to the
+	// account that already uses it.
+	t.Run("ErrUnverifiedEmail", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewAuthService(db)
+
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+
+		auth := &ocs.Auth{
+			Source:      ocs.AuthSourceGithub,
+			SourceID:    "SOURCEID",
+			AccessToken: "ACCESS",
+			Student:     &ocs.Student{Name: "mallory", Email: "jane@email.com"},
+		}
+		if err := s.CreateAuth(DefaultSiteContext(), auth); ocs.ErrorCode(err) != ocs.EUNVERIFIED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if auths, _, err := s.FindAuths(ctx, ocs.AuthFilter{StudentID: &student.ID}); err != nil {
+			t.Fatal(err)
+		} else if len(auths) != 0 {
+			t.Fatalf("unexpected auths: %d", len(auths))
+		}
+	})
+
 	t.Run("ErrStudentRequired", func(t *testing.T) {
 		db := MustOpenDB(t)
 		defer MustCloseDB(t, db)
 		s := sqlite.NewAuthService(db)
-		if err := s.CreateAuth(context.Background(), &ocs.Auth{}); err == nil {
+		if err := s.CreateAuth(DefaultSiteContext(), &ocs.Auth{}); err == nil {
 			t.Fatal("expected error")
 		} else if ocs.ErrorCode(err) != ocs.EINVALID || ocs.ErrorMessage(err) != `Student required.` {
 			t.Fatalf("unexpected error: %#v", err)
@@ -111,7 +137,7 @@ func TestAuthService_DeleteAuth(t *testing.T) {
 		db := MustOpenDB(t)
 		defer MustCloseDB(t, db)
 		s := sqlite.NewAuthService(db)
-		auth0, ctx0 := MustCreateAuth(t, context.Background(), db, &ocs.Auth{
+		auth0, ctx0 := MustCreateAuth(t, DefaultSiteContext(), db, &ocs.Auth{
 			Source:      ocs.AuthSourceGithub,
 			SourceID:    "X",
 			AccessToken: "X",
@@ -132,7 +158,7 @@ func TestAuthService_DeleteAuth(t *testing.T) {
 		db := MustOpenDB(t)
 		defer MustCloseDB(t, db)
 		s := sqlite.NewAuthService(db)
-		if err := s.DeleteAuth(context.Background(), 1); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+		if err := s.DeleteAuth(DefaultSiteContext(), 1); ocs.ErrorCode(err) != ocs.ENOTFOUND {
 			t.Fatalf("unexpected error: %#v", err)
 		}
 	})
@@ -142,12 +168,12 @@ func TestAuthService_DeleteAuth(t *testing.T) {
 		defer MustCloseDB(t, db)
 		s := sqlite.NewAuthService(db)
 
-		auth0, _ := MustCreateAuth(t, context.Background(), db, &ocs.Auth{
+		auth0, _ := MustCreateAuth(t, DefaultSiteContext(), db, &ocs.Auth{
 			Source:      ocs.AuthSourceGithub,
 			SourceID:    "X",
 			AccessToken: "X", Student: &ocs.Student{Name: "X", Email: "X@EMAIL.COM"},
 		})
-		_, ctx1 := MustCreateAuth(t, context.Background(), db, &ocs.Auth{
+		_, ctx1 := MustCreateAuth(t, DefaultSiteContext(), db, &ocs.Auth{
 			Source:      ocs.AuthSourceGithub,
 			SourceID:    "Y",
 			AccessToken: "Y", Student: &ocs.Student{Name: "Y", Email: "Y@EMAIL.COM"},
@@ -167,21 +193,21 @@ func TestAuthService_FindAuths(t *testing.T) {
 		defer MustCloseDB(t, db)
 		s := sqlite.NewAuthService(db)
 
-		ctx := context.Background()
+		ctx := DefaultSiteContext()
 
-		MustCreateAuth(t, context.Background(), db, &ocs.Auth{
+		MustCreateAuth(t, ctx, db, &ocs.Auth{
 			Source:      "SRCA",
 			SourceID:    "X1",
 			AccessToken: "ACCESSX1",
 			Student:     &ocs.Student{Name: "X", Email: "x@y.com"},
 		})
-		MustCreateAuth(t, context.Background(), db, &ocs.Auth{
+		MustCreateAuth(t, DefaultSiteContext(), db, &ocs.Auth{
 			Source:      "SRCB",
 			SourceID:    "X2",
 			AccessToken: "ACCESSX2",
-			Student:     &ocs.Student{Name: "X", Email: "x@y.com"},
+			Student:     &ocs.Student{Name: "X", Email: "x@y.com", EmailVerified: true},
 		})
-		MustCreateAuth(t, context.Background(), db, &ocs.Auth{
+		MustCreateAuth(t, DefaultSiteContext(), db, &ocs.Auth{
 			Source:      ocs.AuthSourceGithub,
 			SourceID:    "Y",
 			AccessToken: "ACCESSY",
@@ -208,7 +234,7 @@ func TestAuthService_FindAuth(t *testing.T) {
 		db := MustOpenDB(t)
 		defer MustCloseDB(t, db)
 		s := sqlite.NewAuthService(db)
-		if _, err := s.FindAuthByID(context.Background(), 1); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+		if _, err := s.FindAuthByID(DefaultSiteContext(), 1); ocs.ErrorCode(err) != ocs.ENOTFOUND {
 			t.Fatalf("unexpected error: %#v", err)
 		}
 	})
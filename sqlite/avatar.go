@@ -0,0 +1,216 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"image/png"
+	"io"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.AvatarService = (*AvatarService)(nil)
+
+// AvatarService stores uploaded avatars and caches resized versions of them
+// in the database.
+type AvatarService struct {
+	db *DB
+}
+
+func NewAvatarService(db *DB) *AvatarService {
+	return &AvatarService{db: db}
+}
+
+func (s *AvatarService) FindAvatar(ctx context.Context, studentID, size int) (*ocs.AvatarImage, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	img, err := findAvatar(ctx, tx, studentID, size)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+func (s *AvatarService) UploadAvatar(ctx context.Context, studentID int, r io.Reader) (*ocs.Student, error) {
+	// Checked before decoding, so that nobody else can make us decode images.
+	if studentID != ocs.StudentIDFromContext(ctx) {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to change this avatar.")
+	}
+
+	data, err := ocs.DecodeAvatar(r)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	student, err := uploadAvatar(ctx, tx, studentID, data)
+	if err != nil {
+		return nil, err
+	} else if err := attachStudentsAuths(ctx, tx, student); err != nil {
+		return nil, err
+	} else if err := attachStudentBadges(ctx, tx, student); err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return student, nil
+}
+
+func (s *AvatarService) DeleteAvatar(ctx context.Context, studentID int) (*ocs.Student, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	student, err := deleteAvatar(ctx, tx, studentID)
+	if err != nil {
+		return nil, err
+	} else if err := attachStudentsAuths(ctx, tx, student); err != nil {
+		return nil, err
+	} else if err := attachStudentBadges(ctx, tx, student); err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return student, nil
+}
+
+// findAvatar returns the student's avatar at the given size. Resized
+// versions of uploaded avatars are made on first use and cached.
+func findAvatar(ctx context.Context, tx *Tx, studentID, size int) (*ocs.AvatarImage, error) {
+	student, err := findStudentByID(ctx, tx, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	size = ocs.AvatarSize(size)
+	if student.AvatarUpdatedAt == nil {
+		return &ocs.AvatarImage{
+			ContentType: "image/svg+xml",
+			Data:        ocs.InitialsAvatar(student, size),
+			UpdatedAt:   student.UpdatedAt,
+		}, nil
+	}
+	img := &ocs.AvatarImage{ContentType: "image/png", UpdatedAt: *student.AvatarUpdatedAt}
+
+	if err := tx.QueryRowContext(ctx, `
+    SELECT data FROM avatar_variants WHERE student_id = ? AND size = ?
+  `,
+		studentID, size,
+	).Scan(&img.Data); err == nil {
+		return img, nil
+	} else if err != sql.ErrNoRows {
+		return nil, FormatError(err)
+	}
+
+	var original []byte
+	if err := tx.QueryRowContext(ctx, `
+    SELECT data FROM avatars WHERE student_id = ?
+  `,
+		studentID,
+	).Scan(&original); err == sql.ErrNoRows {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Avatar not found."}
+	} else if err != nil {
+		return nil, FormatError(err)
+	}
+
+	// Uploads are stored at the largest size.
+	if size == ocs.AvatarSizes[len(ocs.AvatarSizes)-1] {
+		img.Data = original
+		return img, nil
+	}
+
+	decoded, err := png.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, err
+	} else if img.Data, err = ocs.EncodeAvatar(decoded, size); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+    INSERT INTO avatar_variants (student_id, size, data)
+    VALUES (?, ?, ?)
+    ON CONFLICT (student_id, size) DO NOTHING
+  `,
+		studentID, size, img.Data,
+	); err != nil {
+		return nil, FormatError(err)
+	}
+	return img, nil
+}
+
+// uploadAvatar stores data, an avatar already decoded and resized by
+// ocs.DecodeAvatar, and drops the cached sizes of the previous one.
+func uploadAvatar(ctx context.Context, tx *Tx, studentID int, data []byte) (*ocs.Student, error) {
+	student, err := findStudentByID(ctx, tx, studentID)
+	if err != nil {
+		return nil, err
+	} else if student.ID != ocs.StudentIDFromContext(ctx) {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to change this avatar.")
+	}
+	prev := *student
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM avatars WHERE student_id = ?`, studentID); err != nil {
+		return nil, FormatError(err)
+	} else if _, err := tx.ExecContext(ctx, `
+    INSERT INTO avatars (student_id, data, updated_at) VALUES (?, ?, ?)
+  `,
+		studentID, data, (*NullTime)(&tx.now),
+	); err != nil {
+		return nil, FormatError(err)
+	}
+
+	updatedAt := tx.now
+	student.AvatarUpdatedAt = &updatedAt
+	return updateStudentAvatar(ctx, tx, student, &prev)
+}
+
+func deleteAvatar(ctx context.Context, tx *Tx, studentID int) (*ocs.Student, error) {
+	student, err := findStudentByID(ctx, tx, studentID)
+	if err != nil {
+		return nil, err
+	} else if student.ID != ocs.StudentIDFromContext(ctx) {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to change this avatar.")
+	} else if student.AvatarUpdatedAt == nil {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Avatar not found."}
+	}
+	prev := *student
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM avatars WHERE student_id = ?`, studentID); err != nil {
+		return nil, FormatError(err)
+	}
+
+	student.AvatarUpdatedAt = nil
+	return updateStudentAvatar(ctx, tx, student, &prev)
+}
+
+// updateStudentAvatar records a change of the student's avatar.
+func updateStudentAvatar(ctx context.Context, tx *Tx, student, prev *ocs.Student) (*ocs.Student, error) {
+	student.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE students SET avatar_updated_at = ?, updated_at = ? WHERE id = ?
+  `,
+		(*NullTime)(student.AvatarUpdatedAt),
+		(*NullTime)(&student.UpdatedAt),
+		student.ID,
+	); err != nil {
+		return nil, FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "student", student.ID, prev, student); err != nil {
+		return nil, err
+	}
+	return student, nil
+}
@@ -0,0 +1,110 @@
+package sqlite_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestAvatarService_UploadAvatar(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewAvatarService(db)
+
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane doe", Email: "jane@email.com"})
+
+		// A wide image is cropped to its center square.
+		src := image.NewRGBA(image.Rect(0, 0, 300, 200))
+		for y := 0; y < 200; y++ {
+			for x := 0; x < 300; x++ {
+				src.Set(x, y, color.RGBA{R: 255, A: 255})
+			}
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, src); err != nil {
+			t.Fatal(err)
+		}
+
+		if other, err := s.UploadAvatar(ctx, student.ID, &buf); err != nil {
+			t.Fatal(err)
+		} else if other.AvatarUpdatedAt == nil {
+			t.Fatal("expected avatar updated at")
+		} else if got, want := other.AvatarURL(50), "/students/1/avatar?size=64&v="; !strings.HasPrefix(got, want) {
+			t.Fatalf("AvatarURL=%v, want prefix %v", got, want)
+		}
+
+		// Sizes are rounded up, and resized versions are served from cache
+		// once made.
+		for i := 0; i < 2; i++ {
+			avatar, err := s.FindAvatar(DefaultSiteContext(), student.ID, 50)
+			if err != nil {
+				t.Fatal(err)
+			} else if got, want := avatar.ContentType, "image/png"; got != want {
+				t.Fatalf("ContentType=%v, want %v", got, want)
+			}
+
+			img, err := png.Decode(bytes.NewReader(avatar.Data))
+			if err != nil {
+				t.Fatal(err)
+			} else if got, want := img.Bounds(), image.Rect(0, 0, 64, 64); got != want {
+				t.Fatalf("Bounds=%v, want %v", got, want)
+			} else if r, _, _, _ := img.At(32, 32).RGBA(); r>>8 != 255 {
+				t.Fatalf("R=%v, want 255", r>>8)
+			}
+		}
+
+		if _, err := s.DeleteAvatar(ctx, student.ID); err != nil {
+			t.Fatal(err)
+		} else if avatar, err := s.FindAvatar(DefaultSiteContext(), student.ID, 0); err != nil {
+			t.Fatal(err)
+		} else if got, want := avatar.ContentType, "image/svg+xml"; got != want {
+			t.Fatalf("ContentType=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("Initials", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewAvatarService(db)
+
+		student, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane doe", Email: "jane@email.com"})
+		if avatar, err := s.FindAvatar(DefaultSiteContext(), student.ID, 0); err != nil {
+			t.Fatal(err)
+		} else if got, want := avatar.ContentType, "image/svg+xml"; got != want {
+			t.Fatalf("ContentType=%v, want %v", got, want)
+		} else if !strings.Contains(string(avatar.Data), ">JD</text>") {
+			t.Fatalf("unexpected svg: %s", avatar.Data)
+		}
+	})
+
+	t.Run("ErrInvalid", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewAvatarService(db)
+
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		if _, err := s.UploadAvatar(ctx, student.ID, strings.NewReader("not an image")); ocs.ErrorCode(err) != ocs.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewAvatarService(db)
+
+		student, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "john", Email: "john@email.com"})
+		if _, err := s.UploadAvatar(ctx, student.ID, strings.NewReader("")); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
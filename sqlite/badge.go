@@ -0,0 +1,302 @@
+package sqlite
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var (
+	_ ocs.BadgeService = (*BadgeService)(nil)
+	_ ocs.EventHandler = (*BadgeService)(nil)
+)
+
+// BadgeService awards badges by evaluating Rules against published events.
+// Register it as an event handler on the event service to run the engine.
+type BadgeService struct {
+	db    *DB
+	Rules []ocs.BadgeRule
+}
+
+func NewBadgeService(db *DB) *BadgeService {
+	return &BadgeService{db: db, Rules: ocs.DefaultBadgeRules}
+}
+
+func (s *BadgeService) FindBadges(ctx context.Context) ([]*ocs.Badge, error) {
+	badges, seen := make([]*ocs.Badge, 0, len(s.Rules)), make(map[string]bool)
+	for i := range s.Rules {
+		if badge := s.Rules[i].Badge; !seen[badge.Code] {
+			seen[badge.Code] = true
+			badges = append(badges, &badge)
+		}
+	}
+	return badges, nil
+}
+
+func (s *BadgeService) FindBadgeAwards(ctx context.Context, filter ocs.BadgeAwardFilter) ([]*ocs.BadgeAward, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	return findBadgeAwards(ctx, tx, filter)
+}
+
+// HandleEvent counts the event towards every matching rule and awards any
+// badge whose rule is now satisfied. An event that was already counted is
+// ignored, so replaying events is safe.
+func (s *BadgeService) HandleEvent(ctx context.Context, studentID int, event ocs.Event) error {
+	if studentID == 0 {
+		return nil
+	}
+
+	ctx = withAllOrganizations(ctx)
+
+	key, err := eventKey(event)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Streaks count the student's own days, and the day an event happened
+	// does not change if it is replayed or handled late.
+	student, err := findStudentByID(ctx, tx, studentID)
+	if ocs.ErrorCode(err) == ocs.ENOTFOUND {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	at := event.OccurredAt
+	if at.IsZero() {
+		at = tx.now
+	}
+	day := at.In(student.Location()).Format(time.DateOnly)
+
+	for i := range s.Rules {
+		if rule := &s.Rules[i]; rule.Matches(event) {
+			if err := applyBadgeRule(ctx, tx, studentID, rule, key, day); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func applyBadgeRule(ctx context.Context, tx *Tx, studentID int, rule *ocs.BadgeRule, key, day string) error {
+	result, err := tx.ExecContext(ctx, `
+    INSERT OR IGNORE INTO badge_activities (
+      student_id,
+      badge_code,
+      event_key,
+      day,
+      created_at
+    )
+    VALUES (?, ?, ?, ?, ?)
+  `,
+		studentID,
+		rule.Badge.Code,
+		key,
+		day,
+		(*NullTime)(&tx.now),
+	)
+	if err != nil {
+		return FormatError(err)
+	} else if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return nil // already counted
+	}
+
+	if earned, err := isBadgeRuleSatisfied(ctx, tx, studentID, rule); err != nil {
+		return err
+	} else if !earned {
+		return nil
+	}
+
+	return createBadgeAward(ctx, tx, &ocs.BadgeAward{
+		StudentID: studentID,
+		Badge:     rule.Badge,
+	})
+}
+
+func isBadgeRuleSatisfied(ctx context.Context, tx *Tx, studentID int, rule *ocs.BadgeRule) (bool, error) {
+	if rule.Count > 0 {
+		var n int
+		if err := tx.QueryRowContext(ctx, `
+      SELECT COUNT(*)
+      FROM badge_activities
+      WHERE student_id = ? AND badge_code = ?
+    `,
+			studentID,
+			rule.Badge.Code,
+		).Scan(&n); err != nil {
+			return false, FormatError(err)
+		} else if n < rule.Count {
+			return false, nil
+		}
+	}
+
+	if rule.StreakDays > 0 {
+		if n, err := findBadgeStreak(ctx, tx, studentID, rule.Badge.Code, rule.StreakDays); err != nil {
+			return false, err
+		} else if n < rule.StreakDays {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// findBadgeStreak returns the number of consecutive days, up to max, with
+// activity for the badge ending on the most recent active day.
+func findBadgeStreak(ctx context.Context, tx *Tx, studentID int, code string, max int) (int, error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT DISTINCT day
+    FROM badge_activities
+    WHERE student_id = ? AND badge_code = ?
+    ORDER BY day DESC
+    LIMIT ?
+  `,
+		studentID,
+		code,
+		max,
+	)
+	if err != nil {
+		return 0, FormatError(err)
+	}
+	defer rows.Close()
+
+	var n int
+	var prev time.Time
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return 0, err
+		}
+
+		day, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return 0, err
+		} else if n > 0 && !day.AddDate(0, 0, 1).Equal(prev) {
+			break
+		}
+		prev = day
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, FormatError(err)
+	}
+
+	return n, nil
+}
+
+// createBadgeAward awards the badge unless the student already holds it and
+// notifies the student of a new award.
+func createBadgeAward(ctx context.Context, tx *Tx, award *ocs.BadgeAward) error {
+	award.AwardedAt = tx.now
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT OR IGNORE INTO badge_awards (
+      student_id,
+      badge_code,
+      name,
+      description,
+      awarded_at
+    )
+    VALUES (?, ?, ?, ?, ?)
+  `,
+		award.StudentID,
+		award.Badge.Code,
+		award.Badge.Name,
+		award.Badge.Description,
+		(*NullTime)(&award.AwardedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	} else if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return nil // already awarded
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	award.ID = int(id)
+
+	if err := audit(ctx, tx, ocs.AuditActionCreate, "badge_award", award.ID, nil, award); err != nil {
+		return err
+	}
+
+	tx.publishEvent(award.StudentID, ocs.Event{
+		Type:    ocs.EventTypeBadgeEarned,
+		Payload: &ocs.BadgeEarnedPayload{Award: award},
+	})
+
+	return nil
+}
+
+// findBadgeAwards returns awards of any student the site can see; badges are
+// public on profiles.
+func findBadgeAwards(ctx context.Context, tx *Tx, filter ocs.BadgeAwardFilter) (_ []*ocs.BadgeAward, n int, err error) {
+	where, args := tx.scope(byStudent, "student_id")
+	if v := filter.StudentID; v != nil {
+		where, args = append(where, "student_id = ?"), append(args, *v)
+	}
+	if v := filter.BadgeCode; v != nil {
+		where, args = append(where, "badge_code = ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      student_id,
+      badge_code,
+      name,
+      description,
+      awarded_at,
+      COUNT(*) OVER()
+    FROM badge_awards
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	awards := make([]*ocs.BadgeAward, 0)
+	for rows.Next() {
+		var award ocs.BadgeAward
+		if err := rows.Scan(
+			&award.ID,
+			&award.StudentID,
+			&award.Badge.Code,
+			&award.Badge.Name,
+			&award.Badge.Description,
+			(*NullTime)(&award.AwardedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		awards = append(awards, &award)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return awards, n, nil
+}
@@ -0,0 +1,169 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/mock"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestBadgeService_HandleEvent(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewBadgeService(db)
+
+		var events []ocs.Event
+		db.EventService = &mock.EventService{PublishEventFn: func(studentID int, event ocs.Event) {
+			events = append(events, event)
+		}}
+
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+
+		event := ocs.Event{Type: ocs.EventTypeSubmissionCreated, Payload: &ocs.SubmissionCreatedPayload{ID: 1}}
+		if err := s.HandleEvent(context.Background(), student.ID, event); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(events), 1; got != want {
+			t.Fatalf("len(events)=%v, want %v", got, want)
+		} else if got, want := events[0].Type, ocs.EventTypeBadgeEarned; got != want {
+			t.Fatalf("Type=%v, want %v", got, want)
+		}
+
+		if other, err := sqlite.NewStudentService(db).FindStudentByID(ctx, student.ID); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(other.Badges), 1; got != want {
+			t.Fatalf("len(Badges)=%v, want %v", got, want)
+		} else if got, want := other.Badges[0].Badge.Code, "first-submission"; got != want {
+			t.Fatalf("Code=%v, want %v", got, want)
+		}
+	})
+
+	// Replaying an event neither counts it again nor awards a badge twice.
+	t.Run("Replay", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewBadgeService(db)
+		s.Rules = []ocs.BadgeRule{{Badge: ocs.Badge{Code: "two"}, EventType: ocs.EventTypeSubmissionCreated, Count: 2}}
+
+		student, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+
+		event := ocs.Event{Type: ocs.EventTypeSubmissionCreated, Payload: &ocs.SubmissionCreatedPayload{ID: 1}}
+		for i := 0; i < 3; i++ {
+			if err := s.HandleEvent(context.Background(), student.ID, event); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, n, err := s.FindBadgeAwards(DefaultSiteContext(), ocs.BadgeAwardFilter{StudentID: &student.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		event.Payload = &ocs.SubmissionCreatedPayload{ID: 2}
+		for i := 0; i < 2; i++ {
+			if err := s.HandleEvent(context.Background(), student.ID, event); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, n, err := s.FindBadgeAwards(DefaultSiteContext(), ocs.BadgeAwardFilter{StudentID: &student.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("Match", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewBadgeService(db)
+
+		student, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+
+		code := "first-assignment-passed"
+		for i, grade := range []int{4, 5} {
+			if err := s.HandleEvent(context.Background(), student.ID, ocs.Event{
+				Type:    ocs.EventTypeSubmissionGraded,
+				Payload: &ocs.SubmissionGradedPayload{ID: i + 1, Grade: grade, MaxPoints: 10},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, n, err := s.FindBadgeAwards(DefaultSiteContext(), ocs.BadgeAwardFilter{StudentID: &student.ID, BadgeCode: &code}); err != nil {
+				t.Fatal(err)
+			} else if got, want := n, i; got != want {
+				t.Fatalf("%d. n=%v, want %v", i, got, want)
+			}
+		}
+	})
+
+	t.Run("Streak", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewBadgeService(db)
+		s.Rules = []ocs.BadgeRule{{Badge: ocs.Badge{Code: "streak"}, EventType: ocs.EventTypeSubmissionCreated, StreakDays: 3}}
+
+		student, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+
+		// A gap on day 2 resets the streak, so days 3-5 are needed.
+		now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+		for i, day := range []int{0, 1, 3, 4, 5} {
+			db.Now = func() time.Time { return now.AddDate(0, 0, day) }
+			if err := s.HandleEvent(context.Background(), student.ID, ocs.Event{
+				Type:    ocs.EventTypeSubmissionCreated,
+				Payload: &ocs.SubmissionCreatedPayload{ID: i + 1},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			want := 0
+			if day == 5 {
+				want = 1
+			}
+			if _, n, err := s.FindBadgeAwards(DefaultSiteContext(), ocs.BadgeAwardFilter{StudentID: &student.ID}); err != nil {
+				t.Fatal(err)
+			} else if n != want {
+				t.Fatalf("day %d: n=%v, want %v", day, n, want)
+			}
+		}
+	})
+
+	// Streak days are the student's local days on which the events
+	// happened, even if the events are handled later.
+	t.Run("StreakTimeZone", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewBadgeService(db)
+		s.Rules = []ocs.BadgeRule{{Badge: ocs.Badge{Code: "streak"}, EventType: ocs.EventTypeSubmissionCreated, StreakDays: 3}}
+
+		student, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com", TimeZone: "America/New_York"})
+
+		// In UTC, the first two fall on the same day.
+		loc, err := time.LoadLocation("America/New_York")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Now = func() time.Time { return time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC) }
+		for i, at := range []time.Time{
+			time.Date(2024, time.March, 1, 20, 0, 0, 0, loc),
+			time.Date(2024, time.March, 2, 10, 0, 0, 0, loc),
+			time.Date(2024, time.March, 3, 10, 0, 0, 0, loc),
+		} {
+			if err := s.HandleEvent(context.Background(), student.ID, ocs.Event{
+				Type:       ocs.EventTypeSubmissionCreated,
+				Payload:    &ocs.SubmissionCreatedPayload{ID: i + 1},
+				OccurredAt: at,
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, n, err := s.FindBadgeAwards(DefaultSiteContext(), ocs.BadgeAwardFilter{StudentID: &student.ID}); err != nil {
+			t.Fatal(err)
+		} else if n != 1 {
+			t.Fatalf("n=%v, want 1", n)
+		}
+	})
+}
@@ -0,0 +1,471 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.CourseService = (*CourseService)(nil)
+
+type CourseService struct {
+	db *DB
+}
+
+func NewCourseService(db *DB) *CourseService {
+	return &CourseService{db: db}
+}
+
+func (s *CourseService) FindCourseByID(ctx context.Context, id int) (*ocs.Course, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	course, err := findCourseByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachCourseAssociations(ctx, tx, course); err != nil {
+		return nil, err
+	}
+
+	return course, nil
+}
+
+func (s *CourseService) FindCourses(ctx context.Context, filter ocs.CourseFilter) ([]*ocs.Course, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	courses, n, err := findCourses(ctx, tx, filter)
+	if err != nil {
+		return courses, n, err
+	}
+
+	for _, course := range courses {
+		if err := attachCourseAssociations(ctx, tx, course); err != nil {
+			return courses, n, err
+		}
+	}
+	return courses, n, nil
+}
+
+func (s *CourseService) CreateCourse(ctx context.Context, course *ocs.Course) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createCourse(ctx, tx, course); err != nil {
+		return err
+	} else if err := attachCourseAssociations(ctx, tx, course); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *CourseService) UpdateCourse(ctx context.Context, id int, upd ocs.CourseUpdate) (*ocs.Course, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	course, err := updateCourse(ctx, tx, id, upd)
+	if err != nil {
+		return course, err
+	} else if err := attachCourseAssociations(ctx, tx, course); err != nil {
+		return course, err
+	} else if err := tx.Commit(); err != nil {
+		return course, err
+	}
+
+	return course, nil
+}
+
+func (s *CourseService) DeleteCourse(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteCourse(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *CourseService) RestoreCourse(ctx context.Context, id int) (*ocs.Course, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	course, err := restoreCourse(ctx, tx, id)
+	if err != nil {
+		return course, err
+	} else if err := attachCourseAssociations(ctx, tx, course); err != nil {
+		return course, err
+	} else if err := tx.Commit(); err != nil {
+		return course, err
+	}
+
+	return course, nil
+}
+
+func createCourse(ctx context.Context, tx *Tx, course *ocs.Course) error {
+	course.InstructorID = ocs.StudentIDFromContext(ctx)
+	if course.InstructorID == 0 {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You must be logged in to create a course.")
+	}
+	course.OrganizationID = tx.orgID
+	if course.ReminderHours == nil {
+		course.ReminderHours = append([]int(nil), ocs.DefaultReminderHours...)
+	}
+
+	course.CreatedAt = tx.now
+	course.UpdatedAt = course.CreatedAt
+
+	if err := course.Validate(); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO courses (
+      instructor_id,
+      title,
+      description,
+      price,
+      currency,
+      organization_id,
+      student_messaging_disabled,
+      reminder_hours,
+      reminders_disabled,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+  `,
+		course.InstructorID,
+		course.Title,
+		course.Description,
+		course.Price,
+		course.Currency,
+		course.OrganizationID,
+		course.StudentMessagingDisabled,
+		reminderHours(course.ReminderHours),
+		course.RemindersDisabled,
+		(*NullTime)(&course.CreatedAt),
+		(*NullTime)(&course.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	course.ID = int(id)
+
+	return audit(ctx, tx, ocs.AuditActionCreate, "course", course.ID, nil, course)
+}
+
+func findCourseByID(ctx context.Context, tx *Tx, id int) (*ocs.Course, error) {
+	a, _, err := findCourses(ctx, tx, ocs.CourseFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Course not found."}
+	}
+	return a[0], nil
+}
+
+// findAnyCourseByID finds a course even if it has been deleted, so that
+// what refers to it can still be shown.
+func findAnyCourseByID(ctx context.Context, tx *Tx, id int) (*ocs.Course, error) {
+	where, args := tx.scope(byOrganization, "organization_id")
+	where, args = append(where, "id = ?"), append(args, id)
+
+	a, _, err := queryCourses(ctx, tx, where, args, 0, 0)
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Course not found."}
+	}
+	return a[0], nil
+}
+
+// findCourses leaves out deleted courses.
+func findCourses(ctx context.Context, tx *Tx, filter ocs.CourseFilter) (_ []*ocs.Course, n int, err error) {
+	where, args := tx.scope(byOrganization, "organization_id")
+	where = append(where, "deleted_at IS NULL")
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.InstructorID; v != nil {
+		where, args = append(where, "instructor_id = ?"), append(args, *v)
+	}
+	return queryCourses(ctx, tx, where, args, filter.Limit, filter.Offset)
+}
+
+func queryCourses(ctx context.Context, tx *Tx, where []string, args []interface{}, limit, offset int) (_ []*ocs.Course, n int, err error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      instructor_id,
+      title,
+      description,
+      price,
+      currency,
+      organization_id,
+      student_messaging_disabled,
+      reminder_hours,
+      reminders_disabled,
+      created_at,
+      updated_at,
+      deleted_at,
+      COUNT(*) OVER()
+    FROM courses
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(limit, offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	courses := make([]*ocs.Course, 0)
+	for rows.Next() {
+		var course ocs.Course
+		var hours string
+		var deletedAt NullTime
+		if err := rows.Scan(
+			&course.ID,
+			&course.InstructorID,
+			&course.Title,
+			&course.Description,
+			&course.Price,
+			&course.Currency,
+			&course.OrganizationID,
+			&course.StudentMessagingDisabled,
+			&hours,
+			&course.RemindersDisabled,
+			(*NullTime)(&course.CreatedAt),
+			(*NullTime)(&course.UpdatedAt),
+			&deletedAt,
+			&n,
+		); err != nil {
+			return nil, 0, err
+		} else if err := json.Unmarshal([]byte(hours), &course.ReminderHours); err != nil {
+			return nil, 0, fmt.Errorf("course reminder hours: %w", err)
+		}
+		if v := (time.Time)(deletedAt); !v.IsZero() {
+			course.DeletedAt = &v
+		}
+		courses = append(courses, &course)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return courses, n, nil
+}
+
+func updateCourse(ctx context.Context, tx *Tx, id int, upd ocs.CourseUpdate) (*ocs.Course, error) {
+	course, err := findCourseByID(ctx, tx, id)
+	if err != nil {
+		return course, err
+	} else if course.InstructorID != ocs.StudentIDFromContext(ctx) {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to update this course.")
+	}
+	prev := *course
+
+	if v := upd.Title; v != nil {
+		course.Title = *v
+	}
+	if v := upd.Description; v != nil {
+		course.Description = *v
+	}
+	if v := upd.Price; v != nil {
+		course.Price = *v
+	}
+	if v := upd.Currency; v != nil {
+		course.Currency = *v
+	}
+	if v := upd.StudentMessagingDisabled; v != nil {
+		course.StudentMessagingDisabled = *v
+	}
+	if v := upd.ReminderHours; v != nil {
+		course.ReminderHours = *v
+	}
+	if v := upd.RemindersDisabled; v != nil {
+		course.RemindersDisabled = *v
+	}
+
+	course.UpdatedAt = tx.now
+
+	if err := course.Validate(); err != nil {
+		return course, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE courses
+    SET title = ?,
+        description = ?,
+        price = ?,
+        currency = ?,
+        student_messaging_disabled = ?,
+        reminder_hours = ?,
+        reminders_disabled = ?,
+        updated_at = ?
+    WHERE id = ?
+    `,
+		course.Title,
+		course.Description,
+		course.Price,
+		course.Currency,
+		course.StudentMessagingDisabled,
+		reminderHours(course.ReminderHours),
+		course.RemindersDisabled,
+		(*NullTime)(&course.UpdatedAt),
+		id,
+	); err != nil {
+		return course, FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "course", id, &prev, course); err != nil {
+		return course, err
+	}
+
+	return course, nil
+}
+
+// deleteCourse marks the course as deleted. It is kept until it is purged,
+// so that an admin can restore it.
+func deleteCourse(ctx context.Context, tx *Tx, id int) error {
+	course, err := findCourseByID(ctx, tx, id)
+	if err != nil {
+		return err
+	} else if course.InstructorID != ocs.StudentIDFromContext(ctx) {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to delete this course.")
+	}
+	prev := *course
+
+	deletedAt := tx.now
+	course.DeletedAt = &deletedAt
+	course.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE courses SET deleted_at = ?, updated_at = ? WHERE id = ?
+  `,
+		(*NullTime)(course.DeletedAt),
+		(*NullTime)(&course.UpdatedAt),
+		id,
+	); err != nil {
+		return FormatError(err)
+	}
+
+	return audit(ctx, tx, ocs.AuditActionDelete, "course", id, &prev, course)
+}
+
+// restoreCourse lets an admin undo a deletion within the retention window.
+func restoreCourse(ctx context.Context, tx *Tx, id int) (*ocs.Course, error) {
+	if ok, err := isAdmin(ctx, tx); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "Only admins may restore courses.")
+	}
+
+	course, err := findAnyCourseByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if course.DeletedAt == nil {
+		return course, ocs.Errorf(ocs.ECONFLICT, "Course is not deleted.")
+	} else if !tx.now.Before(course.DeletedAt.Add(tx.db.Retention)) {
+		return course, ocs.Errorf(ocs.ECONFLICT, "Course can no longer be restored.")
+	}
+	prev := *course
+
+	course.DeletedAt = nil
+	course.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE courses SET deleted_at = NULL, updated_at = ? WHERE id = ?
+  `,
+		(*NullTime)(&course.UpdatedAt),
+		id,
+	); err != nil {
+		return course, FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionRestore, "course", id, &prev, course); err != nil {
+		return course, err
+	}
+
+	return course, nil
+}
+
+// isCourseStaff reports whether the student is the instructor or a TA of the course.
+func isCourseStaff(ctx context.Context, tx *Tx, courseID, studentID int) (bool, error) {
+	if studentID == 0 {
+		return false, nil
+	}
+
+	where, args := tx.scope(byOrganization, "organization_id")
+	where = append(where, "id = ?", `(
+      instructor_id = ? OR
+      id IN (SELECT course_id FROM enrollments WHERE student_id = ? AND role = ?)
+    )`)
+	args = append(args, courseID, studentID, studentID, ocs.EnrollmentRoleTA)
+
+	var n int
+	if err := tx.QueryRowContext(ctx, `
+    SELECT COUNT(*) FROM courses WHERE `+strings.Join(where, " AND "),
+		args...,
+	).Scan(&n); err != nil {
+		return false, FormatError(err)
+	}
+	return n != 0, nil
+}
+
+// isCourseMember reports whether the student is on the course staff or enrolled in it.
+func isCourseMember(ctx context.Context, tx *Tx, courseID, studentID int) (bool, error) {
+	if studentID == 0 {
+		return false, nil
+	}
+
+	where, args := tx.scope(byOrganization, "organization_id")
+	where = append(where, "id = ?", `(
+      instructor_id = ? OR
+      id IN (SELECT course_id FROM enrollments WHERE student_id = ?)
+    )`)
+	args = append(args, courseID, studentID, studentID)
+
+	var n int
+	if err := tx.QueryRowContext(ctx, `
+    SELECT COUNT(*) FROM courses WHERE `+strings.Join(where, " AND "),
+		args...,
+	).Scan(&n); err != nil {
+		return false, FormatError(err)
+	}
+	return n != 0, nil
+}
+
+func attachCourseAssociations(ctx context.Context, tx *Tx, course *ocs.Course) (err error) {
+	if course.Instructor, err = findAnyStudentByID(ctx, tx, course.InstructorID); err != nil {
+		return fmt.Errorf("attach course instructor: %w", err)
+	}
+	return nil
+}
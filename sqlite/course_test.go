@@ -0,0 +1,160 @@
+package sqlite_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestCourseService_CreateCourse(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewCourseService(db)
+
+		instructor, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+
+		course := &ocs.Course{Title: "Go 101", Description: "Intro to Go"}
+		if err := s.CreateCourse(ctx, course); err != nil {
+			t.Fatal(err)
+		} else if got, want := course.ID, 1; got != want {
+			t.Fatalf("ID=%v, want %v", got, want)
+		} else if got, want := course.InstructorID, instructor.ID; got != want {
+			t.Fatalf("InstructorID=%v, want %v", got, want)
+		} else if course.CreatedAt.IsZero() {
+			t.Fatal("expected created at")
+		}
+
+		if other, err := s.FindCourseByID(ctx, 1); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(course, other) {
+			t.Fatalf("mismatch: %#v != %#v", course, other)
+		}
+	})
+
+	t.Run("ErrTitleRequired", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+
+		if err := sqlite.NewCourseService(db).CreateCourse(ctx, &ocs.Course{}); err == nil {
+			t.Fatal("expected error")
+		} else if ocs.ErrorCode(err) != ocs.EINVALID || ocs.ErrorMessage(err) != `Title required.` {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		if err := sqlite.NewCourseService(db).CreateCourse(DefaultSiteContext(), &ocs.Course{Title: "X"}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestCourseService_UpdateCourse(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewCourseService(db)
+
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		course := MustCreateCourse(t, ctx, db, &ocs.Course{Title: "Go 101"})
+
+		title := "Go 102"
+		if other, err := s.UpdateCourse(ctx, course.ID, ocs.CourseUpdate{Title: &title}); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.Title, title; got != want {
+			t.Fatalf("Title=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewCourseService(db)
+
+		_, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		_, ctx1 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "Go 101"})
+
+		title := "X"
+		if _, err := s.UpdateCourse(ctx1, course.ID, ocs.CourseUpdate{Title: &title}); err == nil {
+			t.Fatal("expected error")
+		} else if ocs.ErrorCode(err) != ocs.EUNAUTHORIZED || ocs.ErrorMessage(err) != `You are not allowed to update this course.` {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestCourseService_DeleteCourse(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewCourseService(db)
+
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		course := MustCreateCourse(t, ctx, db, &ocs.Course{Title: "Go 101"})
+
+		if err := s.DeleteCourse(ctx, course.ID); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.FindCourseByID(ctx, course.ID); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestCourseService_RestoreCourse(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewCourseService(db)
+
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+		course := MustCreateCourse(t, ctx, db, &ocs.Course{Title: "Go 101"})
+
+		if err := s.DeleteCourse(ctx, course.ID); err != nil {
+			t.Fatal(err)
+		} else if _, n, err := s.FindCourses(ctx, ocs.CourseFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		if other, err := s.RestoreCourse(ctx, course.ID); err != nil {
+			t.Fatal(err)
+		} else if other.DeletedAt != nil {
+			t.Fatalf("unexpected DeletedAt: %v", other.DeletedAt)
+		} else if _, err := s.FindCourseByID(ctx, course.ID); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewCourseService(db)
+
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		course := MustCreateCourse(t, ctx, db, &ocs.Course{Title: "Go 101"})
+
+		if err := s.DeleteCourse(ctx, course.ID); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.RestoreCourse(ctx, course.ID); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func MustCreateCourse(tb testing.TB, ctx context.Context, db *sqlite.DB, course *ocs.Course) *ocs.Course {
+	tb.Helper()
+	if err := sqlite.NewCourseService(db).CreateCourse(ctx, course); err != nil {
+		tb.Fatal(err)
+	}
+	return course
+}
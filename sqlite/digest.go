@@ -0,0 +1,214 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+// Default settings of the digest scheduler.
+const (
+	DefaultDigestInterval = 5 * time.Minute
+	DefaultDigestHour     = 8
+)
+
+// DigestScheduler collects the notifications of students who chose a daily
+// or weekly digest into one email per period. Daily periods end at Hour in
+// the student's time zone; weekly periods end at Hour on Monday.
+//
+// Each sent digest is recorded with its period in the same transaction that
+// queues the email, so a restarted scheduler never sends a period twice.
+type DigestScheduler struct {
+	db *DB
+
+	Interval time.Duration
+	Hour     int
+}
+
+func NewDigestScheduler(db *DB) *DigestScheduler {
+	return &DigestScheduler{
+		db:       db,
+		Interval: DefaultDigestInterval,
+		Hour:     DefaultDigestHour,
+	}
+}
+
+// Open starts sending digests in the background. Sending stops when the
+// database is closed.
+func (s *DigestScheduler) Open() {
+	go s.run()
+}
+
+func (s *DigestScheduler) run() {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.db.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := s.SendDigests(s.db.ctx); err != nil {
+			log.Printf("digest scheduler error: %s", err)
+		}
+	}
+}
+
+// SendDigests queues a digest email for every student whose current period
+// has ended with notifications not yet emailed. It returns the number of
+// digests queued.
+func (s *DigestScheduler) SendDigests(ctx context.Context) (int, error) {
+	ctx = withAllOrganizations(ctx)
+
+	studentIDs, err := s.findPendingStudentIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var sent int
+	for _, studentID := range studentIDs {
+		if ok, err := s.sendDigest(ctx, studentID); err != nil {
+			return sent, err
+		} else if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// findPendingStudentIDs returns the digest students with notifications that
+// have not been emailed.
+func (s *DigestScheduler) findPendingStudentIDs(ctx context.Context) ([]int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT DISTINCT n.student_id
+    FROM notifications n
+    INNER JOIN students s ON s.id = n.student_id
+    WHERE n.emailed_at IS NULL AND s.deleted_at IS NULL AND s.erased_at IS NULL AND s.email_digest IN (?, ?)
+    ORDER BY n.student_id ASC
+  `,
+		ocs.EmailDigestDaily,
+		ocs.EmailDigestWeekly,
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+	return ids, nil
+}
+
+// sendDigest queues the digest for the student's last ended period, if it
+// has not been sent and has notifications.
+func (s *DigestScheduler) sendDigest(ctx context.Context, studentID int) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	student, err := findStudentByID(ctx, tx, studentID)
+	if err != nil {
+		return false, err
+	} else if student.EmailDigest == ocs.EmailDigestImmediate {
+		return false, nil
+	}
+
+	key, end := digestPeriod(student.EmailDigest, tx.now.In(student.Location()), s.Hour)
+
+	notifications, _, err := queryNotifications(ctx, tx,
+		[]string{"student_id = ?", "emailed_at IS NULL", "created_at < ?"},
+		[]interface{}{studentID, (*NullTime)(&end)},
+		0, 0,
+	)
+	if err != nil {
+		return false, err
+	} else if len(notifications) == 0 {
+		return false, nil
+	}
+
+	if result, err := tx.ExecContext(ctx, `
+    INSERT OR IGNORE INTO digests (
+      student_id,
+      period_key,
+      notification_count,
+      created_at
+    )
+    VALUES (?, ?, ?, ?)
+  `,
+		studentID,
+		key,
+		len(notifications),
+		(*NullTime)(&tx.now),
+	); err != nil {
+		return false, FormatError(err)
+	} else if n, err := result.RowsAffected(); err != nil {
+		return false, err
+	} else if n == 0 {
+		return false, nil // already sent for this period
+	}
+
+	if err := enqueueEmail(ctx, tx, ocs.DigestEmail, ocs.EmailData{
+		Student:       student,
+		Notifications: notifications,
+		Period:        student.EmailDigest,
+	}); err != nil {
+		return false, err
+	} else if err := markNotificationsEmailed(ctx, tx, notifications); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// digestPeriod returns the key and end of the last digest period that ended
+// at or before now. Periods end at hour in now's location.
+func digestPeriod(digest string, now time.Time, hour int) (key string, end time.Time) {
+	end = time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if end.After(now) {
+		end = end.AddDate(0, 0, -1)
+	}
+	if digest == ocs.EmailDigestWeekly {
+		for end.Weekday() != time.Monday {
+			end = end.AddDate(0, 0, -1)
+		}
+	}
+	return fmt.Sprintf("%s:%s", digest, end.Format("2006-01-02")), end
+}
+
+// markNotificationsEmailed records that the notifications went out by email.
+// Like the email outbox, it is delivery bookkeeping and is not audited.
+func markNotificationsEmailed(ctx context.Context, tx *Tx, notifications []*ocs.Notification) error {
+	for _, notification := range notifications {
+		if _, err := tx.ExecContext(ctx, `
+      UPDATE notifications SET emailed_at = ? WHERE id = ?
+    `,
+			(*NullTime)(&tx.now),
+			notification.ID,
+		); err != nil {
+			return FormatError(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/mock"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestDigestScheduler_SendDigests(t *testing.T) {
+	t.Run("Daily", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewNotificationService(db)
+
+		now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		ann, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com", TimeZone: "Africa/Johannesburg"})
+		bob, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com", TimeZone: "America/New_York"})
+		for id := 1; id <= 2; id++ {
+			MustHandleNotification(t, s, ann.ID, ocs.Event{Type: ocs.EventTypeSubmissionGraded, Payload: &ocs.SubmissionGradedPayload{ID: id}})
+		}
+		MustHandleNotification(t, s, bob.ID, ocs.Event{Type: ocs.EventTypeBadgeEarned, Payload: &ocs.BadgeEarnedPayload{}})
+		MustDeliverEmails(t, db)
+
+		// 07:30 UTC is after 08:00 in Johannesburg but before 08:00 in New
+		// York, so only ann's day has ended.
+		now = time.Date(2026, time.October, 19, 7, 30, 0, 0, time.UTC)
+		if n, err := sqlite.NewDigestScheduler(db).SendDigests(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		// A restarted scheduler does not send the period again, even if
+		// new notifications arrive during it.
+		MustHandleNotification(t, s, ann.ID, ocs.Event{Type: ocs.EventTypeSubmissionGraded, Payload: &ocs.SubmissionGradedPayload{ID: 3}})
+		if n, err := sqlite.NewDigestScheduler(db).SendDigests(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		now = time.Date(2026, time.October, 19, 12, 30, 0, 0, time.UTC)
+		if n, err := sqlite.NewDigestScheduler(db).SendDigests(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		if sent := MustDeliverEmails(t, db); len(sent) != 2 {
+			t.Fatalf("len=%v, want 2", len(sent))
+		} else if got, want := sent[0].Subject, "Your daily digest: 2 new notifications"; got != want {
+			t.Fatalf("Subject=%v, want %v", got, want)
+		} else if got, want := sent[1].To, "bob@email.com"; got != want {
+			t.Fatalf("To=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("Weekly", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewNotificationService(db)
+
+		now := time.Date(2026, time.October, 14, 12, 0, 0, 0, time.UTC) // Wednesday
+		db.Now = func() time.Time { return now }
+
+		ann, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com", EmailDigest: ocs.EmailDigestWeekly})
+		MustHandleNotification(t, s, ann.ID, ocs.Event{Type: ocs.EventTypeBadgeEarned, Payload: &ocs.BadgeEarnedPayload{}})
+
+		// The week has not ended by Sunday.
+		now = time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+		if n, err := sqlite.NewDigestScheduler(db).SendDigests(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		now = time.Date(2026, time.October, 19, 9, 0, 0, 0, time.UTC)
+		if n, err := sqlite.NewDigestScheduler(db).SendDigests(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("Immediate", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewNotificationService(db)
+
+		ann, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com", EmailDigest: ocs.EmailDigestImmediate})
+		MustHandleNotification(t, s, ann.ID, ocs.Event{Type: ocs.EventTypeBadgeEarned, Payload: &ocs.BadgeEarnedPayload{}})
+
+		if sent := MustDeliverEmails(t, db); len(sent) != 2 {
+			t.Fatalf("len=%v, want 2", len(sent))
+		} else if got, want := sent[1].Subject, "You earned a badge."; got != want {
+			t.Fatalf("Subject=%v, want %v", got, want)
+		}
+
+		db.Now = func() time.Time { return time.Now().AddDate(0, 0, 8) }
+		if n, err := sqlite.NewDigestScheduler(db).SendDigests(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+}
+
+// MustDeliverEmails delivers the pending emails in the outbox and returns
+// them in the order they were sent.
+func MustDeliverEmails(tb testing.TB, db *sqlite.DB) []*ocs.Email {
+	tb.Helper()
+
+	var sent []*ocs.Email
+	outbox := sqlite.NewEmailOutbox(db, &mock.EmailSender{SendEmailFn: func(ctx context.Context, email *ocs.Email) error {
+		sent = append(sent, email)
+		return nil
+	}})
+	if _, err := outbox.DeliverPending(context.Background()); err != nil {
+		tb.Fatal(err)
+	}
+	return sent
+}
@@ -0,0 +1,270 @@
+package sqlite
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+// Default delivery settings of the email outbox.
+const (
+	DefaultEmailInterval    = 10 * time.Second
+	DefaultEmailBackoff     = time.Minute
+	DefaultEmailMaxAttempts = 8
+	DefaultEmailBatchSize   = 50
+)
+
+// EmailOutbox delivers the emails queued in the outbox through Sender.
+// Failed deliveries are retried with exponential backoff, starting at
+// Backoff, until MaxAttempts is reached.
+type EmailOutbox struct {
+	db *DB
+
+	Sender      ocs.EmailSender
+	Interval    time.Duration
+	Backoff     time.Duration
+	MaxAttempts int
+	BatchSize   int
+}
+
+func NewEmailOutbox(db *DB, sender ocs.EmailSender) *EmailOutbox {
+	return &EmailOutbox{
+		db:          db,
+		Sender:      sender,
+		Interval:    DefaultEmailInterval,
+		Backoff:     DefaultEmailBackoff,
+		MaxAttempts: DefaultEmailMaxAttempts,
+		BatchSize:   DefaultEmailBatchSize,
+	}
+}
+
+// Open starts delivering emails in the background. Delivery stops when the
+// database is closed.
+func (o *EmailOutbox) Open() {
+	go o.run()
+}
+
+func (o *EmailOutbox) run() {
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.db.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := o.DeliverPending(o.db.ctx); err != nil {
+			log.Printf("email outbox error: %s", err)
+		}
+	}
+}
+
+// DeliverPending sends the emails that are due and returns how many were
+// sent. Each email is claimed before it is sent so that concurrent workers
+// do not deliver it twice.
+func (o *EmailOutbox) DeliverPending(ctx context.Context) (int, error) {
+	ctx = withAllOrganizations(ctx)
+
+	emails, err := o.claimPending(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var sent int
+	for _, email := range emails {
+		sendErr := o.Sender.SendEmail(ctx, email)
+		if err := o.finish(ctx, email, sendErr); err != nil {
+			return sent, err
+		} else if sendErr == nil {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// claimPending finds due emails and pushes their next attempt out by the
+// backoff, which keeps other workers away while they are being sent.
+func (o *EmailOutbox) claimPending(ctx context.Context) ([]*ocs.Email, error) {
+	tx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      student_id,
+      template,
+      recipient,
+      subject,
+      text_body,
+      html_body,
+      status,
+      attempts,
+      next_attempt_at,
+      created_at
+    FROM emails
+    WHERE status = ? AND next_attempt_at <= ?
+    ORDER BY next_attempt_at ASC, id ASC
+    `+FormatLimitOffset(o.BatchSize, 0),
+		ocs.EmailStatusPending,
+		(*NullTime)(&tx.now),
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	emails := make([]*ocs.Email, 0)
+	for rows.Next() {
+		var email ocs.Email
+		if err := rows.Scan(
+			&email.ID,
+			&email.StudentID,
+			&email.Template,
+			&email.To,
+			&email.Subject,
+			&email.Text,
+			&email.HTML,
+			&email.Status,
+			&email.Attempts,
+			(*NullTime)(&email.NextAttemptAt),
+			(*NullTime)(&email.CreatedAt),
+		); err != nil {
+			return nil, err
+		}
+		emails = append(emails, &email)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+
+	claimed := emails[:0]
+	for _, email := range emails {
+		leaseUntil := tx.now.Add(o.backoff(email.Attempts + 1))
+		if result, err := tx.ExecContext(ctx, `
+      UPDATE emails SET next_attempt_at = ? WHERE id = ? AND next_attempt_at = ?
+    `,
+			(*NullTime)(&leaseUntil),
+			email.ID,
+			(*NullTime)(&email.NextAttemptAt),
+		); err != nil {
+			return nil, FormatError(err)
+		} else if n, err := result.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 1 {
+			email.NextAttemptAt = leaseUntil
+			claimed = append(claimed, email)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// finish records the outcome of a delivery attempt. A failed email keeps
+// the next attempt time set when it was claimed.
+func (o *EmailOutbox) finish(ctx context.Context, email *ocs.Email, sendErr error) error {
+	tx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	email.Attempts++
+	switch {
+	case sendErr == nil:
+		email.Status, email.LastError = ocs.EmailStatusSent, ""
+		email.SentAt = &tx.now
+	case email.Attempts >= o.MaxAttempts:
+		email.Status, email.LastError = ocs.EmailStatusFailed, sendErr.Error()
+	default:
+		email.LastError = sendErr.Error()
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE emails
+    SET status = ?,
+        attempts = ?,
+        last_error = ?,
+        sent_at = ?
+    WHERE id = ?
+  `,
+		email.Status,
+		email.Attempts,
+		email.LastError,
+		(*NullTime)(email.SentAt),
+		email.ID,
+	); err != nil {
+		return FormatError(err)
+	}
+
+	return tx.Commit()
+}
+
+// backoff returns the delay before the given attempt, doubling each time.
+func (o *EmailOutbox) backoff(attempt int) time.Duration {
+	d := o.Backoff
+	for i := 1; i < attempt && d < 24*time.Hour; i++ {
+		d *= 2
+	}
+	return d
+}
+
+// enqueueEmail renders an email for the student and writes it to the outbox
+// as part of tx, so it is only sent if the change causing it commits.
+func enqueueEmail(ctx context.Context, tx *Tx, tmpl *ocs.EmailTemplate, data ocs.EmailData) error {
+	email, err := tmpl.Render(data)
+	if err != nil {
+		return err
+	} else if email.To == "" {
+		return nil
+	}
+
+	email.Status = ocs.EmailStatusPending
+	email.NextAttemptAt = tx.now
+	email.CreatedAt = tx.now
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO emails (
+      student_id,
+      template,
+      recipient,
+      subject,
+      text_body,
+      html_body,
+      status,
+      next_attempt_at,
+      created_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+  `,
+		email.StudentID,
+		email.Template,
+		email.To,
+		email.Subject,
+		email.Text,
+		email.HTML,
+		email.Status,
+		(*NullTime)(&email.NextAttemptAt),
+		(*NullTime)(&email.CreatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	email.ID = int(id)
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/inmem"
+	"github.com/maliByatzes/ocs/mock"
+	"github.com/maliByatzes/ocs/smtp"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestEmailOutbox_DeliverPending(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		server := inmem.NewSMTPServer()
+		if err := server.Open(); err != nil {
+			t.Fatal(err)
+		}
+		defer server.Close()
+		outbox := sqlite.NewEmailOutbox(db, smtp.NewSender(server.Addr, "noreply@ocs.test"))
+
+		// Creating a student queues a welcome email; enrolling queues a
+		// confirmation.
+		_, instructorCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, instructorCtx, db, &ocs.Course{Title: "Go 101"})
+		MustCreateEnrollment(t, ctx, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student.ID})
+
+		if n, err := outbox.DeliverPending(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 3; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		messages := server.Messages()
+		if got, want := len(messages), 3; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		} else if got, want := messages[2].To[0], "bob@email.com"; got != want {
+			t.Fatalf("To=%v, want %v", got, want)
+		} else if !strings.Contains(string(messages[2].Data), "Subject: You are enrolled in Go 101") {
+			t.Fatalf("unexpected message: %s", messages[2].Data)
+		}
+
+		// Sent emails are not sent again.
+		if n, err := outbox.DeliverPending(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Now()
+		db.Now = func() time.Time { return now }
+
+		var attempts int
+		outbox := sqlite.NewEmailOutbox(db, &mock.EmailSender{SendEmailFn: func(ctx context.Context, email *ocs.Email) error {
+			if attempts++; attempts < 3 {
+				return errors.New("connection refused")
+			}
+			return nil
+		}})
+		outbox.Backoff = time.Minute
+
+		MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+
+		// Retries wait one minute, then two.
+		for _, step := range []struct {
+			wait     time.Duration
+			attempts int
+			sent     int
+		}{
+			{0, 1, 0},
+			{30 * time.Second, 1, 0},
+			{30 * time.Second, 2, 0},
+			{time.Minute, 2, 0},
+			{time.Minute, 3, 1},
+			{time.Hour, 3, 0},
+		} {
+			now = now.Add(step.wait)
+			if n, err := outbox.DeliverPending(context.Background()); err != nil {
+				t.Fatal(err)
+			} else if n != step.sent || attempts != step.attempts {
+				t.Fatalf("after %v: sent=%v attempts=%v, want %v and %v", step.wait, n, attempts, step.sent, step.attempts)
+			}
+		}
+	})
+
+	t.Run("MaxAttempts", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Now()
+		db.Now = func() time.Time { return now }
+
+		var attempts int
+		outbox := sqlite.NewEmailOutbox(db, &mock.EmailSender{SendEmailFn: func(ctx context.Context, email *ocs.Email) error {
+			attempts++
+			return errors.New("mailbox unavailable")
+		}})
+		outbox.MaxAttempts = 2
+
+		MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+
+		for i := 0; i < 4; i++ {
+			if _, err := outbox.DeliverPending(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			now = now.Add(24 * time.Hour)
+		}
+		if got, want := attempts, 2; got != want {
+			t.Fatalf("attempts=%v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,347 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.EnrollmentService = (*EnrollmentService)(nil)
+
+type EnrollmentService struct {
+	db *DB
+}
+
+func NewEnrollmentService(db *DB) *EnrollmentService {
+	return &EnrollmentService{db: db}
+}
+
+func (s *EnrollmentService) FindEnrollmentByID(ctx context.Context, id int) (*ocs.Enrollment, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	enrollment, err := findEnrollmentByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachEnrollmentAssociations(ctx, tx, enrollment); err != nil {
+		return nil, err
+	}
+
+	return enrollment, nil
+}
+
+func (s *EnrollmentService) FindEnrollments(ctx context.Context, filter ocs.EnrollmentFilter) ([]*ocs.Enrollment, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	enrollments, n, err := findEnrollments(ctx, tx, filter)
+	if err != nil {
+		return enrollments, n, err
+	}
+
+	for _, enrollment := range enrollments {
+		if err := attachEnrollmentAssociations(ctx, tx, enrollment); err != nil {
+			return enrollments, n, err
+		}
+	}
+	return enrollments, n, nil
+}
+
+func (s *EnrollmentService) CreateEnrollment(ctx context.Context, enrollment *ocs.Enrollment) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createEnrollment(ctx, tx, enrollment); err != nil {
+		return err
+	} else if err := attachEnrollmentAssociations(ctx, tx, enrollment); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *EnrollmentService) DeleteEnrollment(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteEnrollment(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *EnrollmentService) CompleteEnrollment(ctx context.Context, id int) (*ocs.Enrollment, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	enrollment, err := completeEnrollment(ctx, tx, id)
+	if err != nil {
+		return enrollment, err
+	} else if err := attachEnrollmentAssociations(ctx, tx, enrollment); err != nil {
+		return enrollment, err
+	} else if err := tx.Commit(); err != nil {
+		return enrollment, err
+	}
+
+	return enrollment, nil
+}
+
+func createEnrollment(ctx context.Context, tx *Tx, enrollment *ocs.Enrollment) error {
+	if enrollment.Role == "" {
+		enrollment.Role = ocs.EnrollmentRoleStudent
+	}
+
+	enrollment.CreatedAt = tx.now
+	enrollment.UpdatedAt = enrollment.CreatedAt
+
+	if err := enrollment.Validate(); err != nil {
+		return err
+	}
+
+	// Students may enroll themselves in free courses; anyone else must be
+	// enrolled by the instructor.
+	course, err := findCourseByID(ctx, tx, enrollment.CourseID)
+	if err != nil {
+		return err
+	} else if _, err := findStudentByID(ctx, tx, enrollment.StudentID); err != nil {
+		return err
+	}
+	if studentID := ocs.StudentIDFromContext(ctx); studentID != course.InstructorID {
+		if studentID != enrollment.StudentID || enrollment.Role != ocs.EnrollmentRoleStudent {
+			return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to create this enrollment.")
+		} else if course.Price > 0 {
+			return ocs.Errorf(ocs.EUNAUTHORIZED, "You must buy this course to enroll.")
+		}
+	}
+
+	return insertEnrollment(ctx, tx, enrollment)
+}
+
+// insertEnrollment inserts a validated enrollment without checking who may
+// create it. Callers are responsible for authorization.
+func insertEnrollment(ctx context.Context, tx *Tx, enrollment *ocs.Enrollment) error {
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO enrollments (
+      course_id,
+      student_id,
+      role,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?)
+  `,
+		enrollment.CourseID,
+		enrollment.StudentID,
+		enrollment.Role,
+		(*NullTime)(&enrollment.CreatedAt),
+		(*NullTime)(&enrollment.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	enrollment.ID = int(id)
+
+	if err := audit(ctx, tx, ocs.AuditActionCreate, "enrollment", enrollment.ID, nil, enrollment); err != nil {
+		return err
+	}
+
+	if enrollment.Role != ocs.EnrollmentRoleStudent {
+		return nil
+	}
+
+	// Confirm the enrollment to the student.
+	student, err := findStudentByID(ctx, tx, enrollment.StudentID)
+	if err != nil {
+		return err
+	}
+	course, err := findCourseByID(ctx, tx, enrollment.CourseID)
+	if err != nil {
+		return err
+	}
+	return enqueueEmail(ctx, tx, ocs.EnrollmentEmail, ocs.EmailData{Student: student, Course: course})
+}
+
+func findEnrollmentByID(ctx context.Context, tx *Tx, id int) (*ocs.Enrollment, error) {
+	a, _, err := findEnrollments(ctx, tx, ocs.EnrollmentFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Enrollment not found."}
+	}
+	return a[0], nil
+}
+
+func findEnrollments(ctx context.Context, tx *Tx, filter ocs.EnrollmentFilter) (_ []*ocs.Enrollment, n int, err error) {
+	where, args := tx.scope(byCourse, "course_id")
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.CourseID; v != nil {
+		where, args = append(where, "course_id = ?"), append(args, *v)
+	}
+	if v := filter.StudentID; v != nil {
+		where, args = append(where, "student_id = ?"), append(args, *v)
+	}
+	if v := filter.Role; v != nil {
+		where, args = append(where, "role = ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      course_id,
+      student_id,
+      role,
+      completed_at,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM enrollments
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	enrollments := make([]*ocs.Enrollment, 0)
+	for rows.Next() {
+		var enrollment ocs.Enrollment
+		var completedAt NullTime
+		if err := rows.Scan(
+			&enrollment.ID,
+			&enrollment.CourseID,
+			&enrollment.StudentID,
+			&enrollment.Role,
+			&completedAt,
+			(*NullTime)(&enrollment.CreatedAt),
+			(*NullTime)(&enrollment.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		if v := (time.Time)(completedAt); !v.IsZero() {
+			enrollment.CompletedAt = &v
+		}
+		enrollments = append(enrollments, &enrollment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return enrollments, n, nil
+}
+
+// completeEnrollment marks the enrollment as completed and updates the
+// student's progress on any learning path containing the course.
+func completeEnrollment(ctx context.Context, tx *Tx, id int) (*ocs.Enrollment, error) {
+	enrollment, err := findEnrollmentByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, err := isCourseStaff(ctx, tx, enrollment.CourseID, ocs.StudentIDFromContext(ctx)); err != nil {
+		return enrollment, err
+	} else if !ok {
+		return enrollment, ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to complete this enrollment.")
+	} else if enrollment.Role != ocs.EnrollmentRoleStudent {
+		return enrollment, ocs.Errorf(ocs.EINVALID, "Only student enrollments can be completed.")
+	} else if enrollment.CompletedAt != nil {
+		return enrollment, nil
+	}
+	prev := *enrollment
+
+	now := tx.now
+	enrollment.CompletedAt = &now
+	enrollment.UpdatedAt = now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE enrollments
+    SET completed_at = ?,
+        updated_at = ?
+    WHERE id = ?
+    `,
+		(*NullTime)(enrollment.CompletedAt),
+		(*NullTime)(&enrollment.UpdatedAt),
+		id,
+	); err != nil {
+		return enrollment, FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "enrollment", id, &prev, enrollment); err != nil {
+		return enrollment, err
+	}
+
+	tx.publishEvent(enrollment.StudentID, ocs.Event{
+		Type: ocs.EventTypeEnrollmentCompleted,
+		Payload: &ocs.EnrollmentCompletedPayload{
+			ID:       enrollment.ID,
+			CourseID: enrollment.CourseID,
+		},
+	})
+
+	if err := updatePathEnrollmentsForCourse(ctx, tx, enrollment.StudentID, enrollment.CourseID); err != nil {
+		return enrollment, err
+	}
+
+	return enrollment, nil
+}
+
+func deleteEnrollment(ctx context.Context, tx *Tx, id int) error {
+	enrollment, err := findEnrollmentByID(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	course, err := findCourseByID(ctx, tx, enrollment.CourseID)
+	if err != nil {
+		return err
+	} else if _, err := findStudentByID(ctx, tx, enrollment.StudentID); err != nil {
+		return err
+	}
+	if studentID := ocs.StudentIDFromContext(ctx); studentID != enrollment.StudentID && studentID != course.InstructorID {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to delete this enrollment.")
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM enrollments WHERE id = ?`, id); err != nil {
+		return FormatError(err)
+	}
+
+	return audit(ctx, tx, ocs.AuditActionDelete, "enrollment", id, enrollment, nil)
+}
+
+func attachEnrollmentAssociations(ctx context.Context, tx *Tx, enrollment *ocs.Enrollment) (err error) {
+	if enrollment.Course, err = findAnyCourseByID(ctx, tx, enrollment.CourseID); err != nil {
+		return fmt.Errorf("attach enrollment course: %w", err)
+	} else if enrollment.Student, err = findAnyStudentByID(ctx, tx, enrollment.StudentID); err != nil {
+		return fmt.Errorf("attach enrollment student: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestEnrollmentService_CreateEnrollment(t *testing.T) {
+	t.Run("Self", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewEnrollmentService(db)
+
+		_, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student1, ctx1 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "Go 101"})
+
+		enrollment := &ocs.Enrollment{CourseID: course.ID, StudentID: student1.ID}
+		if err := s.CreateEnrollment(ctx1, enrollment); err != nil {
+			t.Fatal(err)
+		} else if got, want := enrollment.Role, ocs.EnrollmentRoleStudent; got != want {
+			t.Fatalf("Role=%v, want %v", got, want)
+		} else if enrollment.Course == nil || enrollment.Student == nil {
+			t.Fatal("expected associations")
+		}
+
+		if a, n, err := s.FindEnrollments(ctx1, ocs.EnrollmentFilter{CourseID: &course.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := a[0].StudentID, student1.ID; got != want {
+			t.Fatalf("StudentID=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewEnrollmentService(db)
+
+		_, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student1, ctx1 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "Go 101"})
+
+		if err := s.CreateEnrollment(ctx1, &ocs.Enrollment{CourseID: course.ID, StudentID: student1.ID, Role: ocs.EnrollmentRoleTA}); err == nil {
+			t.Fatal("expected error")
+		} else if ocs.ErrorCode(err) != ocs.EUNAUTHORIZED || ocs.ErrorMessage(err) != `You are not allowed to create this enrollment.` {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrConflict", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewEnrollmentService(db)
+
+		_, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student1, ctx1 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "Go 101"})
+
+		MustCreateEnrollment(t, ctx1, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student1.ID})
+		if err := s.CreateEnrollment(ctx1, &ocs.Enrollment{CourseID: course.ID, StudentID: student1.ID}); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("ErrPaidCourse", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewEnrollmentService(db)
+
+		course, _, ctx := MustCreatePaidCourse(t, db, 5000)
+		if err := s.CreateEnrollment(ctx, &ocs.Enrollment{CourseID: course.ID, StudentID: ocs.StudentIDFromContext(ctx)}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestEnrollmentService_DeleteEnrollment(t *testing.T) {
+	t.Run("ByInstructor", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewEnrollmentService(db)
+
+		_, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student1, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "Go 101"})
+		enrollment := MustCreateEnrollment(t, ctx0, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student1.ID})
+
+		if err := s.DeleteEnrollment(ctx0, enrollment.ID); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.FindEnrollmentByID(ctx0, enrollment.ID); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewEnrollmentService(db)
+
+		_, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student1, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		_, ctx2 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "cat", Email: "cat@email.com"})
+		course := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "Go 101"})
+		enrollment := MustCreateEnrollment(t, ctx0, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student1.ID})
+
+		if err := s.DeleteEnrollment(ctx2, enrollment.ID); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestEnrollmentService_CompleteEnrollment(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewEnrollmentService(db)
+
+		_, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student1, ctx1 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "Go 101"})
+		enrollment := MustCreateEnrollment(t, ctx1, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student1.ID})
+
+		if other, err := s.CompleteEnrollment(ctx0, enrollment.ID); err != nil {
+			t.Fatal(err)
+		} else if other.CompletedAt == nil {
+			t.Fatal("expected completed at")
+		}
+
+		if other, err := s.FindEnrollmentByID(ctx1, enrollment.ID); err != nil {
+			t.Fatal(err)
+		} else if other.CompletedAt == nil {
+			t.Fatal("expected completed at")
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewEnrollmentService(db)
+
+		_, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student1, ctx1 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "Go 101"})
+		enrollment := MustCreateEnrollment(t, ctx1, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student1.ID})
+
+		if _, err := s.CompleteEnrollment(ctx1, enrollment.ID); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func MustCreateEnrollment(tb testing.TB, ctx context.Context, db *sqlite.DB, enrollment *ocs.Enrollment) *ocs.Enrollment {
+	tb.Helper()
+	if err := sqlite.NewEnrollmentService(db).CreateEnrollment(ctx, enrollment); err != nil {
+		tb.Fatal(err)
+	}
+	return enrollment
+}
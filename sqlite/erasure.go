@@ -0,0 +1,428 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.ErasureService = (*ErasureService)(nil)
+
+// ErasureJob is the job kind that erases an account once its request's
+// grace period is over.
+const ErasureJob = "erasure:erase"
+
+// DefaultErasureGracePeriod is how long a student has to cancel a request
+// to erase their account.
+const DefaultErasureGracePeriod = 14 * 24 * time.Hour
+
+// ErasedStudentName replaces the name of erased students.
+const ErasedStudentName = "Erased student"
+
+// ErasureService erases students' accounts at their request. Register its
+// job handler on a job queue to erase accounts when their grace period is
+// over.
+type ErasureService struct {
+	db *DB
+
+	GracePeriod time.Duration
+}
+
+func NewErasureService(db *DB) *ErasureService {
+	return &ErasureService{db: db, GracePeriod: DefaultErasureGracePeriod}
+}
+
+// RegisterJobs registers the erasure job handler on q.
+func (s *ErasureService) RegisterJobs(q *JobQueue) {
+	q.Handle(ErasureJob, s.erase)
+}
+
+func (s *ErasureService) FindErasureRequests(ctx context.Context, filter ocs.ErasureRequestFilter) ([]*ocs.ErasureRequest, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	return findErasureRequests(ctx, tx, filter)
+}
+
+func (s *ErasureService) RequestErasure(ctx context.Context) (*ocs.ErasureRequest, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	req, err := createErasureRequest(ctx, tx, s.GracePeriod)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func (s *ErasureService) CancelErasure(ctx context.Context, id int) (*ocs.ErasureRequest, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	req, err := cancelErasureRequest(ctx, tx, id)
+	if err != nil {
+		return req, err
+	} else if err := tx.Commit(); err != nil {
+		return req, err
+	}
+
+	return req, nil
+}
+
+type erasureJobPayload struct {
+	RequestID int `json:"requestID"`
+}
+
+// erase erases the account of a request that is still pending. Canceled
+// requests are ignored.
+func (s *ErasureService) erase(ctx context.Context, job *Job) error {
+	var payload erasureJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	reqs, _, err := queryErasureRequests(ctx, tx, []string{"id = ?"}, []interface{}{payload.RequestID}, 0, 0)
+	if err != nil {
+		return err
+	} else if len(reqs) == 0 || reqs[0].Status != ocs.ErasureStatusPending {
+		return nil
+	}
+
+	if err := eraseStudent(ctx, tx, reqs[0]); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createErasureRequest records a request to erase the current student's
+// account and queues the job that erases it after the grace period.
+func createErasureRequest(ctx context.Context, tx *Tx, gracePeriod time.Duration) (*ocs.ErasureRequest, error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+	if studentID == 0 {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You must be logged in to erase your account.")
+	} else if err := requireVerifiedEmail(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	student, err := findStudentByID(ctx, tx, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := ocs.ErasureStatusPending
+	if _, n, err := queryErasureRequests(ctx, tx, []string{"student_id = ?", "status = ?"}, []interface{}{studentID, status}, 0, 0); err != nil {
+		return nil, err
+	} else if n != 0 {
+		return nil, ocs.Errorf(ocs.ECONFLICT, "Your account is already scheduled to be erased.")
+	}
+
+	req := &ocs.ErasureRequest{
+		StudentID: studentID,
+		Status:    status,
+		EraseAt:   tx.now.Add(gracePeriod),
+		CreatedAt: tx.now,
+		UpdatedAt: tx.now,
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO erasure_requests (
+      student_id,
+      status,
+      erase_at,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?)
+  `,
+		req.StudentID,
+		req.Status,
+		(*NullTime)(&req.EraseAt),
+		(*NullTime)(&req.CreatedAt),
+		(*NullTime)(&req.UpdatedAt),
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	req.ID = int(id)
+
+	if _, err := enqueueJob(ctx, tx, ErasureJob, erasureJobPayload{RequestID: req.ID}, JobOptions{Delay: gracePeriod}); err != nil {
+		return nil, err
+	} else if err := audit(ctx, tx, ocs.AuditActionCreate, "erasure_request", req.ID, nil, req); err != nil {
+		return nil, err
+	}
+
+	// The erasure date is shown in the student's time zone.
+	local := *req
+	local.EraseAt = req.EraseAt.In(student.Location())
+	if err := enqueueEmail(ctx, tx, ocs.ErasureRequestedEmail, ocs.EmailData{Student: student, ErasureRequest: &local}); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func cancelErasureRequest(ctx context.Context, tx *Tx, id int) (*ocs.ErasureRequest, error) {
+	reqs, _, err := findErasureRequests(ctx, tx, ocs.ErasureRequestFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(reqs) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Erasure request not found."}
+	}
+	req := reqs[0]
+
+	if req.Status != ocs.ErasureStatusPending {
+		return req, ocs.Errorf(ocs.ECONFLICT, "Only pending erasure requests can be canceled.")
+	}
+	prev := *req
+
+	canceledAt := tx.now
+	req.Status = ocs.ErasureStatusCanceled
+	req.CanceledAt = &canceledAt
+	req.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE erasure_requests SET status = ?, canceled_at = ?, updated_at = ? WHERE id = ?
+  `,
+		req.Status,
+		(*NullTime)(req.CanceledAt),
+		(*NullTime)(&req.UpdatedAt),
+		id,
+	); err != nil {
+		return req, FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "erasure_request", id, &prev, req); err != nil {
+		return req, err
+	}
+
+	return req, nil
+}
+
+// eraseStudent removes what identifies the student of a request and marks
+// the request completed. The student's row is kept so that their
+// enrollments, grades and points still count. A confirmation is emailed to
+// the student's address before it is replaced.
+//
+// The audit entry of the erasure records no changes, and earlier entries
+// about the student hold none of the erased fields, since personal details
+// are redacted from the log as it is written. The student's IP is blanked,
+// which is the one change the log allows. The other rows removed here are
+// covered by the erasure's entry.
+//
+// There is no forum. The student's messages and submissions keep their
+// student ID, which now shows as ErasedStudentName.
+func eraseStudent(ctx context.Context, tx *Tx, req *ocs.ErasureRequest) error {
+	student, err := findAnyStudentByID(ctx, tx, req.StudentID)
+	if err != nil {
+		return err
+	}
+
+	// Emails already sent hold the student's address and name.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM emails WHERE student_id = ?`, student.ID); err != nil {
+		return FormatError(err)
+	} else if err := enqueueEmail(ctx, tx, ocs.ErasureCompletedEmail, ocs.EmailData{Student: student, ErasureRequest: req}); err != nil {
+		return err
+	}
+
+	for _, table := range []string{
+		"auths", "notes", "bookmarks", "notifications", "exports", "avatars",
+		"email_verifications", "password_resets", "magic_links",
+	} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE student_id = ?`, student.ID); err != nil {
+			return FormatError(err)
+		}
+	}
+
+	// The student's IP is on the entries they made, and on those made
+	// before they signed in, such as their sign-up and the links they were
+	// sent, which have no actor but are about the student or their rows.
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE audit_entries SET ip = ''
+    WHERE ip != '' AND (actor_id = ? OR (actor_id IS NULL AND (
+      (entity_type = 'student' AND entity_id = ?) OR
+      (entity_type, entity_id) IN (
+        SELECT entity_type, entity_id FROM audit_entries
+        WHERE json_extract(changes, '$.studentID.to') = ? OR json_extract(changes, '$.studentID.from') = ?
+      )
+    )))
+  `,
+		student.ID,
+		student.ID,
+		student.ID,
+		student.ID,
+	); err != nil {
+		return FormatError(err)
+	}
+
+	// Group submissions are kept, since they are also the other members'.
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE submissions SET body = '' WHERE student_id = ? AND group_id IS NULL
+  `,
+		student.ID,
+	); err != nil {
+		return FormatError(err)
+	}
+
+	// The API key is replaced with one that is never given out.
+	apiKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, apiKey); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE students
+    SET name = ?,
+        email = ?,
+        api_key = ?,
+        pending_email = '',
+        password_hash = '',
+        time_zone = '',
+        locale = '',
+        display_name = '',
+        bio = '',
+        pronouns = '',
+        links = '[]',
+        visibility = '{}',
+        avatar_updated_at = NULL,
+        leaderboard_opt_out = 1,
+        erased_at = ?,
+        updated_at = ?
+    WHERE id = ?
+  `,
+		ErasedStudentName,
+		fmt.Sprintf("erased-%d@invalid", student.ID),
+		hex.EncodeToString(apiKey),
+		(*NullTime)(&tx.now),
+		(*NullTime)(&tx.now),
+		student.ID,
+	); err != nil {
+		return FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionErase, "student", student.ID, nil, nil); err != nil {
+		return err
+	}
+
+	prev := *req
+	completedAt := tx.now
+	req.Status = ocs.ErasureStatusCompleted
+	req.CompletedAt = &completedAt
+	req.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE erasure_requests SET status = ?, completed_at = ?, updated_at = ? WHERE id = ?
+  `,
+		req.Status,
+		(*NullTime)(req.CompletedAt),
+		(*NullTime)(&req.UpdatedAt),
+		req.ID,
+	); err != nil {
+		return FormatError(err)
+	}
+
+	return audit(ctx, tx, ocs.AuditActionUpdate, "erasure_request", req.ID, &prev, req)
+}
+
+// findErasureRequests returns the current student's requests, or any
+// request of a student the site can see for admins.
+func findErasureRequests(ctx context.Context, tx *Tx, filter ocs.ErasureRequestFilter) (_ []*ocs.ErasureRequest, n int, err error) {
+	where, args := tx.scope(byStudent, "student_id")
+	if ok, err := isAdmin(ctx, tx); err != nil {
+		return nil, 0, err
+	} else if !ok {
+		where, args = append(where, "student_id = ?"), append(args, ocs.StudentIDFromContext(ctx))
+	}
+
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.StudentID; v != nil {
+		where, args = append(where, "student_id = ?"), append(args, *v)
+	}
+	if v := filter.Status; v != nil {
+		where, args = append(where, "status = ?"), append(args, *v)
+	}
+	return queryErasureRequests(ctx, tx, where, args, filter.Limit, filter.Offset)
+}
+
+func queryErasureRequests(ctx context.Context, tx *Tx, where []string, args []interface{}, limit, offset int) (_ []*ocs.ErasureRequest, n int, err error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      student_id,
+      status,
+      erase_at,
+      canceled_at,
+      completed_at,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM erasure_requests
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(limit, offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	reqs := make([]*ocs.ErasureRequest, 0)
+	for rows.Next() {
+		var req ocs.ErasureRequest
+		var canceledAt, completedAt NullTime
+		if err := rows.Scan(
+			&req.ID,
+			&req.StudentID,
+			&req.Status,
+			(*NullTime)(&req.EraseAt),
+			&canceledAt,
+			&completedAt,
+			(*NullTime)(&req.CreatedAt),
+			(*NullTime)(&req.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		if v := (time.Time)(canceledAt); !v.IsZero() {
+			req.CanceledAt = &v
+		}
+		if v := (time.Time)(completedAt); !v.IsZero() {
+			req.CompletedAt = &v
+		}
+		reqs = append(reqs, &req)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return reqs, n, nil
+}
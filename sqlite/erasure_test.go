@@ -0,0 +1,198 @@
+package sqlite_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestErasureService_RequestErasure(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		q := sqlite.NewJobQueue(db)
+		s := sqlite.NewErasureService(db)
+		s.RegisterJobs(q)
+
+		fx := MustCreateGradedSubmission(t, db)
+		MustExec(t, db, `UPDATE students SET email_verified = 1 WHERE email = 'bob@email.com'`)
+		MustDeliverEmails(t, db)
+
+		req, err := s.RequestErasure(fx.StudentCtx)
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := req.EraseAt, now.Add(sqlite.DefaultErasureGracePeriod); !got.Equal(want) {
+			t.Fatalf("EraseAt=%v, want %v", got, want)
+		} else if _, err := s.RequestErasure(fx.StudentCtx); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		// Nothing is erased during the grace period.
+		MustRunNextJob(t, q, false)
+		if sent := MustDeliverEmails(t, db); len(sent) != 1 {
+			t.Fatalf("len=%v, want 1", len(sent))
+		}
+
+		now = req.EraseAt
+		MustRunNextJob(t, q, true)
+
+		if _, err := sqlite.NewStudentService(db).FindStudentByID(fx.InstructorCtx, fx.Student.ID); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		// The grade still counts, but no longer shows who earned it.
+		if submission, err := sqlite.NewAssignmentService(db).FindSubmissionByID(fx.InstructorCtx, fx.Submission.ID); err != nil {
+			t.Fatal(err)
+		} else if got, want := submission.Student.Name, sqlite.ErasedStudentName; got != want {
+			t.Fatalf("Name=%v, want %v", got, want)
+		} else if got, want := *submission.Grade, 6; got != want {
+			t.Fatalf("Grade=%v, want %v", got, want)
+		} else if got, want := submission.Body, ""; got != want {
+			t.Fatalf("Body=%v, want %v", got, want)
+		}
+
+		// The student is told at their old address, and the request is
+		// kept as a record.
+		if sent := MustDeliverEmails(t, db); len(sent) != 1 {
+			t.Fatalf("len=%v, want 1", len(sent))
+		} else if got, want := sent[0].To, "bob@email.com"; got != want {
+			t.Fatalf("To=%v, want %v", got, want)
+		}
+
+		_, adminCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+		if reqs, _, err := s.FindErasureRequests(adminCtx, ocs.ErasureRequestFilter{ID: &req.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := reqs[0].Status, ocs.ErasureStatusCompleted; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		}
+
+		// The audit log never held the student's personal details.
+		entries, _, err := sqlite.NewAuditService(db).FindAuditEntries(adminCtx, ocs.AuditFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			for k, change := range entry.Changes {
+				if v := string(change.From) + string(change.To); strings.Contains(v, "bob") {
+					t.Fatalf("entry %d logs %s: %s", entry.ID, k, v)
+				}
+			}
+		}
+	})
+
+	// Nothing in the log identifies the student once they are erased: not
+	// their details, their GitHub account or the address they connected from.
+	t.Run("AuditLog", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		q := sqlite.NewJobQueue(db)
+		s := sqlite.NewErasureService(db)
+		s.RegisterJobs(q)
+
+		anonCtx := ocs.NewContextWithRequestInfo(DefaultSiteContext(), ocs.RequestInfo{ID: "req-1", IP: "203.0.113.7"})
+		auth, ctx := MustCreateAuth(t, anonCtx, db, &ocs.Auth{
+			Source:      ocs.AuthSourceGithub,
+			SourceID:    "918273",
+			AccessToken: "ACCESS",
+			Student:     &ocs.Student{Name: "bob", Email: "bob@email.com", EmailVerified: true},
+		})
+
+		if err := sqlite.NewMagicLinkService(db).RequestMagicLink(anonCtx, "bob@email.com", "NONCE"); err != nil {
+			t.Fatal(err)
+		}
+
+		name, email := "robert", "robert@email.com"
+		if _, err := sqlite.NewStudentService(db).UpdateStudent(ctx, auth.StudentID, ocs.StudentUpdate{Name: &name, Email: &email}); err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := s.RequestErasure(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now = req.EraseAt
+		MustRunNextJob(t, q, true)
+
+		_, adminCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+		entries, _, err := sqlite.NewAuditService(db).FindAuditEntries(adminCtx, ocs.AuditFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := json.Marshal(entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range []string{"bob", "robert", "918273", auth.AvatarURL(40), "203.0.113.7"} {
+			if strings.Contains(string(buf), v) {
+				t.Fatalf("audit log holds %q: %s", v, buf)
+			}
+		}
+
+		// Blanking the IP leaves the chain intact.
+		if v, err := sqlite.NewAuditService(db).VerifyAuditLog(adminCtx); err != nil {
+			t.Fatal(err)
+		} else if !v.Valid {
+			t.Fatalf("unexpected broken entry: %d", v.BrokenEntryID)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		q := sqlite.NewJobQueue(db)
+		s := sqlite.NewErasureService(db)
+		s.RegisterJobs(q)
+
+		student, ctx := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		req, err := s.RequestErasure(ctx)
+		if err != nil {
+			t.Fatal(err)
+		} else if req, err = s.CancelErasure(ctx, req.ID); err != nil {
+			t.Fatal(err)
+		} else if got, want := req.Status, ocs.ErasureStatusCanceled; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if _, err := s.CancelErasure(ctx, req.ID); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		now = req.EraseAt
+		MustRunNextJob(t, q, true)
+
+		if other, err := sqlite.NewStudentService(db).FindStudentByID(ctx, student.ID); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.Name, "jane"; got != want {
+			t.Fatalf("Name=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewErasureService(db)
+
+		_, ctx0 := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		_, ctx1 := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "john", Email: "john@email.com"})
+		if req, err := s.RequestErasure(ctx0); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.CancelErasure(ctx1, req.ID); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
@@ -0,0 +1,713 @@
+package sqlite
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.ExportService = (*ExportService)(nil)
+
+// Job kinds of the export service.
+const (
+	ExportBuildJob  = "export:build"
+	ExportExpireJob = "export:expire"
+)
+
+// DefaultExportTTL is how long an export can be downloaded once it is ready.
+const DefaultExportTTL = 7 * 24 * time.Hour
+
+// ExportService builds exports of students' data with the job queue. Register
+// its job handlers on a job queue to build exports and to remove them once
+// they expire.
+type ExportService struct {
+	db *DB
+
+	// The site's base URL, e.g. "https://ocs.example.com", used to build
+	// download links.
+	URL string
+
+	TTL time.Duration
+}
+
+func NewExportService(db *DB) *ExportService {
+	return &ExportService{db: db, TTL: DefaultExportTTL}
+}
+
+// RegisterJobs registers the export job handlers on q and schedules expired
+// exports to be removed daily.
+func (s *ExportService) RegisterJobs(q *JobQueue) error {
+	q.Handle(ExportBuildJob, s.build)
+	q.Handle(ExportExpireJob, func(ctx context.Context, job *Job) error {
+		_, err := s.ExpireExports(ctx)
+		return err
+	})
+	return q.Schedule(ExportExpireJob, "@daily", ExportExpireJob, nil)
+}
+
+func (s *ExportService) FindExports(ctx context.Context, filter ocs.ExportFilter) ([]*ocs.Export, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	return findExports(ctx, tx, filter)
+}
+
+func (s *ExportService) CreateExport(ctx context.Context) (*ocs.Export, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	export, err := createExport(ctx, tx)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+func (s *ExportService) OpenExport(ctx context.Context, id int, token string) ([]byte, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status, tokenHash string
+	var expiresAt NullTime
+	var data []byte
+	if err := tx.QueryRowContext(ctx, `
+    SELECT status, token_hash, expires_at, data FROM exports WHERE id = ?
+  `,
+		id,
+	).Scan(&status, &tokenHash, &expiresAt, &data); err == sql.ErrNoRows {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Export not found."}
+	} else if err != nil {
+		return nil, FormatError(err)
+	}
+
+	if status != ocs.ExportStatusReady || subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(tokenHash)) != 1 {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "Invalid download link.")
+	} else if !tx.now.Before(time.Time(expiresAt)) || data == nil {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "Download link has expired.")
+	}
+	return data, nil
+}
+
+// ExpireExports removes the archives of exports whose download links have
+// expired. Returns the number of archives removed. Nothing is audited, since
+// the archive is not part of an export's audited fields and its expiry was
+// recorded when it was built.
+func (s *ExportService) ExpireExports(ctx context.Context) (int, error) {
+	ctx = withAllOrganizations(ctx)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+    UPDATE exports SET data = NULL, token_hash = '', updated_at = ?
+    WHERE data IS NOT NULL AND expires_at <= ?
+  `,
+		(*NullTime)(&tx.now),
+		(*NullTime)(&tx.now),
+	)
+	if err != nil {
+		return 0, FormatError(err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), tx.Commit()
+}
+
+type exportJobPayload struct {
+	ExportID int `json:"exportID"`
+}
+
+// build builds a pending export's archive, then notifies and emails the
+// student. An export that still fails on the job's last attempt is marked
+// failed.
+func (s *ExportService) build(ctx context.Context, job *Job) error {
+	var payload exportJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+
+	err := s.buildExport(ctx, payload.ExportID)
+	if err != nil && job.Attempts >= job.MaxAttempts {
+		if err := s.failExport(ctx, payload.ExportID, err); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *ExportService) buildExport(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exports, _, err := queryExports(ctx, tx, []string{"id = ?"}, []interface{}{id}, 0, 0)
+	if err != nil {
+		return err
+	} else if len(exports) == 0 || exports[0].Status != ocs.ExportStatusPending {
+		return nil // the student was purged, or the export was already built
+	}
+	export := exports[0]
+
+	student, err := findAnyStudentByID(ctx, tx, export.StudentID)
+	if err != nil {
+		return err
+	}
+
+	data, err := buildExportArchive(ctx, tx, student)
+	if err != nil {
+		return fmt.Errorf("build export archive: %w", err)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(buf)
+
+	prev := *export
+	expiresAt := tx.now.Add(s.TTL)
+	export.Status = ocs.ExportStatusReady
+	export.ExpiresAt = &expiresAt
+	export.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE exports
+    SET status = ?,
+        token_hash = ?,
+        data = ?,
+        expires_at = ?,
+        updated_at = ?
+    WHERE id = ?
+  `,
+		export.Status,
+		hashToken(token),
+		data,
+		(*NullTime)(export.ExpiresAt),
+		(*NullTime)(&export.UpdatedAt),
+		export.ID,
+	); err != nil {
+		return FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "export", export.ID, &prev, export); err != nil {
+		return err
+	}
+
+	tx.publishEvent(student.ID, ocs.Event{
+		Type: ocs.EventTypeExportReady,
+		Payload: &ocs.ExportReadyPayload{
+			ExportID:  export.ID,
+			ExpiresAt: expiresAt,
+		},
+	})
+
+	// The expiry is shown in the student's time zone.
+	local := *export
+	localExpiresAt := expiresAt.In(student.Location())
+	local.ExpiresAt = &localExpiresAt
+
+	if err := enqueueEmail(ctx, tx, ocs.ExportReadyEmail, ocs.EmailData{
+		Student: student,
+		Export:  &local,
+		URL:     fmt.Sprintf("%s/exports/%d/download?token=%s", strings.TrimSuffix(s.URL, "/"), export.ID, token),
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *ExportService) failExport(ctx context.Context, id int, exportErr error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exports, _, err := queryExports(ctx, tx, []string{"id = ?"}, []interface{}{id}, 0, 0)
+	if err != nil {
+		return err
+	} else if len(exports) == 0 {
+		return nil // the student was purged
+	}
+	export := exports[0]
+	prev := *export
+	export.Status, export.LastError, export.UpdatedAt = ocs.ExportStatusFailed, exportErr.Error(), tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE exports SET status = ?, last_error = ?, updated_at = ? WHERE id = ?
+  `,
+		export.Status,
+		export.LastError,
+		(*NullTime)(&export.UpdatedAt),
+		export.ID,
+	); err != nil {
+		return FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "export", export.ID, &prev, export); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// createExport records a pending export of the current student's data and
+// queues the job that builds it.
+func createExport(ctx context.Context, tx *Tx) (*ocs.Export, error) {
+	export := &ocs.Export{
+		StudentID: ocs.StudentIDFromContext(ctx),
+		Status:    ocs.ExportStatusPending,
+		CreatedAt: tx.now,
+		UpdatedAt: tx.now,
+	}
+	if export.StudentID == 0 {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You must be logged in to export your data.")
+	}
+
+	// The download link is emailed, so it must go to the student.
+	if err := requireVerifiedEmail(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	status := ocs.ExportStatusPending
+	if _, n, err := queryExports(ctx, tx, []string{"student_id = ?", "status = ?"}, []interface{}{export.StudentID, status}, 0, 0); err != nil {
+		return nil, err
+	} else if n != 0 {
+		return nil, ocs.Errorf(ocs.ECONFLICT, "An export is already in progress.")
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO exports (
+      student_id,
+      status,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?)
+  `,
+		export.StudentID,
+		export.Status,
+		(*NullTime)(&export.CreatedAt),
+		(*NullTime)(&export.UpdatedAt),
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	export.ID = int(id)
+
+	if _, err := enqueueJob(ctx, tx, ExportBuildJob, exportJobPayload{ExportID: export.ID}, JobOptions{}); err != nil {
+		return nil, err
+	} else if err := audit(ctx, tx, ocs.AuditActionCreate, "export", export.ID, nil, export); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// findExports returns the current student's exports, newest last.
+func findExports(ctx context.Context, tx *Tx, filter ocs.ExportFilter) (_ []*ocs.Export, n int, err error) {
+	where, args := tx.scope(byStudent, "student_id")
+	where, args = append(where, "student_id = ?"), append(args, ocs.StudentIDFromContext(ctx))
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	return queryExports(ctx, tx, where, args, filter.Limit, filter.Offset)
+}
+
+func queryExports(ctx context.Context, tx *Tx, where []string, args []interface{}, limit, offset int) (_ []*ocs.Export, n int, err error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      student_id,
+      status,
+      last_error,
+      expires_at,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM exports
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(limit, offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	exports := make([]*ocs.Export, 0)
+	for rows.Next() {
+		var export ocs.Export
+		var expiresAt NullTime
+		if err := rows.Scan(
+			&export.ID,
+			&export.StudentID,
+			&export.Status,
+			&export.LastError,
+			&expiresAt,
+			(*NullTime)(&export.CreatedAt),
+			(*NullTime)(&export.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		if v := (time.Time)(expiresAt); !v.IsZero() {
+			export.ExpiresAt = &v
+		}
+		exports = append(exports, &export)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return exports, n, nil
+}
+
+// buildExportArchive writes the student's data to a zip archive in the
+// format described by ocs.ExportFormatVersion. Data is read across every
+// organization the student belongs to.
+func buildExportArchive(ctx context.Context, tx *Tx, student *ocs.Student) ([]byte, error) {
+	auths, err := findExportAuths(ctx, tx, student.ID)
+	if err != nil {
+		return nil, err
+	}
+	enrollments, err := findExportEnrollments(ctx, tx, student.ID)
+	if err != nil {
+		return nil, err
+	}
+	submissions, grades, err := findExportSubmissions(ctx, tx, student.ID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := findExportMessages(ctx, tx, student.ID)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := findExportNotes(ctx, tx, student.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{ocs.ExportStudentFile, &ocs.ExportStudent{
+			ID:                student.ID,
+			Name:              student.Name,
+			Email:             student.Email,
+			PendingEmail:      student.PendingEmail,
+			TimeZone:          student.TimeZone,
+			Locale:            student.Locale,
+			DisplayName:       student.DisplayName,
+			Bio:               student.Bio,
+			Pronouns:          student.Pronouns,
+			Links:             student.Links,
+			Visibility:        student.Visibility,
+			EmailDigest:       student.EmailDigest,
+			LeaderboardOptOut: student.LeaderboardOptOut,
+			CreatedAt:         student.CreatedAt,
+			UpdatedAt:         student.UpdatedAt,
+		}},
+		{ocs.ExportAuthsFile, auths},
+		{ocs.ExportEnrollmentsFile, enrollments},
+		{ocs.ExportSubmissionsFile, submissions},
+		{ocs.ExportGradesFile, grades},
+		{ocs.ExportMessagesFile, messages},
+		{ocs.ExportNotesFile, notes},
+	}
+
+	manifest := &ocs.ExportManifest{
+		Version:   ocs.ExportFormatVersion,
+		StudentID: student.ID,
+		CreatedAt: tx.now,
+	}
+	for _, f := range files {
+		manifest.Files = append(manifest.Files, f.name)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if err := writeExportFile(zw, ocs.ExportManifestFile, manifest, tx.now); err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if err := writeExportFile(zw, f.name, f.v, tx.now); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeExportFile(zw *zip.Writer, name string, v interface{}, modified time.Time) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func findExportAuths(ctx context.Context, tx *Tx, studentID int) ([]*ocs.ExportAuth, error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT source, source_id, created_at, updated_at
+    FROM auths
+    WHERE student_id = ?
+    ORDER BY id ASC
+  `,
+		studentID,
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	auths := make([]*ocs.ExportAuth, 0)
+	for rows.Next() {
+		var auth ocs.ExportAuth
+		if err := rows.Scan(
+			&auth.Source,
+			&auth.SourceID,
+			(*NullTime)(&auth.CreatedAt),
+			(*NullTime)(&auth.UpdatedAt),
+		); err != nil {
+			return nil, err
+		}
+		auths = append(auths, &auth)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+	return auths, nil
+}
+
+func findExportEnrollments(ctx context.Context, tx *Tx, studentID int) ([]*ocs.ExportEnrollment, error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT e.course_id, c.title, e.role, e.completed_at, e.created_at
+    FROM enrollments e
+    INNER JOIN courses c ON c.id = e.course_id
+    WHERE e.student_id = ?
+    ORDER BY e.id ASC
+  `,
+		studentID,
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	enrollments := make([]*ocs.ExportEnrollment, 0)
+	for rows.Next() {
+		var enrollment ocs.ExportEnrollment
+		var completedAt NullTime
+		if err := rows.Scan(
+			&enrollment.CourseID,
+			&enrollment.CourseTitle,
+			&enrollment.Role,
+			&completedAt,
+			(*NullTime)(&enrollment.CreatedAt),
+		); err != nil {
+			return nil, err
+		}
+		if v := (time.Time)(completedAt); !v.IsZero() {
+			enrollment.CompletedAt = &v
+		}
+		enrollments = append(enrollments, &enrollment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+	return enrollments, nil
+}
+
+// findExportSubmissions returns the student's submissions, including those
+// of their groups, and the grades of those that were graded.
+func findExportSubmissions(ctx context.Context, tx *Tx, studentID int) ([]*ocs.ExportSubmission, []*ocs.ExportGrade, error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      s.id,
+      s.assignment_id,
+      a.title,
+      a.course_id,
+      s.group_id,
+      s.body,
+      s.grade,
+      a.max_points,
+      s.feedback,
+      s.graded_at,
+      s.created_at
+    FROM submissions s
+    INNER JOIN assignments a ON a.id = s.assignment_id
+    WHERE s.student_id = ? OR s.group_id IN (`+groupMembersAtSubmission+`)
+    ORDER BY s.id ASC
+  `,
+		studentID,
+		studentID,
+	)
+	if err != nil {
+		return nil, nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	submissions, grades := make([]*ocs.ExportSubmission, 0), make([]*ocs.ExportGrade, 0)
+	for rows.Next() {
+		var submission ocs.ExportSubmission
+		var groupID, grade sql.NullInt64
+		var maxPoints int
+		var feedback string
+		var gradedAt NullTime
+		if err := rows.Scan(
+			&submission.ID,
+			&submission.AssignmentID,
+			&submission.AssignmentTitle,
+			&submission.CourseID,
+			&groupID,
+			&submission.Body,
+			&grade,
+			&maxPoints,
+			&feedback,
+			&gradedAt,
+			(*NullTime)(&submission.CreatedAt),
+		); err != nil {
+			return nil, nil, err
+		}
+		submission.GroupID = nullIntPtr(groupID)
+		submissions = append(submissions, &submission)
+
+		if grade.Valid {
+			grades = append(grades, &ocs.ExportGrade{
+				SubmissionID: submission.ID,
+				AssignmentID: submission.AssignmentID,
+				Grade:        int(grade.Int64),
+				MaxPoints:    maxPoints,
+				Feedback:     feedback,
+				GradedAt:     time.Time(gradedAt),
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, FormatError(err)
+	}
+	return submissions, grades, nil
+}
+
+func findExportMessages(ctx context.Context, tx *Tx, studentID int) ([]*ocs.ExportMessage, error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT id, conversation_id, body, created_at
+    FROM messages
+    WHERE sender_id = ?
+    ORDER BY id ASC
+  `,
+		studentID,
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	messages := make([]*ocs.ExportMessage, 0)
+	for rows.Next() {
+		var message ocs.ExportMessage
+		if err := rows.Scan(
+			&message.ID,
+			&message.ConversationID,
+			&message.Body,
+			(*NullTime)(&message.CreatedAt),
+		); err != nil {
+			return nil, err
+		}
+		messages = append(messages, &message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+	return messages, nil
+}
+
+func findExportNotes(ctx context.Context, tx *Tx, studentID int) ([]*ocs.ExportNote, error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      lesson_id,
+      body,
+      anchor_start,
+      anchor_end,
+      video_timestamp,
+      created_at,
+      updated_at
+    FROM notes
+    WHERE student_id = ?
+    ORDER BY id ASC
+  `,
+		studentID,
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	notes := make([]*ocs.ExportNote, 0)
+	for rows.Next() {
+		var note ocs.ExportNote
+		var anchorStart, anchorEnd, videoTimestamp sql.NullInt64
+		if err := rows.Scan(
+			&note.ID,
+			&note.LessonID,
+			&note.Body,
+			&anchorStart,
+			&anchorEnd,
+			&videoTimestamp,
+			(*NullTime)(&note.CreatedAt),
+			(*NullTime)(&note.UpdatedAt),
+		); err != nil {
+			return nil, err
+		}
+		note.AnchorStart = nullIntPtr(anchorStart)
+		note.AnchorEnd = nullIntPtr(anchorEnd)
+		note.VideoTimestamp = nullIntPtr(videoTimestamp)
+		notes = append(notes, &note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+	return notes, nil
+}
@@ -0,0 +1,122 @@
+package sqlite_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestExportService_CreateExport(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		q := sqlite.NewJobQueue(db)
+		s := sqlite.NewExportService(db)
+		s.URL = "https://ocs.example.com"
+		if err := s.RegisterJobs(q); err != nil {
+			t.Fatal(err)
+		}
+
+		student, ctx := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		course := MustCreateCourse(t, ctx, db, &ocs.Course{Title: "Go 101"})
+		MustCreateEnrollment(t, ctx, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student.ID})
+		MustDeliverEmails(t, db)
+
+		export, err := s.CreateExport(ctx)
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := export.Status, ocs.ExportStatusPending; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if _, err := s.CreateExport(ctx); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		MustRunNextJob(t, q, true)
+
+		if exports, _, err := s.FindExports(ctx, ocs.ExportFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := exports[0].Status, ocs.ExportStatusReady; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if got, want := *exports[0].ExpiresAt, now.Add(sqlite.DefaultExportTTL); !got.Equal(want) {
+			t.Fatalf("ExpiresAt=%v, want %v", got, want)
+		}
+
+		// The download link is only emailed.
+		sent := MustDeliverEmails(t, db)
+		if len(sent) != 1 {
+			t.Fatalf("len=%v, want 1", len(sent))
+		}
+		m := regexp.MustCompile(`https://ocs\.example\.com/exports/\d+/download\?token=([0-9a-f]+)`).FindStringSubmatch(sent[0].Text)
+		if m == nil {
+			t.Fatalf("unexpected text: %s", sent[0].Text)
+		}
+
+		data, err := s.OpenExport(DefaultSiteContext(), export.ID, m[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var manifest ocs.ExportManifest
+		var enrollments []*ocs.ExportEnrollment
+		MustReadExportFile(t, zr, ocs.ExportManifestFile, &manifest)
+		MustReadExportFile(t, zr, ocs.ExportEnrollmentsFile, &enrollments)
+		if got, want := manifest.Version, ocs.ExportFormatVersion; got != want {
+			t.Fatalf("Version=%v, want %v", got, want)
+		} else if got, want := len(manifest.Files), 7; got != want {
+			t.Fatalf("len(Files)=%v, want %v", got, want)
+		} else if got, want := len(enrollments), 1; got != want {
+			t.Fatalf("len(enrollments)=%v, want %v", got, want)
+		} else if got, want := enrollments[0].CourseTitle, "Go 101"; got != want {
+			t.Fatalf("CourseTitle=%v, want %v", got, want)
+		}
+
+		if _, err := s.OpenExport(DefaultSiteContext(), export.ID, "bad"); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		now = now.Add(sqlite.DefaultExportTTL)
+		if _, err := s.OpenExport(DefaultSiteContext(), export.ID, m[1]); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if n, err := s.ExpireExports(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		if _, err := sqlite.NewExportService(db).CreateExport(DefaultSiteContext()); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func MustReadExportFile(tb testing.TB, zr *zip.Reader, name string, v interface{}) {
+	tb.Helper()
+	f, err := zr.Open(name)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		tb.Fatal(err)
+	}
+}
@@ -0,0 +1,908 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.GroupService = (*GroupService)(nil)
+
+type GroupService struct {
+	db *DB
+}
+
+func NewGroupService(db *DB) *GroupService {
+	return &GroupService{db: db}
+}
+
+func (s *GroupService) FindGroupSetByID(ctx context.Context, id int) (*ocs.GroupSet, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	set, err := findGroupSetByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachGroupSetAssociations(ctx, tx, set); err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+func (s *GroupService) FindGroupSets(ctx context.Context, filter ocs.GroupSetFilter) ([]*ocs.GroupSet, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	sets, n, err := findGroupSets(ctx, tx, filter)
+	if err != nil {
+		return sets, n, err
+	}
+
+	for _, set := range sets {
+		if err := attachGroupSetAssociations(ctx, tx, set); err != nil {
+			return sets, n, err
+		}
+	}
+	return sets, n, nil
+}
+
+func (s *GroupService) CreateGroupSet(ctx context.Context, set *ocs.GroupSet) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createGroupSet(ctx, tx, set); err != nil {
+		return err
+	} else if err := attachGroupSetAssociations(ctx, tx, set); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *GroupService) DeleteGroupSet(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteGroupSet(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *GroupService) CreateGroup(ctx context.Context, group *ocs.Group) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createGroup(ctx, tx, group); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *GroupService) DeleteGroup(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteGroup(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *GroupService) AssignGroups(ctx context.Context, setID int, assignment ocs.GroupAssignment) ([]*ocs.Group, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	groups, err := assignGroups(ctx, tx, setID, assignment)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		if err := attachGroupAssociations(ctx, tx, group); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (s *GroupService) FindGroupMembers(ctx context.Context, filter ocs.GroupMemberFilter) ([]*ocs.GroupMember, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	return findGroupMembers(ctx, tx, filter)
+}
+
+func (s *GroupService) AddGroupMember(ctx context.Context, groupID, studentID int) (*ocs.GroupMember, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	member, err := addGroupMember(ctx, tx, groupID, studentID)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+func (s *GroupService) RemoveGroupMember(ctx context.Context, groupID, studentID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := removeGroupMember(ctx, tx, groupID, studentID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func createGroupSet(ctx context.Context, tx *Tx, set *ocs.GroupSet) error {
+	set.CreatedAt = tx.now
+	set.UpdatedAt = set.CreatedAt
+
+	if err := set.Validate(); err != nil {
+		return err
+	}
+
+	if ok, err := isCourseStaff(ctx, tx, set.CourseID, ocs.StudentIDFromContext(ctx)); err != nil {
+		return err
+	} else if !ok {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to create groups for this course.")
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO group_sets (
+      course_id,
+      name,
+      self_signup,
+      max_size,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?)
+  `,
+		set.CourseID,
+		set.Name,
+		set.SelfSignup,
+		set.MaxSize,
+		(*NullTime)(&set.CreatedAt),
+		(*NullTime)(&set.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	set.ID = int(id)
+
+	return audit(ctx, tx, ocs.AuditActionCreate, "group_set", set.ID, nil, set)
+}
+
+// deleteGroupSet removes a set along with its groups. Sets whose groups
+// submitted work are kept so their grades can still be explained.
+func deleteGroupSet(ctx context.Context, tx *Tx, id int) error {
+	set, err := findGroupSetByID(ctx, tx, id)
+	if err != nil {
+		return err
+	} else if ok, err := isCourseStaff(ctx, tx, set.CourseID, ocs.StudentIDFromContext(ctx)); err != nil {
+		return err
+	} else if !ok {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to delete this group set.")
+	}
+
+	var n int
+	if err := tx.QueryRowContext(ctx, `
+    SELECT COUNT(*)
+    FROM submissions
+    WHERE group_id IN (SELECT id FROM student_groups WHERE group_set_id = ?)
+  `, id).Scan(&n); err != nil {
+		return FormatError(err)
+	} else if n != 0 {
+		return ocs.Errorf(ocs.ECONFLICT, "Groups of this set have submitted work.")
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM group_sets WHERE id = ?`, id); err != nil {
+		return FormatError(err)
+	}
+	return audit(ctx, tx, ocs.AuditActionDelete, "group_set", id, set, nil)
+}
+
+func findGroupSetByID(ctx context.Context, tx *Tx, id int) (*ocs.GroupSet, error) {
+	a, _, err := findGroupSets(ctx, tx, ocs.GroupSetFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Group set not found."}
+	}
+	return a[0], nil
+}
+
+// findGroupSets only returns group sets of courses the caller is a member of.
+func findGroupSets(ctx context.Context, tx *Tx, filter ocs.GroupSetFilter) (_ []*ocs.GroupSet, n int, err error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+
+	where, args := tx.scope(byCourse, "course_id")
+	where, args = append(where, `(
+    course_id IN (SELECT id FROM courses WHERE instructor_id = ?) OR
+    course_id IN (SELECT course_id FROM enrollments WHERE student_id = ?)
+  )`), append(args, studentID, studentID)
+
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.CourseID; v != nil {
+		where, args = append(where, "course_id = ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      course_id,
+      name,
+      self_signup,
+      max_size,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM group_sets
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	sets := make([]*ocs.GroupSet, 0)
+	for rows.Next() {
+		var set ocs.GroupSet
+		if err := rows.Scan(
+			&set.ID,
+			&set.CourseID,
+			&set.Name,
+			&set.SelfSignup,
+			&set.MaxSize,
+			(*NullTime)(&set.CreatedAt),
+			(*NullTime)(&set.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		sets = append(sets, &set)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return sets, n, nil
+}
+
+func attachGroupSetAssociations(ctx context.Context, tx *Tx, set *ocs.GroupSet) (err error) {
+	if set.Groups, err = findGroupsBySet(ctx, tx, set.ID); err != nil {
+		return fmt.Errorf("attach group set groups: %w", err)
+	}
+	for _, group := range set.Groups {
+		if err := attachGroupAssociations(ctx, tx, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createGroup(ctx context.Context, tx *Tx, group *ocs.Group) error {
+	group.CreatedAt = tx.now
+	group.UpdatedAt = group.CreatedAt
+	group.Members = make([]*ocs.GroupMember, 0)
+
+	if err := group.Validate(); err != nil {
+		return err
+	}
+
+	set, err := findGroupSetByID(ctx, tx, group.GroupSetID)
+	if err != nil {
+		return err
+	} else if ok, err := isCourseStaff(ctx, tx, set.CourseID, ocs.StudentIDFromContext(ctx)); err != nil {
+		return err
+	} else if !ok {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to create groups for this course.")
+	}
+
+	return insertGroup(ctx, tx, group)
+}
+
+func insertGroup(ctx context.Context, tx *Tx, group *ocs.Group) error {
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO student_groups (
+      group_set_id,
+      name,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?)
+  `,
+		group.GroupSetID,
+		group.Name,
+		(*NullTime)(&group.CreatedAt),
+		(*NullTime)(&group.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	group.ID = int(id)
+
+	return audit(ctx, tx, ocs.AuditActionCreate, "group", group.ID, nil, group)
+}
+
+// deleteGroup removes a group that has no current members. Groups that
+// submitted work are kept so their grades can still be explained.
+func deleteGroup(ctx context.Context, tx *Tx, id int) error {
+	group, err := findGroupByID(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	set, err := findGroupSetByID(ctx, tx, group.GroupSetID)
+	if err != nil {
+		return err
+	} else if ok, err := isCourseStaff(ctx, tx, set.CourseID, ocs.StudentIDFromContext(ctx)); err != nil {
+		return err
+	} else if !ok {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to delete this group.")
+	}
+
+	var members, submissions int
+	if err := tx.QueryRowContext(ctx, `
+    SELECT
+      (SELECT COUNT(*) FROM group_members WHERE group_id = ? AND left_at IS NULL),
+      (SELECT COUNT(*) FROM submissions WHERE group_id = ?)
+  `, id, id).Scan(&members, &submissions); err != nil {
+		return FormatError(err)
+	} else if members != 0 {
+		return ocs.Errorf(ocs.ECONFLICT, "Group is not empty.")
+	} else if submissions != 0 {
+		return ocs.Errorf(ocs.ECONFLICT, "Group has submitted work.")
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM student_groups WHERE id = ?`, id); err != nil {
+		return FormatError(err)
+	}
+	return audit(ctx, tx, ocs.AuditActionDelete, "group", id, group, nil)
+}
+
+func findGroupByID(ctx context.Context, tx *Tx, id int) (*ocs.Group, error) {
+	where, args := tx.scope(byCourse, "gs.course_id")
+	where, args = append(where, "g.id = ?"), append(args, id)
+
+	groups, err := queryGroups(ctx, tx, where, args)
+	if err != nil {
+		return nil, err
+	} else if len(groups) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Group not found."}
+	}
+	return groups[0], nil
+}
+
+func findGroupsBySet(ctx context.Context, tx *Tx, setID int) ([]*ocs.Group, error) {
+	where, args := tx.scope(byCourse, "gs.course_id")
+	where, args = append(where, "g.group_set_id = ?"), append(args, setID)
+	return queryGroups(ctx, tx, where, args)
+}
+
+func queryGroups(ctx context.Context, tx *Tx, where []string, args []interface{}) ([]*ocs.Group, error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      g.id,
+      g.group_set_id,
+      g.name,
+      g.created_at,
+      g.updated_at
+    FROM student_groups g
+    INNER JOIN group_sets gs ON gs.id = g.group_set_id
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY g.id ASC
+  `,
+		args...,
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	groups := make([]*ocs.Group, 0)
+	for rows.Next() {
+		var group ocs.Group
+		if err := rows.Scan(
+			&group.ID,
+			&group.GroupSetID,
+			&group.Name,
+			(*NullTime)(&group.CreatedAt),
+			(*NullTime)(&group.UpdatedAt),
+		); err != nil {
+			return nil, err
+		}
+		groups = append(groups, &group)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+
+	return groups, nil
+}
+
+func attachGroupAssociations(ctx context.Context, tx *Tx, group *ocs.Group) (err error) {
+	if group.Members, _, err = findGroupMembers(ctx, tx, ocs.GroupMemberFilter{GroupID: &group.ID}); err != nil {
+		return fmt.Errorf("attach group members: %w", err)
+	}
+	return nil
+}
+
+// assignGroups distributes the enrolled students without a group in the set
+// across its groups, always picking the smallest group with room. Balanced
+// assignment first picks the group with the fewest students sharing the
+// student's attribute.
+func assignGroups(ctx context.Context, tx *Tx, setID int, assignment ocs.GroupAssignment) ([]*ocs.Group, error) {
+	switch assignment.Method {
+	case ocs.GroupAssignmentRandom, ocs.GroupAssignmentBalanced:
+	default:
+		return nil, ocs.Errorf(ocs.EINVALID, "Invalid group assignment method.")
+	}
+
+	set, err := findGroupSetByID(ctx, tx, setID)
+	if err != nil {
+		return nil, err
+	} else if ok, err := isCourseStaff(ctx, tx, set.CourseID, ocs.StudentIDFromContext(ctx)); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to assign groups for this course.")
+	}
+
+	groups, err := findGroupsBySet(ctx, tx, set.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(groups) == 0 {
+		if assignment.GroupCount <= 0 {
+			return nil, ocs.Errorf(ocs.EINVALID, "Group count must be positive.")
+		}
+		for i := 1; i <= assignment.GroupCount; i++ {
+			group := &ocs.Group{
+				GroupSetID: set.ID,
+				Name:       fmt.Sprintf("Group %d", i),
+				CreatedAt:  tx.now,
+				UpdatedAt:  tx.now,
+			}
+			if err := insertGroup(ctx, tx, group); err != nil {
+				return nil, err
+			}
+			groups = append(groups, group)
+		}
+	}
+
+	// Count the current members of each group, by attribute for balancing.
+	sizes := make(map[int]int)
+	attrs := make(map[int]map[string]int)
+	for _, group := range groups {
+		attrs[group.ID] = make(map[string]int)
+
+		members, _, err := findGroupMembers(ctx, tx, ocs.GroupMemberFilter{GroupID: &group.ID})
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			sizes[group.ID]++
+			attrs[group.ID][assignment.Attributes[member.StudentID]]++
+		}
+	}
+
+	studentIDs, err := findUngroupedStudentIDs(ctx, tx, set)
+	if err != nil {
+		return nil, err
+	}
+
+	if set.MaxSize > 0 {
+		room := set.MaxSize * len(groups)
+		for _, size := range sizes {
+			room -= size
+		}
+		if len(studentIDs) > room {
+			return nil, ocs.Errorf(ocs.EINVALID, "Not enough room in the groups for every student.")
+		}
+	}
+
+	rand.Shuffle(len(studentIDs), func(i, j int) {
+		studentIDs[i], studentIDs[j] = studentIDs[j], studentIDs[i]
+	})
+	if assignment.Method == ocs.GroupAssignmentBalanced {
+		sort.SliceStable(studentIDs, func(i, j int) bool {
+			return assignment.Attributes[studentIDs[i]] < assignment.Attributes[studentIDs[j]]
+		})
+	}
+
+	for _, studentID := range studentIDs {
+		attr := assignment.Attributes[studentID]
+
+		var best *ocs.Group
+		for _, group := range groups {
+			if set.MaxSize > 0 && sizes[group.ID] >= set.MaxSize {
+				continue
+			} else if best == nil {
+				best = group
+			} else if assignment.Method == ocs.GroupAssignmentBalanced && attrs[group.ID][attr] != attrs[best.ID][attr] {
+				if attrs[group.ID][attr] < attrs[best.ID][attr] {
+					best = group
+				}
+			} else if sizes[group.ID] < sizes[best.ID] {
+				best = group
+			}
+		}
+
+		if _, err := insertGroupMember(ctx, tx, set.ID, best.ID, studentID); err != nil {
+			return nil, err
+		}
+		sizes[best.ID]++
+		attrs[best.ID][attr]++
+	}
+
+	return groups, nil
+}
+
+// findUngroupedStudentIDs returns the students enrolled in the set's course
+// who are not currently in any of its groups.
+func findUngroupedStudentIDs(ctx context.Context, tx *Tx, set *ocs.GroupSet) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT student_id
+    FROM enrollments
+    WHERE course_id = ? AND role = ? AND student_id NOT IN (
+      SELECT student_id FROM group_members WHERE group_set_id = ? AND left_at IS NULL
+    )
+    ORDER BY student_id ASC
+  `,
+		set.CourseID,
+		ocs.EnrollmentRoleStudent,
+		set.ID,
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	studentIDs := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		studentIDs = append(studentIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+	return studentIDs, nil
+}
+
+// addGroupMember moves the student into the group. The staff may place any
+// enrolled student; students may only sign themselves up, and only in sets
+// that allow it.
+func addGroupMember(ctx context.Context, tx *Tx, groupID, studentID int) (*ocs.GroupMember, error) {
+	group, err := findGroupByID(ctx, tx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := findGroupSetByID(ctx, tx, group.GroupSetID)
+	if err != nil {
+		return nil, err
+	}
+
+	callerID := ocs.StudentIDFromContext(ctx)
+	if ok, err := isCourseStaff(ctx, tx, set.CourseID, callerID); err != nil {
+		return nil, err
+	} else if !ok && (studentID != callerID || !set.SelfSignup) {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to change this group.")
+	}
+
+	role := ocs.EnrollmentRoleStudent
+	if _, n, err := findEnrollments(ctx, tx, ocs.EnrollmentFilter{
+		CourseID:  &set.CourseID,
+		StudentID: &studentID,
+		Role:      &role,
+	}); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ocs.Errorf(ocs.EINVALID, "Student is not enrolled in this course.")
+	}
+
+	if currentID, err := findCurrentGroupID(ctx, tx, set.ID, studentID); err != nil {
+		return nil, err
+	} else if currentID == group.ID {
+		return nil, ocs.Errorf(ocs.ECONFLICT, "Student is already in this group.")
+	}
+
+	if set.MaxSize > 0 {
+		var n int
+		if err := tx.QueryRowContext(ctx, `
+      SELECT COUNT(*) FROM group_members WHERE group_id = ? AND left_at IS NULL
+    `, group.ID).Scan(&n); err != nil {
+			return nil, FormatError(err)
+		} else if n >= set.MaxSize {
+			return nil, ocs.Errorf(ocs.ECONFLICT, "Group is full.")
+		}
+	}
+
+	return insertGroupMember(ctx, tx, set.ID, group.ID, studentID)
+}
+
+// insertGroupMember closes the student's current membership in the set, if
+// any, and opens a new one in the group.
+func insertGroupMember(ctx context.Context, tx *Tx, setID, groupID, studentID int) (*ocs.GroupMember, error) {
+	if current, err := findCurrentGroupMember(ctx, tx, setID, studentID); err != nil {
+		return nil, err
+	} else if current != nil {
+		if err := leaveGroup(ctx, tx, current); err != nil {
+			return nil, err
+		}
+	}
+
+	member := &ocs.GroupMember{
+		GroupID:   groupID,
+		StudentID: studentID,
+		JoinedAt:  tx.now,
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO group_members (
+      group_id,
+      group_set_id,
+      student_id,
+      joined_at
+    )
+    VALUES (?, ?, ?, ?)
+  `,
+		member.GroupID,
+		setID,
+		member.StudentID,
+		(*NullTime)(&member.JoinedAt),
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	member.ID = int(id)
+
+	if err := audit(ctx, tx, ocs.AuditActionCreate, "group_member", member.ID, nil, member); err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// removeGroupMember ends the student's membership. Students may leave groups
+// of sets that allow self-signup.
+func removeGroupMember(ctx context.Context, tx *Tx, groupID, studentID int) error {
+	group, err := findGroupByID(ctx, tx, groupID)
+	if err != nil {
+		return err
+	}
+
+	set, err := findGroupSetByID(ctx, tx, group.GroupSetID)
+	if err != nil {
+		return err
+	}
+
+	callerID := ocs.StudentIDFromContext(ctx)
+	if ok, err := isCourseStaff(ctx, tx, set.CourseID, callerID); err != nil {
+		return err
+	} else if !ok && (studentID != callerID || !set.SelfSignup) {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to change this group.")
+	}
+
+	member, err := findCurrentGroupMember(ctx, tx, set.ID, studentID)
+	if err != nil {
+		return err
+	} else if member == nil || member.GroupID != group.ID {
+		return &ocs.Error{Code: ocs.ENOTFOUND, Message: "Student is not in this group."}
+	}
+	return leaveGroup(ctx, tx, member)
+}
+
+// findCurrentGroupMember returns the student's current membership within
+// the set, or nil if they are in no group.
+func findCurrentGroupMember(ctx context.Context, tx *Tx, setID, studentID int) (*ocs.GroupMember, error) {
+	var member ocs.GroupMember
+	if err := tx.QueryRowContext(ctx, `
+    SELECT id, group_id, student_id, joined_at
+    FROM group_members
+    WHERE group_set_id = ? AND student_id = ? AND left_at IS NULL
+  `,
+		setID,
+		studentID,
+	).Scan(
+		&member.ID,
+		&member.GroupID,
+		&member.StudentID,
+		(*NullTime)(&member.JoinedAt),
+	); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, FormatError(err)
+	}
+	return &member, nil
+}
+
+// leaveGroup ends a current membership.
+func leaveGroup(ctx context.Context, tx *Tx, member *ocs.GroupMember) error {
+	prev := *member
+	leftAt := tx.now
+	member.LeftAt = &leftAt
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE group_members SET left_at = ? WHERE id = ?
+  `,
+		(*NullTime)(member.LeftAt),
+		member.ID,
+	); err != nil {
+		return FormatError(err)
+	}
+	return audit(ctx, tx, ocs.AuditActionUpdate, "group_member", member.ID, &prev, member)
+}
+
+// findCurrentGroupID returns the group the student is in within the set, or
+// zero if they are in none.
+func findCurrentGroupID(ctx context.Context, tx *Tx, setID, studentID int) (int, error) {
+	var groupID int
+	if err := tx.QueryRowContext(ctx, `
+    SELECT group_id
+    FROM group_members
+    WHERE group_set_id = ? AND student_id = ? AND left_at IS NULL
+  `,
+		setID,
+		studentID,
+	).Scan(&groupID); err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, FormatError(err)
+	}
+	return groupID, nil
+}
+
+// findGroupMembers only returns members of groups in courses the caller is
+// a member of.
+func findGroupMembers(ctx context.Context, tx *Tx, filter ocs.GroupMemberFilter) (_ []*ocs.GroupMember, n int, err error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+
+	where, args := tx.scope(byCourse, "gs.course_id")
+	where, args = append(where, `(
+    gs.course_id IN (SELECT id FROM courses WHERE instructor_id = ?) OR
+    gs.course_id IN (SELECT course_id FROM enrollments WHERE student_id = ?)
+  )`), append(args, studentID, studentID)
+
+	if v := filter.GroupID; v != nil {
+		where, args = append(where, "m.group_id = ?"), append(args, *v)
+	}
+	if v := filter.GroupSetID; v != nil {
+		where, args = append(where, "m.group_set_id = ?"), append(args, *v)
+	}
+	if v := filter.StudentID; v != nil {
+		where, args = append(where, "m.student_id = ?"), append(args, *v)
+	}
+	if !filter.History {
+		where = append(where, "m.left_at IS NULL")
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      m.id,
+      m.group_id,
+      m.student_id,
+      m.joined_at,
+      m.left_at,
+      COUNT(*) OVER()
+    FROM group_members m
+    INNER JOIN group_sets gs ON gs.id = m.group_set_id
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY m.id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	members := make([]*ocs.GroupMember, 0)
+	for rows.Next() {
+		var member ocs.GroupMember
+		var leftAt NullTime
+		if err := rows.Scan(
+			&member.ID,
+			&member.GroupID,
+			&member.StudentID,
+			(*NullTime)(&member.JoinedAt),
+			&leftAt,
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		if v := (time.Time)(leftAt); !v.IsZero() {
+			member.LeftAt = &v
+		}
+
+		members = append(members, &member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	for _, member := range members {
+		if member.Student, err = findAnyStudentByID(ctx, tx, member.StudentID); err != nil {
+			return nil, 0, fmt.Errorf("attach group member student: %w", err)
+		}
+	}
+
+	return members, n, nil
+}
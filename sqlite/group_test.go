@@ -0,0 +1,321 @@
+package sqlite_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/mock"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestGroupService_AddGroupMember(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewGroupService(db)
+
+		course, instructorCtx, ctxs := MustCreateGroupCourse(t, db, 1)
+		studentID := ocs.StudentIDFromContext(ctxs[0])
+		set := MustCreateGroupSet(t, instructorCtx, db, &ocs.GroupSet{CourseID: course.ID, Name: "Teams"})
+		group0 := MustCreateGroup(t, instructorCtx, db, &ocs.Group{GroupSetID: set.ID, Name: "Red"})
+		group1 := MustCreateGroup(t, instructorCtx, db, &ocs.Group{GroupSetID: set.ID, Name: "Blue"})
+
+		if _, err := s.AddGroupMember(instructorCtx, group0.ID, studentID); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.AddGroupMember(instructorCtx, group0.ID, studentID); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		// Moving the student closes their first membership.
+		if _, err := s.AddGroupMember(instructorCtx, group1.ID, studentID); err != nil {
+			t.Fatal(err)
+		}
+
+		if members, _, err := s.FindGroupMembers(ctxs[0], ocs.GroupMemberFilter{GroupSetID: &set.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(members), 1; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		} else if got, want := members[0].GroupID, group1.ID; got != want {
+			t.Fatalf("GroupID=%v, want %v", got, want)
+		}
+
+		if members, _, err := s.FindGroupMembers(ctxs[0], ocs.GroupMemberFilter{GroupSetID: &set.ID, History: true}); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(members), 2; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		} else if members[0].GroupID != group0.ID || members[0].LeftAt == nil {
+			t.Fatalf("unexpected member: %#v", members[0])
+		}
+	})
+
+	t.Run("SelfSignup", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewGroupService(db)
+
+		course, instructorCtx, ctxs := MustCreateGroupCourse(t, db, 2)
+		set := MustCreateGroupSet(t, instructorCtx, db, &ocs.GroupSet{CourseID: course.ID, Name: "Teams", SelfSignup: true, MaxSize: 1})
+		group := MustCreateGroup(t, instructorCtx, db, &ocs.Group{GroupSetID: set.ID, Name: "Red"})
+
+		if _, err := s.AddGroupMember(ctxs[0], group.ID, ocs.StudentIDFromContext(ctxs[0])); err != nil {
+			t.Fatal(err)
+		}
+
+		// Students cannot place each other, and the group is full.
+		if _, err := s.AddGroupMember(ctxs[0], group.ID, ocs.StudentIDFromContext(ctxs[1])); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if _, err := s.AddGroupMember(ctxs[1], group.ID, ocs.StudentIDFromContext(ctxs[1])); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		// Leaving makes room.
+		if err := s.RemoveGroupMember(ctxs[0], group.ID, ocs.StudentIDFromContext(ctxs[0])); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.AddGroupMember(ctxs[1], group.ID, ocs.StudentIDFromContext(ctxs[1])); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ErrSelfSignupDisabled", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewGroupService(db)
+
+		course, instructorCtx, ctxs := MustCreateGroupCourse(t, db, 1)
+		set := MustCreateGroupSet(t, instructorCtx, db, &ocs.GroupSet{CourseID: course.ID, Name: "Teams"})
+		group := MustCreateGroup(t, instructorCtx, db, &ocs.Group{GroupSetID: set.ID, Name: "Red"})
+
+		if _, err := s.AddGroupMember(ctxs[0], group.ID, ocs.StudentIDFromContext(ctxs[0])); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestGroupService_AssignGroups(t *testing.T) {
+	t.Run("Random", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewGroupService(db)
+
+		course, instructorCtx, _ := MustCreateGroupCourse(t, db, 7)
+		set := MustCreateGroupSet(t, instructorCtx, db, &ocs.GroupSet{CourseID: course.ID, Name: "Teams"})
+
+		groups, err := s.AssignGroups(instructorCtx, set.ID, ocs.GroupAssignment{Method: ocs.GroupAssignmentRandom, GroupCount: 3})
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := len(groups), 3; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		}
+		for _, group := range groups {
+			if n := len(group.Members); n < 2 || n > 3 {
+				t.Fatalf("unbalanced group %q with %d members", group.Name, n)
+			}
+		}
+
+		// Everyone has a group, so assigning again changes nothing.
+		if groups, err := s.AssignGroups(instructorCtx, set.ID, ocs.GroupAssignment{Method: ocs.GroupAssignmentRandom}); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(groups), 3; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("Balanced", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewGroupService(db)
+
+		course, instructorCtx, ctxs := MustCreateGroupCourse(t, db, 6)
+		set := MustCreateGroupSet(t, instructorCtx, db, &ocs.GroupSet{CourseID: course.ID, Name: "Teams"})
+
+		attrs := make(map[int]string)
+		for i, ctx := range ctxs {
+			attrs[ocs.StudentIDFromContext(ctx)] = "novice"
+			if i < 2 {
+				attrs[ocs.StudentIDFromContext(ctx)] = "expert"
+			}
+		}
+
+		groups, err := s.AssignGroups(instructorCtx, set.ID, ocs.GroupAssignment{
+			Method:     ocs.GroupAssignmentBalanced,
+			GroupCount: 2,
+			Attributes: attrs,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, group := range groups {
+			var experts int
+			for _, member := range group.Members {
+				if attrs[member.StudentID] == "expert" {
+					experts++
+				}
+			}
+			if len(group.Members) != 3 || experts != 1 {
+				t.Fatalf("unbalanced group %q: %d members, %d experts", group.Name, len(group.Members), experts)
+			}
+		}
+	})
+
+	t.Run("ErrNoRoom", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewGroupService(db)
+
+		course, instructorCtx, _ := MustCreateGroupCourse(t, db, 5)
+		set := MustCreateGroupSet(t, instructorCtx, db, &ocs.GroupSet{CourseID: course.ID, Name: "Pairs", MaxSize: 2})
+
+		if _, err := s.AssignGroups(instructorCtx, set.ID, ocs.GroupAssignment{Method: ocs.GroupAssignmentRandom, GroupCount: 2}); ocs.ErrorCode(err) != ocs.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewGroupService(db)
+
+		course, instructorCtx, ctxs := MustCreateGroupCourse(t, db, 1)
+		set := MustCreateGroupSet(t, instructorCtx, db, &ocs.GroupSet{CourseID: course.ID, Name: "Teams"})
+
+		if _, err := s.AssignGroups(ctxs[0], set.ID, ocs.GroupAssignment{Method: ocs.GroupAssignmentRandom, GroupCount: 1}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestAssignmentService_GroupSubmission(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewAssignmentService(db)
+		groups := sqlite.NewGroupService(db)
+
+		now := time.Now()
+		db.Now = func() time.Time { return now }
+
+		course, instructorCtx, ctxs := MustCreateGroupCourse(t, db, 3)
+		set := MustCreateGroupSet(t, instructorCtx, db, &ocs.GroupSet{CourseID: course.ID, Name: "Teams"})
+		red := MustCreateGroup(t, instructorCtx, db, &ocs.Group{GroupSetID: set.ID, Name: "Red"})
+		blue := MustCreateGroup(t, instructorCtx, db, &ocs.Group{GroupSetID: set.ID, Name: "Blue"})
+		MustAddGroupMember(t, instructorCtx, db, red.ID, ocs.StudentIDFromContext(ctxs[0]))
+		MustAddGroupMember(t, instructorCtx, db, red.ID, ocs.StudentIDFromContext(ctxs[1]))
+		MustAddGroupMember(t, instructorCtx, db, blue.ID, ocs.StudentIDFromContext(ctxs[2]))
+		assignment := MustCreateAssignment(t, instructorCtx, db, &ocs.Assignment{CourseID: course.ID, Title: "Project", MaxPoints: 10, GroupSetID: &set.ID})
+
+		now = now.Add(time.Minute)
+		submission := MustCreateSubmission(t, ctxs[0], db, &ocs.Submission{AssignmentID: assignment.ID, Body: "our work"})
+		if submission.GroupID == nil || *submission.GroupID != red.ID {
+			t.Fatalf("GroupID=%v, want %v", submission.GroupID, red.ID)
+		}
+
+		// The group has already submitted.
+		if err := s.CreateSubmission(ctxs[1], &ocs.Submission{AssignmentID: assignment.ID}); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		// A member moves to another group after the submission.
+		now = now.Add(time.Minute)
+		MustAddGroupMember(t, instructorCtx, db, blue.ID, ocs.StudentIDFromContext(ctxs[1]))
+
+		var graded []int
+		db.EventService = &mock.EventService{PublishEventFn: func(studentID int, event ocs.Event) {
+			if event.Type == ocs.EventTypeSubmissionGraded {
+				graded = append(graded, studentID)
+			}
+		}}
+
+		if other, err := s.GradeSubmission(instructorCtx, submission.ID, ocs.SubmissionGrade{Grade: 9}); err != nil {
+			t.Fatal(err)
+		} else if got, want := fmt.Sprint(other.MemberIDs), fmt.Sprint(graded); got != want {
+			t.Fatalf("MemberIDs=%v, want %v", got, want)
+		} else if got, want := len(graded), 2; got != want {
+			t.Fatalf("len(graded)=%v, want %v", got, want)
+		}
+
+		// The grade still applies to the member who moved, but not to the
+		// member who joined the group afterwards.
+		studentID := ocs.StudentIDFromContext(ctxs[1])
+		if _, n, err := s.FindSubmissions(ctxs[1], ocs.SubmissionFilter{StudentID: &studentID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		now = now.Add(time.Minute)
+		MustAddGroupMember(t, instructorCtx, db, red.ID, ocs.StudentIDFromContext(ctxs[2]))
+		if _, n, err := s.FindSubmissions(ctxs[2], ocs.SubmissionFilter{AssignmentID: &assignment.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		// Groups that submitted work are kept.
+		if err := groups.RemoveGroupMember(instructorCtx, red.ID, ocs.StudentIDFromContext(ctxs[0])); err != nil {
+			t.Fatal(err)
+		} else if err := groups.RemoveGroupMember(instructorCtx, red.ID, ocs.StudentIDFromContext(ctxs[2])); err != nil {
+			t.Fatal(err)
+		} else if err := groups.DeleteGroup(instructorCtx, red.ID); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrNoGroup", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewAssignmentService(db)
+
+		course, instructorCtx, ctxs := MustCreateGroupCourse(t, db, 1)
+		set := MustCreateGroupSet(t, instructorCtx, db, &ocs.GroupSet{CourseID: course.ID, Name: "Teams"})
+		assignment := MustCreateAssignment(t, instructorCtx, db, &ocs.Assignment{CourseID: course.ID, Title: "Project", MaxPoints: 10, GroupSetID: &set.ID})
+
+		if err := s.CreateSubmission(ctxs[0], &ocs.Submission{AssignmentID: assignment.ID}); ocs.ErrorCode(err) != ocs.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+// MustCreateGroupCourse creates a course taught by ann with n enrolled
+// students and returns the contexts of the instructor and the students.
+func MustCreateGroupCourse(tb testing.TB, db *sqlite.DB, n int) (*ocs.Course, context.Context, []context.Context) {
+	tb.Helper()
+	_, instructorCtx := MustCreateStudent(tb, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+	course := MustCreateCourse(tb, instructorCtx, db, &ocs.Course{Title: "Go 101"})
+
+	ctxs := make([]context.Context, n)
+	for i := range ctxs {
+		name := fmt.Sprintf("student%d", i)
+		var student *ocs.Student
+		student, ctxs[i] = MustCreateStudent(tb, context.Background(), db, &ocs.Student{Name: name, Email: name + "@email.com"})
+		MustCreateEnrollment(tb, ctxs[i], db, &ocs.Enrollment{CourseID: course.ID, StudentID: student.ID})
+	}
+	return course, instructorCtx, ctxs
+}
+
+func MustCreateGroupSet(tb testing.TB, ctx context.Context, db *sqlite.DB, set *ocs.GroupSet) *ocs.GroupSet {
+	tb.Helper()
+	if err := sqlite.NewGroupService(db).CreateGroupSet(ctx, set); err != nil {
+		tb.Fatal(err)
+	}
+	return set
+}
+
+func MustCreateGroup(tb testing.TB, ctx context.Context, db *sqlite.DB, group *ocs.Group) *ocs.Group {
+	tb.Helper()
+	if err := sqlite.NewGroupService(db).CreateGroup(ctx, group); err != nil {
+		tb.Fatal(err)
+	}
+	return group
+}
+
+func MustAddGroupMember(tb testing.TB, ctx context.Context, db *sqlite.DB, groupID, studentID int) *ocs.GroupMember {
+	tb.Helper()
+	member, err := sqlite.NewGroupService(db).AddGroupMember(ctx, groupID, studentID)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return member
+}
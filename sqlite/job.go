@@ -0,0 +1,702 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	jobEnqueuedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocs_jobs_enqueued_total",
+		Help: "The total number of jobs enqueued",
+	}, []string{"kind"})
+
+	jobAttemptCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocs_job_attempts_total",
+		Help: "The total number of job attempts by outcome",
+	}, []string{"kind", "outcome"})
+
+	jobDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "ocs_job_duration_seconds",
+		Help: "The time taken by job attempts",
+	}, []string{"kind"})
+)
+
+const (
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusDead    = "dead"
+)
+
+// Default settings of the job queue.
+const (
+	DefaultJobWorkers           = 4
+	DefaultJobPollInterval      = time.Second
+	DefaultJobVisibilityTimeout = 5 * time.Minute
+	DefaultJobBackoff           = 10 * time.Second
+	DefaultJobMaxAttempts       = 10
+)
+
+// Job is a unit of background work. Payload is the JSON passed to the job's
+// handler.
+type Job struct {
+	ID          int             `json:"id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Priority    int             `json:"priority"`
+	UniqueKey   string          `json:"uniqueKey"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"maxAttempts"`
+	LastError   string          `json:"lastError"`
+	RunAt       time.Time       `json:"runAt"`
+	LockedUntil *time.Time      `json:"lockedUntil"`
+	FinishedAt  *time.Time      `json:"finishedAt"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// JobOptions controls how a job is enqueued.
+type JobOptions struct {
+	// Delay before the job may run.
+	Delay time.Duration
+
+	// Jobs with a higher priority run first.
+	Priority int
+
+	// If set, enqueueing is a no-op while an unfinished job holds the key.
+	UniqueKey string
+
+	// Attempts before the job is dead. Defaults to DefaultJobMaxAttempts.
+	MaxAttempts int
+}
+
+type JobFilter struct {
+	Status *string `json:"status"`
+	Kind   *string `json:"kind"`
+	Offset int     `json:"offset"`
+	Limit  int     `json:"limit"`
+}
+
+// JobHandler runs a job. A returned error, or a panic, fails the attempt.
+type JobHandler func(ctx context.Context, job *Job) error
+
+// JobQueue runs jobs stored in the database with a pool of workers.
+//
+// A worker claims a job by leasing it for VisibilityTimeout. Failed jobs are
+// retried with exponential backoff, starting at Backoff, until they run out
+// of attempts and are dead. Jobs whose lease expires, e.g. because the
+// process died, are claimed again.
+type JobQueue struct {
+	db        *DB
+	handlers  map[string]JobHandler
+	schedules []*jobSchedule
+
+	Workers           int
+	PollInterval      time.Duration
+	VisibilityTimeout time.Duration
+	Backoff           time.Duration
+}
+
+func NewJobQueue(db *DB) *JobQueue {
+	return &JobQueue{
+		db:                db,
+		handlers:          make(map[string]JobHandler),
+		Workers:           DefaultJobWorkers,
+		PollInterval:      DefaultJobPollInterval,
+		VisibilityTimeout: DefaultJobVisibilityTimeout,
+		Backoff:           DefaultJobBackoff,
+	}
+}
+
+// Handle registers the handler for jobs of the given kind. Workers only
+// claim jobs they have a handler for. Handlers must be registered before
+// the queue is opened.
+func (q *JobQueue) Handle(kind string, h JobHandler) {
+	q.handlers[kind] = h
+}
+
+// Schedule enqueues a job of the given kind whenever the cron spec fires.
+// The spec has five fields, minute, hour, day of month, month and day of
+// week, or is one of @hourly, @daily, @weekly and @monthly. Times are UTC.
+//
+// The next run of each schedule is stored under name, so runs missed while
+// the server was down happen once on startup and are not repeated.
+func (q *JobQueue) Schedule(name, spec, kind string, payload interface{}) error {
+	cron, err := parseCron(spec)
+	if err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	q.schedules = append(q.schedules, &jobSchedule{
+		name:    name,
+		spec:    spec,
+		cron:    cron,
+		kind:    kind,
+		payload: buf,
+	})
+	return nil
+}
+
+// Open starts the workers and the scheduler in the background. They stop
+// when the database is closed, which waits for running jobs to finish.
+func (q *JobQueue) Open() {
+	for i := 0; i < q.Workers; i++ {
+		q.db.wg.Add(1)
+		go q.work()
+	}
+
+	q.db.wg.Add(1)
+	go q.schedule()
+}
+
+func (q *JobQueue) work() {
+	defer q.db.wg.Done()
+
+	ticker := time.NewTicker(q.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Run jobs until none are ready, then wait for the next poll.
+		for q.db.ctx.Err() == nil {
+			if ok, err := q.RunNext(q.db.ctx); err != nil {
+				if q.db.ctx.Err() == nil {
+					log.Printf("job queue error: %s", err)
+				}
+				break
+			} else if !ok {
+				break
+			}
+		}
+
+		select {
+		case <-q.db.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *JobQueue) schedule() {
+	defer q.db.wg.Done()
+
+	ticker := time.NewTicker(q.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := q.RunSchedules(q.db.ctx); err != nil && q.db.ctx.Err() == nil {
+			log.Printf("job scheduler error: %s", err)
+		}
+
+		select {
+		case <-q.db.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Enqueue adds a job of the given kind. If opts.UniqueKey is held by an
+// unfinished job, that job is returned instead.
+func (q *JobQueue) Enqueue(ctx context.Context, kind string, payload interface{}, opts JobOptions) (*Job, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	job, err := enqueueJob(ctx, tx, kind, payload, opts)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (q *JobQueue) FindJobByID(ctx context.Context, id int) (*Job, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findJobByID(ctx, tx, id)
+}
+
+func (q *JobQueue) FindJobs(ctx context.Context, filter JobFilter) ([]*Job, int, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	return findJobs(ctx, tx, filter)
+}
+
+// RetryJob moves a dead job back to pending with a fresh set of attempts.
+func (q *JobQueue) RetryJob(ctx context.Context, id int) (*Job, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	job, err := findJobByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if job.Status != JobStatusDead {
+		return nil, ocs.Errorf(ocs.ECONFLICT, "Only dead jobs can be retried.")
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE jobs
+    SET status = ?,
+        attempts = 0,
+        run_at = ?,
+        finished_at = NULL,
+        updated_at = ?
+    WHERE id = ?
+  `,
+		JobStatusPending,
+		(*NullTime)(&tx.now),
+		(*NullTime)(&tx.now),
+		id,
+	); err != nil {
+		return nil, FormatError(err)
+	}
+
+	if job, err = findJobByID(ctx, tx, id); err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// RunNext claims the next ready job and runs it. It reports whether a job
+// was run, whatever its outcome.
+func (q *JobQueue) RunNext(ctx context.Context) (bool, error) {
+	ctx = withAllOrganizations(ctx)
+
+	job, err := q.claimNext(ctx)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	start := time.Now()
+	runErr := q.run(ctx, job)
+	jobDurationHistogram.WithLabelValues(job.Kind).Observe(time.Since(start).Seconds())
+
+	// Record the outcome even if the queue is shutting down, so the job is
+	// not run again once its lease expires.
+	if err := q.finish(context.WithoutCancel(ctx), job, runErr); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// run calls the job's handler, turning a panic into an error.
+func (q *JobQueue) run(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return q.handlers[job.Kind](ctx, job)
+}
+
+// claimNext leases the highest priority ready job that the queue has a
+// handler for. Jobs whose lease expired without attempts left are dead.
+func (q *JobQueue) claimNext(ctx context.Context) (*Job, error) {
+	if len(q.handlers) == 0 {
+		return nil, nil
+	}
+
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE jobs
+    SET status = ?,
+        last_error = 'Visibility timeout expired.',
+        locked_until = NULL,
+        finished_at = ?,
+        updated_at = ?
+    WHERE status = ? AND locked_until <= ? AND attempts >= max_attempts
+  `,
+		JobStatusDead,
+		(*NullTime)(&tx.now),
+		(*NullTime)(&tx.now),
+		JobStatusRunning,
+		(*NullTime)(&tx.now),
+	); err != nil {
+		return nil, FormatError(err)
+	}
+
+	where := []string{"((status = ? AND run_at <= ?) OR (status = ? AND locked_until <= ?))"}
+	args := []interface{}{JobStatusPending, (*NullTime)(&tx.now), JobStatusRunning, (*NullTime)(&tx.now)}
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds, args = append(kinds, "?"), append(args, kind)
+	}
+	where = append(where, "kind IN ("+strings.Join(kinds, ", ")+")")
+
+	jobs, _, err := queryJobs(ctx, tx, where, args, "priority DESC, run_at ASC, id ASC", 1, 0)
+	if err != nil {
+		return nil, err
+	} else if len(jobs) == 0 {
+		return nil, nil
+	}
+	job := jobs[0]
+
+	lockedUntil := tx.now.Add(q.VisibilityTimeout)
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE jobs
+    SET status = ?,
+        attempts = attempts + 1,
+        locked_until = ?,
+        updated_at = ?
+    WHERE id = ?
+  `,
+		JobStatusRunning,
+		(*NullTime)(&lockedUntil),
+		(*NullTime)(&tx.now),
+		job.ID,
+	); err != nil {
+		return nil, FormatError(err)
+	}
+	job.Status, job.Attempts, job.LockedUntil = JobStatusRunning, job.Attempts+1, &lockedUntil
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// finish records the outcome of a job attempt. The outcome is dropped if the
+// job was claimed again in the meantime.
+func (q *JobQueue) finish(ctx context.Context, job *Job, runErr error) error {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	outcome := JobStatusDone
+	job.LockedUntil, job.LastError = nil, ""
+	switch {
+	case runErr == nil:
+		job.Status, job.FinishedAt = JobStatusDone, &tx.now
+	case job.Attempts >= job.MaxAttempts:
+		job.Status, job.FinishedAt, job.LastError = JobStatusDead, &tx.now, runErr.Error()
+		outcome = JobStatusDead
+	default:
+		job.Status, job.RunAt, job.LastError = JobStatusPending, tx.now.Add(q.backoff(job.Attempts)), runErr.Error()
+		outcome = "retry"
+	}
+	job.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE jobs
+    SET status = ?,
+        last_error = ?,
+        run_at = ?,
+        locked_until = NULL,
+        finished_at = ?,
+        updated_at = ?
+    WHERE id = ? AND status = ? AND attempts = ?
+  `,
+		job.Status,
+		job.LastError,
+		(*NullTime)(&job.RunAt),
+		(*NullTime)(job.FinishedAt),
+		(*NullTime)(&job.UpdatedAt),
+		job.ID,
+		JobStatusRunning,
+		job.Attempts,
+	); err != nil {
+		return FormatError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	jobAttemptCounter.WithLabelValues(job.Kind, outcome).Inc()
+	return nil
+}
+
+// backoff returns the delay after the given failed attempt, doubling each
+// time.
+func (q *JobQueue) backoff(attempt int) time.Duration {
+	d := q.Backoff
+	for i := 1; i < attempt && d < 24*time.Hour; i++ {
+		d *= 2
+	}
+	return d
+}
+
+// RunSchedules enqueues the recurring jobs that are due and returns how many
+// were enqueued.
+func (q *JobQueue) RunSchedules(ctx context.Context) (int, error) {
+	var n int
+	for _, s := range q.schedules {
+		if ok, err := q.runSchedule(ctx, s); err != nil {
+			return n, fmt.Errorf("schedule %q: %w", s.name, err)
+		} else if ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (q *JobQueue) runSchedule(ctx context.Context, s *jobSchedule) (bool, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var spec string
+	var nextRunAt time.Time
+	if err := tx.QueryRowContext(ctx, `
+    SELECT spec, next_run_at FROM job_schedules WHERE name = ?
+  `, s.name).Scan(&spec, (*NullTime)(&nextRunAt)); err != nil && err != sql.ErrNoRows {
+		return false, FormatError(err)
+	}
+
+	var ran bool
+	switch {
+	case spec != s.spec:
+		// New or changed schedules start from now.
+	case nextRunAt.After(tx.now):
+		return false, nil
+	default:
+		if _, err := enqueueJob(ctx, tx, s.kind, s.payload, JobOptions{
+			UniqueKey: fmt.Sprintf("schedule:%s:%s", s.name, nextRunAt.Format(time.RFC3339)),
+		}); err != nil {
+			return false, err
+		}
+		ran = true
+	}
+
+	next := s.cron.Next(tx.now)
+	if _, err := tx.ExecContext(ctx, `
+    INSERT INTO job_schedules (name, spec, next_run_at)
+    VALUES (?, ?, ?)
+    ON CONFLICT (name) DO UPDATE SET spec = excluded.spec, next_run_at = excluded.next_run_at
+  `,
+		s.name,
+		s.spec,
+		(*NullTime)(&next),
+	); err != nil {
+		return false, FormatError(err)
+	}
+
+	return ran, tx.Commit()
+}
+
+type jobSchedule struct {
+	name    string
+	spec    string
+	cron    *cronSchedule
+	kind    string
+	payload json.RawMessage
+}
+
+// enqueueJob adds a job as part of tx, so it only runs if the change that
+// caused it commits.
+func enqueueJob(ctx context.Context, tx *Tx, kind string, payload interface{}, opts JobOptions) (*Job, error) {
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		Kind:        kind,
+		Payload:     buf,
+		Priority:    opts.Priority,
+		UniqueKey:   opts.UniqueKey,
+		Status:      JobStatusPending,
+		MaxAttempts: opts.MaxAttempts,
+		RunAt:       tx.now.Add(opts.Delay),
+		CreatedAt:   tx.now,
+		UpdatedAt:   tx.now,
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = DefaultJobMaxAttempts
+	}
+	if job.Kind == "" {
+		return nil, ocs.Errorf(ocs.EINVALID, "Job kind required.")
+	}
+
+	var uniqueKey sql.NullString
+	if job.UniqueKey != "" {
+		uniqueKey = sql.NullString{String: job.UniqueKey, Valid: true}
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT OR IGNORE INTO jobs (
+      kind,
+      payload,
+      priority,
+      unique_key,
+      status,
+      max_attempts,
+      run_at,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+  `,
+		job.Kind,
+		string(job.Payload),
+		job.Priority,
+		uniqueKey,
+		job.Status,
+		job.MaxAttempts,
+		(*NullTime)(&job.RunAt),
+		(*NullTime)(&job.CreatedAt),
+		(*NullTime)(&job.UpdatedAt),
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	} else if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		// An unfinished job holds the unique key.
+		jobs, _, err := queryJobs(ctx, tx,
+			[]string{"unique_key = ?", "status IN (?, ?)"},
+			[]interface{}{job.UniqueKey, JobStatusPending, JobStatusRunning},
+			"id ASC", 1, 0,
+		)
+		if err != nil {
+			return nil, err
+		} else if len(jobs) == 0 {
+			return nil, fmt.Errorf("job with unique key %q not found", job.UniqueKey)
+		}
+		return jobs[0], nil
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	job.ID = int(id)
+
+	jobEnqueuedCounter.WithLabelValues(job.Kind).Inc()
+	return job, nil
+}
+
+func findJobByID(ctx context.Context, tx *Tx, id int) (*Job, error) {
+	a, _, err := queryJobs(ctx, tx, []string{"id = ?"}, []interface{}{id}, "id ASC", 0, 0)
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Job not found."}
+	}
+	return a[0], nil
+}
+
+func findJobs(ctx context.Context, tx *Tx, filter JobFilter) (_ []*Job, n int, err error) {
+	where, args := []string{"1 = 1"}, []interface{}{}
+	if v := filter.Status; v != nil {
+		where, args = append(where, "status = ?"), append(args, *v)
+	}
+	if v := filter.Kind; v != nil {
+		where, args = append(where, "kind = ?"), append(args, *v)
+	}
+	return queryJobs(ctx, tx, where, args, "id ASC", filter.Limit, filter.Offset)
+}
+
+func queryJobs(ctx context.Context, tx *Tx, where []string, args []interface{}, orderBy string, limit, offset int) (_ []*Job, n int, err error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      kind,
+      payload,
+      priority,
+      unique_key,
+      status,
+      attempts,
+      max_attempts,
+      last_error,
+      run_at,
+      locked_until,
+      finished_at,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM jobs
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY `+orderBy+`
+    `+FormatLimitOffset(limit, offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	jobs := make([]*Job, 0)
+	for rows.Next() {
+		var job Job
+		var payload string
+		var uniqueKey sql.NullString
+		var lockedUntil, finishedAt NullTime
+		if err := rows.Scan(
+			&job.ID,
+			&job.Kind,
+			&payload,
+			&job.Priority,
+			&uniqueKey,
+			&job.Status,
+			&job.Attempts,
+			&job.MaxAttempts,
+			&job.LastError,
+			(*NullTime)(&job.RunAt),
+			&lockedUntil,
+			&finishedAt,
+			(*NullTime)(&job.CreatedAt),
+			(*NullTime)(&job.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		job.Payload = json.RawMessage(payload)
+		job.UniqueKey = uniqueKey.String
+		if v := (time.Time)(lockedUntil); !v.IsZero() {
+			job.LockedUntil = &v
+		}
+		if v := (time.Time)(finishedAt); !v.IsZero() {
+			job.FinishedAt = &v
+		}
+
+		jobs = append(jobs, &job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return jobs, n, nil
+}
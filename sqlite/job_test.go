@@ -0,0 +1,361 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestJobQueue_Enqueue(t *testing.T) {
+	t.Run("UniqueKey", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		q := sqlite.NewJobQueue(db)
+		q.Handle("export", func(ctx context.Context, job *sqlite.Job) error { return nil })
+
+		job0 := MustEnqueueJob(t, q, "export", sqlite.JobOptions{UniqueKey: "export:1"})
+		if job1 := MustEnqueueJob(t, q, "export", sqlite.JobOptions{UniqueKey: "export:1"}); job1.ID != job0.ID {
+			t.Fatalf("ID=%v, want %v", job1.ID, job0.ID)
+		}
+
+		// Once the job is finished, the key is free again.
+		MustRunNextJob(t, q, true)
+		if job2 := MustEnqueueJob(t, q, "export", sqlite.JobOptions{UniqueKey: "export:1"}); job2.ID == job0.ID {
+			t.Fatal("expected new job")
+		}
+	})
+
+	t.Run("ErrKindRequired", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		if _, err := sqlite.NewJobQueue(db).Enqueue(context.Background(), "", nil, sqlite.JobOptions{}); ocs.ErrorCode(err) != ocs.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestJobQueue_RunNext(t *testing.T) {
+	t.Run("Order", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		q := sqlite.NewJobQueue(db)
+
+		var ran []int
+		q.Handle("work", func(ctx context.Context, job *sqlite.Job) error {
+			ran = append(ran, job.Priority)
+			return nil
+		})
+
+		MustEnqueueJob(t, q, "work", sqlite.JobOptions{Priority: 1})
+		MustEnqueueJob(t, q, "work", sqlite.JobOptions{Priority: 9, Delay: time.Hour})
+		MustEnqueueJob(t, q, "work", sqlite.JobOptions{Priority: 5})
+		MustEnqueueJob(t, q, "other", sqlite.JobOptions{Priority: 10})
+
+		// Delayed jobs and jobs without a handler are not run.
+		MustRunNextJob(t, q, true)
+		MustRunNextJob(t, q, true)
+		MustRunNextJob(t, q, false)
+		if got, want := ran, []int{5, 1}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Fatalf("ran=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		q := sqlite.NewJobQueue(db)
+		q.Backoff = time.Minute
+		q.Handle("flaky", func(ctx context.Context, job *sqlite.Job) error {
+			if job.Attempts == 2 {
+				panic("boom")
+			}
+			return errors.New("unavailable")
+		})
+
+		job := MustEnqueueJob(t, q, "flaky", sqlite.JobOptions{MaxAttempts: 3})
+
+		// Retries wait one minute, then two, then the job is dead.
+		for _, wait := range []time.Duration{0, time.Minute, 2 * time.Minute} {
+			now = now.Add(wait - time.Second)
+			MustRunNextJob(t, q, false)
+			now = now.Add(time.Second)
+			MustRunNextJob(t, q, true)
+		}
+
+		if job, err := q.FindJobByID(context.Background(), job.ID); err != nil {
+			t.Fatal(err)
+		} else if got, want := job.Status, sqlite.JobStatusDead; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if got, want := job.LastError, "unavailable"; got != want {
+			t.Fatalf("LastError=%v, want %v", got, want)
+		}
+
+		// A dead job can be retried by hand.
+		if job, err := q.RetryJob(context.Background(), job.ID); err != nil {
+			t.Fatal(err)
+		} else if got, want := job.Status, sqlite.JobStatusPending; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if _, err := q.RetryJob(context.Background(), job.ID); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("VisibilityTimeout", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		// The second worker claims the job once the first worker's lease
+		// expires, and the first worker's late outcome is dropped.
+		q1, q2 := sqlite.NewJobQueue(db), sqlite.NewJobQueue(db)
+		q2.Handle("slow", func(ctx context.Context, job *sqlite.Job) error { return nil })
+		q1.Handle("slow", func(ctx context.Context, job *sqlite.Job) error {
+			now = now.Add(q1.VisibilityTimeout)
+			MustRunNextJob(t, q2, true)
+			return errors.New("too late")
+		})
+
+		job := MustEnqueueJob(t, q1, "slow", sqlite.JobOptions{})
+		MustRunNextJob(t, q1, true)
+
+		if job, err := q1.FindJobByID(context.Background(), job.ID); err != nil {
+			t.Fatal(err)
+		} else if got, want := job.Status, sqlite.JobStatusDone; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if got, want := job.Attempts, 2; got != want {
+			t.Fatalf("Attempts=%v, want %v", got, want)
+		}
+	})
+}
+
+// Ensure that concurrent workers, alongside other writers, run every job
+// exactly once without failing on a locked database.
+func TestJobQueue_RunNext_Concurrent(t *testing.T) {
+	// Workers use several connections, so the database must be a file.
+	db := sqlite.NewDB(filepath.Join(t.TempDir(), "db"))
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer MustCloseDB(t, db)
+
+	var mu sync.Mutex
+	ran := make(map[int]int)
+	var failed []error
+	q := sqlite.NewJobQueue(db)
+	q.Handle("work", func(ctx context.Context, job *sqlite.Job) error {
+		err := touchJob(ctx, db, job.ID)
+
+		mu.Lock()
+		defer mu.Unlock()
+		ran[job.ID]++
+		if err != nil {
+			failed = append(failed, err)
+		}
+		return err
+	})
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		MustEnqueueJob(t, q, "work", sqlite.JobOptions{})
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if ok, err := q.RunNext(context.Background()); err != nil {
+					errs <- err
+					return
+				} else if !ok {
+					return
+				}
+			}
+		}()
+	}
+
+	// Requests write at the same time.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			if _, err := q.Enqueue(context.Background(), "other", nil, sqlite.JobOptions{UniqueKey: fmt.Sprintf("request:%d", i)}); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	for _, err := range failed {
+		t.Fatal(err)
+	}
+
+	if got, want := len(ran), n; got != want {
+		t.Fatalf("len(ran)=%v, want %v", got, want)
+	}
+	for id, count := range ran {
+		if count != 1 {
+			t.Fatalf("job %d ran %d times", id, count)
+		}
+	}
+}
+
+// touchJob writes to the job after reading, as services do, so that it
+// conflicts with concurrent writers.
+func touchJob(ctx context.Context, db *sqlite.DB, id int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM jobs`).Scan(&n); err != nil {
+		return err
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := tx.Exec(`UPDATE jobs SET updated_at = updated_at WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func TestJobQueue_RunSchedules(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Date(2026, time.October, 19, 10, 7, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		newQueue := func() *sqlite.JobQueue {
+			q := sqlite.NewJobQueue(db)
+			if err := q.Schedule("purge", "*/15 * * * *", "purge", nil); err != nil {
+				t.Fatal(err)
+			}
+			return q
+		}
+
+		// The first run is at 10:15. Restarting does not repeat a run, and
+		// runs missed while down happen once.
+		q := newQueue()
+		for _, step := range []struct {
+			now  time.Time
+			q    *sqlite.JobQueue
+			want int
+		}{
+			{now, q, 0},
+			{now.Add(13 * time.Minute), q, 1},
+			{now.Add(14 * time.Minute), q, 0},
+			{now.Add(14 * time.Minute), newQueue(), 0},
+			{now.Add(3 * time.Hour), newQueue(), 1},
+		} {
+			now = step.now
+			if n, err := step.q.RunSchedules(context.Background()); err != nil {
+				t.Fatal(err)
+			} else if got := n; got != step.want {
+				t.Fatalf("%s: n=%v, want %v", now.Format("15:04"), got, step.want)
+			}
+		}
+
+		if _, n, err := q.FindJobs(context.Background(), sqlite.JobFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 2; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrInvalidSpec", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		for _, spec := range []string{"61 * * * *", "* * *", "*/0 * * * *", "@yearly"} {
+			if err := sqlite.NewJobQueue(db).Schedule("x", spec, "x", nil); err == nil {
+				t.Fatalf("%q: expected error", spec)
+			}
+		}
+	})
+}
+
+func TestJobQueue_Open(t *testing.T) {
+	// Workers use several connections, so the database must be a file.
+	dsn := filepath.Join(t.TempDir(), "db")
+	db := sqlite.NewDB(dsn)
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	q := sqlite.NewJobQueue(db)
+	q.PollInterval = 10 * time.Millisecond
+	q.Handle("wait", func(ctx context.Context, job *sqlite.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	q.Open()
+
+	job := MustEnqueueJob(t, q, "wait", sqlite.JobOptions{})
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	// Closing cancels the running job and waits for its worker, which
+	// records the failed attempt.
+	MustCloseDB(t, db)
+
+	db = sqlite.NewDB(dsn)
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer MustCloseDB(t, db)
+
+	if job, err := sqlite.NewJobQueue(db).FindJobByID(context.Background(), job.ID); err != nil {
+		t.Fatal(err)
+	} else if got, want := job.Status, sqlite.JobStatusPending; got != want {
+		t.Fatalf("Status=%v, want %v", got, want)
+	} else if got, want := job.LastError, context.Canceled.Error(); got != want {
+		t.Fatalf("LastError=%v, want %v", got, want)
+	}
+}
+
+func MustEnqueueJob(tb testing.TB, q *sqlite.JobQueue, kind string, opts sqlite.JobOptions) *sqlite.Job {
+	tb.Helper()
+	job, err := q.Enqueue(context.Background(), kind, nil, opts)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return job
+}
+
+func MustRunNextJob(tb testing.TB, q *sqlite.JobQueue, want bool) {
+	tb.Helper()
+	if ok, err := q.RunNext(context.Background()); err != nil {
+		tb.Fatal(err)
+	} else if ok != want {
+		tb.Fatalf("ran=%v, want %v", ok, want)
+	}
+}
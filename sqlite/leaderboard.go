@@ -0,0 +1,388 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var (
+	_ ocs.LeaderboardService = (*LeaderboardService)(nil)
+	_ ocs.EventHandler       = (*LeaderboardService)(nil)
+)
+
+// LeaderboardService awards points by evaluating Rules against published
+// events and ranks students by their points. Register it as an event handler
+// on the event service to award points.
+type LeaderboardService struct {
+	db    *DB
+	Rules []ocs.PointRule
+}
+
+func NewLeaderboardService(db *DB) *LeaderboardService {
+	return &LeaderboardService{db: db, Rules: ocs.DefaultPointRules}
+}
+
+func (s *LeaderboardService) FindLeaderboard(ctx context.Context, filter ocs.LeaderboardFilter) (*ocs.Leaderboard, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findLeaderboard(ctx, tx, filter)
+}
+
+func (s *LeaderboardService) FindPointEntries(ctx context.Context, filter ocs.PointEntryFilter) ([]*ocs.PointEntry, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	return findPointEntries(ctx, tx, filter)
+}
+
+// HandleEvent awards the points of every matching rule as a single entry. An
+// event that already earned points is ignored, so replaying events is safe.
+func (s *LeaderboardService) HandleEvent(ctx context.Context, studentID int, event ocs.Event) error {
+	if studentID == 0 {
+		return nil
+	}
+
+	ctx = withAllOrganizations(ctx)
+
+	entry := &ocs.PointEntry{
+		StudentID: studentID,
+		CourseID:  eventCourseID(event),
+		Reason:    event.Type,
+	}
+	for i := range s.Rules {
+		if rule := &s.Rules[i]; rule.Matches(event) {
+			entry.Points += rule.Points
+		}
+	}
+	if entry.Points == 0 {
+		return nil
+	}
+
+	key, err := eventKey(event)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createPointEntry(ctx, tx, entry, key); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// eventCourseID returns the course an event happened in, if any.
+func eventCourseID(event ocs.Event) *int {
+	var courseID int
+	switch p := event.Payload.(type) {
+	case *ocs.SubmissionCreatedPayload:
+		courseID = p.CourseID
+	case *ocs.SubmissionGradedPayload:
+		courseID = p.CourseID
+	case *ocs.EnrollmentCompletedPayload:
+		courseID = p.CourseID
+	case *ocs.RegradeRequestChangedPayload:
+		courseID = p.CourseID
+	}
+
+	if courseID == 0 {
+		return nil
+	}
+	return &courseID
+}
+
+// createPointEntry records the entry unless points were already awarded for
+// the event, then pushes the change to everyone who can see the leaderboard.
+func createPointEntry(ctx context.Context, tx *Tx, entry *ocs.PointEntry, key string) error {
+	entry.CreatedAt = tx.now
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT OR IGNORE INTO point_entries (
+      student_id,
+      course_id,
+      reason,
+      event_key,
+      points,
+      created_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?)
+  `,
+		entry.StudentID,
+		entry.CourseID,
+		entry.Reason,
+		key,
+		entry.Points,
+		(*NullTime)(&entry.CreatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	} else if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return nil // already awarded
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.ID = int(id)
+
+	if err := audit(ctx, tx, ocs.AuditActionCreate, "point_entry", entry.ID, nil, entry); err != nil {
+		return err
+	}
+	return publishLeaderboardChanged(ctx, tx, entry)
+}
+
+// publishLeaderboardChanged notifies the course members of a change to the
+// course leaderboard. Students who opted out are only visible to themselves,
+// as are points earned outside of a course.
+func publishLeaderboardChanged(ctx context.Context, tx *Tx, entry *ocs.PointEntry) error {
+	student, err := findAnyStudentByID(ctx, tx, entry.StudentID)
+	if err != nil {
+		return err
+	}
+
+	event := ocs.Event{
+		Type: ocs.EventTypeLeaderboardChanged,
+		Payload: &ocs.LeaderboardChangedPayload{
+			CourseID:  entry.CourseID,
+			StudentID: entry.StudentID,
+			Points:    entry.Points,
+		},
+	}
+
+	if entry.CourseID == nil || student.LeaderboardOptOut {
+		tx.publishEvent(entry.StudentID, event)
+		return nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT instructor_id FROM courses WHERE id = ?
+    UNION
+    SELECT student_id FROM enrollments WHERE course_id = ?
+  `,
+		*entry.CourseID,
+		*entry.CourseID,
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var studentID int
+		if err := rows.Scan(&studentID); err != nil {
+			return err
+		}
+		tx.publishEvent(studentID, event)
+	}
+	if err := rows.Err(); err != nil {
+		return FormatError(err)
+	}
+
+	return nil
+}
+
+// findLeaderboard ranks students by the sum of their points. Deleted and
+// erased students are left out. Course leaderboards are only visible to the
+// members of the course. The global
+// leaderboard counts the points earned outside of any course and in the
+// courses of the current organization.
+func findLeaderboard(ctx context.Context, tx *Tx, filter ocs.LeaderboardFilter) (*ocs.Leaderboard, error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+
+	board := &ocs.Leaderboard{
+		CourseID: filter.CourseID,
+		Period:   filter.Period,
+		Entries:  make([]*ocs.LeaderboardEntry, 0),
+	}
+
+	where, args := []string{"s.leaderboard_opt_out = 0 AND s.deleted_at IS NULL AND s.erased_at IS NULL"}, []interface{}{}
+	scope, scopeArgs := tx.scope(byStudent, "p.student_id")
+	where, args = append(where, scope...), append(args, scopeArgs...)
+
+	// Points earned in another organization's courses do not count here.
+	scope, scopeArgs = tx.scope(byCourse, "p.course_id")
+	where, args = append(where, "(p.course_id IS NULL OR "+scope[0]+")"), append(args, scopeArgs...)
+	if v := filter.CourseID; v != nil {
+		if ok, err := isCourseMember(ctx, tx, *v, studentID); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You are not a member of this course.")
+		}
+		where, args = append(where, "p.course_id = ?"), append(args, *v)
+	}
+
+	switch board.Period {
+	case "", ocs.LeaderboardPeriodAllTime:
+		board.Period = ocs.LeaderboardPeriodAllTime
+	case ocs.LeaderboardPeriodWeek:
+		since := startOfWeek(tx.now)
+		board.Since = &since
+		where, args = append(where, "p.created_at >= ?"), append(args, (*NullTime)(&since))
+	default:
+		return nil, ocs.Errorf(ocs.EINVALID, "Invalid leaderboard period.")
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      p.student_id,
+      s.name,
+      SUM(p.points) AS total,
+      RANK() OVER (ORDER BY SUM(p.points) DESC),
+      COUNT(*) OVER()
+    FROM point_entries p
+    INNER JOIN students s ON s.id = p.student_id
+    WHERE `+strings.Join(where, " AND ")+`
+    GROUP BY p.student_id
+    ORDER BY total DESC, p.student_id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry ocs.LeaderboardEntry
+		if err := rows.Scan(
+			&entry.StudentID,
+			&entry.Name,
+			&entry.Points,
+			&entry.Rank,
+			&board.N,
+		); err != nil {
+			return nil, err
+		}
+		board.Entries = append(board.Entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+
+	if studentID != 0 {
+		if board.Me, err = findLeaderboardStanding(ctx, tx, studentID, where[1:], args); err != nil {
+			return nil, err
+		}
+	}
+
+	return board, nil
+}
+
+// findLeaderboardStanding returns the student's points and the rank they
+// would have among the visible students, whether or not they opted out.
+func findLeaderboardStanding(ctx context.Context, tx *Tx, studentID int, where []string, args []interface{}) (*ocs.LeaderboardEntry, error) {
+	cond := strings.Join(append([]string{"1 = 1"}, where...), " AND ")
+
+	entry := &ocs.LeaderboardEntry{StudentID: studentID}
+	if err := tx.QueryRowContext(ctx, `
+    SELECT s.name, COALESCE(SUM(p.points), 0)
+    FROM students s
+    LEFT JOIN point_entries p ON p.student_id = s.id AND `+cond+`
+    WHERE s.id = ?
+    GROUP BY s.id
+  `,
+		append(append([]interface{}{}, args...), studentID)...,
+	).Scan(&entry.Name, &entry.Points); err != nil {
+		return nil, FormatError(err)
+	}
+
+	if err := tx.QueryRowContext(ctx, `
+    SELECT COUNT(*) + 1
+    FROM (
+      SELECT p.student_id
+      FROM point_entries p
+      INNER JOIN students s ON s.id = p.student_id
+      WHERE s.leaderboard_opt_out = 0 AND s.deleted_at IS NULL AND s.erased_at IS NULL AND p.student_id != ? AND `+cond+`
+      GROUP BY p.student_id
+      HAVING SUM(p.points) > ?
+    )
+  `,
+		append(append([]interface{}{studentID}, args...), entry.Points)...,
+	).Scan(&entry.Rank); err != nil {
+		return nil, FormatError(err)
+	}
+
+	return entry, nil
+}
+
+// startOfWeek returns midnight UTC on the Monday of t's week.
+func startOfWeek(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// findPointEntries only returns the caller's own point entries, leaving out
+// those earned in another organization's courses.
+func findPointEntries(ctx context.Context, tx *Tx, filter ocs.PointEntryFilter) (_ []*ocs.PointEntry, n int, err error) {
+	scope, args := tx.scope(byCourse, "course_id")
+	where := []string{"(course_id IS NULL OR " + scope[0] + ")"}
+	where, args = append(where, "student_id = ?"), append(args, ocs.StudentIDFromContext(ctx))
+	if v := filter.CourseID; v != nil {
+		where, args = append(where, "course_id = ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      student_id,
+      course_id,
+      reason,
+      points,
+      created_at,
+      COUNT(*) OVER()
+    FROM point_entries
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	entries := make([]*ocs.PointEntry, 0)
+	for rows.Next() {
+		var entry ocs.PointEntry
+		var courseID sql.NullInt64
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.StudentID,
+			&courseID,
+			&entry.Reason,
+			&entry.Points,
+			(*NullTime)(&entry.CreatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		entry.CourseID = nullIntPtr(courseID)
+		entries = append(entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return entries, n, nil
+}
@@ -0,0 +1,237 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/mock"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestLeaderboardService_HandleEvent(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewLeaderboardService(db)
+
+		_, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student1, ctx1 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "Go 101"})
+		MustCreateEnrollment(t, ctx1, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student1.ID})
+
+		var published []int
+		db.EventService = &mock.EventService{PublishEventFn: func(studentID int, event ocs.Event) {
+			published = append(published, studentID)
+		}}
+
+		// Replaying the event does not award points twice.
+		event := ocs.Event{Type: ocs.EventTypeSubmissionCreated, Payload: &ocs.SubmissionCreatedPayload{ID: 1, CourseID: course.ID}}
+		for i := 0; i < 2; i++ {
+			if err := s.HandleEvent(context.Background(), student1.ID, event); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if entries, n, err := s.FindPointEntries(ctx1, ocs.PointEntryFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := entries[0].Points, 10; got != want {
+			t.Fatalf("Points=%v, want %v", got, want)
+		} else if entries[0].CourseID == nil || *entries[0].CourseID != course.ID {
+			t.Fatalf("CourseID=%v, want %v", entries[0].CourseID, course.ID)
+		}
+
+		// Both the instructor and the student are told about the change.
+		if got, want := len(published), 2; got != want {
+			t.Fatalf("len(published)=%v, want %v", got, want)
+		}
+	})
+
+	// Points for passing a submission are awarded once, however often it is
+	// graded.
+	t.Run("Regrade", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewLeaderboardService(db)
+
+		db.EventService = &mock.EventService{PublishEventFn: func(studentID int, event ocs.Event) {
+			if err := s.HandleEvent(context.Background(), studentID, event); err != nil {
+				t.Fatal(err)
+			}
+		}}
+
+		fx := MustCreateGradedSubmission(t, db)
+		req := MustCreateRegradeRequest(t, fx.StudentCtx, db, &ocs.RegradeRequest{SubmissionID: fx.Submission.ID, Justification: "X"})
+
+		grade := 9
+		if _, err := sqlite.NewRegradeService(db).ResolveRegradeRequest(fx.InstructorCtx, req.ID, ocs.RegradeResolution{
+			Status: ocs.RegradeStatusAccepted,
+			Reply:  "You are right.",
+			Grade:  &grade,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		MustGradeSubmission(t, fx.InstructorCtx, db, fx.Submission.ID, ocs.SubmissionGrade{Grade: 6})
+
+		// 10 for submitting and 20 for passing.
+		if board, err := s.FindLeaderboard(fx.StudentCtx, ocs.LeaderboardFilter{CourseID: &fx.Course.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := board.Me.Points, 30; got != want {
+			t.Fatalf("Points=%v, want %v", got, want)
+		}
+	})
+}
+
+func TestLeaderboardService_FindLeaderboard(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewLeaderboardService(db)
+
+		student0, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student1, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		student2, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "cat", Email: "cat@email.com"})
+
+		MustHandlePoints(t, s, student0.ID, 1)
+		MustHandlePoints(t, s, student1.ID, 3)
+		MustHandlePoints(t, s, student2.ID, 2)
+
+		board, err := s.FindLeaderboard(ctx0, ocs.LeaderboardFilter{})
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := board.Period, ocs.LeaderboardPeriodAllTime; got != want {
+			t.Fatalf("Period=%v, want %v", got, want)
+		} else if got, want := board.N, 3; got != want {
+			t.Fatalf("N=%v, want %v", got, want)
+		} else if e := board.Entries[0]; e.StudentID != student1.ID || e.Rank != 1 || e.Points != 30 || e.Name != "bob" {
+			t.Fatalf("unexpected entry: %#v", e)
+		} else if got, want := board.Me.Rank, 3; got != want {
+			t.Fatalf("Me.Rank=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("OptOut", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewLeaderboardService(db)
+
+		student0, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student1, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+
+		MustHandlePoints(t, s, student0.ID, 2)
+		MustHandlePoints(t, s, student1.ID, 1)
+
+		optOut := true
+		if _, err := sqlite.NewStudentService(db).UpdateStudent(ctx0, student0.ID, ocs.StudentUpdate{LeaderboardOptOut: &optOut}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Opted-out students are hidden but still see their own standing.
+		if board, err := s.FindLeaderboard(ctx0, ocs.LeaderboardFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := board.N, 1; got != want {
+			t.Fatalf("N=%v, want %v", got, want)
+		} else if got, want := board.Entries[0].StudentID, student1.ID; got != want {
+			t.Fatalf("StudentID=%v, want %v", got, want)
+		} else if me := board.Me; me.Points != 20 || me.Rank != 1 {
+			t.Fatalf("unexpected standing: %#v", me)
+		}
+	})
+
+	// Erased students no longer rank, even under their placeholder name.
+	t.Run("Erased", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewLeaderboardService(db)
+
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		q := sqlite.NewJobQueue(db)
+		erasures := sqlite.NewErasureService(db)
+		erasures.RegisterJobs(q)
+
+		student0, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		student1, ctx1 := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+
+		MustHandlePoints(t, s, student0.ID, 1)
+		MustHandlePoints(t, s, student1.ID, 3)
+
+		req, err := erasures.RequestErasure(ctx1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now = req.EraseAt
+		MustRunNextJob(t, q, true)
+
+		// Erasure also opts the student out, but they must stay hidden
+		// without relying on it.
+		MustExec(t, db, `UPDATE students SET leaderboard_opt_out = 0`)
+
+		if board, err := s.FindLeaderboard(ctx0, ocs.LeaderboardFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := board.N, 1; got != want {
+			t.Fatalf("N=%v, want %v", got, want)
+		} else if got, want := board.Entries[0].StudentID, student0.ID; got != want {
+			t.Fatalf("StudentID=%v, want %v", got, want)
+		} else if got, want := board.Me.Rank, 1; got != want {
+			t.Fatalf("Me.Rank=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("Week", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewLeaderboardService(db)
+
+		student0, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+
+		// Wednesday of one week, then Monday of the next.
+		db.Now = func() time.Time { return time.Date(2024, time.March, 6, 12, 0, 0, 0, time.UTC) }
+		MustHandlePoints(t, s, student0.ID, 2)
+		db.Now = func() time.Time { return time.Date(2024, time.March, 11, 0, 30, 0, 0, time.UTC) }
+		MustHandlePoints(t, s, student0.ID, 1)
+
+		if board, err := s.FindLeaderboard(ctx0, ocs.LeaderboardFilter{Period: ocs.LeaderboardPeriodWeek}); err != nil {
+			t.Fatal(err)
+		} else if got, want := board.Entries[0].Points, 10; got != want {
+			t.Fatalf("Points=%v, want %v", got, want)
+		} else if got, want := *board.Since, time.Date(2024, time.March, 11, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+			t.Fatalf("Since=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrCourseMember", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewLeaderboardService(db)
+
+		_, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		_, ctx1 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "Go 101"})
+
+		if _, err := s.FindLeaderboard(ctx1, ocs.LeaderboardFilter{CourseID: &course.ID}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+// pointEventID makes each event of MustHandlePoints distinct.
+var pointEventID int
+
+// MustHandlePoints awards the student the points of n new submissions.
+func MustHandlePoints(tb testing.TB, s *sqlite.LeaderboardService, studentID, n int) {
+	tb.Helper()
+	for i := 0; i < n; i++ {
+		pointEventID++
+		if err := s.HandleEvent(context.Background(), studentID, ocs.Event{
+			Type:    ocs.EventTypeSubmissionCreated,
+			Payload: &ocs.SubmissionCreatedPayload{ID: pointEventID},
+		}); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,755 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.LearningPathService = (*LearningPathService)(nil)
+
+type LearningPathService struct {
+	db *DB
+}
+
+func NewLearningPathService(db *DB) *LearningPathService {
+	return &LearningPathService{db: db}
+}
+
+func (s *LearningPathService) FindLearningPathByID(ctx context.Context, id int) (*ocs.LearningPath, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	path, err := findLearningPathByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachLearningPathAssociations(ctx, tx, path); err != nil {
+		return nil, err
+	}
+
+	return path, nil
+}
+
+func (s *LearningPathService) FindLearningPaths(ctx context.Context, filter ocs.LearningPathFilter) ([]*ocs.LearningPath, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	paths, n, err := findLearningPaths(ctx, tx, filter)
+	if err != nil {
+		return paths, n, err
+	}
+
+	for _, path := range paths {
+		if err := attachLearningPathAssociations(ctx, tx, path); err != nil {
+			return paths, n, err
+		}
+	}
+	return paths, n, nil
+}
+
+func (s *LearningPathService) CreateLearningPath(ctx context.Context, path *ocs.LearningPath) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createLearningPath(ctx, tx, path); err != nil {
+		return err
+	} else if err := attachLearningPathAssociations(ctx, tx, path); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *LearningPathService) DeleteLearningPath(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteLearningPath(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *LearningPathService) EnrollInLearningPath(ctx context.Context, id int) (*ocs.PathEnrollment, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	pe, err := enrollInLearningPath(ctx, tx, id)
+	if err != nil {
+		return pe, err
+	} else if err := attachPathEnrollmentAssociations(ctx, tx, pe); err != nil {
+		return pe, err
+	} else if err := tx.Commit(); err != nil {
+		return pe, err
+	}
+
+	return pe, nil
+}
+
+func (s *LearningPathService) FindPathEnrollments(ctx context.Context, filter ocs.PathEnrollmentFilter) ([]*ocs.PathEnrollment, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	pes, n, err := findPathEnrollments(ctx, tx, filter)
+	if err != nil {
+		return pes, n, err
+	}
+
+	for _, pe := range pes {
+		if err := attachPathEnrollmentAssociations(ctx, tx, pe); err != nil {
+			return pes, n, err
+		}
+	}
+	return pes, n, nil
+}
+
+func (s *LearningPathService) FindCertificates(ctx context.Context, filter ocs.CertificateFilter) ([]*ocs.Certificate, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	return findCertificates(ctx, tx, filter)
+}
+
+func createLearningPath(ctx context.Context, tx *Tx, path *ocs.LearningPath) error {
+	path.OwnerID = ocs.StudentIDFromContext(ctx)
+	if path.OwnerID == 0 {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You must be logged in to create a learning path.")
+	}
+
+	path.OrganizationID = tx.orgID
+	path.CreatedAt = tx.now
+	path.UpdatedAt = path.CreatedAt
+
+	if err := path.Validate(); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO learning_paths (
+      owner_id,
+      organization_id,
+      title,
+      description,
+      electives_required,
+      issues_certificate,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+  `,
+		path.OwnerID,
+		path.OrganizationID,
+		path.Title,
+		path.Description,
+		path.ElectivesRequired,
+		path.IssuesCertificate,
+		(*NullTime)(&path.CreatedAt),
+		(*NullTime)(&path.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	path.ID = int(id)
+
+	// Courses are stored in the order they were given.
+	for i, c := range path.Courses {
+		if _, err := findCourseByID(ctx, tx, c.CourseID); err != nil {
+			return err
+		}
+
+		c.Position = i + 1
+		if _, err := tx.ExecContext(ctx, `
+      INSERT INTO learning_path_courses (
+        learning_path_id,
+        course_id,
+        position,
+        required
+      )
+      VALUES (?, ?, ?, ?)
+    `,
+			path.ID,
+			c.CourseID,
+			c.Position,
+			c.Required,
+		); err != nil {
+			return FormatError(err)
+		}
+	}
+
+	return audit(ctx, tx, ocs.AuditActionCreate, "learning_path", path.ID, nil, path)
+}
+
+func findLearningPathByID(ctx context.Context, tx *Tx, id int) (*ocs.LearningPath, error) {
+	a, _, err := findLearningPaths(ctx, tx, ocs.LearningPathFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Learning path not found."}
+	}
+	return a[0], nil
+}
+
+func findLearningPaths(ctx context.Context, tx *Tx, filter ocs.LearningPathFilter) (_ []*ocs.LearningPath, n int, err error) {
+	where, args := tx.scope(byOrganization, "organization_id")
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.OwnerID; v != nil {
+		where, args = append(where, "owner_id = ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      owner_id,
+      organization_id,
+      title,
+      description,
+      electives_required,
+      issues_certificate,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM learning_paths
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	paths := make([]*ocs.LearningPath, 0)
+	for rows.Next() {
+		var path ocs.LearningPath
+		if err := rows.Scan(
+			&path.ID,
+			&path.OwnerID,
+			&path.OrganizationID,
+			&path.Title,
+			&path.Description,
+			&path.ElectivesRequired,
+			&path.IssuesCertificate,
+			(*NullTime)(&path.CreatedAt),
+			(*NullTime)(&path.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		paths = append(paths, &path)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return paths, n, nil
+}
+
+func findLearningPathCourses(ctx context.Context, tx *Tx, pathID int) (_ []*ocs.LearningPathCourse, err error) {
+	where, args := tx.scope(byCourse, "course_id")
+	where, args = append(where, "learning_path_id = ?"), append(args, pathID)
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      course_id,
+      position,
+      required
+    FROM learning_path_courses
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY position ASC
+  `,
+		args...,
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	courses := make([]*ocs.LearningPathCourse, 0)
+	for rows.Next() {
+		var c ocs.LearningPathCourse
+		if err := rows.Scan(
+			&c.CourseID,
+			&c.Position,
+			&c.Required,
+		); err != nil {
+			return nil, err
+		}
+		courses = append(courses, &c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+
+	return courses, nil
+}
+
+func deleteLearningPath(ctx context.Context, tx *Tx, id int) error {
+	path, err := findLearningPathByID(ctx, tx, id)
+	if err != nil {
+		return err
+	} else if path.OwnerID != ocs.StudentIDFromContext(ctx) {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to delete this learning path.")
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM learning_paths WHERE id = ?`, id); err != nil {
+		return FormatError(err)
+	}
+
+	return audit(ctx, tx, ocs.AuditActionDelete, "learning_path", id, path, nil)
+}
+
+// enrollInLearningPath enrolls the current student in the path and in each of
+// its courses. Existing course enrollments are kept, so progress made before
+// joining the path counts towards it. Paid courses are left to the order
+// flow: paying for one enrolls the student, and until then it simply does
+// not count towards the path.
+func enrollInLearningPath(ctx context.Context, tx *Tx, id int) (*ocs.PathEnrollment, error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+	if studentID == 0 {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You must be logged in to enroll in a learning path.")
+	}
+
+	path, err := findLearningPathByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if path.Courses, err = findLearningPathCourses(ctx, tx, path.ID); err != nil {
+		return nil, err
+	}
+
+	if _, n, err := findPathEnrollments(ctx, tx, ocs.PathEnrollmentFilter{LearningPathID: &path.ID, StudentID: &studentID}); err != nil {
+		return nil, err
+	} else if n != 0 {
+		return nil, ocs.Errorf(ocs.ECONFLICT, "You are already enrolled in this learning path.")
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO path_enrollments (
+      learning_path_id,
+      student_id,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?)
+  `,
+		path.ID,
+		studentID,
+		(*NullTime)(&tx.now),
+		(*NullTime)(&tx.now),
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+
+	peID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range path.Courses {
+		if _, n, err := findEnrollments(ctx, tx, ocs.EnrollmentFilter{CourseID: &c.CourseID, StudentID: &studentID}); err != nil {
+			return nil, err
+		} else if n != 0 {
+			continue
+		}
+
+		if course, err := findCourseByID(ctx, tx, c.CourseID); err != nil {
+			return nil, err
+		} else if course.Price > 0 {
+			continue
+		}
+
+		if err := createEnrollment(ctx, tx, &ocs.Enrollment{
+			CourseID:  c.CourseID,
+			StudentID: studentID,
+			Role:      ocs.EnrollmentRoleStudent,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	pe, err := findPathEnrollmentByID(ctx, tx, int(peID))
+	if err != nil {
+		return nil, err
+	} else if err := audit(ctx, tx, ocs.AuditActionCreate, "path_enrollment", pe.ID, nil, pe); err != nil {
+		return pe, err
+	} else if err := completePathEnrollmentIfDone(ctx, tx, pe, path); err != nil {
+		return pe, err
+	}
+
+	return pe, nil
+}
+
+func findPathEnrollmentByID(ctx context.Context, tx *Tx, id int) (*ocs.PathEnrollment, error) {
+	a, _, err := findPathEnrollments(ctx, tx, ocs.PathEnrollmentFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Path enrollment not found."}
+	}
+	return a[0], nil
+}
+
+// findPathEnrollments only returns the caller's own path enrollments and
+// enrollments in paths they own. Progress is computed from the completed
+// course enrollments of each student.
+func findPathEnrollments(ctx context.Context, tx *Tx, filter ocs.PathEnrollmentFilter) (_ []*ocs.PathEnrollment, n int, err error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+
+	where, args := tx.scope(byLearningPath, "pe.learning_path_id")
+	where, args = append(where, `(
+    pe.student_id = ? OR
+    pe.learning_path_id IN (SELECT id FROM learning_paths WHERE owner_id = ?)
+  )`), append(args, studentID, studentID)
+
+	if v := filter.ID; v != nil {
+		where, args = append(where, "pe.id = ?"), append(args, *v)
+	}
+	if v := filter.LearningPathID; v != nil {
+		where, args = append(where, "pe.learning_path_id = ?"), append(args, *v)
+	}
+	if v := filter.StudentID; v != nil {
+		where, args = append(where, "pe.student_id = ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      pe.id,
+      pe.learning_path_id,
+      pe.student_id,
+      (
+        SELECT COUNT(*)
+        FROM learning_path_courses lpc
+        INNER JOIN enrollments e ON e.course_id = lpc.course_id AND e.student_id = pe.student_id
+        WHERE lpc.learning_path_id = pe.learning_path_id AND lpc.required = 1 AND e.completed_at IS NOT NULL
+      ),
+      (
+        SELECT COUNT(*)
+        FROM learning_path_courses lpc
+        INNER JOIN enrollments e ON e.course_id = lpc.course_id AND e.student_id = pe.student_id
+        WHERE lpc.learning_path_id = pe.learning_path_id AND lpc.required = 0 AND e.completed_at IS NOT NULL
+      ),
+      pe.completed_at,
+      pe.created_at,
+      pe.updated_at,
+      COUNT(*) OVER()
+    FROM path_enrollments pe
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY pe.id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	pes := make([]*ocs.PathEnrollment, 0)
+	for rows.Next() {
+		var pe ocs.PathEnrollment
+		var completedAt NullTime
+		if err := rows.Scan(
+			&pe.ID,
+			&pe.LearningPathID,
+			&pe.StudentID,
+			&pe.RequiredCompleted,
+			&pe.ElectivesCompleted,
+			&completedAt,
+			(*NullTime)(&pe.CreatedAt),
+			(*NullTime)(&pe.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		if v := (time.Time)(completedAt); !v.IsZero() {
+			pe.CompletedAt = &v
+		}
+
+		pes = append(pes, &pe)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return pes, n, nil
+}
+
+// updatePathEnrollmentsForCourse re-checks every incomplete path enrollment
+// of the student whose path contains the course.
+func updatePathEnrollmentsForCourse(ctx context.Context, tx *Tx, studentID, courseID int) error {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT id
+    FROM path_enrollments
+    WHERE student_id = ? AND completed_at IS NULL AND learning_path_id IN (
+      SELECT learning_path_id FROM learning_path_courses WHERE course_id = ?
+    )
+  `,
+		studentID,
+		courseID,
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return FormatError(err)
+	}
+
+	for _, id := range ids {
+		// The caller is course staff rather than the student, so the
+		// enrollment is read by the student's identity.
+		pe, err := findPathEnrollmentByID(ocs.NewContextWithStudent(ctx, &ocs.Student{ID: studentID}), tx, id)
+		if err != nil {
+			return err
+		}
+
+		path, err := findLearningPathByID(ctx, tx, pe.LearningPathID)
+		if err != nil {
+			return err
+		} else if path.Courses, err = findLearningPathCourses(ctx, tx, path.ID); err != nil {
+			return err
+		}
+
+		if err := completePathEnrollmentIfDone(ctx, tx, pe, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// completePathEnrollmentIfDone marks the path enrollment as completed once
+// every required course and enough electives are completed. A certificate is
+// issued if the path offers one, and the student is notified.
+func completePathEnrollmentIfDone(ctx context.Context, tx *Tx, pe *ocs.PathEnrollment, path *ocs.LearningPath) error {
+	if pe.CompletedAt != nil {
+		return nil
+	}
+
+	required := 0
+	for _, c := range path.Courses {
+		if c.Required {
+			required++
+		}
+	}
+	if pe.RequiredCompleted < required || pe.ElectivesCompleted < path.ElectivesRequired {
+		return nil
+	}
+
+	prev := *pe
+	now := tx.now
+	pe.CompletedAt = &now
+	pe.UpdatedAt = now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE path_enrollments
+    SET completed_at = ?,
+        updated_at = ?
+    WHERE id = ?
+    `,
+		(*NullTime)(pe.CompletedAt),
+		(*NullTime)(&pe.UpdatedAt),
+		pe.ID,
+	); err != nil {
+		return FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "path_enrollment", pe.ID, &prev, pe); err != nil {
+		return err
+	}
+
+	if path.IssuesCertificate {
+		pe.Certificate = &ocs.Certificate{
+			StudentID:      pe.StudentID,
+			LearningPathID: path.ID,
+		}
+		if err := createCertificate(ctx, tx, pe.Certificate); err != nil {
+			return err
+		}
+	}
+
+	tx.publishEvent(pe.StudentID, ocs.Event{
+		Type: ocs.EventTypeLearningPathCompleted,
+		Payload: &ocs.LearningPathCompletedPayload{
+			LearningPathID: path.ID,
+			Certificate:    pe.Certificate,
+		},
+	})
+
+	return nil
+}
+
+func createCertificate(ctx context.Context, tx *Tx, cert *ocs.Certificate) error {
+	cert.IssuedAt = tx.now
+
+	code := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, code); err != nil {
+		return err
+	}
+	cert.Code = hex.EncodeToString(code)
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO certificates (
+      student_id,
+      learning_path_id,
+      code,
+      issued_at
+    )
+    VALUES (?, ?, ?, ?)
+  `,
+		cert.StudentID,
+		cert.LearningPathID,
+		cert.Code,
+		(*NullTime)(&cert.IssuedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	cert.ID = int(id)
+
+	return audit(ctx, tx, ocs.AuditActionCreate, "certificate", cert.ID, nil, cert)
+}
+
+func findCertificates(ctx context.Context, tx *Tx, filter ocs.CertificateFilter) (_ []*ocs.Certificate, n int, err error) {
+	where, args := tx.scope(byLearningPath, "learning_path_id")
+	if v := filter.Code; v != nil {
+		where, args = append(where, "code = ?"), append(args, *v)
+	} else {
+		studentID := ocs.StudentIDFromContext(ctx)
+		where, args = append(where, `(
+      student_id = ? OR
+      learning_path_id IN (SELECT id FROM learning_paths WHERE owner_id = ?)
+    )`), append(args, studentID, studentID)
+	}
+
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.StudentID; v != nil {
+		where, args = append(where, "student_id = ?"), append(args, *v)
+	}
+	if v := filter.LearningPathID; v != nil {
+		where, args = append(where, "learning_path_id = ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      student_id,
+      learning_path_id,
+      code,
+      issued_at,
+      COUNT(*) OVER()
+    FROM certificates
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	certs := make([]*ocs.Certificate, 0)
+	for rows.Next() {
+		var cert ocs.Certificate
+		if err := rows.Scan(
+			&cert.ID,
+			&cert.StudentID,
+			&cert.LearningPathID,
+			&cert.Code,
+			(*NullTime)(&cert.IssuedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		certs = append(certs, &cert)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return certs, n, nil
+}
+
+func attachLearningPathAssociations(ctx context.Context, tx *Tx, path *ocs.LearningPath) (err error) {
+	if path.Courses, err = findLearningPathCourses(ctx, tx, path.ID); err != nil {
+		return fmt.Errorf("attach learning path courses: %w", err)
+	}
+
+	for _, c := range path.Courses {
+		if c.Course, err = findAnyCourseByID(ctx, tx, c.CourseID); err != nil {
+			return fmt.Errorf("attach learning path course: %w", err)
+		}
+	}
+	return nil
+}
+
+func attachPathEnrollmentAssociations(ctx context.Context, tx *Tx, pe *ocs.PathEnrollment) (err error) {
+	if pe.LearningPath, err = findLearningPathByID(ctx, tx, pe.LearningPathID); err != nil {
+		return fmt.Errorf("attach path enrollment learning path: %w", err)
+	} else if err := attachLearningPathAssociations(ctx, tx, pe.LearningPath); err != nil {
+		return err
+	}
+
+	certs, _, err := findCertificates(ctx, tx, ocs.CertificateFilter{LearningPathID: &pe.LearningPathID, StudentID: &pe.StudentID})
+	if err != nil {
+		return fmt.Errorf("attach path enrollment certificate: %w", err)
+	} else if len(certs) != 0 {
+		pe.Certificate = certs[0]
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/mock"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestLearningPathService_CreateLearningPath(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewLearningPathService(db)
+
+		_, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		course0 := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "Go 101"})
+		course1 := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "SQL 101"})
+
+		path := &ocs.LearningPath{
+			Title: "Backend Engineer",
+			Courses: []*ocs.LearningPathCourse{
+				{CourseID: course1.ID, Required: true},
+				{CourseID: course0.ID, Required: true},
+			},
+		}
+		if err := s.CreateLearningPath(ctx0, path); err != nil {
+			t.Fatal(err)
+		}
+
+		if other, err := s.FindLearningPathByID(ctx0, path.ID); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(other.Courses), 2; got != want {
+			t.Fatalf("len(Courses)=%v, want %v", got, want)
+		} else if got, want := other.Courses[0].Course.Title, "SQL 101"; got != want {
+			t.Fatalf("Courses[0].Title=%v, want %v", got, want)
+		} else if got, want := other.Courses[1].Position, 2; got != want {
+			t.Fatalf("Courses[1].Position=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrElectivesRequired", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewLearningPathService(db)
+
+		_, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		course := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "Go 101"})
+
+		if err := s.CreateLearningPath(ctx0, &ocs.LearningPath{
+			Title:             "Backend Engineer",
+			ElectivesRequired: 1,
+			Courses:           []*ocs.LearningPathCourse{{CourseID: course.ID, Required: true}},
+		}); err == nil {
+			t.Fatal("expected error")
+		} else if ocs.ErrorCode(err) != ocs.EINVALID || ocs.ErrorMessage(err) != `Electives required must be between 0 and 0.` {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestLearningPathService_EnrollInLearningPath(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewLearningPathService(db)
+
+		path, ctx0 := MustCreateLearningPathFixture(t, db)
+		student1, ctx1 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+
+		pe, err := s.EnrollInLearningPath(ctx1, path.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if pe.CompletedAt != nil {
+			t.Fatal("expected incomplete path enrollment")
+		}
+
+		if _, n, err := sqlite.NewEnrollmentService(db).FindEnrollments(ctx0, ocs.EnrollmentFilter{StudentID: &student1.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, len(path.Courses); got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		if _, err := s.EnrollInLearningPath(ctx1, path.ID); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Paid courses are not enrolled in until they are bought, and buying one
+	// afterwards counts towards the path.
+	t.Run("PaidCourse", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewLearningPathService(db)
+		orders, provider := NewOrderService(db)
+
+		course, ownerCtx, ctx := MustCreatePaidCourse(t, db, 5000)
+		free := MustCreateCourse(t, ownerCtx, db, &ocs.Course{Title: "SQL 101"})
+		path := &ocs.LearningPath{
+			Title: "Backend Engineer",
+			Courses: []*ocs.LearningPathCourse{
+				{CourseID: free.ID, Required: true},
+				{CourseID: course.ID, Required: true},
+			},
+		}
+		if err := s.CreateLearningPath(ownerCtx, path); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.EnrollInLearningPath(ctx, path.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		studentID := ocs.StudentIDFromContext(ctx)
+		if a, _, err := sqlite.NewEnrollmentService(db).FindEnrollments(ownerCtx, ocs.EnrollmentFilter{StudentID: &studentID}); err != nil {
+			t.Fatal(err)
+		} else if len(a) != 1 || a[0].CourseID != free.ID {
+			t.Fatalf("unexpected enrollments: %#v", a)
+		}
+
+		order, err := orders.Checkout(ctx, ocs.Checkout{CourseID: course.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		MustCompletePayment(t, orders, provider, order.ProviderRef)
+
+		if _, n, err := sqlite.NewEnrollmentService(db).FindEnrollments(ownerCtx, ocs.EnrollmentFilter{StudentID: &studentID}); err != nil {
+			t.Fatal(err)
+		} else if n != 2 {
+			t.Fatalf("n=%v, want 2", n)
+		}
+	})
+
+	// Completing all required courses and enough electives completes the
+	// path, issues a certificate and notifies the student.
+	t.Run("Complete", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewLearningPathService(db)
+		enrollments := sqlite.NewEnrollmentService(db)
+
+		var events []ocs.Event
+		db.EventService = &mock.EventService{PublishEventFn: func(studentID int, event ocs.Event) {
+			if event.Type == ocs.EventTypeLearningPathCompleted {
+				events = append(events, event)
+			}
+		}}
+
+		path, ctx0 := MustCreateLearningPathFixture(t, db)
+		student1, ctx1 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		if _, err := s.EnrollInLearningPath(ctx1, path.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		a, _, err := enrollments.FindEnrollments(ctx0, ocs.EnrollmentFilter{StudentID: &student1.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Complete the required course and the first elective only.
+		for _, enrollment := range a[:2] {
+			if _, err := enrollments.CompleteEnrollment(ctx0, enrollment.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		pes, _, err := s.FindPathEnrollments(ctx1, ocs.PathEnrollmentFilter{LearningPathID: &path.ID})
+		if err != nil {
+			t.Fatal(err)
+		} else if pe := pes[0]; pe.CompletedAt == nil {
+			t.Fatal("expected completed path enrollment")
+		} else if got, want := pe.RequiredCompleted, 1; got != want {
+			t.Fatalf("RequiredCompleted=%v, want %v", got, want)
+		} else if got, want := pe.ElectivesCompleted, 1; got != want {
+			t.Fatalf("ElectivesCompleted=%v, want %v", got, want)
+		} else if pe.Certificate == nil || pe.Certificate.Code == "" {
+			t.Fatal("expected certificate")
+		} else if got, want := len(events), 1; got != want {
+			t.Fatalf("len(events)=%v, want %v", got, want)
+		}
+
+		// Anyone holding the code can verify the certificate.
+		_, ctx2 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "cat", Email: "cat@email.com"})
+		if _, n, err := s.FindCertificates(ctx2, ocs.CertificateFilter{Code: &pes[0].Certificate.Code}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+}
+
+// MustCreateLearningPathFixture creates a path of one required course and two
+// electives, one of which must be completed. Returns the owner's context.
+func MustCreateLearningPathFixture(tb testing.TB, db *sqlite.DB) (*ocs.LearningPath, context.Context) {
+	tb.Helper()
+
+	_, ctx := MustCreateStudent(tb, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+	course0 := MustCreateCourse(tb, ctx, db, &ocs.Course{Title: "Go 101"})
+	course1 := MustCreateCourse(tb, ctx, db, &ocs.Course{Title: "SQL 101"})
+	course2 := MustCreateCourse(tb, ctx, db, &ocs.Course{Title: "Redis 101"})
+
+	path := &ocs.LearningPath{
+		Title:             "Backend Engineer",
+		ElectivesRequired: 1,
+		IssuesCertificate: true,
+		Courses: []*ocs.LearningPathCourse{
+			{CourseID: course0.ID, Required: true},
+			{CourseID: course1.ID},
+			{CourseID: course2.ID},
+		},
+	}
+	if err := sqlite.NewLearningPathService(db).CreateLearningPath(ctx, path); err != nil {
+		tb.Fatal(err)
+	}
+	return path, ctx
+}
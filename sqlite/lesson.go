@@ -0,0 +1,193 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.LessonService = (*LessonService)(nil)
+
+type LessonService struct {
+	db *DB
+}
+
+func NewLessonService(db *DB) *LessonService {
+	return &LessonService{db: db}
+}
+
+func (s *LessonService) FindLessonByID(ctx context.Context, id int) (*ocs.Lesson, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	lesson, err := findLessonByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachLessonAssociations(ctx, tx, lesson); err != nil {
+		return nil, err
+	}
+
+	return lesson, nil
+}
+
+func (s *LessonService) FindLessons(ctx context.Context, filter ocs.LessonFilter) ([]*ocs.Lesson, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	lessons, n, err := findLessons(ctx, tx, filter)
+	if err != nil {
+		return lessons, n, err
+	}
+
+	for _, lesson := range lessons {
+		if err := attachLessonAssociations(ctx, tx, lesson); err != nil {
+			return lessons, n, err
+		}
+	}
+	return lessons, n, nil
+}
+
+func (s *LessonService) CreateLesson(ctx context.Context, lesson *ocs.Lesson) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createLesson(ctx, tx, lesson); err != nil {
+		return err
+	} else if err := attachLessonAssociations(ctx, tx, lesson); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func createLesson(ctx context.Context, tx *Tx, lesson *ocs.Lesson) error {
+	lesson.CreatedAt = tx.now
+	lesson.UpdatedAt = lesson.CreatedAt
+
+	if err := lesson.Validate(); err != nil {
+		return err
+	}
+
+	if ok, err := isCourseStaff(ctx, tx, lesson.CourseID, ocs.StudentIDFromContext(ctx)); err != nil {
+		return err
+	} else if !ok {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to create lessons for this course.")
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO lessons (
+      course_id,
+      title,
+      body,
+      video_url,
+      position,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?, ?)
+  `,
+		lesson.CourseID,
+		lesson.Title,
+		lesson.Body,
+		lesson.VideoURL,
+		lesson.Position,
+		(*NullTime)(&lesson.CreatedAt),
+		(*NullTime)(&lesson.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	lesson.ID = int(id)
+
+	return audit(ctx, tx, ocs.AuditActionCreate, "lesson", lesson.ID, nil, lesson)
+}
+
+func findLessonByID(ctx context.Context, tx *Tx, id int) (*ocs.Lesson, error) {
+	a, _, err := findLessons(ctx, tx, ocs.LessonFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Lesson not found."}
+	}
+	return a[0], nil
+}
+
+func findLessons(ctx context.Context, tx *Tx, filter ocs.LessonFilter) (_ []*ocs.Lesson, n int, err error) {
+	where, args := tx.scope(byCourse, "course_id")
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.CourseID; v != nil {
+		where, args = append(where, "course_id = ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      course_id,
+      title,
+      body,
+      video_url,
+      position,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM lessons
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY course_id ASC, position ASC, id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	lessons := make([]*ocs.Lesson, 0)
+	for rows.Next() {
+		var lesson ocs.Lesson
+		if err := rows.Scan(
+			&lesson.ID,
+			&lesson.CourseID,
+			&lesson.Title,
+			&lesson.Body,
+			&lesson.VideoURL,
+			&lesson.Position,
+			(*NullTime)(&lesson.CreatedAt),
+			(*NullTime)(&lesson.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		lessons = append(lessons, &lesson)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return lessons, n, nil
+}
+
+func attachLessonAssociations(ctx context.Context, tx *Tx, lesson *ocs.Lesson) (err error) {
+	if lesson.Course, err = findAnyCourseByID(ctx, tx, lesson.CourseID); err != nil {
+		return fmt.Errorf("attach lesson course: %w", err)
+	}
+	return nil
+}
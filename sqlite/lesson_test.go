@@ -0,0 +1,53 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestLessonService_CreateLesson(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewLessonService(db)
+
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		course := MustCreateCourse(t, ctx, db, &ocs.Course{Title: "Go 101"})
+
+		MustCreateLesson(t, ctx, db, &ocs.Lesson{CourseID: course.ID, Title: "Two", Position: 2})
+		MustCreateLesson(t, ctx, db, &ocs.Lesson{CourseID: course.ID, Title: "One", Position: 1})
+
+		if a, n, err := s.FindLessons(ctx, ocs.LessonFilter{CourseID: &course.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 2; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := a[0].Title, "One"; got != want {
+			t.Fatalf("Title=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewLessonService(db)
+
+		_, ctx0 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		_, ctx1 := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, ctx0, db, &ocs.Course{Title: "Go 101"})
+
+		if err := s.CreateLesson(ctx1, &ocs.Lesson{CourseID: course.ID, Title: "X"}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func MustCreateLesson(tb testing.TB, ctx context.Context, db *sqlite.DB, lesson *ocs.Lesson) *ocs.Lesson {
+	tb.Helper()
+	if err := sqlite.NewLessonService(db).CreateLesson(ctx, lesson); err != nil {
+		tb.Fatal(err)
+	}
+	return lesson
+}
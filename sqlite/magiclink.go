@@ -0,0 +1,198 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.MagicLinkService = (*MagicLinkService)(nil)
+
+// DefaultMagicLinkTTL is how long a sign-in link works.
+const DefaultMagicLinkTTL = 5 * time.Minute
+
+// MagicLinkInterval is the least time between sign-in emails to one student.
+const MagicLinkInterval = time.Minute
+
+// MagicLinkService logs students in with emailed links.
+type MagicLinkService struct {
+	db *DB
+
+	// The site's base URL, e.g. "https://ocs.example.com", used to build
+	// sign-in links.
+	URL string
+
+	TTL time.Duration
+}
+
+func NewMagicLinkService(db *DB) *MagicLinkService {
+	return &MagicLinkService{db: db, TTL: DefaultMagicLinkTTL}
+}
+
+func (s *MagicLinkService) RequestMagicLink(ctx context.Context, email, nonce string) error {
+	if nonce == "" {
+		return ocs.Errorf(ocs.EINVALID, "Nonce required.")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	students, _, err := findStudents(ctx, tx, ocs.StudentFilter{Email: &email})
+	if err != nil {
+		return err
+	} else if len(students) == 0 {
+		return nil
+	}
+	student := students[0]
+
+	var recent int
+	since := tx.now.Add(-MagicLinkInterval)
+	if err := tx.QueryRowContext(ctx, `
+    SELECT COUNT(*) FROM magic_links WHERE student_id = ? AND created_at > ?
+  `,
+		student.ID,
+		(*NullTime)(&since),
+	).Scan(&recent); err != nil {
+		return FormatError(err)
+	} else if recent > 0 {
+		return nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(buf)
+	expiresAt := tx.now.Add(s.TTL)
+
+	// Only the latest link works.
+	if err := revokeLinkTokens(ctx, tx, "magic_links", student.ID); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO magic_links (student_id, email, token_hash, nonce_hash, expires_at, created_at)
+    VALUES (?, ?, ?, ?, ?, ?)
+  `,
+		student.ID,
+		student.Email,
+		hashToken(token),
+		hashToken(nonce),
+		(*NullTime)(&expiresAt),
+		(*NullTime)(&tx.now),
+	)
+	if err != nil {
+		return FormatError(err)
+	} else if err := createLinkToken(ctx, tx, "magic_links", result, &linkToken{
+		StudentID: student.ID,
+		Email:     student.Email,
+		ExpiresAt: expiresAt,
+		CreatedAt: tx.now,
+	}); err != nil {
+		return err
+	}
+
+	if err := enqueueEmail(ctx, tx, ocs.MagicLinkEmail, ocs.EmailData{
+		Student:   student,
+		URL:       fmt.Sprintf("%s/login/magic-link/callback?token=%s", strings.TrimSuffix(s.URL, "/"), token),
+		ExpiresAt: expiresAt.In(student.Location()),
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *MagicLinkService) RedeemMagicLink(ctx context.Context, token, nonce string) (*ocs.Student, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	student, err := redeemMagicLink(ctx, tx, token, nonce)
+	if err != nil {
+		return nil, err
+	} else if err := attachStudentsAuths(ctx, tx, student); err != nil {
+		return nil, err
+	} else if err := attachStudentBadges(ctx, tx, student); err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return student, nil
+}
+
+// redeemMagicLink uses a sign-in token. Following the link also proves the
+// student owns the address it was sent to.
+func redeemMagicLink(ctx context.Context, tx *Tx, token, nonce string) (*ocs.Student, error) {
+	var id, studentID int
+	var email, nonceHash string
+	var expiresAt, usedAt NullTime
+	if err := tx.QueryRowContext(ctx, `
+    SELECT id, student_id, email, nonce_hash, expires_at, used_at
+    FROM magic_links
+    WHERE token_hash = ?
+  `,
+		hashToken(token),
+	).Scan(&id, &studentID, &email, &nonceHash, &expiresAt, &usedAt); err == sql.ErrNoRows {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "Invalid sign-in link.")
+	} else if err != nil {
+		return nil, FormatError(err)
+	}
+
+	// Checked first, so that someone else holding the link cannot learn
+	// whether it is still good.
+	if subtle.ConstantTimeCompare([]byte(hashToken(nonce)), []byte(nonceHash)) != 1 {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "Please open the sign-in link in the browser you requested it from.")
+	} else if !time.Time(usedAt).IsZero() {
+		return nil, ocs.Errorf(ocs.ECONFLICT, "This sign-in link has already been used.")
+	} else if !tx.now.Before(time.Time(expiresAt)) {
+		return nil, ocs.Errorf(ocs.EEXPIRED, "This sign-in link has expired. Please request a new one.")
+	}
+
+	student, err := findStudentByID(ctx, tx, studentID)
+	if err != nil {
+		return nil, err
+	} else if email != student.Email {
+		return nil, ocs.Errorf(ocs.EEXPIRED, "This sign-in link is for an email you no longer use.")
+	}
+
+	if err := useLinkToken(ctx, tx, "magic_links", &linkToken{
+		ID:        id,
+		StudentID: studentID,
+		Email:     email,
+		ExpiresAt: time.Time(expiresAt),
+	}); err != nil {
+		return nil, err
+	}
+
+	if !student.EmailVerified {
+		prev := *student
+		student.EmailVerified = true
+		student.UpdatedAt = tx.now
+
+		if _, err := tx.ExecContext(ctx, `
+      UPDATE students SET email_verified = 1, updated_at = ? WHERE id = ?
+    `,
+			(*NullTime)(&student.UpdatedAt),
+			student.ID,
+		); err != nil {
+			return nil, FormatError(err)
+		} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "student", student.ID, &prev, student); err != nil {
+			return nil, err
+		}
+	}
+
+	return student, nil
+}
@@ -0,0 +1,131 @@
+package sqlite_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+var magicLinkURL = regexp.MustCompile(`https://ocs\.example\.com/login/magic-link/callback\?token=([0-9a-f]+)`)
+
+func TestMagicLinkService_RedeemMagicLink(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewMagicLinkService(db)
+		s.URL = "https://ocs.example.com"
+
+		student, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		MustDeliverEmails(t, db)
+
+		if err := s.RequestMagicLink(DefaultSiteContext(), "jane@email.com", "NONCE"); err != nil {
+			t.Fatal(err)
+		} else if err := s.RequestMagicLink(DefaultSiteContext(), "nobody@email.com", "NONCE"); err != nil {
+			t.Fatal(err)
+		}
+
+		sent := MustDeliverEmails(t, db)
+		if len(sent) != 1 {
+			t.Fatalf("len=%v, want 1", len(sent))
+		}
+		m := magicLinkURL.FindStringSubmatch(sent[0].Text)
+		if m == nil {
+			t.Fatalf("unexpected text: %s", sent[0].Text)
+		}
+
+		if other, err := s.RedeemMagicLink(DefaultSiteContext(), m[1], "NONCE"); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.ID, student.ID; got != want {
+			t.Fatalf("ID=%v, want %v", got, want)
+		} else if _, err := s.RedeemMagicLink(DefaultSiteContext(), m[1], "NONCE"); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Links only work in the browser they were requested from.
+	t.Run("ErrNonce", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewMagicLinkService(db)
+		s.URL = "https://ocs.example.com"
+
+		MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		MustDeliverEmails(t, db)
+		if err := s.RequestMagicLink(DefaultSiteContext(), "jane@email.com", "NONCE"); err != nil {
+			t.Fatal(err)
+		}
+		m := magicLinkURL.FindStringSubmatch(MustDeliverEmails(t, db)[0].Text)
+
+		if _, err := s.RedeemMagicLink(DefaultSiteContext(), m[1], "OTHER"); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if _, err := s.RedeemMagicLink(DefaultSiteContext(), m[1], ""); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if _, err := s.RedeemMagicLink(DefaultSiteContext(), "BADTOKEN", "NONCE"); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrExpired", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		s := sqlite.NewMagicLinkService(db)
+		s.URL = "https://ocs.example.com"
+
+		MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		MustDeliverEmails(t, db)
+		if err := s.RequestMagicLink(DefaultSiteContext(), "jane@email.com", "NONCE"); err != nil {
+			t.Fatal(err)
+		}
+		m := magicLinkURL.FindStringSubmatch(MustDeliverEmails(t, db)[0].Text)
+
+		now = now.Add(sqlite.DefaultMagicLinkTTL)
+		if _, err := s.RedeemMagicLink(DefaultSiteContext(), m[1], "NONCE"); ocs.ErrorCode(err) != ocs.EEXPIRED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Only the latest link works, and requests are rate limited.
+	t.Run("Superseded", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		s := sqlite.NewMagicLinkService(db)
+		s.URL = "https://ocs.example.com"
+
+		MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		MustDeliverEmails(t, db)
+		if err := s.RequestMagicLink(DefaultSiteContext(), "jane@email.com", "NONCE"); err != nil {
+			t.Fatal(err)
+		} else if err := s.RequestMagicLink(DefaultSiteContext(), "jane@email.com", "NONCE"); err != nil {
+			t.Fatal(err)
+		}
+		sent := MustDeliverEmails(t, db)
+		if len(sent) != 1 {
+			t.Fatalf("len=%v, want 1", len(sent))
+		}
+		first := magicLinkURL.FindStringSubmatch(sent[0].Text)
+
+		now = now.Add(sqlite.MagicLinkInterval)
+		if err := s.RequestMagicLink(DefaultSiteContext(), "jane@email.com", "NONCE"); err != nil {
+			t.Fatal(err)
+		}
+		second := magicLinkURL.FindStringSubmatch(MustDeliverEmails(t, db)[0].Text)
+
+		if _, err := s.RedeemMagicLink(DefaultSiteContext(), first[1], "NONCE"); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if _, err := s.RedeemMagicLink(DefaultSiteContext(), second[1], "NONCE"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,606 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.MessageService = (*MessageService)(nil)
+
+type MessageService struct {
+	db *DB
+}
+
+func NewMessageService(db *DB) *MessageService {
+	return &MessageService{db: db}
+}
+
+func (s *MessageService) FindConversationByID(ctx context.Context, id int) (*ocs.Conversation, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	conversation, err := findConversationByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachConversationAssociations(ctx, tx, conversation); err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+func (s *MessageService) FindConversations(ctx context.Context, filter ocs.ConversationFilter) ([]*ocs.Conversation, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	conversations, n, err := findConversations(ctx, tx, filter)
+	if err != nil {
+		return conversations, n, err
+	}
+
+	for _, conversation := range conversations {
+		if err := attachConversationAssociations(ctx, tx, conversation); err != nil {
+			return conversations, n, err
+		}
+	}
+	return conversations, n, nil
+}
+
+func (s *MessageService) CreateConversation(ctx context.Context, conversation *ocs.Conversation) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createConversation(ctx, tx, conversation); err != nil {
+		return err
+	} else if err := attachConversationAssociations(ctx, tx, conversation); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *MessageService) FindMessages(ctx context.Context, filter ocs.MessageFilter) ([]*ocs.Message, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	return findMessages(ctx, tx, filter)
+}
+
+func (s *MessageService) SendMessage(ctx context.Context, message *ocs.Message) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := sendMessage(ctx, tx, message); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *MessageService) MarkConversationRead(ctx context.Context, conversationID int) (*ocs.ConversationParticipant, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	participant, err := markConversationRead(ctx, tx, conversationID)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return participant, nil
+}
+
+func (s *MessageService) CountUnreadMessages(ctx context.Context) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	return countUnreadMessages(ctx, tx)
+}
+
+// createConversation starts a conversation between the current student and
+// the given participants, who must all be members of the course.
+func createConversation(ctx context.Context, tx *Tx, conversation *ocs.Conversation) error {
+	studentID := ocs.StudentIDFromContext(ctx)
+	if studentID == 0 {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You must be logged in to start a conversation.")
+	}
+
+	// Add the current student and drop duplicates.
+	seen := make(map[int]bool)
+	participantIDs := make([]int, 0, len(conversation.ParticipantIDs)+1)
+	for _, id := range append([]int{studentID}, conversation.ParticipantIDs...) {
+		if !seen[id] {
+			seen[id] = true
+			participantIDs = append(participantIDs, id)
+		}
+	}
+	conversation.ParticipantIDs = participantIDs
+	conversation.CreatedAt = tx.now
+	conversation.UpdatedAt = conversation.CreatedAt
+
+	if err := conversation.Validate(); err != nil {
+		return err
+	}
+
+	course, err := findCourseByID(ctx, tx, conversation.CourseID)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range conversation.ParticipantIDs {
+		if ok, err := isCourseMember(ctx, tx, course.ID, id); err != nil {
+			return err
+		} else if !ok && id == studentID {
+			return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not a member of this course.")
+		} else if !ok {
+			return ocs.Errorf(ocs.EINVALID, "All participants must be members of the course.")
+		}
+	}
+
+	if err := checkStudentMessaging(ctx, tx, course, conversation.ParticipantIDs); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO conversations (
+      course_id,
+      subject,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?)
+  `,
+		conversation.CourseID,
+		conversation.Subject,
+		(*NullTime)(&conversation.CreatedAt),
+		(*NullTime)(&conversation.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	conversation.ID = int(id)
+
+	for _, id := range conversation.ParticipantIDs {
+		if _, err := tx.ExecContext(ctx, `
+      INSERT INTO conversation_participants (conversation_id, student_id)
+      VALUES (?, ?)
+    `, conversation.ID, id); err != nil {
+			return FormatError(err)
+		}
+	}
+
+	return audit(ctx, tx, ocs.AuditActionCreate, "conversation", conversation.ID, nil, conversation)
+}
+
+// checkStudentMessaging rejects conversations without a member of the
+// course staff if the course has disabled messaging between students.
+func checkStudentMessaging(ctx context.Context, tx *Tx, course *ocs.Course, participantIDs []int) error {
+	if !course.StudentMessagingDisabled {
+		return nil
+	}
+
+	for _, id := range participantIDs {
+		if ok, err := isCourseStaff(ctx, tx, course.ID, id); err != nil {
+			return err
+		} else if ok {
+			return nil
+		}
+	}
+	return ocs.Errorf(ocs.EUNAUTHORIZED, "Messaging between students is disabled for this course.")
+}
+
+func findConversationByID(ctx context.Context, tx *Tx, id int) (*ocs.Conversation, error) {
+	a, _, err := findConversations(ctx, tx, ocs.ConversationFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Conversation not found."}
+	}
+	return a[0], nil
+}
+
+// findConversations only returns conversations the caller takes part in.
+func findConversations(ctx context.Context, tx *Tx, filter ocs.ConversationFilter) (_ []*ocs.Conversation, n int, err error) {
+	where, args := tx.scope(byCourse, "c.course_id")
+	if v := filter.ID; v != nil {
+		where, args = append(where, "c.id = ?"), append(args, *v)
+	}
+	if v := filter.CourseID; v != nil {
+		where, args = append(where, "c.course_id = ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      c.id,
+      c.course_id,
+      c.subject,
+      (
+        SELECT COUNT(*)
+        FROM messages m
+        WHERE m.conversation_id = c.id AND m.sender_id != p.student_id AND m.id > p.last_read_message_id
+      ),
+      c.created_at,
+      c.updated_at,
+      COUNT(*) OVER()
+    FROM conversations c
+    INNER JOIN conversation_participants p ON p.conversation_id = c.id AND p.student_id = ?
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY c.updated_at DESC, c.id DESC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		append([]interface{}{ocs.StudentIDFromContext(ctx)}, args...)...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	conversations := make([]*ocs.Conversation, 0)
+	for rows.Next() {
+		var conversation ocs.Conversation
+		if err := rows.Scan(
+			&conversation.ID,
+			&conversation.CourseID,
+			&conversation.Subject,
+			&conversation.UnreadCount,
+			(*NullTime)(&conversation.CreatedAt),
+			(*NullTime)(&conversation.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		conversations = append(conversations, &conversation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return conversations, n, nil
+}
+
+func attachConversationAssociations(ctx context.Context, tx *Tx, conversation *ocs.Conversation) (err error) {
+	if conversation.Participants, err = findConversationParticipants(ctx, tx, conversation.ID); err != nil {
+		return fmt.Errorf("attach conversation participants: %w", err)
+	}
+
+	conversation.ParticipantIDs = make([]int, len(conversation.Participants))
+	for i, participant := range conversation.Participants {
+		conversation.ParticipantIDs[i] = participant.StudentID
+		if participant.Student, err = findAnyStudentByID(ctx, tx, participant.StudentID); err != nil {
+			return fmt.Errorf("attach conversation participant student: %w", err)
+		}
+	}
+	return nil
+}
+
+func findConversationParticipants(ctx context.Context, tx *Tx, conversationID int) ([]*ocs.ConversationParticipant, error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      student_id,
+      last_read_message_id,
+      last_read_at
+    FROM conversation_participants
+    WHERE conversation_id = ?
+    ORDER BY id ASC
+  `,
+		conversationID,
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	participants := make([]*ocs.ConversationParticipant, 0)
+	for rows.Next() {
+		var participant ocs.ConversationParticipant
+		var lastReadAt NullTime
+		if err := rows.Scan(
+			&participant.StudentID,
+			&participant.LastReadMessageID,
+			&lastReadAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if v := (time.Time)(lastReadAt); !v.IsZero() {
+			participant.LastReadAt = &v
+		}
+
+		participants = append(participants, &participant)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+
+	return participants, nil
+}
+
+// sendMessage adds a message from the current student to the conversation
+// and delivers it to every participant. Sending a message also marks the
+// conversation as read by the sender.
+func sendMessage(ctx context.Context, tx *Tx, message *ocs.Message) error {
+	message.SenderID = ocs.StudentIDFromContext(ctx)
+	message.CreatedAt = tx.now
+
+	if err := message.Validate(); err != nil {
+		return err
+	}
+
+	conversation, err := findConversationByID(ctx, tx, message.ConversationID)
+	if err != nil {
+		return err
+	}
+
+	participants, err := findConversationParticipants(ctx, tx, conversation.ID)
+	if err != nil {
+		return err
+	}
+	participantIDs := make([]int, len(participants))
+	for i, participant := range participants {
+		participantIDs[i] = participant.StudentID
+	}
+
+	// The course may have disabled student messaging since the
+	// conversation started.
+	if course, err := findCourseByID(ctx, tx, conversation.CourseID); err != nil {
+		return err
+	} else if err := checkStudentMessaging(ctx, tx, course, participantIDs); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO messages (
+      conversation_id,
+      sender_id,
+      body,
+      created_at
+    )
+    VALUES (?, ?, ?, ?)
+  `,
+		message.ConversationID,
+		message.SenderID,
+		message.Body,
+		(*NullTime)(&message.CreatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	message.ID = int(id)
+
+	if err := audit(ctx, tx, ocs.AuditActionCreate, "message", message.ID, nil, message); err != nil {
+		return err
+	}
+
+	// Bumping the conversation's update time needs no audit entry of its
+	// own; the message's entry records it.
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE conversations SET updated_at = ? WHERE id = ?
+  `, (*NullTime)(&message.CreatedAt), message.ConversationID); err != nil {
+		return FormatError(err)
+	}
+
+	if _, err := updateReadReceipt(ctx, tx, message.ConversationID, message.SenderID, message.ID); err != nil {
+		return err
+	}
+
+	event := ocs.Event{
+		Type:    ocs.EventTypeMessageCreated,
+		Payload: &ocs.MessageCreatedPayload{Message: message},
+	}
+	for _, id := range participantIDs {
+		tx.publishEvent(id, event)
+	}
+
+	return nil
+}
+
+// markConversationRead moves the current student's read receipt to the
+// latest message and sends it to the other participants. Nothing is
+// published if there was nothing new to read.
+func markConversationRead(ctx context.Context, tx *Tx, conversationID int) (*ocs.ConversationParticipant, error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+
+	conversation, err := findConversationByID(ctx, tx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastID int
+	if err := tx.QueryRowContext(ctx, `
+    SELECT COALESCE(MAX(id), 0) FROM messages WHERE conversation_id = ?
+  `, conversation.ID).Scan(&lastID); err != nil {
+		return nil, FormatError(err)
+	}
+
+	moved, err := updateReadReceipt(ctx, tx, conversation.ID, studentID, lastID)
+	if err != nil {
+		return nil, err
+	}
+
+	participants, err := findConversationParticipants(ctx, tx, conversation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var participant *ocs.ConversationParticipant
+	for _, p := range participants {
+		if p.StudentID == studentID {
+			participant = p
+		}
+	}
+
+	if !moved {
+		return participant, nil
+	}
+
+	event := ocs.Event{
+		Type: ocs.EventTypeConversationRead,
+		Payload: &ocs.ConversationReadPayload{
+			ConversationID:    conversation.ID,
+			StudentID:         studentID,
+			LastReadMessageID: lastID,
+			ReadAt:            tx.now,
+		},
+	}
+	for _, p := range participants {
+		tx.publishEvent(p.StudentID, event)
+	}
+
+	return participant, nil
+}
+
+// updateReadReceipt moves a participant's read receipt forward to the given
+// message. It reports whether the receipt moved; it never moves back, and
+// students who are not participants have none.
+func updateReadReceipt(ctx context.Context, tx *Tx, conversationID, studentID, messageID int) (bool, error) {
+	var id int
+	var lastReadAt NullTime
+	prev := ocs.ConversationParticipant{StudentID: studentID}
+	if err := tx.QueryRowContext(ctx, `
+    SELECT id, last_read_message_id, last_read_at
+    FROM conversation_participants
+    WHERE conversation_id = ? AND student_id = ?
+  `,
+		conversationID,
+		studentID,
+	).Scan(&id, &prev.LastReadMessageID, &lastReadAt); err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, FormatError(err)
+	} else if prev.LastReadMessageID >= messageID {
+		return false, nil
+	}
+	if v := (time.Time)(lastReadAt); !v.IsZero() {
+		prev.LastReadAt = &v
+	}
+
+	participant := prev
+	participant.LastReadMessageID, participant.LastReadAt = messageID, &tx.now
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE conversation_participants
+    SET last_read_message_id = ?,
+        last_read_at = ?
+    WHERE id = ?
+  `,
+		participant.LastReadMessageID,
+		(*NullTime)(participant.LastReadAt),
+		id,
+	); err != nil {
+		return false, FormatError(err)
+	}
+
+	return true, audit(ctx, tx, ocs.AuditActionUpdate, "conversation_participant", id, &prev, &participant)
+}
+
+// findMessages returns the latest messages before filter.BeforeID, in the
+// order they were sent. N is the number of messages before it.
+func findMessages(ctx context.Context, tx *Tx, filter ocs.MessageFilter) (_ []*ocs.Message, n int, err error) {
+	if _, err := findConversationByID(ctx, tx, filter.ConversationID); err != nil {
+		return nil, 0, err
+	}
+
+	where, args := []string{"conversation_id = ?"}, []interface{}{filter.ConversationID}
+	if v := filter.BeforeID; v != nil {
+		where, args = append(where, "id < ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      conversation_id,
+      sender_id,
+      body,
+      created_at,
+      COUNT(*) OVER()
+    FROM messages
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id DESC
+    `+FormatLimitOffset(filter.Limit, 0),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	messages := make([]*ocs.Message, 0)
+	for rows.Next() {
+		var message ocs.Message
+		if err := rows.Scan(
+			&message.ID,
+			&message.ConversationID,
+			&message.SenderID,
+			&message.Body,
+			(*NullTime)(&message.CreatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		messages = append(messages, &message)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	return messages, n, nil
+}
+
+func countUnreadMessages(ctx context.Context, tx *Tx) (int, error) {
+	where, args := tx.scope(byCourse, "c.course_id")
+
+	var n int
+	if err := tx.QueryRowContext(ctx, `
+    SELECT COUNT(*)
+    FROM messages m
+    INNER JOIN conversation_participants p ON p.conversation_id = m.conversation_id AND p.student_id = ?
+    INNER JOIN conversations c ON c.id = m.conversation_id
+    WHERE m.sender_id != p.student_id AND m.id > p.last_read_message_id AND `+strings.Join(where, " AND "),
+		append([]interface{}{ocs.StudentIDFromContext(ctx)}, args...)...,
+	).Scan(&n); err != nil {
+		return 0, FormatError(err)
+	}
+	return n, nil
+}
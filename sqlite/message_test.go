@@ -0,0 +1,194 @@
+package sqlite_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/mock"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestMessageService_CreateConversation(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewMessageService(db)
+
+		course, instructorCtx, ctxs := MustCreateGroupCourse(t, db, 2)
+		conversation := &ocs.Conversation{
+			CourseID:       course.ID,
+			Subject:        "Project",
+			ParticipantIDs: []int{ocs.StudentIDFromContext(ctxs[0]), ocs.StudentIDFromContext(ctxs[1])},
+		}
+		if err := s.CreateConversation(instructorCtx, conversation); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(conversation.Participants), 3; got != want {
+			t.Fatalf("len(Participants)=%v, want %v", got, want)
+		}
+
+		// Only participants can see the conversation.
+		if _, n, err := s.FindConversations(ctxs[1], ocs.ConversationFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrNotMember", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewMessageService(db)
+
+		course, _, ctxs := MustCreateGroupCourse(t, db, 1)
+		other, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "zed", Email: "zed@email.com"})
+
+		if err := s.CreateConversation(ctxs[0], &ocs.Conversation{CourseID: course.ID, ParticipantIDs: []int{other.ID}}); ocs.ErrorCode(err) != ocs.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrStudentMessagingDisabled", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewMessageService(db)
+
+		course, instructorCtx, ctxs := MustCreateGroupCourse(t, db, 2)
+		conversation := MustCreateConversation(t, ctxs[0], db, &ocs.Conversation{CourseID: course.ID, ParticipantIDs: []int{ocs.StudentIDFromContext(ctxs[1])}})
+
+		disabled := true
+		if _, err := sqlite.NewCourseService(db).UpdateCourse(instructorCtx, course.ID, ocs.CourseUpdate{StudentMessagingDisabled: &disabled}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.CreateConversation(ctxs[0], &ocs.Conversation{CourseID: course.ID, ParticipantIDs: []int{ocs.StudentIDFromContext(ctxs[1])}}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if err := s.SendMessage(ctxs[0], &ocs.Message{ConversationID: conversation.ID, Body: "hi"}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		// Students may still message the instructor.
+		MustCreateConversation(t, ctxs[0], db, &ocs.Conversation{CourseID: course.ID, ParticipantIDs: []int{course.InstructorID}})
+	})
+}
+
+func TestMessageService_SendMessage(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewMessageService(db)
+
+		course, _, ctxs := MustCreateGroupCourse(t, db, 2)
+		conversation := MustCreateConversation(t, ctxs[0], db, &ocs.Conversation{CourseID: course.ID, ParticipantIDs: []int{ocs.StudentIDFromContext(ctxs[1])}})
+
+		var delivered, receipts []int
+		db.EventService = &mock.EventService{PublishEventFn: func(studentID int, event ocs.Event) {
+			switch event.Type {
+			case ocs.EventTypeMessageCreated:
+				delivered = append(delivered, studentID)
+			case ocs.EventTypeConversationRead:
+				receipts = append(receipts, studentID)
+			}
+		}}
+
+		MustSendMessage(t, ctxs[0], db, &ocs.Message{ConversationID: conversation.ID, Body: "hi"})
+		MustSendMessage(t, ctxs[0], db, &ocs.Message{ConversationID: conversation.ID, Body: "there"})
+		if got, want := len(delivered), 4; got != want {
+			t.Fatalf("len(delivered)=%v, want %v", got, want)
+		}
+
+		// The sender has read their own messages.
+		if n, err := s.CountUnreadMessages(ctxs[0]); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if n, err := s.CountUnreadMessages(ctxs[1]); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 2; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		if participant, err := s.MarkConversationRead(ctxs[1], conversation.ID); err != nil {
+			t.Fatal(err)
+		} else if participant.LastReadAt == nil {
+			t.Fatal("expected last read at")
+		} else if got, want := len(receipts), 2; got != want {
+			t.Fatalf("len(receipts)=%v, want %v", got, want)
+		}
+
+		if other, err := s.FindConversationByID(ctxs[1], conversation.ID); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.UnreadCount, 0; got != want {
+			t.Fatalf("UnreadCount=%v, want %v", got, want)
+		}
+
+		// Marking again publishes no receipt.
+		if _, err := s.MarkConversationRead(ctxs[1], conversation.ID); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(receipts), 2; got != want {
+			t.Fatalf("len(receipts)=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrNotParticipant", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewMessageService(db)
+
+		course, instructorCtx, ctxs := MustCreateGroupCourse(t, db, 2)
+		conversation := MustCreateConversation(t, ctxs[0], db, &ocs.Conversation{CourseID: course.ID, ParticipantIDs: []int{ocs.StudentIDFromContext(ctxs[1])}})
+
+		if err := s.SendMessage(instructorCtx, &ocs.Message{ConversationID: conversation.ID, Body: "hi"}); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestMessageService_FindMessages(t *testing.T) {
+	t.Run("Backfill", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewMessageService(db)
+
+		course, _, ctxs := MustCreateGroupCourse(t, db, 2)
+		conversation := MustCreateConversation(t, ctxs[0], db, &ocs.Conversation{CourseID: course.ID, ParticipantIDs: []int{ocs.StudentIDFromContext(ctxs[1])}})
+		for i := 1; i <= 5; i++ {
+			MustSendMessage(t, ctxs[i%2], db, &ocs.Message{ConversationID: conversation.ID, Body: fmt.Sprint(i)})
+		}
+
+		messages, n, err := s.FindMessages(ctxs[1], ocs.MessageFilter{ConversationID: conversation.ID, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 5; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := messages[0].Body+messages[1].Body, "45"; got != want {
+			t.Fatalf("bodies=%v, want %v", got, want)
+		}
+
+		messages, n, err = s.FindMessages(ctxs[1], ocs.MessageFilter{ConversationID: conversation.ID, BeforeID: &messages[0].ID, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 3; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := messages[0].Body+messages[1].Body, "23"; got != want {
+			t.Fatalf("bodies=%v, want %v", got, want)
+		}
+	})
+}
+
+func MustCreateConversation(tb testing.TB, ctx context.Context, db *sqlite.DB, conversation *ocs.Conversation) *ocs.Conversation {
+	tb.Helper()
+	if err := sqlite.NewMessageService(db).CreateConversation(ctx, conversation); err != nil {
+		tb.Fatal(err)
+	}
+	return conversation
+}
+
+func MustSendMessage(tb testing.TB, ctx context.Context, db *sqlite.DB, message *ocs.Message) *ocs.Message {
+	tb.Helper()
+	if err := sqlite.NewMessageService(db).SendMessage(ctx, message); err != nil {
+		tb.Fatal(err)
+	}
+	return message
+}
@@ -0,0 +1,75 @@
+CREATE TABLE courses (
+  id            INTEGER PRIMARY KEY AUTOINCREMENT,
+  instructor_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  title         TEXT NOT NULL,
+  description   TEXT NOT NULL,
+  created_at    TEXT NOT NULL,
+  updated_at    TEXT NOT NULL
+);
+
+CREATE INDEX courses_instructor_id_idx ON courses (instructor_id);
+
+CREATE TABLE enrollments (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  course_id  INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+  student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  role       TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+
+  UNIQUE(course_id, student_id)
+);
+
+CREATE INDEX enrollments_student_id_idx ON enrollments (student_id);
+
+CREATE TABLE assignments (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT,
+  course_id   INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+  title       TEXT NOT NULL,
+  description TEXT NOT NULL,
+  max_points  INTEGER NOT NULL,
+  due_at      TEXT,
+  created_at  TEXT NOT NULL,
+  updated_at  TEXT NOT NULL
+);
+
+CREATE INDEX assignments_course_id_idx ON assignments (course_id);
+
+CREATE TABLE submissions (
+  id            INTEGER PRIMARY KEY AUTOINCREMENT,
+  assignment_id INTEGER NOT NULL REFERENCES assignments(id) ON DELETE CASCADE,
+  student_id    INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  body          TEXT NOT NULL,
+  grade         INTEGER,
+  feedback      TEXT NOT NULL,
+  graded_at     TEXT,
+  created_at    TEXT NOT NULL,
+  updated_at    TEXT NOT NULL,
+
+  UNIQUE(assignment_id, student_id)
+);
+
+CREATE TABLE regrade_requests (
+  id            INTEGER PRIMARY KEY AUTOINCREMENT,
+  submission_id INTEGER NOT NULL REFERENCES submissions(id) ON DELETE CASCADE,
+  course_id     INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+  student_id    INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  justification TEXT NOT NULL,
+  status        TEXT NOT NULL,
+  reply         TEXT NOT NULL,
+  reviewer_id   INTEGER REFERENCES students(id) ON DELETE SET NULL,
+  created_at    TEXT NOT NULL,
+  updated_at    TEXT NOT NULL
+);
+
+CREATE INDEX regrade_requests_course_id_status_idx ON regrade_requests (course_id, status);
+
+CREATE TABLE regrade_transitions (
+  id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+  regrade_request_id INTEGER NOT NULL REFERENCES regrade_requests(id) ON DELETE CASCADE,
+  actor_id           INTEGER REFERENCES students(id) ON DELETE SET NULL,
+  from_status        TEXT NOT NULL,
+  to_status          TEXT NOT NULL,
+  note               TEXT NOT NULL,
+  created_at         TEXT NOT NULL
+);
@@ -0,0 +1,54 @@
+CREATE TABLE lessons (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  course_id  INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+  title      TEXT NOT NULL,
+  body       TEXT NOT NULL,
+  video_url  TEXT NOT NULL,
+  position   INTEGER NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE INDEX lessons_course_id_idx ON lessons (course_id, position);
+
+CREATE TABLE notes (
+  id              INTEGER PRIMARY KEY AUTOINCREMENT,
+  student_id      INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  lesson_id       INTEGER NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+  body            TEXT NOT NULL,
+  anchor_start    INTEGER,
+  anchor_end      INTEGER,
+  video_timestamp INTEGER,
+  created_at      TEXT NOT NULL,
+  updated_at      TEXT NOT NULL
+);
+
+CREATE INDEX notes_student_id_lesson_id_idx ON notes (student_id, lesson_id);
+
+-- Full-text index over note bodies, kept in sync with the notes table.
+CREATE VIRTUAL TABLE notes_fts USING fts4(content="notes", body);
+
+CREATE TRIGGER notes_fts_ai AFTER INSERT ON notes BEGIN
+  INSERT INTO notes_fts (docid, body) VALUES (new.id, new.body);
+END;
+
+CREATE TRIGGER notes_fts_bu BEFORE UPDATE ON notes BEGIN
+  DELETE FROM notes_fts WHERE docid = old.id;
+END;
+
+CREATE TRIGGER notes_fts_au AFTER UPDATE ON notes BEGIN
+  INSERT INTO notes_fts (docid, body) VALUES (new.id, new.body);
+END;
+
+CREATE TRIGGER notes_fts_bd BEFORE DELETE ON notes BEGIN
+  DELETE FROM notes_fts WHERE docid = old.id;
+END;
+
+CREATE TABLE bookmarks (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  lesson_id  INTEGER NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+  created_at TEXT NOT NULL,
+
+  UNIQUE(student_id, lesson_id)
+);
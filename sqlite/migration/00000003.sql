@@ -0,0 +1,46 @@
+ALTER TABLE enrollments ADD COLUMN completed_at TEXT;
+
+CREATE TABLE learning_paths (
+  id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+  owner_id           INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  title              TEXT NOT NULL,
+  description        TEXT NOT NULL,
+  electives_required INTEGER NOT NULL,
+  issues_certificate INTEGER NOT NULL,
+  created_at         TEXT NOT NULL,
+  updated_at         TEXT NOT NULL
+);
+
+CREATE TABLE learning_path_courses (
+  learning_path_id INTEGER NOT NULL REFERENCES learning_paths(id) ON DELETE CASCADE,
+  course_id        INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+  position         INTEGER NOT NULL,
+  required         INTEGER NOT NULL,
+
+  PRIMARY KEY (learning_path_id, course_id)
+);
+
+CREATE INDEX learning_path_courses_course_id_idx ON learning_path_courses (course_id);
+
+CREATE TABLE path_enrollments (
+  id               INTEGER PRIMARY KEY AUTOINCREMENT,
+  learning_path_id INTEGER NOT NULL REFERENCES learning_paths(id) ON DELETE CASCADE,
+  student_id       INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  completed_at     TEXT,
+  created_at       TEXT NOT NULL,
+  updated_at       TEXT NOT NULL,
+
+  UNIQUE(learning_path_id, student_id)
+);
+
+CREATE INDEX path_enrollments_student_id_idx ON path_enrollments (student_id);
+
+CREATE TABLE certificates (
+  id               INTEGER PRIMARY KEY AUTOINCREMENT,
+  student_id       INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  learning_path_id INTEGER NOT NULL REFERENCES learning_paths(id) ON DELETE CASCADE,
+  code             TEXT NOT NULL UNIQUE,
+  issued_at        TEXT NOT NULL,
+
+  UNIQUE(learning_path_id, student_id)
+);
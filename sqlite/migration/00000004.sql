@@ -0,0 +1,21 @@
+CREATE TABLE badge_activities (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  badge_code TEXT NOT NULL,
+  event_key  TEXT NOT NULL,
+  day        TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+
+  UNIQUE(student_id, badge_code, event_key)
+);
+
+CREATE TABLE badge_awards (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT,
+  student_id  INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  badge_code  TEXT NOT NULL,
+  name        TEXT NOT NULL,
+  description TEXT NOT NULL,
+  awarded_at  TEXT NOT NULL,
+
+  UNIQUE(student_id, badge_code)
+);
@@ -0,0 +1,16 @@
+ALTER TABLE students ADD COLUMN leaderboard_opt_out INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE point_entries (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  course_id  INTEGER REFERENCES courses(id) ON DELETE CASCADE,
+  reason     TEXT NOT NULL,
+  event_key  TEXT NOT NULL,
+  points     INTEGER NOT NULL,
+  created_at TEXT NOT NULL,
+
+  UNIQUE(student_id, event_key)
+);
+
+CREATE INDEX point_entries_course_id_created_at_idx ON point_entries (course_id, created_at);
+CREATE INDEX point_entries_created_at_idx ON point_entries (created_at);
@@ -0,0 +1,42 @@
+ALTER TABLE courses ADD COLUMN price INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE courses ADD COLUMN currency TEXT NOT NULL DEFAULT '';
+
+-- Coupon codes are unique per course rather than globally, so that courses
+-- can pick codes freely.
+CREATE TABLE coupons (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  course_id  INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+  code       TEXT NOT NULL,
+  kind       TEXT NOT NULL,
+  amount     INTEGER NOT NULL,
+  expires_at TEXT,
+  max_uses   INTEGER NOT NULL,
+  uses       INTEGER NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+
+  UNIQUE (course_id, code)
+);
+
+CREATE TABLE orders (
+  id            INTEGER PRIMARY KEY AUTOINCREMENT,
+  student_id    INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  course_id     INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+  coupon_id     INTEGER REFERENCES coupons(id) ON DELETE SET NULL,
+  subtotal      INTEGER NOT NULL,
+  discount      INTEGER NOT NULL,
+  total         INTEGER NOT NULL,
+  currency      TEXT NOT NULL,
+  status        TEXT NOT NULL,
+  provider_ref  TEXT NOT NULL,
+  payment_url   TEXT NOT NULL,
+  enrollment_id INTEGER REFERENCES enrollments(id) ON DELETE SET NULL,
+  paid_at       TEXT,
+  created_at    TEXT NOT NULL,
+  updated_at    TEXT NOT NULL
+);
+
+CREATE INDEX orders_student_id_idx ON orders (student_id);
+CREATE INDEX orders_course_id_idx ON orders (course_id);
+CREATE INDEX orders_provider_ref_idx ON orders (provider_ref);
+CREATE INDEX orders_coupon_id_idx ON orders (coupon_id);
@@ -0,0 +1,45 @@
+ALTER TABLE students ADD COLUMN admin INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE orders ADD COLUMN refunded INTEGER NOT NULL DEFAULT 0;
+
+-- The refund ledger is append-only and outlives the orders it refers to.
+-- Refunds are recorded as pending before the provider is called, then
+-- settled. Only the status of a pending refund may change.
+CREATE TABLE refunds (
+  id           INTEGER PRIMARY KEY AUTOINCREMENT,
+  order_id     INTEGER NOT NULL,
+  student_id   INTEGER NOT NULL,
+  course_id    INTEGER NOT NULL,
+  amount       INTEGER NOT NULL,
+  currency     TEXT NOT NULL,
+  reason       TEXT NOT NULL,
+  access       TEXT NOT NULL,
+  status       TEXT NOT NULL,
+  issued_by_id INTEGER NOT NULL,
+  created_at   TEXT NOT NULL
+);
+
+CREATE INDEX refunds_order_id_idx ON refunds (order_id);
+CREATE INDEX refunds_course_id_idx ON refunds (course_id);
+CREATE INDEX refunds_status_idx ON refunds (status) WHERE status = 'pending';
+
+CREATE TRIGGER refunds_no_update BEFORE UPDATE ON refunds
+WHEN OLD.status != 'pending'
+  OR NEW.status NOT IN ('issued', 'failed')
+  OR NEW.order_id != OLD.order_id
+  OR NEW.student_id != OLD.student_id
+  OR NEW.course_id != OLD.course_id
+  OR NEW.amount != OLD.amount
+  OR NEW.currency != OLD.currency
+  OR NEW.reason != OLD.reason
+  OR NEW.access != OLD.access
+  OR NEW.issued_by_id != OLD.issued_by_id
+  OR NEW.created_at != OLD.created_at
+BEGIN
+  SELECT RAISE(ABORT, 'refunds are append-only');
+END;
+
+CREATE TRIGGER refunds_no_delete BEFORE DELETE ON refunds
+BEGIN
+  SELECT RAISE(ABORT, 'refunds are append-only');
+END;
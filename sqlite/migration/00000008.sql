@@ -0,0 +1,31 @@
+CREATE TABLE organizations (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  name       TEXT NOT NULL,
+  slug       TEXT NOT NULL UNIQUE,
+  domain     TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX organizations_domain_idx ON organizations (domain) WHERE domain != '';
+
+CREATE TABLE organization_members (
+  id              INTEGER PRIMARY KEY AUTOINCREMENT,
+  organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+  student_id      INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  role            TEXT NOT NULL,
+  created_at      TEXT NOT NULL,
+
+  UNIQUE(organization_id, student_id)
+);
+
+CREATE INDEX organization_members_student_id_idx ON organization_members (student_id);
+
+-- Zero is the catalog that belongs to no organization.
+ALTER TABLE courses ADD COLUMN organization_id INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX courses_organization_id_idx ON courses (organization_id);
+
+ALTER TABLE orders ADD COLUMN organization_id INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE learning_paths ADD COLUMN organization_id INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX learning_paths_organization_id_idx ON learning_paths (organization_id);
@@ -0,0 +1,40 @@
+CREATE TABLE group_sets (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT,
+  course_id   INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+  name        TEXT NOT NULL,
+  self_signup INTEGER NOT NULL,
+  max_size    INTEGER NOT NULL,
+  created_at  TEXT NOT NULL,
+  updated_at  TEXT NOT NULL
+);
+
+CREATE INDEX group_sets_course_id_idx ON group_sets (course_id);
+
+CREATE TABLE student_groups (
+  id           INTEGER PRIMARY KEY AUTOINCREMENT,
+  group_set_id INTEGER NOT NULL REFERENCES group_sets(id) ON DELETE CASCADE,
+  name         TEXT NOT NULL,
+  created_at   TEXT NOT NULL,
+  updated_at   TEXT NOT NULL
+);
+
+CREATE INDEX student_groups_group_set_id_idx ON student_groups (group_set_id);
+
+-- Memberships are closed by setting left_at rather than deleted, so that the
+-- members of a group at any point in time are known.
+CREATE TABLE group_members (
+  id           INTEGER PRIMARY KEY AUTOINCREMENT,
+  group_id     INTEGER NOT NULL REFERENCES student_groups(id) ON DELETE CASCADE,
+  group_set_id INTEGER NOT NULL REFERENCES group_sets(id) ON DELETE CASCADE,
+  student_id   INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  joined_at    TEXT NOT NULL,
+  left_at      TEXT
+);
+
+CREATE INDEX group_members_group_id_idx ON group_members (group_id);
+CREATE UNIQUE INDEX group_members_current_idx ON group_members (group_set_id, student_id) WHERE left_at IS NULL;
+
+ALTER TABLE assignments ADD COLUMN group_set_id INTEGER REFERENCES group_sets(id) ON DELETE SET NULL;
+
+ALTER TABLE submissions ADD COLUMN group_id INTEGER REFERENCES student_groups(id) ON DELETE SET NULL;
+CREATE UNIQUE INDEX submissions_group_id_idx ON submissions (assignment_id, group_id) WHERE group_id IS NOT NULL;
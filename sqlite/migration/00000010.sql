@@ -0,0 +1,33 @@
+ALTER TABLE courses ADD COLUMN student_messaging_disabled INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE conversations (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  course_id  INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+  subject    TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE INDEX conversations_course_id_idx ON conversations (course_id);
+
+CREATE TABLE conversation_participants (
+  id                   INTEGER PRIMARY KEY AUTOINCREMENT,
+  conversation_id      INTEGER NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+  student_id           INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  last_read_message_id INTEGER NOT NULL DEFAULT 0,
+  last_read_at         TEXT,
+
+  UNIQUE(conversation_id, student_id)
+);
+
+CREATE INDEX conversation_participants_student_id_idx ON conversation_participants (student_id);
+
+CREATE TABLE messages (
+  id              INTEGER PRIMARY KEY AUTOINCREMENT,
+  conversation_id INTEGER NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+  sender_id       INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  body            TEXT NOT NULL,
+  created_at      TEXT NOT NULL
+);
+
+CREATE INDEX messages_conversation_id_idx ON messages (conversation_id, id);
@@ -0,0 +1,14 @@
+CREATE TABLE notifications (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  type       TEXT NOT NULL,
+  payload    TEXT NOT NULL,
+  event_key  TEXT NOT NULL,
+  read_at    TEXT,
+  created_at TEXT NOT NULL,
+
+  UNIQUE(student_id, event_key)
+);
+
+-- Keeps unread counts cheap however large the inbox grows.
+CREATE INDEX notifications_unread_idx ON notifications (student_id) WHERE read_at IS NULL;
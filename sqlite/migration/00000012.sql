@@ -0,0 +1,19 @@
+-- Emails are written to the outbox in the same transaction as the change
+-- that causes them and delivered by a background worker.
+CREATE TABLE emails (
+  id              INTEGER PRIMARY KEY AUTOINCREMENT,
+  student_id      INTEGER NOT NULL,
+  template        TEXT NOT NULL,
+  recipient       TEXT NOT NULL,
+  subject         TEXT NOT NULL,
+  text_body       TEXT NOT NULL,
+  html_body       TEXT NOT NULL,
+  status          TEXT NOT NULL,
+  attempts        INTEGER NOT NULL DEFAULT 0,
+  last_error      TEXT NOT NULL DEFAULT '',
+  next_attempt_at TEXT NOT NULL,
+  sent_at         TEXT,
+  created_at      TEXT NOT NULL
+);
+
+CREATE INDEX emails_pending_idx ON emails (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,20 @@
+-- Students choose whether notifications are emailed one by one or collected
+-- in a daily or weekly digest, sent in the morning of their time zone.
+ALTER TABLE students ADD COLUMN email_digest TEXT NOT NULL DEFAULT 'daily';
+ALTER TABLE students ADD COLUMN time_zone TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE notifications ADD COLUMN emailed_at TEXT;
+
+CREATE INDEX notifications_unemailed_idx ON notifications (student_id, created_at) WHERE emailed_at IS NULL;
+
+-- One row per digest period and student, written in the same transaction as
+-- the digest email so that a period is never sent twice.
+CREATE TABLE digests (
+  id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+  student_id         INTEGER NOT NULL REFERENCES students (id) ON DELETE CASCADE,
+  period_key         TEXT NOT NULL,
+  notification_count INTEGER NOT NULL,
+  created_at         TEXT NOT NULL,
+
+  UNIQUE (student_id, period_key)
+);
@@ -0,0 +1,17 @@
+-- Courses choose how many hours before a due date students are reminded.
+ALTER TABLE courses ADD COLUMN reminder_hours TEXT NOT NULL DEFAULT '[48,2]';
+ALTER TABLE courses ADD COLUMN reminders_disabled INTEGER NOT NULL DEFAULT 0;
+
+-- One row per reminder sent, so that a restarted scheduler does not remind
+-- a student twice.
+CREATE TABLE deadline_reminders (
+  id            INTEGER PRIMARY KEY AUTOINCREMENT,
+  assignment_id INTEGER NOT NULL REFERENCES assignments (id) ON DELETE CASCADE,
+  student_id    INTEGER NOT NULL REFERENCES students (id) ON DELETE CASCADE,
+  hours_before  INTEGER NOT NULL,
+  created_at    TEXT NOT NULL,
+
+  UNIQUE (assignment_id, student_id, hours_before)
+);
+
+CREATE INDEX assignments_due_at_idx ON assignments (due_at) WHERE due_at IS NOT NULL;
@@ -0,0 +1,30 @@
+-- Background jobs. A running job is leased until locked_until; if its
+-- worker dies, another worker claims it once the lease expires.
+CREATE TABLE jobs (
+  id           INTEGER PRIMARY KEY AUTOINCREMENT,
+  kind         TEXT NOT NULL,
+  payload      TEXT NOT NULL,
+  priority     INTEGER NOT NULL DEFAULT 0,
+  unique_key   TEXT,
+  status       TEXT NOT NULL,
+  attempts     INTEGER NOT NULL DEFAULT 0,
+  max_attempts INTEGER NOT NULL,
+  last_error   TEXT NOT NULL DEFAULT '',
+  run_at       TEXT NOT NULL,
+  locked_until TEXT,
+  finished_at  TEXT,
+  created_at   TEXT NOT NULL,
+  updated_at   TEXT NOT NULL
+);
+
+-- Only one unfinished job may hold a unique key.
+CREATE UNIQUE INDEX jobs_unique_key_idx ON jobs (unique_key) WHERE status IN ('pending', 'running');
+CREATE INDEX jobs_ready_idx ON jobs (priority DESC, run_at) WHERE status IN ('pending', 'running');
+
+-- Recurring jobs and when they next run, so that a restart neither skips
+-- nor repeats a run.
+CREATE TABLE job_schedules (
+  name        TEXT PRIMARY KEY,
+  spec        TEXT NOT NULL,
+  next_run_at TEXT NOT NULL
+);
@@ -0,0 +1,33 @@
+-- Webhooks registered by admins. Event types are stored as a JSON array.
+CREATE TABLE webhooks (
+  id            INTEGER PRIMARY KEY AUTOINCREMENT,
+  url           TEXT NOT NULL,
+  secret        TEXT NOT NULL,
+  event_types   TEXT NOT NULL,
+  disabled      INTEGER NOT NULL DEFAULT 0,
+  failure_count INTEGER NOT NULL DEFAULT 0,
+  created_at    TEXT NOT NULL,
+  updated_at    TEXT NOT NULL
+);
+
+-- One row per event sent to a webhook, with the outcome of the latest
+-- attempt. Attempts are made by the job queue. Events about a single student
+-- carry the student in their key, so shared events are posted once.
+CREATE TABLE webhook_deliveries (
+  id              INTEGER PRIMARY KEY AUTOINCREMENT,
+  webhook_id      INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+  student_id      INTEGER NOT NULL,
+  event_type      TEXT NOT NULL,
+  event_key       TEXT NOT NULL,
+  body            TEXT NOT NULL,
+  status          TEXT NOT NULL,
+  attempts        INTEGER NOT NULL DEFAULT 0,
+  response_status INTEGER NOT NULL DEFAULT 0,
+  latency_ms      INTEGER NOT NULL DEFAULT 0,
+  last_error      TEXT NOT NULL DEFAULT '',
+  delivered_at    TEXT,
+  created_at      TEXT NOT NULL,
+  updated_at      TEXT NOT NULL,
+
+  UNIQUE (webhook_id, event_key)
+);
@@ -0,0 +1,38 @@
+-- Append-only log of changes. The actor is not a foreign key so that
+-- entries outlive the students who made them. Each hash covers the entry,
+-- except its IP, and the previous entry's hash.
+CREATE TABLE audit_entries (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT,
+  actor_id    INTEGER,
+  action      TEXT NOT NULL,
+  entity_type TEXT NOT NULL,
+  entity_id   INTEGER NOT NULL,
+  changes     TEXT NOT NULL,
+  request_id  TEXT NOT NULL DEFAULT '',
+  ip          TEXT NOT NULL DEFAULT '',
+  prev_hash   TEXT NOT NULL,
+  hash        TEXT NOT NULL,
+  created_at  TEXT NOT NULL
+);
+
+CREATE INDEX audit_entries_entity_idx ON audit_entries (entity_type, entity_id);
+CREATE INDEX audit_entries_actor_id_idx ON audit_entries (actor_id);
+
+CREATE TRIGGER audit_entries_no_update
+BEFORE UPDATE OF id, actor_id, action, entity_type, entity_id, changes, request_id, prev_hash, hash, created_at
+ON audit_entries
+BEGIN
+  SELECT RAISE(ABORT, 'audit entries are immutable');
+END;
+
+-- The only change allowed is blanking the IP of an erased student.
+CREATE TRIGGER audit_entries_no_update_ip BEFORE UPDATE OF ip ON audit_entries
+WHEN NEW.ip != ''
+BEGIN
+  SELECT RAISE(ABORT, 'audit entries are immutable');
+END;
+
+CREATE TRIGGER audit_entries_no_delete BEFORE DELETE ON audit_entries
+BEGIN
+  SELECT RAISE(ABORT, 'audit entries are immutable');
+END;
@@ -0,0 +1,7 @@
+-- Deleted students and courses are kept until they are purged, so that a
+-- deletion can be undone.
+ALTER TABLE students ADD COLUMN deleted_at TEXT;
+ALTER TABLE courses ADD COLUMN deleted_at TEXT;
+
+CREATE INDEX students_deleted_at_idx ON students (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX courses_deleted_at_idx ON courses (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,15 @@
+-- Exports of a student's personal data. The archive is kept until its
+-- download link expires. Only a hash of the link's token is stored.
+CREATE TABLE exports (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  status     TEXT NOT NULL,
+  last_error TEXT NOT NULL DEFAULT '',
+  token_hash TEXT NOT NULL DEFAULT '',
+  data       BLOB,
+  expires_at TEXT,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE INDEX exports_student_id_idx ON exports (student_id);
@@ -0,0 +1,16 @@
+ALTER TABLE students ADD COLUMN erased_at TEXT;
+
+-- Requests to erase a student's account. A request is kept as a record of
+-- the erasure, so it does not reference the student.
+CREATE TABLE erasure_requests (
+  id           INTEGER PRIMARY KEY AUTOINCREMENT,
+  student_id   INTEGER NOT NULL,
+  status       TEXT NOT NULL,
+  erase_at     TEXT NOT NULL,
+  canceled_at  TEXT,
+  completed_at TEXT,
+  created_at   TEXT NOT NULL,
+  updated_at   TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX erasure_requests_pending_idx ON erasure_requests (student_id) WHERE status = 'pending';
@@ -0,0 +1,10 @@
+ALTER TABLE students ADD COLUMN locale TEXT NOT NULL DEFAULT '';
+ALTER TABLE students ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE students ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+ALTER TABLE students ADD COLUMN pronouns TEXT NOT NULL DEFAULT '';
+
+-- JSON array of URLs.
+ALTER TABLE students ADD COLUMN links TEXT NOT NULL DEFAULT '[]';
+
+-- JSON object of profile field visibilities, keyed by field.
+ALTER TABLE students ADD COLUMN visibility TEXT NOT NULL DEFAULT '{}';
@@ -0,0 +1,17 @@
+ALTER TABLE students ADD COLUMN avatar_updated_at TEXT;
+
+-- Uploaded avatars, stored as PNG at the largest avatar size.
+CREATE TABLE avatars (
+  student_id INTEGER PRIMARY KEY REFERENCES students (id) ON DELETE CASCADE,
+  data       BLOB NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+-- Avatars resized to the other sizes, cached on first use.
+CREATE TABLE avatar_variants (
+  student_id INTEGER NOT NULL REFERENCES avatars (student_id) ON DELETE CASCADE,
+  size       INTEGER NOT NULL,
+  data       BLOB NOT NULL,
+
+  PRIMARY KEY (student_id, size)
+);
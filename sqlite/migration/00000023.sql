@@ -0,0 +1,14 @@
+ALTER TABLE students ADD COLUMN email_verified INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE students ADD COLUMN pending_email TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE email_verifications (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  student_id INTEGER NOT NULL REFERENCES students (id) ON DELETE CASCADE,
+  email      TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,
+  expires_at TEXT NOT NULL,
+  used_at    TEXT,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX email_verifications_student_id_idx ON email_verifications (student_id);
@@ -0,0 +1,14 @@
+-- Empty for students without a password.
+ALTER TABLE students ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE password_resets (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  student_id INTEGER NOT NULL REFERENCES students (id) ON DELETE CASCADE,
+  email      TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,
+  expires_at TEXT NOT NULL,
+  used_at    TEXT,
+  created_at TEXT NOT NULL
+);
+
+CREATE INDEX password_resets_student_id_idx ON password_resets (student_id);
@@ -0,0 +1,496 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.NoteService = (*NoteService)(nil)
+
+type NoteService struct {
+	db *DB
+}
+
+func NewNoteService(db *DB) *NoteService {
+	return &NoteService{db: db}
+}
+
+func (s *NoteService) FindNoteByID(ctx context.Context, id int) (*ocs.Note, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	note, err := findNoteByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachNoteAssociations(ctx, tx, note); err != nil {
+		return nil, err
+	}
+
+	return note, nil
+}
+
+func (s *NoteService) FindNotes(ctx context.Context, filter ocs.NoteFilter) ([]*ocs.Note, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	notes, n, err := findNotes(ctx, tx, filter)
+	if err != nil {
+		return notes, n, err
+	}
+
+	for _, note := range notes {
+		if err := attachNoteAssociations(ctx, tx, note); err != nil {
+			return notes, n, err
+		}
+	}
+	return notes, n, nil
+}
+
+func (s *NoteService) CreateNote(ctx context.Context, note *ocs.Note) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createNote(ctx, tx, note); err != nil {
+		return err
+	} else if err := attachNoteAssociations(ctx, tx, note); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *NoteService) UpdateNote(ctx context.Context, id int, upd ocs.NoteUpdate) (*ocs.Note, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	note, err := updateNote(ctx, tx, id, upd)
+	if err != nil {
+		return note, err
+	} else if err := attachNoteAssociations(ctx, tx, note); err != nil {
+		return note, err
+	} else if err := tx.Commit(); err != nil {
+		return note, err
+	}
+
+	return note, nil
+}
+
+func (s *NoteService) DeleteNote(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteNote(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *NoteService) FindBookmarks(ctx context.Context, filter ocs.BookmarkFilter) ([]*ocs.Bookmark, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	bookmarks, n, err := findBookmarks(ctx, tx, filter)
+	if err != nil {
+		return bookmarks, n, err
+	}
+
+	for _, bookmark := range bookmarks {
+		if bookmark.Lesson, err = findLessonByID(ctx, tx, bookmark.LessonID); err != nil {
+			return bookmarks, n, fmt.Errorf("attach bookmark lesson: %w", err)
+		}
+	}
+	return bookmarks, n, nil
+}
+
+func (s *NoteService) CreateBookmark(ctx context.Context, bookmark *ocs.Bookmark) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createBookmark(ctx, tx, bookmark); err != nil {
+		return err
+	} else if bookmark.Lesson, err = findLessonByID(ctx, tx, bookmark.LessonID); err != nil {
+		return fmt.Errorf("attach bookmark lesson: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *NoteService) DeleteBookmark(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteBookmark(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// findAccessibleLesson returns the lesson if the student may read it.
+func findAccessibleLesson(ctx context.Context, tx *Tx, lessonID, studentID int) (*ocs.Lesson, error) {
+	lesson, err := findLessonByID(ctx, tx, lessonID)
+	if err != nil {
+		return nil, err
+	} else if ok, err := isCourseMember(ctx, tx, lesson.CourseID, studentID); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You are not a member of this lesson's course.")
+	}
+	return lesson, nil
+}
+
+func createNote(ctx context.Context, tx *Tx, note *ocs.Note) error {
+	note.StudentID = ocs.StudentIDFromContext(ctx)
+	if note.StudentID == 0 {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You must be logged in to take notes.")
+	}
+
+	note.CreatedAt = tx.now
+	note.UpdatedAt = note.CreatedAt
+
+	if err := note.Validate(); err != nil {
+		return err
+	}
+
+	lesson, err := findAccessibleLesson(ctx, tx, note.LessonID, note.StudentID)
+	if err != nil {
+		return err
+	} else if note.AnchorEnd != nil && *note.AnchorEnd > utf8.RuneCountInString(lesson.Body) {
+		return ocs.Errorf(ocs.EINVALID, "Anchor is outside of the lesson body.")
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO notes (
+      student_id,
+      lesson_id,
+      body,
+      anchor_start,
+      anchor_end,
+      video_timestamp,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+  `,
+		note.StudentID,
+		note.LessonID,
+		note.Body,
+		note.AnchorStart,
+		note.AnchorEnd,
+		note.VideoTimestamp,
+		(*NullTime)(&note.CreatedAt),
+		(*NullTime)(&note.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	note.ID = int(id)
+
+	return audit(ctx, tx, ocs.AuditActionCreate, "note", note.ID, nil, note)
+}
+
+func findNoteByID(ctx context.Context, tx *Tx, id int) (*ocs.Note, error) {
+	a, _, err := findNotes(ctx, tx, ocs.NoteFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Note not found."}
+	}
+	return a[0], nil
+}
+
+// findNotes only ever returns notes owned by the student in the context.
+func findNotes(ctx context.Context, tx *Tx, filter ocs.NoteFilter) (_ []*ocs.Note, n int, err error) {
+	where, args := tx.scope(byLesson, "lesson_id")
+	where, args = append(where, "student_id = ?"), append(args, ocs.StudentIDFromContext(ctx))
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.LessonID; v != nil {
+		where, args = append(where, "lesson_id = ?"), append(args, *v)
+	}
+	if v := filter.CourseID; v != nil {
+		where, args = append(where, "lesson_id IN (SELECT id FROM lessons WHERE course_id = ?)"), append(args, *v)
+	}
+	if v := filter.Query; v != nil {
+		if q := formatMatchQuery(*v); q != "" {
+			where, args = append(where, "id IN (SELECT docid FROM notes_fts WHERE notes_fts MATCH ?)"), append(args, q)
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      student_id,
+      lesson_id,
+      body,
+      anchor_start,
+      anchor_end,
+      video_timestamp,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM notes
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	notes := make([]*ocs.Note, 0)
+	for rows.Next() {
+		var note ocs.Note
+		var anchorStart, anchorEnd, videoTimestamp sql.NullInt64
+		if err := rows.Scan(
+			&note.ID,
+			&note.StudentID,
+			&note.LessonID,
+			&note.Body,
+			&anchorStart,
+			&anchorEnd,
+			&videoTimestamp,
+			(*NullTime)(&note.CreatedAt),
+			(*NullTime)(&note.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		note.AnchorStart = nullIntPtr(anchorStart)
+		note.AnchorEnd = nullIntPtr(anchorEnd)
+		note.VideoTimestamp = nullIntPtr(videoTimestamp)
+
+		notes = append(notes, &note)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return notes, n, nil
+}
+
+func updateNote(ctx context.Context, tx *Tx, id int, upd ocs.NoteUpdate) (*ocs.Note, error) {
+	note, err := findNoteByID(ctx, tx, id)
+	if err != nil {
+		return note, err
+	}
+	prev := *note
+
+	if v := upd.Body; v != nil {
+		note.Body = *v
+	}
+
+	note.UpdatedAt = tx.now
+
+	if err := note.Validate(); err != nil {
+		return note, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE notes
+    SET body = ?,
+        updated_at = ?
+    WHERE id = ?
+    `,
+		note.Body,
+		(*NullTime)(&note.UpdatedAt),
+		id,
+	); err != nil {
+		return note, FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "note", id, &prev, note); err != nil {
+		return note, err
+	}
+
+	return note, nil
+}
+
+func deleteNote(ctx context.Context, tx *Tx, id int) error {
+	note, err := findNoteByID(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM notes WHERE id = ?`, id); err != nil {
+		return FormatError(err)
+	}
+
+	return audit(ctx, tx, ocs.AuditActionDelete, "note", id, note, nil)
+}
+
+func attachNoteAssociations(ctx context.Context, tx *Tx, note *ocs.Note) (err error) {
+	if note.Lesson, err = findLessonByID(ctx, tx, note.LessonID); err != nil {
+		return fmt.Errorf("attach note lesson: %w", err)
+	}
+	return nil
+}
+
+func createBookmark(ctx context.Context, tx *Tx, bookmark *ocs.Bookmark) error {
+	bookmark.StudentID = ocs.StudentIDFromContext(ctx)
+	if bookmark.StudentID == 0 {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You must be logged in to bookmark a lesson.")
+	} else if bookmark.LessonID == 0 {
+		return ocs.Errorf(ocs.EINVALID, "Lesson required.")
+	} else if _, err := findAccessibleLesson(ctx, tx, bookmark.LessonID, bookmark.StudentID); err != nil {
+		return err
+	}
+
+	lessonID := bookmark.LessonID
+	if _, n, err := findBookmarks(ctx, tx, ocs.BookmarkFilter{LessonID: &lessonID}); err != nil {
+		return err
+	} else if n != 0 {
+		return ocs.Errorf(ocs.ECONFLICT, "Lesson is already bookmarked.")
+	}
+
+	bookmark.CreatedAt = tx.now
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO bookmarks (
+      student_id,
+      lesson_id,
+      created_at
+    )
+    VALUES (?, ?, ?)
+  `,
+		bookmark.StudentID,
+		bookmark.LessonID,
+		(*NullTime)(&bookmark.CreatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	bookmark.ID = int(id)
+
+	return audit(ctx, tx, ocs.AuditActionCreate, "bookmark", bookmark.ID, nil, bookmark)
+}
+
+// findBookmarks only ever returns bookmarks owned by the student in the context.
+func findBookmarks(ctx context.Context, tx *Tx, filter ocs.BookmarkFilter) (_ []*ocs.Bookmark, n int, err error) {
+	where, args := tx.scope(byLesson, "lesson_id")
+	where, args = append(where, "student_id = ?"), append(args, ocs.StudentIDFromContext(ctx))
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.LessonID; v != nil {
+		where, args = append(where, "lesson_id = ?"), append(args, *v)
+	}
+	if v := filter.CourseID; v != nil {
+		where, args = append(where, "lesson_id IN (SELECT id FROM lessons WHERE course_id = ?)"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      student_id,
+      lesson_id,
+      created_at,
+      COUNT(*) OVER()
+    FROM bookmarks
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	bookmarks := make([]*ocs.Bookmark, 0)
+	for rows.Next() {
+		var bookmark ocs.Bookmark
+		if err := rows.Scan(
+			&bookmark.ID,
+			&bookmark.StudentID,
+			&bookmark.LessonID,
+			(*NullTime)(&bookmark.CreatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		bookmarks = append(bookmarks, &bookmark)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return bookmarks, n, nil
+}
+
+func deleteBookmark(ctx context.Context, tx *Tx, id int) error {
+	a, _, err := findBookmarks(ctx, tx, ocs.BookmarkFilter{ID: &id})
+	if err != nil {
+		return err
+	} else if len(a) == 0 {
+		return &ocs.Error{Code: ocs.ENOTFOUND, Message: "Bookmark not found."}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM bookmarks WHERE id = ?`, id); err != nil {
+		return FormatError(err)
+	}
+
+	return audit(ctx, tx, ocs.AuditActionDelete, "bookmark", id, a[0], nil)
+}
+
+// formatMatchQuery turns free text into an FTS query where every word must
+// match. Words are quoted so that FTS operators in user input are ignored.
+func formatMatchQuery(s string) string {
+	var terms []string
+	for _, field := range strings.Fields(strings.ReplaceAll(s, `"`, " ")) {
+		terms = append(terms, `"`+field+`"`)
+	}
+	return strings.Join(terms, " ")
+}
+
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
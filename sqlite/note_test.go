@@ -0,0 +1,179 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestNoteService_CreateNote(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewNoteService(db)
+
+		lesson, ctx := MustCreateEnrolledLesson(t, db)
+
+		start, end, ts := 0, 5, 95
+		note := &ocs.Note{LessonID: lesson.ID, Body: "remember this", AnchorStart: &start, AnchorEnd: &end, VideoTimestamp: &ts}
+		if err := s.CreateNote(ctx, note); err != nil {
+			t.Fatal(err)
+		}
+
+		if other, err := s.FindNoteByID(ctx, note.ID); err != nil {
+			t.Fatal(err)
+		} else if other.AnchorStart == nil || *other.AnchorStart != start || *other.AnchorEnd != end {
+			t.Fatalf("unexpected anchor: %v-%v", other.AnchorStart, other.AnchorEnd)
+		} else if other.VideoTimestamp == nil || *other.VideoTimestamp != ts {
+			t.Fatalf("VideoTimestamp=%v, want %v", other.VideoTimestamp, ts)
+		} else if other.Lesson == nil || other.Lesson.ID != lesson.ID {
+			t.Fatal("expected lesson")
+		}
+	})
+
+	t.Run("ErrAnchorOutOfRange", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewNoteService(db)
+
+		lesson, ctx := MustCreateEnrolledLesson(t, db)
+
+		start, end := 0, 1000
+		if err := s.CreateNote(ctx, &ocs.Note{LessonID: lesson.ID, Body: "X", AnchorStart: &start, AnchorEnd: &end}); err == nil {
+			t.Fatal("expected error")
+		} else if ocs.ErrorCode(err) != ocs.EINVALID || ocs.ErrorMessage(err) != `Anchor is outside of the lesson body.` {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrNotEnrolled", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewNoteService(db)
+
+		lesson, _ := MustCreateEnrolledLesson(t, db)
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "eve", Email: "eve@email.com"})
+
+		if err := s.CreateNote(ctx, &ocs.Note{LessonID: lesson.ID, Body: "X"}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestNoteService_FindNotes(t *testing.T) {
+	t.Run("Search", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewNoteService(db)
+
+		lesson, ctx := MustCreateEnrolledLesson(t, db)
+		MustCreateNote(t, ctx, db, &ocs.Note{LessonID: lesson.ID, Body: "goroutines are cheap"})
+		MustCreateNote(t, ctx, db, &ocs.Note{LessonID: lesson.ID, Body: "channels block"})
+		note := MustCreateNote(t, ctx, db, &ocs.Note{LessonID: lesson.ID, Body: "buffered channels"})
+
+		q := "channels"
+		if _, n, err := s.FindNotes(ctx, ocs.NoteFilter{Query: &q}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 2; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		q = `buffered "channels`
+		if a, n, err := s.FindNotes(ctx, ocs.NoteFilter{Query: &q}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := a[0].ID, note.ID; got != want {
+			t.Fatalf("ID=%v, want %v", got, want)
+		}
+
+		// Search index follows updates.
+		body := "unbuffered"
+		if _, err := s.UpdateNote(ctx, note.ID, ocs.NoteUpdate{Body: &body}); err != nil {
+			t.Fatal(err)
+		}
+		q = "buffered"
+		if _, n, err := s.FindNotes(ctx, ocs.NoteFilter{Query: &q}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("OwnerOnly", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewNoteService(db)
+
+		lesson, ctx := MustCreateEnrolledLesson(t, db)
+		note := MustCreateNote(t, ctx, db, &ocs.Note{LessonID: lesson.ID, Body: "private"})
+
+		// The instructor can read the lesson but not the student's notes.
+		instructorCtx := ocs.NewContextWithStudent(DefaultSiteContext(), &ocs.Student{ID: lesson.Course.InstructorID})
+		if _, err := s.FindNoteByID(instructorCtx, note.ID); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if _, n, err := s.FindNotes(instructorCtx, ocs.NoteFilter{LessonID: &lesson.ID}); err != nil {
+			t.Fatal(err)
+		} else if n != 0 {
+			t.Fatalf("n=%v, want 0", n)
+		} else if err := s.DeleteNote(instructorCtx, note.ID); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestNoteService_CreateBookmark(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewNoteService(db)
+
+		lesson, ctx := MustCreateEnrolledLesson(t, db)
+
+		bookmark := &ocs.Bookmark{LessonID: lesson.ID}
+		if err := s.CreateBookmark(ctx, bookmark); err != nil {
+			t.Fatal(err)
+		} else if err := s.CreateBookmark(ctx, &ocs.Bookmark{LessonID: lesson.ID}); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if a, n, err := s.FindBookmarks(ctx, ocs.BookmarkFilter{CourseID: &lesson.CourseID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if a[0].Lesson == nil {
+			t.Fatal("expected lesson")
+		}
+
+		if err := s.DeleteBookmark(ctx, bookmark.ID); err != nil {
+			t.Fatal(err)
+		} else if _, n, err := s.FindBookmarks(ctx, ocs.BookmarkFilter{}); err != nil {
+			t.Fatal(err)
+		} else if n != 0 {
+			t.Fatalf("n=%v, want 0", n)
+		}
+	})
+}
+
+// MustCreateEnrolledLesson creates a course with one lesson and returns it
+// along with the context of a student enrolled in the course.
+func MustCreateEnrolledLesson(tb testing.TB, db *sqlite.DB) (*ocs.Lesson, context.Context) {
+	tb.Helper()
+
+	_, instructorCtx := MustCreateStudent(tb, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+	student, ctx := MustCreateStudent(tb, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+	course := MustCreateCourse(tb, instructorCtx, db, &ocs.Course{Title: "Go 101"})
+	MustCreateEnrollment(tb, ctx, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student.ID})
+	lesson := MustCreateLesson(tb, instructorCtx, db, &ocs.Lesson{CourseID: course.ID, Title: "Concurrency", Body: "Goroutines and channels."})
+	return lesson, ctx
+}
+
+func MustCreateNote(tb testing.TB, ctx context.Context, db *sqlite.DB, note *ocs.Note) *ocs.Note {
+	tb.Helper()
+	if err := sqlite.NewNoteService(db).CreateNote(ctx, note); err != nil {
+		tb.Fatal(err)
+	}
+	return note
+}
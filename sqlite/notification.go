@@ -0,0 +1,374 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var (
+	_ ocs.NotificationService = (*NotificationService)(nil)
+	_ ocs.EventHandler        = (*NotificationService)(nil)
+)
+
+// NotificationService keeps events of the given Types in each student's
+// inbox. Register it as an event handler on the event service to fill the
+// inbox.
+type NotificationService struct {
+	db    *DB
+	Types []string
+}
+
+func NewNotificationService(db *DB) *NotificationService {
+	return &NotificationService{db: db, Types: ocs.DefaultNotificationTypes}
+}
+
+func (s *NotificationService) FindNotifications(ctx context.Context, filter ocs.NotificationFilter) ([]*ocs.Notification, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	return findNotifications(ctx, tx, filter)
+}
+
+func (s *NotificationService) MarkNotificationRead(ctx context.Context, id int) (*ocs.Notification, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	notification, err := markNotificationRead(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+func (s *NotificationService) MarkAllNotificationsRead(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := markAllNotificationsRead(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *NotificationService) CountUnreadNotifications(ctx context.Context) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	return countUnreadNotifications(ctx, tx, ocs.StudentIDFromContext(ctx))
+}
+
+// HandleEvent adds events of interest to the student's inbox. An event that
+// is already in the inbox is ignored, so replaying events is safe. Students
+// are not notified of their own messages. Students who want immediate emails
+// get one per notification; the rest wait for their digest.
+func (s *NotificationService) HandleEvent(ctx context.Context, studentID int, event ocs.Event) error {
+	if studentID == 0 || !s.handles(event.Type) {
+		return nil
+	} else if p, ok := event.Payload.(*ocs.MessageCreatedPayload); ok && p.Message.SenderID == studentID {
+		return nil
+	}
+
+	ctx = withAllOrganizations(ctx)
+
+	key, err := eventKey(event)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	notification := &ocs.Notification{
+		StudentID: studentID,
+		Type:      event.Type,
+		Payload:   payload,
+	}
+	if err := createNotification(ctx, tx, notification, key); err != nil {
+		return err
+	}
+
+	switch {
+	case notification.ID == 0:
+		// already in the inbox
+	case event.Type == ocs.EventTypeDeadlineReminder, event.Type == ocs.EventTypeExportReady:
+		// Reminders and export links are emailed by whoever sends them,
+		// since they cannot wait for a digest.
+		if err := markNotificationsEmailed(ctx, tx, []*ocs.Notification{notification}); err != nil {
+			return err
+		}
+	default:
+		if err := emailNotification(ctx, tx, notification); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *NotificationService) handles(typ string) bool {
+	for _, t := range s.Types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func createNotification(ctx context.Context, tx *Tx, notification *ocs.Notification, key string) error {
+	notification.CreatedAt = tx.now
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT OR IGNORE INTO notifications (
+      student_id,
+      type,
+      payload,
+      event_key,
+      created_at
+    )
+    VALUES (?, ?, ?, ?, ?)
+  `,
+		notification.StudentID,
+		notification.Type,
+		string(notification.Payload),
+		key,
+		(*NullTime)(&notification.CreatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	} else if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return nil // already in the inbox
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	notification.ID = int(id)
+
+	if err := audit(ctx, tx, ocs.AuditActionCreate, "notification", notification.ID, nil, notification); err != nil {
+		return err
+	}
+	return publishNotificationUnreadCount(ctx, tx, notification.StudentID)
+}
+
+// emailNotification queues an email for a new notification if the student
+// wants notifications emailed immediately.
+func emailNotification(ctx context.Context, tx *Tx, notification *ocs.Notification) error {
+	student, err := findStudentByID(ctx, tx, notification.StudentID)
+	if ocs.ErrorCode(err) == ocs.ENOTFOUND {
+		return nil
+	} else if err != nil {
+		return err
+	} else if student.EmailDigest != ocs.EmailDigestImmediate {
+		return nil
+	}
+
+	if err := enqueueEmail(ctx, tx, ocs.NotificationEmail, ocs.EmailData{
+		Student:       student,
+		Notifications: []*ocs.Notification{notification},
+	}); err != nil {
+		return err
+	}
+	return markNotificationsEmailed(ctx, tx, []*ocs.Notification{notification})
+}
+
+// markNotificationRead marks one of the caller's notifications as read.
+// Notifications already read keep their original read time.
+func markNotificationRead(ctx context.Context, tx *Tx, id int) (*ocs.Notification, error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+
+	result, err := tx.ExecContext(ctx, `
+    UPDATE notifications
+    SET read_at = ?
+    WHERE id = ? AND student_id = ? AND read_at IS NULL
+  `,
+		(*NullTime)(&tx.now),
+		id,
+		studentID,
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+
+	notification, err := findNotificationByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n != 0 {
+		prev := *notification
+		prev.ReadAt = nil
+		if err := audit(ctx, tx, ocs.AuditActionUpdate, "notification", id, &prev, notification); err != nil {
+			return nil, err
+		} else if err := publishNotificationUnreadCount(ctx, tx, studentID); err != nil {
+			return nil, err
+		}
+	}
+
+	return notification, nil
+}
+
+func markAllNotificationsRead(ctx context.Context, tx *Tx) error {
+	studentID := ocs.StudentIDFromContext(ctx)
+
+	notifications, _, err := queryNotifications(ctx, tx, []string{"student_id = ?", "read_at IS NULL"}, []interface{}{studentID}, 0, 0)
+	if err != nil {
+		return err
+	} else if len(notifications) == 0 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE notifications
+    SET read_at = ?
+    WHERE student_id = ? AND read_at IS NULL
+  `,
+		(*NullTime)(&tx.now),
+		studentID,
+	); err != nil {
+		return FormatError(err)
+	}
+
+	for _, notification := range notifications {
+		prev := *notification
+		notification.ReadAt = &tx.now
+		if err := audit(ctx, tx, ocs.AuditActionUpdate, "notification", notification.ID, &prev, notification); err != nil {
+			return err
+		}
+	}
+
+	return publishNotificationUnreadCount(ctx, tx, studentID)
+}
+
+// publishNotificationUnreadCount pushes the student's new unread count to
+// their event stream.
+func publishNotificationUnreadCount(ctx context.Context, tx *Tx, studentID int) error {
+	n, err := countUnreadNotifications(ctx, tx, studentID)
+	if err != nil {
+		return err
+	}
+
+	tx.publishEvent(studentID, ocs.Event{
+		Type:    ocs.EventTypeNotificationUnreadCount,
+		Payload: &ocs.NotificationUnreadCountPayload{UnreadCount: n},
+	})
+	return nil
+}
+
+func countUnreadNotifications(ctx context.Context, tx *Tx, studentID int) (int, error) {
+	var n int
+	if err := tx.QueryRowContext(ctx, `
+    SELECT COUNT(*) FROM notifications WHERE student_id = ? AND read_at IS NULL
+  `, studentID).Scan(&n); err != nil {
+		return 0, FormatError(err)
+	}
+	return n, nil
+}
+
+func findNotificationByID(ctx context.Context, tx *Tx, id int) (*ocs.Notification, error) {
+	where, args := tx.scope(byStudent, "student_id")
+	where, args = append(where, "student_id = ?", "id = ?"), append(args, ocs.StudentIDFromContext(ctx), id)
+
+	a, _, err := queryNotifications(ctx, tx, where, args, 0, 0)
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Notification not found."}
+	}
+	return a[0], nil
+}
+
+// findNotifications returns the caller's notifications, newest first.
+func findNotifications(ctx context.Context, tx *Tx, filter ocs.NotificationFilter) (_ []*ocs.Notification, n int, err error) {
+	where, args := tx.scope(byStudent, "student_id")
+	where, args = append(where, "student_id = ?"), append(args, ocs.StudentIDFromContext(ctx))
+	if filter.Unread {
+		where = append(where, "read_at IS NULL")
+	}
+	return queryNotifications(ctx, tx, where, args, filter.Limit, filter.Offset)
+}
+
+func queryNotifications(ctx context.Context, tx *Tx, where []string, args []interface{}, limit, offset int) (_ []*ocs.Notification, n int, err error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      student_id,
+      type,
+      payload,
+      read_at,
+      created_at,
+      COUNT(*) OVER()
+    FROM notifications
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id DESC
+    `+FormatLimitOffset(limit, offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	notifications := make([]*ocs.Notification, 0)
+	for rows.Next() {
+		var notification ocs.Notification
+		var payload string
+		var readAt NullTime
+		if err := rows.Scan(
+			&notification.ID,
+			&notification.StudentID,
+			&notification.Type,
+			&payload,
+			&readAt,
+			(*NullTime)(&notification.CreatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		notification.Payload = json.RawMessage(payload)
+		if v := (time.Time)(readAt); !v.IsZero() {
+			notification.ReadAt = &v
+		}
+
+		notifications = append(notifications, &notification)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return notifications, n, nil
+}
@@ -0,0 +1,139 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/mock"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestNotificationService_HandleEvent(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewNotificationService(db)
+
+		var counts []int
+		db.EventService = &mock.EventService{PublishEventFn: func(studentID int, event ocs.Event) {
+			if p, ok := event.Payload.(*ocs.NotificationUnreadCountPayload); ok {
+				counts = append(counts, p.UnreadCount)
+			}
+		}}
+
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+
+		// Replays and events that are only streamed live are ignored.
+		event := ocs.Event{Type: ocs.EventTypeSubmissionGraded, Payload: &ocs.SubmissionGradedPayload{ID: 1, Grade: 7}}
+		for _, event := range []ocs.Event{
+			event,
+			event,
+			{Type: ocs.EventTypeLeaderboardChanged, Payload: &ocs.LeaderboardChangedPayload{StudentID: student.ID}},
+		} {
+			if err := s.HandleEvent(context.Background(), student.ID, event); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if notifications, n, err := s.FindNotifications(ctx, ocs.NotificationFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := notifications[0].Type, ocs.EventTypeSubmissionGraded; got != want {
+			t.Fatalf("Type=%v, want %v", got, want)
+		} else if got, want := string(notifications[0].Payload), `{"id":1,"assignmentID":0,"courseID":0,"grade":7,"maxPoints":0}`; got != want {
+			t.Fatalf("Payload=%v, want %v", got, want)
+		} else if got, want := len(counts), 1; got != want {
+			t.Fatalf("len(counts)=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("OwnMessage", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewNotificationService(db)
+
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+
+		event := ocs.Event{Type: ocs.EventTypeMessageCreated, Payload: &ocs.MessageCreatedPayload{Message: &ocs.Message{ID: 1, SenderID: student.ID}}}
+		if err := s.HandleEvent(context.Background(), student.ID, event); err != nil {
+			t.Fatal(err)
+		} else if n, err := s.CountUnreadNotifications(ctx); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+}
+
+func TestNotificationService_MarkNotificationRead(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewNotificationService(db)
+
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		for id := 1; id <= 3; id++ {
+			MustHandleNotification(t, s, student.ID, ocs.Event{Type: ocs.EventTypeSubmissionGraded, Payload: &ocs.SubmissionGradedPayload{ID: id}})
+		}
+
+		notifications, _, err := s.FindNotifications(ctx, ocs.NotificationFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var counts []int
+		db.EventService = &mock.EventService{PublishEventFn: func(studentID int, event ocs.Event) {
+			if p, ok := event.Payload.(*ocs.NotificationUnreadCountPayload); ok {
+				counts = append(counts, p.UnreadCount)
+			}
+		}}
+
+		if notification, err := s.MarkNotificationRead(ctx, notifications[0].ID); err != nil {
+			t.Fatal(err)
+		} else if notification.ReadAt == nil {
+			t.Fatal("expected read at")
+		} else if _, n, err := s.FindNotifications(ctx, ocs.NotificationFilter{Unread: true}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 2; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		if err := s.MarkAllNotificationsRead(ctx); err != nil {
+			t.Fatal(err)
+		} else if n, err := s.CountUnreadNotifications(ctx); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		if got, want := counts, []int{2, 0}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Fatalf("counts=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewNotificationService(db)
+
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		_, otherCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		MustHandleNotification(t, s, student.ID, ocs.Event{Type: ocs.EventTypeBadgeEarned, Payload: &ocs.BadgeEarnedPayload{}})
+
+		notifications, _, err := s.FindNotifications(ctx, ocs.NotificationFilter{})
+		if err != nil {
+			t.Fatal(err)
+		} else if _, err := s.MarkNotificationRead(otherCtx, notifications[0].ID); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func MustHandleNotification(tb testing.TB, s *sqlite.NotificationService, studentID int, event ocs.Event) {
+	tb.Helper()
+	if err := s.HandleEvent(context.Background(), studentID, event); err != nil {
+		tb.Fatal(err)
+	}
+}
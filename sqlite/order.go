@@ -0,0 +1,838 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.OrderService = (*OrderService)(nil)
+
+// CouponHoldTTL is how long an unpaid order holds a use of its coupon. Once
+// it has passed, the order is failed when someone else needs the use.
+const CouponHoldTTL = time.Hour
+
+type OrderService struct {
+	db *DB
+
+	// PaymentProvider takes the payments for checkouts.
+	PaymentProvider ocs.PaymentProvider
+}
+
+func NewOrderService(db *DB) *OrderService {
+	return &OrderService{db: db}
+}
+
+func (s *OrderService) FindOrderByID(ctx context.Context, id int) (*ocs.Order, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	order, err := findOrderByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachOrderAssociations(ctx, tx, order); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func (s *OrderService) FindOrders(ctx context.Context, filter ocs.OrderFilter) ([]*ocs.Order, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	orders, n, err := findOrders(ctx, tx, filter)
+	if err != nil {
+		return orders, n, err
+	}
+
+	for _, order := range orders {
+		if err := attachOrderAssociations(ctx, tx, order); err != nil {
+			return orders, n, err
+		}
+	}
+	return orders, n, nil
+}
+
+// Checkout creates the order in one transaction and records the provider's
+// payment in another, so that the database is not locked while the provider
+// is called. If the provider fails, the order is marked as failed.
+func (s *OrderService) Checkout(ctx context.Context, checkout ocs.Checkout) (*ocs.Order, error) {
+	order, err := s.createOrder(ctx, checkout)
+	if err != nil {
+		return nil, err
+	} else if order.Status != ocs.OrderStatusPending {
+		return order, nil
+	}
+
+	payment, err := s.PaymentProvider.CreatePayment(ctx, order)
+	if err != nil {
+		if _, e := s.CompletePayment(ctx, &ocs.PaymentNotification{ProviderRef: order.ProviderRef, Status: ocs.PaymentStatusFailed}); e != nil {
+			return nil, fmt.Errorf("fail order: %w (payment: %s)", e, err)
+		}
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := setOrderPayment(ctx, tx, order, payment); err != nil {
+		return nil, err
+	} else if err := attachOrderAssociations(ctx, tx, order); err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func (s *OrderService) createOrder(ctx context.Context, checkout ocs.Checkout) (*ocs.Order, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	order, err := createOrder(ctx, tx, checkout)
+	if err != nil {
+		return nil, err
+	} else if err := attachOrderAssociations(ctx, tx, order); err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// CompletePayment applies the notification within the organization of the
+// order, since provider callbacks are not made to an organization.
+func (s *OrderService) CompletePayment(ctx context.Context, n *ocs.PaymentNotification) (*ocs.Order, error) {
+	org, err := s.findPaymentOrganization(ctx, n.ProviderRef)
+	if err != nil {
+		return nil, err
+	}
+	ctx = ocs.NewContextWithOrganization(ctx, org)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	order, err := completePayment(ctx, tx, n)
+	if err != nil {
+		return order, err
+	} else if err := attachOrderAssociations(ctx, tx, order); err != nil {
+		return order, err
+	} else if err := tx.Commit(); err != nil {
+		return order, err
+	}
+
+	return order, nil
+}
+
+// findPaymentOrganization returns the organization of the order paid by the
+// provider's payment, or nil if the order belongs to none.
+func (s *OrderService) findPaymentOrganization(ctx context.Context, ref string) (*ocs.Organization, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var orgID int
+	if err := tx.QueryRowContext(ctx, `
+    SELECT organization_id FROM orders WHERE provider_ref = ? AND provider_ref != ''
+  `,
+		ref,
+	).Scan(&orgID); err == sql.ErrNoRows {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Order not found."}
+	} else if err != nil {
+		return nil, FormatError(err)
+	} else if orgID == 0 {
+		return nil, nil
+	}
+
+	return findOrganizationByID(ctx, tx, orgID)
+}
+
+func (s *OrderService) FindCoupons(ctx context.Context, filter ocs.CouponFilter) ([]*ocs.Coupon, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	return findCoupons(ctx, tx, filter)
+}
+
+func (s *OrderService) CreateCoupon(ctx context.Context, coupon *ocs.Coupon) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createCoupon(ctx, tx, coupon); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *OrderService) DeleteCoupon(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteCoupon(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// createOrder prices the course for the current student, redeeming the
+// coupon if one is given. The order is given a local reference until the
+// provider assigns its own.
+func createOrder(ctx context.Context, tx *Tx, checkout ocs.Checkout) (*ocs.Order, error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+	if studentID == 0 {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You must be logged in to check out.")
+	}
+
+	// Receipts and refunds go to the student's email.
+	if err := requireVerifiedEmail(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	course, err := findCourseByID(ctx, tx, checkout.CourseID)
+	if err != nil {
+		return nil, err
+	} else if course.Price == 0 {
+		return nil, ocs.Errorf(ocs.EINVALID, "This course is free.")
+	}
+
+	if _, n, err := findEnrollments(ctx, tx, ocs.EnrollmentFilter{CourseID: &course.ID, StudentID: &studentID}); err != nil {
+		return nil, err
+	} else if n != 0 {
+		return nil, ocs.Errorf(ocs.ECONFLICT, "You are already enrolled in this course.")
+	}
+
+	// Checking out again abandons the earlier checkout, so that repeated
+	// attempts do not each hold a coupon use.
+	if err := failPendingOrders(ctx, tx, []string{"student_id = ?", "course_id = ?"}, []interface{}{studentID, course.ID}); err != nil {
+		return nil, err
+	}
+
+	order := &ocs.Order{
+		StudentID: studentID,
+		CourseID:  course.ID,
+		Subtotal:  course.Price,
+		Currency:  course.Currency,
+		Status:    ocs.OrderStatusPending,
+		CreatedAt: tx.now,
+		UpdatedAt: tx.now,
+	}
+
+	if checkout.CouponCode != "" {
+		coupon, err := redeemCoupon(ctx, tx, course.ID, checkout.CouponCode)
+		if err != nil {
+			return nil, err
+		}
+		order.CouponID = &coupon.ID
+		order.Discount = coupon.Discount(order.Subtotal)
+	}
+	order.Total = order.Subtotal - order.Discount
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO orders (
+      student_id,
+      course_id,
+      coupon_id,
+      subtotal,
+      discount,
+      total,
+      currency,
+      status,
+      provider_ref,
+      payment_url,
+      organization_id,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+  `,
+		order.StudentID,
+		order.CourseID,
+		order.CouponID,
+		order.Subtotal,
+		order.Discount,
+		order.Total,
+		order.Currency,
+		order.Status,
+		order.ProviderRef,
+		order.PaymentURL,
+		tx.orgID,
+		(*NullTime)(&order.CreatedAt),
+		(*NullTime)(&order.UpdatedAt),
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	order.ID = int(id)
+	order.ProviderRef = fmt.Sprintf("order-%d", order.ID)
+
+	if _, err := tx.ExecContext(ctx, `UPDATE orders SET provider_ref = ? WHERE id = ?`, order.ProviderRef, order.ID); err != nil {
+		return nil, FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionCreate, "order", order.ID, nil, order); err != nil {
+		return nil, err
+	}
+
+	// Nothing left to pay, so no payment is taken.
+	if order.Total == 0 {
+		if err := markOrderPaid(ctx, tx, order); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// redeemCoupon checks that the coupon applies to the course and holds one
+// use of it for the order. The use is given back if the order fails.
+func redeemCoupon(ctx context.Context, tx *Tx, courseID int, code string) (*ocs.Coupon, error) {
+	code = strings.ToUpper(code)
+	coupons, _, err := queryCoupons(ctx, tx, []string{"course_id = ?", "code = ?"}, []interface{}{courseID, code}, 0, 0)
+	if err != nil {
+		return nil, err
+	} else if len(coupons) == 0 {
+		return nil, ocs.Errorf(ocs.EINVALID, "Invalid coupon code.")
+	}
+
+	coupon := coupons[0]
+	if coupon.ExpiresAt != nil && !tx.now.Before(*coupon.ExpiresAt) {
+		return nil, ocs.Errorf(ocs.EINVALID, "Coupon has expired.")
+	}
+
+	// Abandoned checkouts give their uses back before the cap is checked.
+	cutoff := tx.now.Add(-CouponHoldTTL)
+	if err := failPendingOrders(ctx, tx, []string{"coupon_id = ?", "created_at <= ?"}, []interface{}{coupon.ID, (*NullTime)(&cutoff)}); err != nil {
+		return nil, err
+	}
+	return updateCouponUses(ctx, tx, coupon.ID, 1)
+}
+
+// updateCouponUses adds delta to the coupon's count of uses. Taking a use
+// fails if the coupon is used up; the cap is checked by the update itself, so
+// concurrent checkouts cannot exceed it. Giving a use back never takes the
+// count below zero.
+func updateCouponUses(ctx context.Context, tx *Tx, couponID, delta int) (*ocs.Coupon, error) {
+	coupons, _, err := queryCoupons(ctx, tx, []string{"id = ?"}, []interface{}{couponID}, 0, 0)
+	if err != nil {
+		return nil, err
+	} else if len(coupons) == 0 {
+		return nil, ocs.Errorf(ocs.ENOTFOUND, "Coupon not found.")
+	}
+	coupon := coupons[0]
+	prev := *coupon
+
+	result, err := tx.ExecContext(ctx, `
+    UPDATE coupons
+    SET uses = uses + ?,
+        updated_at = ?
+    WHERE id = ? AND uses + ? >= 0 AND (? < 0 OR max_uses = 0 OR uses + ? <= max_uses)
+  `,
+		delta,
+		(*NullTime)(&tx.now),
+		couponID,
+		delta,
+		delta,
+		delta,
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	} else if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 && delta > 0 {
+		return nil, ocs.Errorf(ocs.EINVALID, "Coupon has been used up.")
+	} else if n == 0 {
+		return coupon, nil
+	}
+
+	coupon.Uses += delta
+	coupon.UpdatedAt = tx.now
+	if err := audit(ctx, tx, ocs.AuditActionUpdate, "coupon", coupon.ID, &prev, coupon); err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// failPendingOrders fails the pending orders matching where, giving back
+// their coupon uses.
+func failPendingOrders(ctx context.Context, tx *Tx, where []string, args []interface{}) error {
+	where, args = append(where, "status = ?"), append(args, ocs.OrderStatusPending)
+
+	orders, _, err := queryOrders(ctx, tx, where, args, 0, 0)
+	if err != nil {
+		return err
+	}
+	for _, order := range orders {
+		if err := markOrderFailed(ctx, tx, order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setOrderPayment(ctx context.Context, tx *Tx, order *ocs.Order, payment *ocs.Payment) error {
+	prev := *order
+	order.ProviderRef = payment.ProviderRef
+	order.PaymentURL = payment.URL
+	order.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE orders
+    SET provider_ref = ?,
+        payment_url = ?,
+        updated_at = ?
+    WHERE id = ?
+    `,
+		order.ProviderRef,
+		order.PaymentURL,
+		(*NullTime)(&order.UpdatedAt),
+		order.ID,
+	); err != nil {
+		return FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "order", order.ID, &prev, order); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// completePayment applies the payment's outcome to its order. Notifications
+// come from the provider rather than a student, so the order is looked up
+// by its provider reference alone.
+func completePayment(ctx context.Context, tx *Tx, n *ocs.PaymentNotification) (*ocs.Order, error) {
+	where, args := tx.scope(byCourse, "course_id")
+	where, args = append(where, "provider_ref = ?"), append(args, n.ProviderRef)
+
+	orders, _, err := queryOrders(ctx, tx, where, args, 0, 0)
+	if err != nil {
+		return nil, err
+	} else if len(orders) == 0 || n.ProviderRef == "" {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Order not found."}
+	}
+	order := orders[0]
+
+	switch n.Status {
+	case ocs.PaymentStatusSucceeded:
+		if order.Status != ocs.OrderStatusPending && order.Status != ocs.OrderStatusFailed {
+			return order, nil
+		}
+		return order, markOrderPaid(ctx, tx, order)
+
+	case ocs.PaymentStatusFailed:
+		if order.Status != ocs.OrderStatusPending {
+			return order, nil
+		}
+		return order, markOrderFailed(ctx, tx, order)
+
+	default:
+		return order, ocs.Errorf(ocs.EINVALID, "Invalid payment status.")
+	}
+}
+
+// markOrderPaid marks the order as paid and enrolls the student in the
+// same transaction, so a paid order always comes with its enrollment.
+//
+// A failed order gave its coupon use back, so it has to take one again. If
+// the coupon has been used up since, the order stays failed and the payment
+// must be refunded by hand.
+func markOrderPaid(ctx context.Context, tx *Tx, order *ocs.Order) error {
+	if order.Status == ocs.OrderStatusFailed && order.CouponID != nil {
+		if _, err := updateCouponUses(ctx, tx, *order.CouponID, 1); err != nil {
+			return err
+		}
+	}
+
+	enrollments, _, err := findEnrollments(ctx, tx, ocs.EnrollmentFilter{CourseID: &order.CourseID, StudentID: &order.StudentID})
+	if err != nil {
+		return err
+	}
+
+	var enrollment *ocs.Enrollment
+	if len(enrollments) != 0 {
+		enrollment = enrollments[0]
+	} else {
+		enrollment = &ocs.Enrollment{
+			CourseID:  order.CourseID,
+			StudentID: order.StudentID,
+			Role:      ocs.EnrollmentRoleStudent,
+			CreatedAt: tx.now,
+			UpdatedAt: tx.now,
+		}
+		if err := insertEnrollment(ctx, tx, enrollment); err != nil {
+			return err
+		}
+	}
+
+	prev := *order
+	paidAt := tx.now
+	order.Status = ocs.OrderStatusPaid
+	order.EnrollmentID = &enrollment.ID
+	order.PaidAt = &paidAt
+	order.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE orders
+    SET status = ?,
+        enrollment_id = ?,
+        paid_at = ?,
+        updated_at = ?
+    WHERE id = ?
+    `,
+		order.Status,
+		order.EnrollmentID,
+		(*NullTime)(order.PaidAt),
+		(*NullTime)(&order.UpdatedAt),
+		order.ID,
+	); err != nil {
+		return FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "order", order.ID, &prev, order); err != nil {
+		return err
+	}
+
+	tx.publishEvent(order.StudentID, ocs.Event{
+		Type: ocs.EventTypeOrderPaid,
+		Payload: &ocs.OrderPaidPayload{
+			ID:       order.ID,
+			CourseID: order.CourseID,
+			Total:    order.Total,
+			Currency: order.Currency,
+		},
+	})
+
+	return nil
+}
+
+// markOrderFailed marks the order as failed and gives back its coupon use.
+func markOrderFailed(ctx context.Context, tx *Tx, order *ocs.Order) error {
+	prev := *order
+	order.Status = ocs.OrderStatusFailed
+	order.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE orders
+    SET status = ?,
+        updated_at = ?
+    WHERE id = ?
+    `,
+		order.Status,
+		(*NullTime)(&order.UpdatedAt),
+		order.ID,
+	); err != nil {
+		return FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "order", order.ID, &prev, order); err != nil {
+		return err
+	}
+
+	if order.CouponID != nil {
+		if _, err := updateCouponUses(ctx, tx, *order.CouponID, -1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func findOrderByID(ctx context.Context, tx *Tx, id int) (*ocs.Order, error) {
+	a, _, err := findOrders(ctx, tx, ocs.OrderFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Order not found."}
+	}
+	return a[0], nil
+}
+
+// findOrders only returns the caller's own orders and orders for courses
+// they teach.
+func findOrders(ctx context.Context, tx *Tx, filter ocs.OrderFilter) (_ []*ocs.Order, n int, err error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+
+	where, args := tx.scope(byCourse, "course_id")
+	where, args = append(where, `(
+    student_id = ? OR
+    course_id IN (SELECT id FROM courses WHERE instructor_id = ?)
+  )`), append(args, studentID, studentID)
+
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.CourseID; v != nil {
+		where, args = append(where, "course_id = ?"), append(args, *v)
+	}
+	if v := filter.Status; v != nil {
+		where, args = append(where, "status = ?"), append(args, *v)
+	}
+
+	return queryOrders(ctx, tx, where, args, filter.Limit, filter.Offset)
+}
+
+func queryOrders(ctx context.Context, tx *Tx, where []string, args []interface{}, limit, offset int) (_ []*ocs.Order, n int, err error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      student_id,
+      course_id,
+      coupon_id,
+      subtotal,
+      discount,
+      total,
+      currency,
+      status,
+      refunded,
+      provider_ref,
+      payment_url,
+      enrollment_id,
+      paid_at,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM orders
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(limit, offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	orders := make([]*ocs.Order, 0)
+	for rows.Next() {
+		var order ocs.Order
+		var couponID, enrollmentID sql.NullInt64
+		var paidAt NullTime
+		if err := rows.Scan(
+			&order.ID,
+			&order.StudentID,
+			&order.CourseID,
+			&couponID,
+			&order.Subtotal,
+			&order.Discount,
+			&order.Total,
+			&order.Currency,
+			&order.Status,
+			&order.Refunded,
+			&order.ProviderRef,
+			&order.PaymentURL,
+			&enrollmentID,
+			&paidAt,
+			(*NullTime)(&order.CreatedAt),
+			(*NullTime)(&order.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		order.CouponID = nullIntPtr(couponID)
+		order.EnrollmentID = nullIntPtr(enrollmentID)
+		if v := (time.Time)(paidAt); !v.IsZero() {
+			order.PaidAt = &v
+		}
+
+		orders = append(orders, &order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return orders, n, nil
+}
+
+func attachOrderAssociations(ctx context.Context, tx *Tx, order *ocs.Order) (err error) {
+	if order.Course, err = findAnyCourseByID(ctx, tx, order.CourseID); err != nil {
+		return fmt.Errorf("attach order course: %w", err)
+	}
+	return nil
+}
+
+// createCoupon creates a coupon for a course the caller teaches. Codes are
+// case-insensitive and stored in upper case.
+func createCoupon(ctx context.Context, tx *Tx, coupon *ocs.Coupon) error {
+	coupon.Code = strings.ToUpper(strings.TrimSpace(coupon.Code))
+	coupon.Uses = 0
+	coupon.CreatedAt = tx.now
+	coupon.UpdatedAt = coupon.CreatedAt
+
+	if err := coupon.Validate(); err != nil {
+		return err
+	}
+
+	if course, err := findCourseByID(ctx, tx, coupon.CourseID); err != nil {
+		return err
+	} else if course.InstructorID != ocs.StudentIDFromContext(ctx) {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to create coupons for this course.")
+	}
+
+	if coupons, _, err := queryCoupons(ctx, tx, []string{"course_id = ?", "code = ?"}, []interface{}{coupon.CourseID, coupon.Code}, 0, 0); err != nil {
+		return err
+	} else if len(coupons) != 0 {
+		return ocs.Errorf(ocs.ECONFLICT, "Coupon code is already taken.")
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO coupons (
+      course_id,
+      code,
+      kind,
+      amount,
+      expires_at,
+      max_uses,
+      uses,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+  `,
+		coupon.CourseID,
+		coupon.Code,
+		coupon.Kind,
+		coupon.Amount,
+		(*NullTime)(coupon.ExpiresAt),
+		coupon.MaxUses,
+		coupon.Uses,
+		(*NullTime)(&coupon.CreatedAt),
+		(*NullTime)(&coupon.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	coupon.ID = int(id)
+
+	return audit(ctx, tx, ocs.AuditActionCreate, "coupon", coupon.ID, nil, coupon)
+}
+
+// findCoupons only returns coupons for courses the caller teaches.
+func findCoupons(ctx context.Context, tx *Tx, filter ocs.CouponFilter) (_ []*ocs.Coupon, n int, err error) {
+	where, args := tx.scope(byCourse, "course_id")
+	where, args = append(where, "course_id IN (SELECT id FROM courses WHERE instructor_id = ?)"), append(args, ocs.StudentIDFromContext(ctx))
+
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.CourseID; v != nil {
+		where, args = append(where, "course_id = ?"), append(args, *v)
+	}
+	if v := filter.Code; v != nil {
+		where, args = append(where, "code = ?"), append(args, strings.ToUpper(*v))
+	}
+
+	return queryCoupons(ctx, tx, where, args, filter.Limit, filter.Offset)
+}
+
+func queryCoupons(ctx context.Context, tx *Tx, where []string, args []interface{}, limit, offset int) (_ []*ocs.Coupon, n int, err error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      course_id,
+      code,
+      kind,
+      amount,
+      expires_at,
+      max_uses,
+      uses,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM coupons
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(limit, offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	coupons := make([]*ocs.Coupon, 0)
+	for rows.Next() {
+		var coupon ocs.Coupon
+		var expiresAt NullTime
+		if err := rows.Scan(
+			&coupon.ID,
+			&coupon.CourseID,
+			&coupon.Code,
+			&coupon.Kind,
+			&coupon.Amount,
+			&expiresAt,
+			&coupon.MaxUses,
+			&coupon.Uses,
+			(*NullTime)(&coupon.CreatedAt),
+			(*NullTime)(&coupon.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		if v := (time.Time)(expiresAt); !v.IsZero() {
+			coupon.ExpiresAt = &v
+		}
+
+		coupons = append(coupons, &coupon)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return coupons, n, nil
+}
+
+func deleteCoupon(ctx context.Context, tx *Tx, id int) error {
+	coupons, _, err := findCoupons(ctx, tx, ocs.CouponFilter{ID: &id})
+	if err != nil {
+		return err
+	} else if len(coupons) == 0 {
+		return &ocs.Error{Code: ocs.ENOTFOUND, Message: "Coupon not found."}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM coupons WHERE id = ?`, id); err != nil {
+		return FormatError(err)
+	}
+
+	return audit(ctx, tx, ocs.AuditActionDelete, "coupon", id, coupons[0], nil)
+}
@@ -0,0 +1,354 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/inmem"
+	"github.com/maliByatzes/ocs/mock"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestOrderService_Checkout(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s, provider := NewOrderService(db)
+
+		course, ownerCtx, ctx := MustCreatePaidCourse(t, db, 5000)
+		MustCreateCoupon(t, ownerCtx, db, &ocs.Coupon{CourseID: course.ID, Code: "half", Kind: ocs.CouponKindPercent, Amount: 50})
+
+		var paid int
+		db.EventService = &mock.EventService{PublishEventFn: func(studentID int, event ocs.Event) {
+			if event.Type == ocs.EventTypeOrderPaid {
+				paid++
+			}
+		}}
+
+		order, err := s.Checkout(ctx, ocs.Checkout{CourseID: course.ID, CouponCode: "HALF"})
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := order.Status, ocs.OrderStatusPending; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if order.Subtotal != 5000 || order.Discount != 2500 || order.Total != 2500 || order.Currency != "USD" {
+			t.Fatalf("unexpected amounts: %#v", order)
+		} else if order.PaymentURL == "" {
+			t.Fatal("expected payment url")
+		}
+
+		// The provider's callback is repeated; only the first one counts.
+		payload, signature, err := provider.Webhook(ocs.PaymentNotification{ProviderRef: order.ProviderRef, Status: ocs.PaymentStatusSucceeded})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			n, err := provider.VerifyWebhook(payload, signature)
+			if err != nil {
+				t.Fatal(err)
+			} else if _, err := s.CompletePayment(context.Background(), n); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got, want := paid, 1; got != want {
+			t.Fatalf("paid=%v, want %v", got, want)
+		}
+
+		if other, err := s.FindOrderByID(ctx, order.ID); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.Status, ocs.OrderStatusPaid; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if other.PaidAt == nil || other.EnrollmentID == nil {
+			t.Fatalf("expected paid order with enrollment: %#v", other)
+		} else if other.Course == nil || other.Course.ID != course.ID {
+			t.Fatalf("unexpected course: %#v", other.Course)
+		}
+
+		if _, n, err := sqlite.NewEnrollmentService(db).FindEnrollments(ctx, ocs.EnrollmentFilter{CourseID: &course.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		// The instructor sees the order too.
+		if _, n, err := s.FindOrders(ownerCtx, ocs.OrderFilter{CourseID: &course.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("FullDiscount", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s, _ := NewOrderService(db)
+
+		course, ownerCtx, ctx := MustCreatePaidCourse(t, db, 5000)
+		MustCreateCoupon(t, ownerCtx, db, &ocs.Coupon{CourseID: course.ID, Code: "FREE", Kind: ocs.CouponKindFixed, Amount: 9000})
+
+		if order, err := s.Checkout(ctx, ocs.Checkout{CourseID: course.ID, CouponCode: "free"}); err != nil {
+			t.Fatal(err)
+		} else if order.Total != 0 || order.Status != ocs.OrderStatusPaid || order.EnrollmentID == nil {
+			t.Fatalf("unexpected order: %#v", order)
+		}
+	})
+
+	t.Run("ErrCouponExpired", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s, _ := NewOrderService(db)
+
+		course, ownerCtx, ctx := MustCreatePaidCourse(t, db, 5000)
+		expiresAt := db.Now().Add(-time.Hour)
+		MustCreateCoupon(t, ownerCtx, db, &ocs.Coupon{CourseID: course.ID, Code: "OLD", Kind: ocs.CouponKindFixed, Amount: 100, ExpiresAt: &expiresAt})
+
+		if _, err := s.Checkout(ctx, ocs.Checkout{CourseID: course.ID, CouponCode: "OLD"}); ocs.ErrorCode(err) != ocs.EINVALID || ocs.ErrorMessage(err) != "Coupon has expired." {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrCouponUsedUp", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s, _ := NewOrderService(db)
+
+		course, ownerCtx, ctx0 := MustCreatePaidCourse(t, db, 5000)
+		_, ctx1 := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "cat", Email: "cat@email.com"})
+		MustCreateCoupon(t, ownerCtx, db, &ocs.Coupon{CourseID: course.ID, Code: "ONCE", Kind: ocs.CouponKindFixed, Amount: 100, MaxUses: 1})
+
+		if _, err := s.Checkout(ctx0, ocs.Checkout{CourseID: course.ID, CouponCode: "ONCE"}); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.Checkout(ctx1, ocs.Checkout{CourseID: course.ID, CouponCode: "ONCE"}); ocs.ErrorCode(err) != ocs.EINVALID || ocs.ErrorMessage(err) != "Coupon has been used up." {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Abandoned checkouts do not use the coupon up.
+	t.Run("CouponHold", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		s, provider := NewOrderService(db)
+		course, ownerCtx, ctx0 := MustCreatePaidCourse(t, db, 5000)
+		_, ctx1 := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "cat", Email: "cat@email.com"})
+		coupon := MustCreateCoupon(t, ownerCtx, db, &ocs.Coupon{CourseID: course.ID, Code: "ONCE", Kind: ocs.CouponKindFixed, Amount: 100, MaxUses: 1})
+
+		// Checking out again replaces the earlier checkout.
+		first, err := s.Checkout(ctx0, ocs.Checkout{CourseID: course.ID, CouponCode: "ONCE"})
+		if err != nil {
+			t.Fatal(err)
+		} else if _, err := s.Checkout(ctx0, ocs.Checkout{CourseID: course.ID, CouponCode: "ONCE"}); err != nil {
+			t.Fatal(err)
+		} else if other, err := s.FindOrderByID(ctx0, first.ID); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.Status, ocs.OrderStatusFailed; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		}
+
+		// The hold lapses once it is old enough.
+		now = now.Add(sqlite.CouponHoldTTL)
+		order, err := s.Checkout(ctx1, ocs.Checkout{CourseID: course.ID, CouponCode: "ONCE"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		MustCompletePayment(t, s, provider, order.ProviderRef)
+
+		// A lapsed order paid late cannot exceed the cap.
+		payload, signature, err := provider.Webhook(ocs.PaymentNotification{ProviderRef: first.ProviderRef, Status: ocs.PaymentStatusSucceeded})
+		if err != nil {
+			t.Fatal(err)
+		} else if n, err := provider.VerifyWebhook(payload, signature); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.CompletePayment(context.Background(), n); ocs.ErrorMessage(err) != "Coupon has been used up." {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if coupons, _, err := s.FindCoupons(ownerCtx, ocs.CouponFilter{ID: &coupon.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := coupons[0].Uses, 1; got != want {
+			t.Fatalf("Uses=%v, want %v", got, want)
+		}
+	})
+
+	// Codes belong to their course.
+	t.Run("ErrCouponOtherCourse", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s, _ := NewOrderService(db)
+
+		course, ownerCtx, ctx := MustCreatePaidCourse(t, db, 5000)
+		other := MustCreateCourse(t, ownerCtx, db, &ocs.Course{Title: "Go 201", Price: 5000, Currency: "USD"})
+		MustCreateCoupon(t, ownerCtx, db, &ocs.Coupon{CourseID: course.ID, Code: "HALF", Kind: ocs.CouponKindPercent, Amount: 50})
+		MustCreateCoupon(t, ownerCtx, db, &ocs.Coupon{CourseID: other.ID, Code: "HALF", Kind: ocs.CouponKindPercent, Amount: 10})
+
+		if order, err := s.Checkout(ctx, ocs.Checkout{CourseID: other.ID, CouponCode: "HALF"}); err != nil {
+			t.Fatal(err)
+		} else if got, want := order.Discount, 500; got != want {
+			t.Fatalf("Discount=%v, want %v", got, want)
+		}
+
+		third := MustCreateCourse(t, ownerCtx, db, &ocs.Course{Title: "Go 301", Price: 5000, Currency: "USD"})
+		if _, err := s.Checkout(ctx, ocs.Checkout{CourseID: third.ID, CouponCode: "HALF"}); ocs.ErrorMessage(err) != "Invalid coupon code." {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrPaymentFailed", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s, _ := NewOrderService(db)
+		s.PaymentProvider = &mock.PaymentProvider{CreatePaymentFn: func(ctx context.Context, order *ocs.Order) (*ocs.Payment, error) {
+			return nil, errors.New("provider unavailable")
+		}}
+
+		course, ownerCtx, ctx := MustCreatePaidCourse(t, db, 5000)
+		coupon := MustCreateCoupon(t, ownerCtx, db, &ocs.Coupon{CourseID: course.ID, Code: "ONCE", Kind: ocs.CouponKindFixed, Amount: 100, MaxUses: 1})
+
+		if _, err := s.Checkout(ctx, ocs.Checkout{CourseID: course.ID, CouponCode: "ONCE"}); err == nil {
+			t.Fatal("expected error")
+		}
+
+		// The failed order gives its coupon use back.
+		if orders, _, err := s.FindOrders(ctx, ocs.OrderFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := orders[0].Status, ocs.OrderStatusFailed; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if coupons, _, err := s.FindCoupons(ownerCtx, ocs.CouponFilter{ID: &coupon.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := coupons[0].Uses, 0; got != want {
+			t.Fatalf("Uses=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrAlreadyEnrolled", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s, provider := NewOrderService(db)
+
+		course, _, ctx := MustCreatePaidCourse(t, db, 5000)
+		order, err := s.Checkout(ctx, ocs.Checkout{CourseID: course.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		MustCompletePayment(t, s, provider, order.ProviderRef)
+
+		if _, err := s.Checkout(ctx, ocs.Checkout{CourseID: course.ID}); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestOrderService_CompletePayment(t *testing.T) {
+	// Callbacks are not made to an organization but apply to its orders.
+	t.Run("Organization", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s, provider := NewOrderService(db)
+
+		org, ownerCtx := MustCreateOrganization(t, db, "acme")
+		course := MustCreateCourse(t, ownerCtx, db, &ocs.Course{Title: "Acme 101", Price: 5000, Currency: "USD"})
+		student, _ := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		MustCreateOrganizationMember(t, ownerCtx, db, &ocs.OrganizationMember{OrganizationID: org.ID, StudentID: student.ID})
+		ctx := ocs.NewContextWithStudent(ocs.NewContextWithOrganization(context.Background(), org), student)
+
+		order, err := s.Checkout(ctx, ocs.Checkout{CourseID: course.ID})
+		if err != nil {
+			t.Fatal(err)
+		} else if order = MustCompletePayment(t, s, provider, order.ProviderRef); order.Status != ocs.OrderStatusPaid {
+			t.Fatalf("Status=%v, want %v", order.Status, ocs.OrderStatusPaid)
+		}
+	})
+
+	t.Run("ErrInvalidSignature", func(t *testing.T) {
+		provider := inmem.NewPaymentProvider("secret")
+		payload, _, err := provider.Webhook(ocs.PaymentNotification{ProviderRef: "fake_1", Status: ocs.PaymentStatusSucceeded})
+		if err != nil {
+			t.Fatal(err)
+		} else if _, err := provider.VerifyWebhook(payload, "bad"); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrNotFound", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s, _ := NewOrderService(db)
+
+		if _, err := s.CompletePayment(context.Background(), &ocs.PaymentNotification{ProviderRef: "fake_1", Status: ocs.PaymentStatusSucceeded}); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestOrderService_CreateCoupon(t *testing.T) {
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s, _ := NewOrderService(db)
+
+		course, _, ctx := MustCreatePaidCourse(t, db, 5000)
+		if err := s.CreateCoupon(ctx, &ocs.Coupon{CourseID: course.ID, Code: "MINE", Kind: ocs.CouponKindPercent, Amount: 100}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrInvalidPercent", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s, _ := NewOrderService(db)
+
+		course, ownerCtx, _ := MustCreatePaidCourse(t, db, 5000)
+		if err := s.CreateCoupon(ownerCtx, &ocs.Coupon{CourseID: course.ID, Code: "MANY", Kind: ocs.CouponKindPercent, Amount: 150}); ocs.ErrorCode(err) != ocs.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+// NewOrderService returns an order service that takes payments with the
+// in-memory provider.
+func NewOrderService(db *sqlite.DB) (*sqlite.OrderService, *inmem.PaymentProvider) {
+	provider := inmem.NewPaymentProvider("secret")
+	s := sqlite.NewOrderService(db)
+	s.PaymentProvider = provider
+	return s, provider
+}
+
+// MustCreatePaidCourse creates a course sold for price and returns it with
+// the instructor's context and a prospective student's context.
+func MustCreatePaidCourse(tb testing.TB, db *sqlite.DB, price int) (*ocs.Course, context.Context, context.Context) {
+	tb.Helper()
+	_, ownerCtx := MustCreateVerifiedStudent(tb, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+	_, ctx := MustCreateVerifiedStudent(tb, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+	course := MustCreateCourse(tb, ownerCtx, db, &ocs.Course{Title: "Go 101", Price: price, Currency: "USD"})
+	return course, ownerCtx, ctx
+}
+
+func MustCreateCoupon(tb testing.TB, ctx context.Context, db *sqlite.DB, coupon *ocs.Coupon) *ocs.Coupon {
+	tb.Helper()
+	if err := sqlite.NewOrderService(db).CreateCoupon(ctx, coupon); err != nil {
+		tb.Fatal(err)
+	}
+	return coupon
+}
+
+func MustCompletePayment(tb testing.TB, s *sqlite.OrderService, provider *inmem.PaymentProvider, ref string) *ocs.Order {
+	tb.Helper()
+	payload, signature, err := provider.Webhook(ocs.PaymentNotification{ProviderRef: ref, Status: ocs.PaymentStatusSucceeded})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	n, err := provider.VerifyWebhook(payload, signature)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	order, err := s.CompletePayment(context.Background(), n)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return order
+}
@@ -0,0 +1,480 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.OrganizationService = (*OrganizationService)(nil)
+
+type OrganizationService struct {
+	db *DB
+}
+
+func NewOrganizationService(db *DB) *OrganizationService {
+	return &OrganizationService{db: db}
+}
+
+func (s *OrganizationService) FindOrganizationByID(ctx context.Context, id int) (*ocs.Organization, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findOrganizationByID(ctx, tx, id)
+}
+
+func (s *OrganizationService) FindOrganizations(ctx context.Context, filter ocs.OrganizationFilter) ([]*ocs.Organization, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	return findOrganizations(ctx, tx, filter)
+}
+
+func (s *OrganizationService) CreateOrganization(ctx context.Context, org *ocs.Organization) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createOrganization(ctx, tx, org); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *OrganizationService) FindOrganizationMembers(ctx context.Context, filter ocs.OrganizationMemberFilter) ([]*ocs.OrganizationMember, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	return findOrganizationMembers(ctx, tx, filter)
+}
+
+func (s *OrganizationService) CreateOrganizationMember(ctx context.Context, member *ocs.OrganizationMember) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createOrganizationMember(ctx, tx, member); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *OrganizationService) DeleteOrganizationMember(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteOrganizationMember(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// scopeKind is how the rows of a table belong to an organization: through
+// the column holding their organization, course, learning path, lesson or
+// student.
+type scopeKind int
+
+const (
+	byOrganization scopeKind = iota
+	byCourse
+	byLearningPath
+	byLesson
+	byStudent
+)
+
+var scopeConditions = map[scopeKind]string{
+	byOrganization: "%s = ?",
+	byCourse:       "%s IN (SELECT id FROM courses WHERE organization_id = ?)",
+	byLearningPath: "%s IN (SELECT id FROM learning_paths WHERE organization_id = ?)",
+	byLesson:       "%s IN (SELECT id FROM lessons WHERE course_id IN (SELECT id FROM courses WHERE organization_id = ?))",
+	byStudent:      "%s IN (SELECT student_id FROM organization_members WHERE organization_id = ?)",
+}
+
+// scope returns the initial conditions of every query over tenant rows,
+// restricting column to what the transaction may read. It is the only place
+// that knows how tenants are separated:
+//
+//   - Requests read their organization, or the default site's catalog. An
+//     account may sign in to the default site whatever organizations it
+//     belongs to, so the default site sees every student.
+//   - Background work, scoped with withAllOrganizations, reads every
+//     organization.
+//   - A transaction whose context was never scoped reads nothing, so a
+//     caller that forgets to scope fails closed instead of seeing every
+//     tenant.
+//
+// Rows that belong to an account rather than a course, such as exports and
+// notifications, are scoped by student. TestOrganization_IsolationEveryEntity
+// reads every kind of tenant row across two organizations.
+func (tx *Tx) scope(kind scopeKind, column string) ([]string, []interface{}) {
+	switch {
+	case !tx.scoped:
+		return []string{"0 = 1"}, []interface{}{}
+	case tx.allOrgs, kind == byStudent && tx.orgID == 0:
+		return []string{"1 = 1"}, []interface{}{}
+	}
+	return []string{fmt.Sprintf(scopeConditions[kind], column)}, []interface{}{tx.orgID}
+}
+
+type allOrganizationsContextKey struct{}
+
+// withAllOrganizations returns a context for background work, such as jobs
+// and event handlers, whose transactions read every organization. Requests
+// are never scoped this way.
+func withAllOrganizations(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allOrganizationsContextKey{}, true)
+}
+
+func isAllOrganizations(ctx context.Context) bool {
+	v, _ := ctx.Value(allOrganizationsContextKey{}).(bool)
+	return v
+}
+
+// createOrganization creates the organization and makes the admin who
+// created it its owner.
+func createOrganization(ctx context.Context, tx *Tx, org *ocs.Organization) error {
+	if ok, err := isAdmin(ctx, tx); err != nil {
+		return err
+	} else if !ok {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "Only admins may create organizations.")
+	} else if err := requireVerifiedEmail(ctx, tx); err != nil {
+		return err
+	}
+
+	org.Slug = strings.ToLower(strings.TrimSpace(org.Slug))
+	org.Domain = strings.ToLower(strings.TrimSpace(org.Domain))
+	org.CreatedAt = tx.now
+	org.UpdatedAt = org.CreatedAt
+
+	if err := org.Validate(); err != nil {
+		return err
+	}
+
+	if _, n, err := findOrganizations(ctx, tx, ocs.OrganizationFilter{Slug: &org.Slug}); err != nil {
+		return err
+	} else if n != 0 {
+		return ocs.Errorf(ocs.ECONFLICT, "Slug is already taken.")
+	}
+	if org.Domain != "" {
+		if _, n, err := findOrganizations(ctx, tx, ocs.OrganizationFilter{Domain: &org.Domain}); err != nil {
+			return err
+		} else if n != 0 {
+			return ocs.Errorf(ocs.ECONFLICT, "Domain is already taken.")
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO organizations (
+      name,
+      slug,
+      domain,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?)
+  `,
+		org.Name,
+		org.Slug,
+		org.Domain,
+		(*NullTime)(&org.CreatedAt),
+		(*NullTime)(&org.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	org.ID = int(id)
+
+	if err := audit(ctx, tx, ocs.AuditActionCreate, "organization", org.ID, nil, org); err != nil {
+		return err
+	}
+
+	return insertOrganizationMember(ctx, tx, &ocs.OrganizationMember{
+		OrganizationID: org.ID,
+		StudentID:      ocs.StudentIDFromContext(ctx),
+		Role:           ocs.OrganizationRoleOwner,
+	})
+}
+
+func findOrganizationByID(ctx context.Context, tx *Tx, id int) (*ocs.Organization, error) {
+	a, _, err := findOrganizations(ctx, tx, ocs.OrganizationFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Organization not found."}
+	}
+	return a[0], nil
+}
+
+// findOrganizations returns any organization. An organization's name, slug
+// and domain are public since requests are resolved to them before anyone
+// is authenticated.
+func findOrganizations(ctx context.Context, tx *Tx, filter ocs.OrganizationFilter) (_ []*ocs.Organization, n int, err error) {
+	where, args := []string{"1 = 1"}, []interface{}{}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.Slug; v != nil {
+		where, args = append(where, "slug = ?"), append(args, strings.ToLower(*v))
+	}
+	if v := filter.Domain; v != nil {
+		where, args = append(where, "domain = ?"), append(args, strings.ToLower(*v))
+	}
+	if v := filter.StudentID; v != nil {
+		where, args = append(where, "id IN (SELECT organization_id FROM organization_members WHERE student_id = ?)"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      name,
+      slug,
+      domain,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM organizations
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	orgs := make([]*ocs.Organization, 0)
+	for rows.Next() {
+		var org ocs.Organization
+		if err := rows.Scan(
+			&org.ID,
+			&org.Name,
+			&org.Slug,
+			&org.Domain,
+			(*NullTime)(&org.CreatedAt),
+			(*NullTime)(&org.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		orgs = append(orgs, &org)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return orgs, n, nil
+}
+
+// createOrganizationMember lets an owner add a student to the organization.
+func createOrganizationMember(ctx context.Context, tx *Tx, member *ocs.OrganizationMember) error {
+	if member.Role == "" {
+		member.Role = ocs.OrganizationRoleMember
+	}
+
+	if ok, err := isOrganizationOwner(ctx, tx, member.OrganizationID, ocs.StudentIDFromContext(ctx)); err != nil {
+		return err
+	} else if !ok {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to add members to this organization.")
+	} else if err := requireVerifiedEmail(ctx, tx); err != nil {
+		return err
+	}
+
+	// The student is not a member yet, so they cannot be found through the
+	// organization's roster.
+	if member.StudentID == 0 && member.Email != "" {
+		if err := tx.QueryRowContext(ctx, `
+      SELECT id FROM students WHERE email = ? AND deleted_at IS NULL
+    `,
+			member.Email,
+		).Scan(&member.StudentID); err == sql.ErrNoRows {
+			return &ocs.Error{Code: ocs.ENOTFOUND, Message: "Student not found."}
+		} else if err != nil {
+			return FormatError(err)
+		}
+	}
+
+	if err := member.Validate(); err != nil {
+		return err
+	}
+
+	if _, n, err := findOrganizationMembers(ctx, tx, ocs.OrganizationMemberFilter{
+		OrganizationID: &member.OrganizationID,
+		StudentID:      &member.StudentID,
+	}); err != nil {
+		return err
+	} else if n != 0 {
+		return ocs.Errorf(ocs.ECONFLICT, "Student is already a member of this organization.")
+	}
+
+	return insertOrganizationMember(ctx, tx, member)
+}
+
+// insertOrganizationMember inserts a membership without checking who may
+// create it. Callers are responsible for authorization.
+func insertOrganizationMember(ctx context.Context, tx *Tx, member *ocs.OrganizationMember) error {
+	member.CreatedAt = tx.now
+
+	if err := member.Validate(); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO organization_members (
+      organization_id,
+      student_id,
+      role,
+      created_at
+    )
+    VALUES (?, ?, ?, ?)
+  `,
+		member.OrganizationID,
+		member.StudentID,
+		member.Role,
+		(*NullTime)(&member.CreatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	member.ID = int(id)
+
+	return audit(ctx, tx, ocs.AuditActionCreate, "organization_member", member.ID, nil, member)
+}
+
+// findOrganizationMembers only returns members of organizations the caller
+// belongs to.
+func findOrganizationMembers(ctx context.Context, tx *Tx, filter ocs.OrganizationMemberFilter) (_ []*ocs.OrganizationMember, n int, err error) {
+	where, args := []string{"m.organization_id IN (SELECT organization_id FROM organization_members WHERE student_id = ?)"}, []interface{}{ocs.StudentIDFromContext(ctx)}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "m.id = ?"), append(args, *v)
+	}
+	if v := filter.OrganizationID; v != nil {
+		where, args = append(where, "m.organization_id = ?"), append(args, *v)
+	}
+	if v := filter.StudentID; v != nil {
+		where, args = append(where, "m.student_id = ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      m.id,
+      m.organization_id,
+      m.student_id,
+      s.email,
+      m.role,
+      m.created_at,
+      COUNT(*) OVER()
+    FROM organization_members m
+    INNER JOIN students s ON s.id = m.student_id
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY m.id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	members := make([]*ocs.OrganizationMember, 0)
+	for rows.Next() {
+		var member ocs.OrganizationMember
+		if err := rows.Scan(
+			&member.ID,
+			&member.OrganizationID,
+			&member.StudentID,
+			&member.Email,
+			&member.Role,
+			(*NullTime)(&member.CreatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		members = append(members, &member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return members, n, nil
+}
+
+// deleteOrganizationMember removes a membership. Owners may remove anyone
+// and members may leave.
+func deleteOrganizationMember(ctx context.Context, tx *Tx, id int) error {
+	studentID := ocs.StudentIDFromContext(ctx)
+
+	members, _, err := findOrganizationMembers(ctx, tx, ocs.OrganizationMemberFilter{ID: &id})
+	if err != nil {
+		return err
+	} else if len(members) == 0 {
+		return &ocs.Error{Code: ocs.ENOTFOUND, Message: "Organization member not found."}
+	}
+
+	if member := members[0]; member.StudentID != studentID {
+		if ok, err := isOrganizationOwner(ctx, tx, member.OrganizationID, studentID); err != nil {
+			return err
+		} else if !ok {
+			return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to remove this member.")
+		} else if err := requireVerifiedEmail(ctx, tx); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM organization_members WHERE id = ?`, id); err != nil {
+		return FormatError(err)
+	}
+
+	return audit(ctx, tx, ocs.AuditActionDelete, "organization_member", id, members[0], nil)
+}
+
+// isOrganizationOwner reports whether the student owns the organization.
+func isOrganizationOwner(ctx context.Context, tx *Tx, orgID, studentID int) (bool, error) {
+	var n int
+	if err := tx.QueryRowContext(ctx, `
+    SELECT COUNT(*)
+    FROM organization_members
+    WHERE organization_id = ? AND student_id = ? AND role = ?
+  `,
+		orgID,
+		studentID,
+		ocs.OrganizationRoleOwner,
+	).Scan(&n); err != nil {
+		return false, FormatError(err)
+	}
+	return n != 0, nil
+}
@@ -0,0 +1,400 @@
+package sqlite_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestOrganizationService_CreateOrganization(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewOrganizationService(db)
+
+		admin, adminCtx := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+
+		org := &ocs.Organization{Name: "Acme", Slug: "ACME", Domain: "learn.acme.com"}
+		if err := s.CreateOrganization(adminCtx, org); err != nil {
+			t.Fatal(err)
+		} else if got, want := org.Slug, "acme"; got != want {
+			t.Fatalf("Slug=%v, want %v", got, want)
+		}
+
+		// The creator owns the organization.
+		if members, _, err := s.FindOrganizationMembers(adminCtx, ocs.OrganizationMemberFilter{OrganizationID: &org.ID}); err != nil {
+			t.Fatal(err)
+		} else if len(members) != 1 || members[0].StudentID != admin.ID || members[0].Role != ocs.OrganizationRoleOwner {
+			t.Fatalf("unexpected members: %#v", members)
+		}
+
+		domain := "learn.acme.com"
+		if orgs, _, err := s.FindOrganizations(DefaultSiteContext(), ocs.OrganizationFilter{Domain: &domain}); err != nil {
+			t.Fatal(err)
+		} else if len(orgs) != 1 || orgs[0].ID != org.ID {
+			t.Fatalf("unexpected organizations: %#v", orgs)
+		}
+
+		if err := s.CreateOrganization(adminCtx, &ocs.Organization{Name: "Acme 2", Slug: "acme"}); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewOrganizationService(db)
+
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		if err := s.CreateOrganization(ctx, &ocs.Organization{Name: "Acme", Slug: "acme"}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestOrganizationService_CreateOrganizationMember(t *testing.T) {
+	t.Run("ByEmail", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewOrganizationService(db)
+
+		org, ownerCtx := MustCreateOrganization(t, db, "acme")
+		student, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+
+		member := &ocs.OrganizationMember{OrganizationID: org.ID, Email: "bob@email.com"}
+		if err := s.CreateOrganizationMember(ownerCtx, member); err != nil {
+			t.Fatal(err)
+		} else if member.StudentID != student.ID || member.Role != ocs.OrganizationRoleMember {
+			t.Fatalf("unexpected member: %#v", member)
+		}
+
+		if err := s.CreateOrganizationMember(ownerCtx, &ocs.OrganizationMember{OrganizationID: org.ID, StudentID: student.ID}); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewOrganizationService(db)
+
+		org, _ := MustCreateOrganization(t, db, "acme")
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+
+		if err := s.CreateOrganizationMember(ctx, &ocs.OrganizationMember{OrganizationID: org.ID, StudentID: student.ID}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+// Ensure that nothing in one organization can be read from another, or from
+// outside of any organization.
+func TestOrganization_Isolation(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	acme, acmeCtx := MustCreateOrganization(t, db, "acme")
+	globex, globexCtx := MustCreateOrganization(t, db, "globex")
+
+	// The owner of acme teaches a course in it and enrolls a member.
+	course := MustCreateCourse(t, acmeCtx, db, &ocs.Course{Title: "Acme 101"})
+	student, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+	MustCreateOrganizationMember(t, acmeCtx, db, &ocs.OrganizationMember{OrganizationID: acme.ID, StudentID: student.ID})
+	MustCreateEnrollment(t, acmeCtx, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student.ID})
+
+	if got, want := course.OrganizationID, acme.ID; got != want {
+		t.Fatalf("OrganizationID=%v, want %v", got, want)
+	}
+
+	for name, ctx := range map[string]context.Context{
+		"Other":   globexCtx,
+		"Outside": ocs.NewContextWithStudent(DefaultSiteContext(), ocs.StudentFromContext(acmeCtx)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := sqlite.NewCourseService(db).FindCourseByID(ctx, course.ID); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+				t.Fatalf("unexpected error: %#v", err)
+			} else if _, n, err := sqlite.NewCourseService(db).FindCourses(ctx, ocs.CourseFilter{}); err != nil {
+				t.Fatal(err)
+			} else if n != 0 {
+				t.Fatalf("n=%v, want 0", n)
+			} else if _, n, err := sqlite.NewEnrollmentService(db).FindEnrollments(ctx, ocs.EnrollmentFilter{StudentID: &student.ID}); err != nil {
+				t.Fatal(err)
+			} else if n != 0 {
+				t.Fatalf("n=%v, want 0", n)
+			}
+		})
+	}
+
+	t.Run("Roster", func(t *testing.T) {
+		if _, err := sqlite.NewStudentService(db).FindStudentByID(acmeCtx, student.ID); err != nil {
+			t.Fatal(err)
+		} else if _, err := sqlite.NewStudentService(db).FindStudentByID(globexCtx, student.ID); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Points earned in acme do not count in globex, even for its members.
+	t.Run("Leaderboard", func(t *testing.T) {
+		MustCreateOrganizationMember(t, globexCtx, db, &ocs.OrganizationMember{OrganizationID: globex.ID, StudentID: student.ID})
+
+		s := sqlite.NewLeaderboardService(db)
+		if err := s.HandleEvent(context.Background(), student.ID, ocs.Event{
+			Type:    ocs.EventTypeSubmissionCreated,
+			Payload: &ocs.SubmissionCreatedPayload{ID: 1, CourseID: course.ID},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if board, err := s.FindLeaderboard(acmeCtx, ocs.LeaderboardFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := board.N, 1; got != want {
+			t.Fatalf("N=%v, want %v", got, want)
+		} else if board, err := s.FindLeaderboard(globexCtx, ocs.LeaderboardFilter{}); err != nil {
+			t.Fatal(err)
+		} else if got, want := board.N, 0; got != want {
+			t.Fatalf("N=%v, want %v", got, want)
+		}
+	})
+
+	// Coupon codes are per course, so organizations can pick the same code
+	// without seeing each other's.
+	t.Run("Coupons", func(t *testing.T) {
+		acmePaid := MustCreateCourse(t, acmeCtx, db, &ocs.Course{Title: "Acme 201", Price: 5000, Currency: "USD"})
+		globexPaid := MustCreateCourse(t, globexCtx, db, &ocs.Course{Title: "Globex 101", Price: 5000, Currency: "USD"})
+		MustCreateCoupon(t, acmeCtx, db, &ocs.Coupon{CourseID: acmePaid.ID, Code: "SAVE", Kind: ocs.CouponKindPercent, Amount: 10})
+		coupon := MustCreateCoupon(t, globexCtx, db, &ocs.Coupon{CourseID: globexPaid.ID, Code: "SAVE", Kind: ocs.CouponKindPercent, Amount: 20})
+
+		code := "SAVE"
+		if coupons, n, err := sqlite.NewOrderService(db).FindCoupons(globexCtx, ocs.CouponFilter{Code: &code}); err != nil {
+			t.Fatal(err)
+		} else if n != 1 || coupons[0].ID != coupon.ID {
+			t.Fatalf("unexpected coupons: %#v", coupons)
+		}
+	})
+
+	t.Run("ErrEnrollNonMember", func(t *testing.T) {
+		other, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "cat", Email: "cat@email.com"})
+		if err := sqlite.NewEnrollmentService(db).CreateEnrollment(acmeCtx, &ocs.Enrollment{CourseID: course.ID, StudentID: other.ID}); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrAuthNonMember", func(t *testing.T) {
+		ctx := ocs.NewContextWithOrganization(context.Background(), acme)
+		if err := sqlite.NewAuthService(db).CreateAuth(ctx, &ocs.Auth{
+			Source:      ocs.AuthSourceGithub,
+			SourceID:    "SOURCEID",
+			AccessToken: "ACCESS",
+			Student:     &ocs.Student{Name: "dan", Email: "dan@email.com"},
+		}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+// TestOrganization_IsolationEveryEntity reads each kind of tenant row as the
+// same students scoped to acme, to another organization they belong to, and
+// to nothing at all. Only acme may see the rows.
+func TestOrganization_IsolationEveryEntity(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+
+	acme, acmeCtx := MustCreateOrganization(t, db, "acme")
+	globex, globexCtx := MustCreateOrganization(t, db, "globex")
+	owner := ocs.StudentFromContext(acmeCtx)
+
+	// Eve and the owner of acme also belong to globex, Carl only to acme.
+	eve, eveCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "eve", Email: "eve@email.com"})
+	carl, _ := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "carl", Email: "carl@email.com"})
+	MustCreateOrganizationMember(t, acmeCtx, db, &ocs.OrganizationMember{OrganizationID: acme.ID, StudentID: eve.ID})
+	MustCreateOrganizationMember(t, acmeCtx, db, &ocs.OrganizationMember{OrganizationID: acme.ID, StudentID: carl.ID})
+	MustCreateOrganizationMember(t, globexCtx, db, &ocs.OrganizationMember{OrganizationID: globex.ID, StudentID: eve.ID})
+	MustCreateOrganizationMember(t, globexCtx, db, &ocs.OrganizationMember{OrganizationID: globex.ID, StudentID: owner.ID})
+	eveCtx = ocs.NewContextWithOrganization(eveCtx, acme)
+
+	course := MustCreateCourse(t, acmeCtx, db, &ocs.Course{Title: "Acme 101"})
+	paid := MustCreateCourse(t, acmeCtx, db, &ocs.Course{Title: "Acme 201", Price: 5000, Currency: "USD"})
+	MustCreateCoupon(t, acmeCtx, db, &ocs.Coupon{CourseID: paid.ID, Code: "SAVE", Kind: ocs.CouponKindPercent, Amount: 10})
+
+	path := &ocs.LearningPath{Title: "Acme Track", Courses: []*ocs.LearningPathCourse{{CourseID: course.ID, Required: true}}}
+	if err := sqlite.NewLearningPathService(db).CreateLearningPath(acmeCtx, path); err != nil {
+		t.Fatal(err)
+	} else if _, err := sqlite.NewLearningPathService(db).EnrollInLearningPath(eveCtx, path.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	lesson := MustCreateLesson(t, acmeCtx, db, &ocs.Lesson{CourseID: course.ID, Title: "Intro", Body: "Hello."})
+	MustCreateNote(t, eveCtx, db, &ocs.Note{LessonID: lesson.ID, Body: "note"})
+	if err := sqlite.NewNoteService(db).CreateBookmark(eveCtx, &ocs.Bookmark{LessonID: lesson.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	assignment := MustCreateAssignment(t, acmeCtx, db, &ocs.Assignment{CourseID: course.ID, Title: "HW1", MaxPoints: 10})
+	submission := MustCreateSubmission(t, eveCtx, db, &ocs.Submission{AssignmentID: assignment.ID, Body: "answer"})
+	MustGradeSubmission(t, acmeCtx, db, submission.ID, ocs.SubmissionGrade{Grade: 6})
+	MustCreateRegradeRequest(t, eveCtx, db, &ocs.RegradeRequest{SubmissionID: submission.ID, Justification: "Please look again."})
+
+	MustCreateGroupSet(t, acmeCtx, db, &ocs.GroupSet{CourseID: course.ID, Name: "Teams"})
+	conversation := MustCreateConversation(t, eveCtx, db, &ocs.Conversation{CourseID: course.ID, Subject: "Hi", ParticipantIDs: []int{owner.ID}})
+	MustSendMessage(t, eveCtx, db, &ocs.Message{ConversationID: conversation.ID, Body: "Hello"})
+
+	// Rows only made in the background are inserted directly.
+	MustExec(t, db, fmt.Sprintf(`
+    INSERT INTO orders (student_id, course_id, subtotal, discount, total, currency, status, provider_ref, payment_url, organization_id, created_at, updated_at)
+    VALUES (%[1]d, %[2]d, 5000, 0, 5000, 'USD', 'paid', 'REF', '', %[3]d, '2000-01-01T00:00:00Z', '2000-01-01T00:00:00Z');
+    INSERT INTO refunds (order_id, student_id, course_id, amount, currency, reason, access, status, issued_by_id, created_at)
+    VALUES (last_insert_rowid(), %[1]d, %[2]d, 5000, 'USD', '', 'revoked', 'issued', %[4]d, '2000-01-01T00:00:00Z');
+    INSERT INTO point_entries (student_id, course_id, reason, event_key, points, created_at)
+    VALUES (%[1]d, %[5]d, 'submission', 'KEY', 10, '2000-01-01T00:00:00Z');
+    INSERT INTO certificates (student_id, learning_path_id, code, issued_at)
+    VALUES (%[1]d, %[6]d, 'CODE', '2000-01-01T00:00:00Z');
+    INSERT INTO badge_awards (student_id, badge_code, name, description, awarded_at)
+    VALUES (%[7]d, 'first-submission', 'First Submission', '', '2000-01-01T00:00:00Z');
+    INSERT INTO erasure_requests (student_id, status, erase_at, created_at, updated_at)
+    VALUES (%[7]d, 'pending', '2000-01-01T00:00:00Z', '2000-01-01T00:00:00Z', '2000-01-01T00:00:00Z');
+  `, eve.ID, paid.ID, acme.ID, owner.ID, course.ID, path.ID, carl.ID))
+
+	// Each check reads as Eve or as the owner of acme.
+	checks := map[string]func(eve, owner context.Context) (int, error){
+		"Students": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewStudentService(db).FindStudents(ctx, ocs.StudentFilter{ID: &carl.ID})
+			return n, err
+		},
+		"Courses": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewCourseService(db).FindCourses(ctx, ocs.CourseFilter{})
+			return n, err
+		},
+		"Enrollments": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewEnrollmentService(db).FindEnrollments(ctx, ocs.EnrollmentFilter{StudentID: &eve.ID})
+			return n, err
+		},
+		"Lessons": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewLessonService(db).FindLessons(ctx, ocs.LessonFilter{CourseID: &course.ID})
+			return n, err
+		},
+		"Notes": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewNoteService(db).FindNotes(ctx, ocs.NoteFilter{})
+			return n, err
+		},
+		"Bookmarks": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewNoteService(db).FindBookmarks(ctx, ocs.BookmarkFilter{})
+			return n, err
+		},
+		"Assignments": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewAssignmentService(db).FindAssignments(ctx, ocs.AssignmentFilter{CourseID: &course.ID})
+			return n, err
+		},
+		"Submissions": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewAssignmentService(db).FindSubmissions(ctx, ocs.SubmissionFilter{AssignmentID: &assignment.ID})
+			return n, err
+		},
+		"RegradeRequests": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewRegradeService(db).FindRegradeRequests(ctx, ocs.RegradeRequestFilter{})
+			return n, err
+		},
+		"GroupSets": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewGroupService(db).FindGroupSets(ctx, ocs.GroupSetFilter{CourseID: &course.ID})
+			return n, err
+		},
+		"Conversations": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewMessageService(db).FindConversations(ctx, ocs.ConversationFilter{})
+			return n, err
+		},
+		"Messages": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewMessageService(db).FindMessages(ctx, ocs.MessageFilter{ConversationID: conversation.ID})
+			return n, err
+		},
+		"LearningPaths": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewLearningPathService(db).FindLearningPaths(ctx, ocs.LearningPathFilter{})
+			return n, err
+		},
+		"PathEnrollments": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewLearningPathService(db).FindPathEnrollments(ctx, ocs.PathEnrollmentFilter{})
+			return n, err
+		},
+		"Certificates": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewLearningPathService(db).FindCertificates(ctx, ocs.CertificateFilter{})
+			return n, err
+		},
+		"BadgeAwards": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewBadgeService(db).FindBadgeAwards(ctx, ocs.BadgeAwardFilter{StudentID: &carl.ID})
+			return n, err
+		},
+		"PointEntries": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewLeaderboardService(db).FindPointEntries(ctx, ocs.PointEntryFilter{})
+			return n, err
+		},
+		"Orders": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewOrderService(db).FindOrders(ctx, ocs.OrderFilter{})
+			return n, err
+		},
+		"Coupons": func(_, ctx context.Context) (int, error) {
+			_, n, err := sqlite.NewOrderService(db).FindCoupons(ctx, ocs.CouponFilter{})
+			return n, err
+		},
+		"Refunds": func(ctx, _ context.Context) (int, error) {
+			_, n, err := sqlite.NewRefundService(db).FindRefunds(ctx, ocs.RefundFilter{})
+			return n, err
+		},
+		"ErasureRequests": func(_, ctx context.Context) (int, error) {
+			_, n, err := sqlite.NewErasureService(db).FindErasureRequests(ctx, ocs.ErasureRequestFilter{StudentID: &carl.ID})
+			return n, err
+		},
+	}
+
+	t.Run("Acme", func(t *testing.T) {
+		for name, check := range checks {
+			if n, err := check(eveCtx, acmeCtx); err != nil {
+				t.Fatalf("%s: %s", name, err)
+			} else if n == 0 {
+				t.Fatalf("%s: n=0, want rows", name)
+			}
+		}
+	})
+
+	for name, readers := range map[string][2]context.Context{
+		"Other": {
+			ocs.NewContextWithOrganization(eveCtx, globex),
+			ocs.NewContextWithOrganization(acmeCtx, globex),
+		},
+		"Unscoped": {
+			ocs.NewContextWithStudent(context.Background(), eve),
+			ocs.NewContextWithStudent(context.Background(), owner),
+		},
+	} {
+		readers := readers
+		t.Run(name, func(t *testing.T) {
+			for name, check := range checks {
+				if n, err := check(readers[0], readers[1]); err != nil && ocs.ErrorCode(err) != ocs.ENOTFOUND && ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+					t.Fatalf("%s: %s", name, err)
+				} else if n != 0 {
+					t.Fatalf("%s: n=%v, want 0", name, n)
+				}
+			}
+		})
+	}
+}
+
+// MustCreateOrganization creates an organization as a new admin and returns
+// it with the owner's context scoped to the organization.
+func MustCreateOrganization(tb testing.TB, db *sqlite.DB, slug string) (*ocs.Organization, context.Context) {
+	tb.Helper()
+	_, ctx := MustCreateVerifiedStudent(tb, context.Background(), db, &ocs.Student{Name: slug, Email: slug + "@email.com", Admin: true})
+
+	org := &ocs.Organization{Name: slug, Slug: slug}
+	if err := sqlite.NewOrganizationService(db).CreateOrganization(ctx, org); err != nil {
+		tb.Fatal(err)
+	}
+	return org, ocs.NewContextWithOrganization(ctx, org)
+}
+
+func MustCreateOrganizationMember(tb testing.TB, ctx context.Context, db *sqlite.DB, member *ocs.OrganizationMember) *ocs.OrganizationMember {
+	tb.Helper()
+	if err := sqlite.NewOrganizationService(db).CreateOrganizationMember(ctx, member); err != nil {
+		tb.Fatal(err)
+	}
+	return member
+}
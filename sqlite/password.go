@@ -0,0 +1,433 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"golang.org/x/crypto/argon2"
+)
+
+var _ ocs.PasswordService = (*PasswordService)(nil)
+
+// DefaultPasswordResetTTL is how long a password reset link works.
+const DefaultPasswordResetTTL = time.Hour
+
+// PasswordResetInterval is the least time between reset emails to one
+// student, so that the reset form cannot be used to flood their inbox.
+const PasswordResetInterval = time.Minute
+
+// Argon2id parameters for new password hashes. Hashes record the parameters
+// they were made with, so these can be raised without breaking logins.
+const (
+	passwordTime    = 1
+	passwordMemory  = 64 * 1024 // KiB
+	passwordThreads = 4
+	passwordKeyLen  = 32
+	passwordSaltLen = 16
+)
+
+// dummyPasswordHash is checked against when there is no student or
+// password, so that failed logins take as long whatever the reason.
+const dummyPasswordHash = "$argon2id$v=19$m=65536,t=1,p=4$AAAAAAAAAAAAAAAAAAAAAA$AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+
+// PasswordService manages local accounts.
+type PasswordService struct {
+	db *DB
+
+	// The site's base URL, e.g. "https://ocs.example.com", used to build
+	// reset links.
+	URL string
+
+	TTL time.Duration
+}
+
+func NewPasswordService(db *DB) *PasswordService {
+	return &PasswordService{db: db, TTL: DefaultPasswordResetTTL}
+}
+
+func (s *PasswordService) SignUp(ctx context.Context, student *ocs.Student, password string) error {
+	// Whether an email is verified or a student is an admin is never up to
+	// whoever signs up.
+	student.EmailVerified, student.Admin = false, false
+
+	if err := ocs.ValidatePassword(password, student); err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := findAnyStudentByEmail(ctx, tx, student.Email); err == nil {
+		return ocs.Errorf(ocs.ECONFLICT, "An account with this email already exists.")
+	} else if ocs.ErrorCode(err) != ocs.ENOTFOUND {
+		return err
+	}
+
+	if err := createStudent(ctx, tx, student); err != nil {
+		return err
+	} else if err := setPasswordHash(ctx, tx, student.ID, hash); err != nil {
+		return err
+	}
+	student.HasPassword = true
+
+	return tx.Commit()
+}
+
+func (s *PasswordService) Login(ctx context.Context, email, password string) (*ocs.Student, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	student, hash, err := findStudentPassword(ctx, tx, email)
+	if err != nil {
+		return nil, err
+	} else if ok, err := checkPassword(hash, password); err != nil {
+		return nil, err
+	} else if !ok || student == nil {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "Invalid email or password.")
+	}
+
+	if err := attachStudentsAuths(ctx, tx, student); err != nil {
+		return nil, err
+	} else if err := attachStudentBadges(ctx, tx, student); err != nil {
+		return nil, err
+	}
+	return student, nil
+}
+
+func (s *PasswordService) ChangePassword(ctx context.Context, current, password string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := changePassword(ctx, tx, current, password); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PasswordService) RequestPasswordReset(ctx context.Context, email string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	students, _, err := findStudents(ctx, tx, ocs.StudentFilter{Email: &email})
+	if err != nil {
+		return err
+	} else if len(students) == 0 {
+		return nil
+	}
+	student := students[0]
+
+	var recent int
+	since := tx.now.Add(-PasswordResetInterval)
+	if err := tx.QueryRowContext(ctx, `
+    SELECT COUNT(*) FROM password_resets WHERE student_id = ? AND created_at > ?
+  `,
+		student.ID,
+		(*NullTime)(&since),
+	).Scan(&recent); err != nil {
+		return FormatError(err)
+	} else if recent > 0 {
+		return nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(buf)
+	expiresAt := tx.now.Add(s.TTL)
+
+	// Only the latest link works.
+	if err := revokeLinkTokens(ctx, tx, "password_resets", student.ID); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO password_resets (student_id, email, token_hash, expires_at, created_at)
+    VALUES (?, ?, ?, ?, ?)
+  `,
+		student.ID,
+		student.Email,
+		hashToken(token),
+		(*NullTime)(&expiresAt),
+		(*NullTime)(&tx.now),
+	)
+	if err != nil {
+		return FormatError(err)
+	} else if err := createLinkToken(ctx, tx, "password_resets", result, &linkToken{
+		StudentID: student.ID,
+		Email:     student.Email,
+		ExpiresAt: expiresAt,
+		CreatedAt: tx.now,
+	}); err != nil {
+		return err
+	}
+
+	if err := enqueueEmail(ctx, tx, ocs.PasswordResetEmail, ocs.EmailData{
+		Student:   student,
+		URL:       fmt.Sprintf("%s/reset-password?token=%s", strings.TrimSuffix(s.URL, "/"), token),
+		ExpiresAt: expiresAt.In(student.Location()),
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PasswordService) ResetPassword(ctx context.Context, token, password string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := resetPassword(ctx, tx, token, password); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// findStudentPassword returns the student with the given email and their
+// password hash. If there is no such student, or they have no password, the
+// student is nil and the hash is one that no password matches.
+func findStudentPassword(ctx context.Context, tx *Tx, email string) (*ocs.Student, string, error) {
+	students, _, err := findStudents(ctx, tx, ocs.StudentFilter{Email: &email})
+	if err != nil {
+		return nil, "", err
+	} else if len(students) == 0 || !students[0].HasPassword {
+		return nil, dummyPasswordHash, nil
+	}
+
+	var hash string
+	if err := tx.QueryRowContext(ctx, `
+    SELECT password_hash FROM students WHERE id = ?
+  `,
+		students[0].ID,
+	).Scan(&hash); err != nil {
+		return nil, "", FormatError(err)
+	}
+	return students[0], hash, nil
+}
+
+func changePassword(ctx context.Context, tx *Tx, current, password string) error {
+	studentID := ocs.StudentIDFromContext(ctx)
+	if studentID == 0 {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You must be logged in to change your password.")
+	} else if err := requireVerifiedEmail(ctx, tx); err != nil {
+		return err
+	}
+
+	student, err := findStudentByID(ctx, tx, studentID)
+	if err != nil {
+		return err
+	}
+
+	// Students who only sign in through a provider may set a password
+	// without giving a current one.
+	if student.HasPassword {
+		var hash string
+		if err := tx.QueryRowContext(ctx, `
+      SELECT password_hash FROM students WHERE id = ?
+    `,
+			student.ID,
+		).Scan(&hash); err != nil {
+			return FormatError(err)
+		} else if ok, err := checkPassword(hash, current); err != nil {
+			return err
+		} else if !ok {
+			return ocs.Errorf(ocs.EUNAUTHORIZED, "Current password is incorrect.")
+		}
+	}
+
+	if err := ocs.ValidatePassword(password, student); err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	prev := *student
+	student.HasPassword = true
+	student.UpdatedAt = tx.now
+	if err := setPasswordHash(ctx, tx, student.ID, hash); err != nil {
+		return err
+	}
+
+	// Outstanding reset links would undo the change.
+	if err := revokeLinkTokens(ctx, tx, "password_resets", student.ID); err != nil {
+		return err
+	}
+
+	return audit(ctx, tx, ocs.AuditActionUpdate, "student", student.ID, &prev, student)
+}
+
+// resetPassword sets a password given a reset token. Following the link also
+// proves the student owns the address it was sent to.
+func resetPassword(ctx context.Context, tx *Tx, token, password string) error {
+	var id, studentID int
+	var email string
+	var expiresAt, usedAt NullTime
+	if err := tx.QueryRowContext(ctx, `
+    SELECT id, student_id, email, expires_at, used_at
+    FROM password_resets
+    WHERE token_hash = ?
+  `,
+		hashToken(token),
+	).Scan(&id, &studentID, &email, &expiresAt, &usedAt); err == sql.ErrNoRows {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "Invalid reset link.")
+	} else if err != nil {
+		return FormatError(err)
+	}
+
+	if !time.Time(usedAt).IsZero() {
+		return ocs.Errorf(ocs.ECONFLICT, "This reset link has already been used.")
+	} else if !tx.now.Before(time.Time(expiresAt)) {
+		return ocs.Errorf(ocs.EEXPIRED, "This reset link has expired. Please request a new one.")
+	}
+
+	student, err := findStudentByID(ctx, tx, studentID)
+	if err != nil {
+		return err
+	} else if err := ocs.ValidatePassword(password, student); err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	prev := *student
+	student.HasPassword = true
+	student.EmailVerified = student.EmailVerified || email == student.Email
+	student.UpdatedAt = tx.now
+
+	if err := useLinkToken(ctx, tx, "password_resets", &linkToken{
+		ID:        id,
+		StudentID: studentID,
+		Email:     email,
+		ExpiresAt: time.Time(expiresAt),
+	}); err != nil {
+		return err
+	} else if _, err := tx.ExecContext(ctx, `
+    UPDATE students SET email_verified = ?, updated_at = ? WHERE id = ?
+  `,
+		student.EmailVerified,
+		(*NullTime)(&student.UpdatedAt),
+		student.ID,
+	); err != nil {
+		return FormatError(err)
+	} else if err := setPasswordHash(ctx, tx, student.ID, hash); err != nil {
+		return err
+	}
+
+	return audit(ctx, tx, ocs.AuditActionUpdate, "student", student.ID, &prev, student)
+}
+
+// claimStudentEmail is called when a provider has verified a student's email
+// but we have not. Whoever set a password on the account never proved they
+// own the address, so the password is removed and the email marked verified.
+func claimStudentEmail(ctx context.Context, tx *Tx, student *ocs.Student) error {
+	prev := *student
+	student.EmailVerified, student.HasPassword = true, false
+	student.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE students
+    SET email_verified = 1,
+        password_hash = '',
+        updated_at = ?
+    WHERE id = ?
+  `,
+		(*NullTime)(&student.UpdatedAt),
+		student.ID,
+	); err != nil {
+		return FormatError(err)
+	}
+
+	return audit(ctx, tx, ocs.AuditActionUpdate, "student", student.ID, &prev, student)
+}
+
+func setPasswordHash(ctx context.Context, tx *Tx, studentID int, hash string) error {
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE students SET password_hash = ?, updated_at = ? WHERE id = ?
+  `,
+		hash,
+		(*NullTime)(&tx.now),
+		studentID,
+	); err != nil {
+		return FormatError(err)
+	}
+	return nil
+}
+
+// hashPassword hashes a password with argon2id and a random salt. The
+// result is in the PHC string format, e.g.
+// "$argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>".
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, passwordTime, passwordMemory, passwordThreads, passwordKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, passwordMemory, passwordTime, passwordThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// checkPassword reports whether password matches a hash from hashPassword.
+func checkPassword(hash, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, fmt.Errorf("invalid password hash")
+	}
+
+	var version int
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return false, fmt.Errorf("invalid password hash version: %w", err)
+	} else if version != argon2.Version {
+		return false, fmt.Errorf("unsupported argon2 version: %d", version)
+	} else if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, fmt.Errorf("invalid password hash parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, fmt.Errorf("invalid password hash salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, fmt.Errorf("invalid password hash key: %w", err)
+	}
+
+	other := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
@@ -0,0 +1,192 @@
+package sqlite_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+var resetPasswordURL = regexp.MustCompile(`https://ocs\.example\.com/reset-password\?token=([0-9a-f]+)`)
+
+func TestPasswordService_SignUp(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewPasswordService(db)
+
+		student := &ocs.Student{Name: "jane", Email: "jane@email.com", Admin: true}
+		if err := s.SignUp(DefaultSiteContext(), student, "correct horse battery"); err != nil {
+			t.Fatal(err)
+		} else if !student.HasPassword {
+			t.Fatal("expected password")
+		} else if student.Admin || student.EmailVerified {
+			t.Fatal("expected admin and verified to be ignored")
+		}
+
+		if other, err := s.Login(DefaultSiteContext(), "jane@email.com", "correct horse battery"); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.ID, student.ID; got != want {
+			t.Fatalf("ID=%v, want %v", got, want)
+		}
+
+		// Wrong passwords and unknown emails fail alike.
+		if _, err := s.Login(DefaultSiteContext(), "jane@email.com", "wrong horse battery"); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if _, err := s.Login(DefaultSiteContext(), "john@email.com", "correct horse battery"); ocs.ErrorMessage(err) != "Invalid email or password." {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrWeakPassword", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewPasswordService(db)
+
+		for _, password := range []string{"short", "password123", "aaaaabbbbbaaaaa", "jane-is-great!"} {
+			if err := s.SignUp(DefaultSiteContext(), &ocs.Student{Name: "jane", Email: "jane@email.com"}, password); ocs.ErrorCode(err) != ocs.EINVALID {
+				t.Fatalf("%s: unexpected error: %#v", password, err)
+			}
+		}
+	})
+
+	t.Run("ErrEmailInUse", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		if err := sqlite.NewPasswordService(db).SignUp(DefaultSiteContext(), &ocs.Student{Name: "jane", Email: "jane@email.com"}, "correct horse battery"); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// A provider that has verified the email takes the account over from
+	// whoever signed up with it without verifying it.
+	t.Run("ClaimedByProvider", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewPasswordService(db)
+
+		student := &ocs.Student{Name: "jane", Email: "jane@email.com"}
+		if err := s.SignUp(DefaultSiteContext(), student, "correct horse battery"); err != nil {
+			t.Fatal(err)
+		}
+
+		auth, _ := MustCreateAuth(t, DefaultSiteContext(), db, &ocs.Auth{
+			Source:      ocs.AuthSourceGithub,
+			SourceID:    "SOURCEID",
+			AccessToken: "ACCESS",
+			Student:     &ocs.Student{Name: "jane", Email: "jane@email.com", EmailVerified: true},
+		})
+		if got, want := auth.StudentID, student.ID; got != want {
+			t.Fatalf("StudentID=%v, want %v", got, want)
+		} else if !auth.Student.EmailVerified || auth.Student.HasPassword {
+			t.Fatalf("unexpected student: %#v", auth.Student)
+		} else if _, err := s.Login(DefaultSiteContext(), "jane@email.com", "correct horse battery"); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestPasswordService_ChangePassword(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewPasswordService(db)
+
+		// Students without a password set one without a current password.
+		_, ctx := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		if err := s.ChangePassword(ctx, "", "correct horse battery"); err != nil {
+			t.Fatal(err)
+		} else if err := s.ChangePassword(ctx, "wrong horse battery", "staple horse battery"); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if err := s.ChangePassword(ctx, "correct horse battery", "staple horse battery"); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.Login(DefaultSiteContext(), "jane@email.com", "staple horse battery"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// A password could otherwise be set on an address nobody confirmed.
+	t.Run("ErrUnverified", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewPasswordService(db)
+
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		if err := s.ChangePassword(ctx, "", "correct horse battery"); ocs.ErrorCode(err) != ocs.EUNVERIFIED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if _, err := s.Login(DefaultSiteContext(), "jane@email.com", "correct horse battery"); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestPasswordService_ResetPassword(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewPasswordService(db)
+		s.URL = "https://ocs.example.com"
+
+		student := &ocs.Student{Name: "jane", Email: "jane@email.com"}
+		if err := s.SignUp(DefaultSiteContext(), student, "correct horse battery"); err != nil {
+			t.Fatal(err)
+		}
+		MustDeliverEmails(t, db)
+
+		if err := s.RequestPasswordReset(DefaultSiteContext(), "jane@email.com"); err != nil {
+			t.Fatal(err)
+		} else if err := s.RequestPasswordReset(DefaultSiteContext(), "nobody@email.com"); err != nil {
+			t.Fatal(err)
+		}
+
+		sent := MustDeliverEmails(t, db)
+		if len(sent) != 1 {
+			t.Fatalf("len=%v, want 1", len(sent))
+		}
+		m := resetPasswordURL.FindStringSubmatch(sent[0].Text)
+		if m == nil {
+			t.Fatalf("unexpected text: %s", sent[0].Text)
+		}
+
+		if err := s.ResetPassword(DefaultSiteContext(), m[1], "staple horse battery"); err != nil {
+			t.Fatal(err)
+		} else if err := s.ResetPassword(DefaultSiteContext(), m[1], "staple horse battery"); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		// The link also proves the student owns the address.
+		if other, err := s.Login(DefaultSiteContext(), "jane@email.com", "staple horse battery"); err != nil {
+			t.Fatal(err)
+		} else if !other.EmailVerified {
+			t.Fatal("expected verified email")
+		}
+	})
+
+	t.Run("ErrExpired", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		s := sqlite.NewPasswordService(db)
+		s.URL = "https://ocs.example.com"
+
+		MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		MustDeliverEmails(t, db)
+		if err := s.RequestPasswordReset(DefaultSiteContext(), "jane@email.com"); err != nil {
+			t.Fatal(err)
+		}
+		m := resetPasswordURL.FindStringSubmatch(MustDeliverEmails(t, db)[0].Text)
+
+		now = now.Add(sqlite.DefaultPasswordResetTTL)
+		if err := s.ResetPassword(DefaultSiteContext(), m[1], "staple horse battery"); ocs.ErrorCode(err) != ocs.EEXPIRED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+// PurgeJob is the job kind that purges deleted students and courses.
+const PurgeJob = "purge:deleted"
+
+// RegisterPurgeJob registers the purge job handler on q and schedules it to
+// run daily.
+func RegisterPurgeJob(q *JobQueue) error {
+	q.Handle(PurgeJob, func(ctx context.Context, job *Job) error {
+		_, err := PurgeDeleted(ctx, q.db)
+		return err
+	})
+	return q.Schedule(PurgeJob, "@daily", PurgeJob, nil)
+}
+
+// PurgeDeleted removes the students and courses that were deleted longer
+// than the retention window ago, along with everything that belongs to them.
+// Purged rows can no longer be restored. Returns the number of rows purged.
+func PurgeDeleted(ctx context.Context, db *DB) (int, error) {
+	ctx = withAllOrganizations(ctx)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	cutoff := tx.now.Add(-db.Retention)
+
+	var n int
+	for _, entityType := range []string{"course", "student"} {
+		table := entityType + "s"
+
+		ids, err := findPurgeableIDs(ctx, tx, table, cutoff)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, id := range ids {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE id = ?`, id); err != nil {
+				return 0, FormatError(err)
+			} else if err := audit(ctx, tx, ocs.AuditActionPurge, entityType, id, nil, nil); err != nil {
+				return 0, err
+			}
+		}
+		n += len(ids)
+	}
+
+	return n, tx.Commit()
+}
+
+func findPurgeableIDs(ctx context.Context, tx *Tx, table string, cutoff time.Time) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT id FROM `+table+`
+    WHERE deleted_at IS NOT NULL AND deleted_at <= ?
+    ORDER BY id ASC
+  `,
+		(*NullTime)(&cutoff),
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,48 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestPurgeDeleted(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+	s := sqlite.NewCourseService(db)
+
+	_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+	course := MustCreateCourse(t, ctx, db, &ocs.Course{Title: "Go 101"})
+	MustCreateCourse(t, ctx, db, &ocs.Course{Title: "Go 102"})
+	if err := s.DeleteCourse(ctx, course.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is purged within the retention window.
+	if n, err := sqlite.PurgeDeleted(context.Background(), db); err != nil {
+		t.Fatal(err)
+	} else if got, want := n, 0; got != want {
+		t.Fatalf("n=%v, want %v", got, want)
+	}
+
+	now := time.Now().Add(db.Retention + time.Hour)
+	db.Now = func() time.Time { return now }
+
+	if n, err := sqlite.PurgeDeleted(context.Background(), db); err != nil {
+		t.Fatal(err)
+	} else if got, want := n, 1; got != want {
+		t.Fatalf("n=%v, want %v", got, want)
+	} else if _, err := s.RestoreCourse(ctx, course.ID); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	action := ocs.AuditActionPurge
+	if _, n, err := sqlite.NewAuditService(db).FindAuditEntries(ctx, ocs.AuditFilter{Action: &action, EntityID: &course.ID}); err != nil {
+		t.Fatal(err)
+	} else if got, want := n, 1; got != want {
+		t.Fatalf("n=%v, want %v", got, want)
+	}
+}
@@ -0,0 +1,611 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.RefundService = (*RefundService)(nil)
+
+// RefundJob is the job kind that settles a refund the provider was asked for
+// but that was never settled, e.g. because the process stopped in between.
+const RefundJob = "refund:settle"
+
+// RefundRetryDelay is how long a pending refund waits before its job retries
+// it. Refunds are normally settled right away.
+const RefundRetryDelay = 5 * time.Minute
+
+// RefundService refunds orders in three steps: the refund is recorded as
+// pending and its amount reserved on the order, the provider is called
+// outside of any transaction, and the refund is then settled. A refund that
+// is left pending is retried by a job, so register its job handler on a job
+// queue.
+type RefundService struct {
+	db *DB
+
+	// Policy decides which orders students may refund themselves.
+	Policy ocs.RefundPolicy
+
+	// PaymentProvider returns the money to the student.
+	PaymentProvider ocs.PaymentProvider
+}
+
+func NewRefundService(db *DB) *RefundService {
+	return &RefundService{db: db, Policy: ocs.DefaultRefundPolicy}
+}
+
+// RegisterJobs registers the job handler that settles pending refunds.
+func (s *RefundService) RegisterJobs(q *JobQueue) {
+	q.Handle(RefundJob, s.retry)
+}
+
+func (s *RefundService) FindRefunds(ctx context.Context, filter ocs.RefundFilter) ([]*ocs.Refund, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	return findRefunds(ctx, tx, filter)
+}
+
+func (s *RefundService) RequestRefund(ctx context.Context, orderID int, reason string) (*ocs.Refund, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	refund, order, err := requestRefund(ctx, tx, s.Policy, orderID, reason)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if err := s.settle(ctx, order, refund); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+func (s *RefundService) IssueRefund(ctx context.Context, refund *ocs.Refund) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	order, err := issueRefund(ctx, tx, refund)
+	if err != nil {
+		return err
+	} else if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return s.settle(ctx, order, refund)
+}
+
+// settle asks the provider for the money and records the outcome. If the
+// provider refuses, the refund fails and its amount is released. If the
+// outcome cannot be recorded, the refund stays pending for its job to retry.
+func (s *RefundService) settle(ctx context.Context, order *ocs.Order, refund *ocs.Refund) error {
+	providerErr := s.PaymentProvider.RefundPayment(ctx, order, refund)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if providerErr != nil {
+		if err := failRefund(ctx, tx, refund); err != nil {
+			return fmt.Errorf("fail refund: %w (refund: %s)", err, providerErr)
+		} else if err := tx.Commit(); err != nil {
+			return fmt.Errorf("fail refund: %w (refund: %s)", err, providerErr)
+		}
+		return providerErr
+	}
+
+	if err := completeRefund(ctx, tx, refund); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type refundJobPayload struct {
+	RefundID int `json:"refundID"`
+}
+
+// retry settles a refund that is still pending. The provider is called again
+// with the same refund, so money that was already returned is not returned
+// twice.
+func (s *RefundService) retry(ctx context.Context, job *Job) error {
+	var payload refundJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	refund, err := findRefundByID(ctx, tx, payload.RefundID)
+	if err != nil {
+		return err
+	} else if refund.Status != ocs.RefundStatusPending {
+		return nil // settled already
+	}
+
+	orders, _, err := queryOrders(ctx, tx, []string{"id = ?"}, []interface{}{refund.OrderID}, 0, 0)
+	if err != nil {
+		return err
+	} else if len(orders) == 0 {
+		return &ocs.Error{Code: ocs.ENOTFOUND, Message: "Order not found."}
+	}
+	tx.Rollback()
+
+	return s.settle(ctx, orders[0], refund)
+}
+
+// requestRefund reserves what is left of the student's own order for a
+// refund, as long as the policy still allows it.
+func requestRefund(ctx context.Context, tx *Tx, policy ocs.RefundPolicy, orderID int, reason string) (*ocs.Refund, *ocs.Order, error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+
+	if err := requireVerifiedEmail(ctx, tx); err != nil {
+		return nil, nil, err
+	}
+
+	order, err := findOrderByID(ctx, tx, orderID)
+	if err != nil {
+		return nil, nil, err
+	} else if order.StudentID != studentID {
+		return nil, nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to refund this order.")
+	} else if order.PaidAt == nil {
+		return nil, nil, ocs.Errorf(ocs.EINVALID, "Only paid orders can be refunded.")
+	}
+
+	progress, err := findCourseProgress(ctx, tx, order.CourseID, studentID)
+	if err != nil {
+		return nil, nil, err
+	} else if !policy.Eligible(*order.PaidAt, progress, tx.now) {
+		return nil, nil, ocs.Errorf(ocs.EINVALID, "This order is no longer eligible for a refund.")
+	}
+
+	refund := &ocs.Refund{OrderID: order.ID, Reason: reason}
+	if err := createRefund(ctx, tx, order, refund); err != nil {
+		return nil, nil, err
+	}
+	return refund, order, nil
+}
+
+// issueRefund lets an admin refund any order, in full or in part.
+func issueRefund(ctx context.Context, tx *Tx, refund *ocs.Refund) (*ocs.Order, error) {
+	if ok, err := isAdmin(ctx, tx); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "Only admins may issue refunds.")
+	} else if err := requireVerifiedEmail(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	where, args := tx.scope(byCourse, "course_id")
+	where, args = append(where, "id = ?"), append(args, refund.OrderID)
+
+	orders, _, err := queryOrders(ctx, tx, where, args, 0, 0)
+	if err != nil {
+		return nil, err
+	} else if len(orders) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Order not found."}
+	}
+
+	if err := createRefund(ctx, tx, orders[0], refund); err != nil {
+		return nil, err
+	}
+	return orders[0], nil
+}
+
+// createRefund records a pending refund in the ledger and reserves its
+// amount on the order, so that concurrent refunds cannot return more than
+// was paid. Whether the refund revokes or downgrades the enrollment is
+// decided now: a refund of everything that is left revokes it.
+func createRefund(ctx context.Context, tx *Tx, order *ocs.Order, refund *ocs.Refund) error {
+	switch order.Status {
+	case ocs.OrderStatusPaid, ocs.OrderStatusPartiallyRefunded:
+	default:
+		return ocs.Errorf(ocs.EINVALID, "Only paid orders can be refunded.")
+	}
+
+	remaining := order.Total - order.Refunded
+	if refund.Amount == 0 {
+		refund.Amount = remaining
+	}
+
+	refund.OrderID = order.ID
+	refund.StudentID = order.StudentID
+	refund.CourseID = order.CourseID
+	refund.Currency = order.Currency
+	refund.Status = ocs.RefundStatusPending
+	refund.IssuedByID = ocs.StudentIDFromContext(ctx)
+	refund.CreatedAt = tx.now
+
+	if err := refund.Validate(); err != nil {
+		return err
+	} else if remaining == 0 {
+		return ocs.Errorf(ocs.EINVALID, "Nothing is left to refund.")
+	} else if refund.Amount > remaining {
+		return ocs.Errorf(ocs.EINVALID, "Amount exceeds what is left to refund.")
+	}
+
+	if refund.Amount == remaining {
+		refund.Access = ocs.RefundAccessRevoked
+	} else {
+		refund.Access = ocs.RefundAccessDowngraded
+	}
+
+	prev := *order
+	order.Refunded += refund.Amount
+	order.UpdatedAt = tx.now
+
+	// Checked again by the update, in case another refund was reserved
+	// since the order was read.
+	if result, err := tx.ExecContext(ctx, `
+    UPDATE orders
+    SET refunded = refunded + ?,
+        updated_at = ?
+    WHERE id = ? AND refunded + ? <= total
+    `,
+		refund.Amount,
+		(*NullTime)(&order.UpdatedAt),
+		order.ID,
+		refund.Amount,
+	); err != nil {
+		return FormatError(err)
+	} else if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ocs.Errorf(ocs.ECONFLICT, "Another refund of this order is in progress.")
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "order", order.ID, &prev, order); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO refunds (
+      order_id,
+      student_id,
+      course_id,
+      amount,
+      currency,
+      reason,
+      access,
+      status,
+      issued_by_id,
+      created_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+  `,
+		refund.OrderID,
+		refund.StudentID,
+		refund.CourseID,
+		refund.Amount,
+		refund.Currency,
+		refund.Reason,
+		refund.Access,
+		refund.Status,
+		refund.IssuedByID,
+		(*NullTime)(&refund.CreatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	refund.ID = int(id)
+
+	if err := audit(ctx, tx, ocs.AuditActionCreate, "refund", refund.ID, nil, refund); err != nil {
+		return err
+	}
+
+	_, err = enqueueJob(ctx, tx, RefundJob, refundJobPayload{RefundID: refund.ID}, JobOptions{
+		Delay:     RefundRetryDelay,
+		UniqueKey: fmt.Sprintf("%s:%d", RefundJob, refund.ID),
+	})
+	return err
+}
+
+// completeRefund settles a pending refund as issued, then revokes or
+// downgrades the enrollment and updates the order's status. Refunds that
+// are already settled are left alone.
+func completeRefund(ctx context.Context, tx *Tx, refund *ocs.Refund) error {
+	if ok, err := settleRefund(ctx, tx, refund, ocs.RefundStatusIssued); err != nil || !ok {
+		return err
+	}
+
+	orders, _, err := queryOrders(ctx, tx, []string{"id = ?"}, []interface{}{refund.OrderID}, 0, 0)
+	if err != nil {
+		return err
+	} else if len(orders) == 0 {
+		return &ocs.Error{Code: ocs.ENOTFOUND, Message: "Order not found."}
+	}
+	order := orders[0]
+
+	course, err := findAnyCourseByID(ctx, tx, order.CourseID)
+	if err != nil {
+		return err
+	}
+
+	prev := *order
+	order.UpdatedAt = tx.now
+	if refund.Access == ocs.RefundAccessRevoked {
+		order.Status = ocs.OrderStatusRefunded
+	} else if order.Status == ocs.OrderStatusPaid {
+		order.Status = ocs.OrderStatusPartiallyRefunded
+	}
+
+	if err := changeRefundedAccess(ctx, tx, order, refund.Access); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE orders
+    SET status = ?,
+        enrollment_id = ?,
+        updated_at = ?
+    WHERE id = ?
+    `,
+		order.Status,
+		order.EnrollmentID,
+		(*NullTime)(&order.UpdatedAt),
+		order.ID,
+	); err != nil {
+		return FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "order", order.ID, &prev, order); err != nil {
+		return err
+	}
+
+	event := ocs.Event{
+		Type:    ocs.EventTypeRefundIssued,
+		Payload: &ocs.RefundIssuedPayload{Refund: refund},
+	}
+	tx.publishEvent(refund.StudentID, event)
+	tx.publishEvent(course.InstructorID, event)
+
+	return nil
+}
+
+// failRefund settles a pending refund as failed and releases its amount.
+func failRefund(ctx context.Context, tx *Tx, refund *ocs.Refund) error {
+	if ok, err := settleRefund(ctx, tx, refund, ocs.RefundStatusFailed); err != nil || !ok {
+		return err
+	}
+
+	orders, _, err := queryOrders(ctx, tx, []string{"id = ?"}, []interface{}{refund.OrderID}, 0, 0)
+	if err != nil {
+		return err
+	} else if len(orders) == 0 {
+		return &ocs.Error{Code: ocs.ENOTFOUND, Message: "Order not found."}
+	}
+	order := orders[0]
+
+	prev := *order
+	order.Refunded -= refund.Amount
+	order.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE orders
+    SET refunded = refunded - ?,
+        updated_at = ?
+    WHERE id = ?
+    `,
+		refund.Amount,
+		(*NullTime)(&order.UpdatedAt),
+		order.ID,
+	); err != nil {
+		return FormatError(err)
+	}
+	return audit(ctx, tx, ocs.AuditActionUpdate, "order", order.ID, &prev, order)
+}
+
+// settleRefund moves a pending refund to status. Returns false if it was
+// settled already.
+func settleRefund(ctx context.Context, tx *Tx, refund *ocs.Refund, status string) (bool, error) {
+	result, err := tx.ExecContext(ctx, `
+    UPDATE refunds SET status = ? WHERE id = ? AND status = ?
+  `,
+		status,
+		refund.ID,
+		ocs.RefundStatusPending,
+	)
+	if err != nil {
+		return false, FormatError(err)
+	} else if n, err := result.RowsAffected(); err != nil {
+		return false, err
+	} else if n == 0 {
+		return false, nil
+	}
+
+	prev := *refund
+	refund.Status = status
+	if err := audit(ctx, tx, ocs.AuditActionUpdate, "refund", refund.ID, &prev, refund); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// changeRefundedAccess removes or downgrades the enrollment the order paid
+// for. Staff enrollments are left alone.
+func changeRefundedAccess(ctx context.Context, tx *Tx, order *ocs.Order, access string) error {
+	if order.EnrollmentID == nil {
+		return nil
+	}
+
+	enrollment, err := findEnrollmentByID(ctx, tx, *order.EnrollmentID)
+	if ocs.ErrorCode(err) == ocs.ENOTFOUND {
+		enrollment = nil
+	} else if err != nil {
+		return err
+	}
+
+	switch access {
+	case ocs.RefundAccessRevoked:
+		order.EnrollmentID = nil
+		if enrollment == nil || (enrollment.Role != ocs.EnrollmentRoleStudent && enrollment.Role != ocs.EnrollmentRoleAuditor) {
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM enrollments WHERE id = ?`, enrollment.ID); err != nil {
+			return FormatError(err)
+		}
+		return audit(ctx, tx, ocs.AuditActionDelete, "enrollment", enrollment.ID, enrollment, nil)
+
+	case ocs.RefundAccessDowngraded:
+		if enrollment == nil || enrollment.Role != ocs.EnrollmentRoleStudent {
+			return nil
+		}
+
+		prev := *enrollment
+		enrollment.Role, enrollment.UpdatedAt = ocs.EnrollmentRoleAuditor, tx.now
+		if _, err := tx.ExecContext(ctx, `
+      UPDATE enrollments
+      SET role = ?,
+          updated_at = ?
+      WHERE id = ?
+    `,
+			enrollment.Role,
+			(*NullTime)(&enrollment.UpdatedAt),
+			enrollment.ID,
+		); err != nil {
+			return FormatError(err)
+		}
+		return audit(ctx, tx, ocs.AuditActionUpdate, "enrollment", enrollment.ID, &prev, enrollment)
+	}
+
+	return nil
+}
+
+// findCourseProgress returns the fraction of the course's assignments the
+// student has submitted, alone or with a group. A course without assignments
+// has no progress.
+func findCourseProgress(ctx context.Context, tx *Tx, courseID, studentID int) (float64, error) {
+	var total, submitted int
+	if err := tx.QueryRowContext(ctx, `
+    SELECT COUNT(DISTINCT a.id), COUNT(DISTINCT s.assignment_id)
+    FROM assignments a
+    LEFT JOIN submissions s ON s.assignment_id = a.id AND (
+      s.student_id = ? OR s.group_id IN (`+groupMembersAtSubmission+`)
+    )
+    WHERE a.course_id = ?
+  `,
+		studentID,
+		studentID,
+		courseID,
+	).Scan(&total, &submitted); err != nil {
+		return 0, FormatError(err)
+	} else if total == 0 {
+		return 0, nil
+	}
+	return float64(submitted) / float64(total), nil
+}
+
+// findRefunds returns refunds of the caller's orders and of courses they
+// teach. Admins see every refund.
+func findRefunds(ctx context.Context, tx *Tx, filter ocs.RefundFilter) (_ []*ocs.Refund, n int, err error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+
+	where, args := tx.scope(byCourse, "course_id")
+	if ok, err := isAdmin(ctx, tx); err != nil {
+		return nil, 0, err
+	} else if !ok {
+		where, args = append(where, `(
+      student_id = ? OR
+      course_id IN (SELECT id FROM courses WHERE instructor_id = ?)
+    )`), append(args, studentID, studentID)
+	}
+
+	if v := filter.OrderID; v != nil {
+		where, args = append(where, "order_id = ?"), append(args, *v)
+	}
+	if v := filter.CourseID; v != nil {
+		where, args = append(where, "course_id = ?"), append(args, *v)
+	}
+
+	return queryRefunds(ctx, tx, where, args, filter.Limit, filter.Offset)
+}
+
+// findRefundByID returns a refund regardless of who is asking, for settling
+// it in the background.
+func findRefundByID(ctx context.Context, tx *Tx, id int) (*ocs.Refund, error) {
+	refunds, _, err := queryRefunds(ctx, tx, []string{"id = ?"}, []interface{}{id}, 0, 0)
+	if err != nil {
+		return nil, err
+	} else if len(refunds) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Refund not found."}
+	}
+	return refunds[0], nil
+}
+
+func queryRefunds(ctx context.Context, tx *Tx, where []string, args []interface{}, limit, offset int) (_ []*ocs.Refund, n int, err error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      order_id,
+      student_id,
+      course_id,
+      amount,
+      currency,
+      reason,
+      access,
+      status,
+      issued_by_id,
+      created_at,
+      COUNT(*) OVER()
+    FROM refunds
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(limit, offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	refunds := make([]*ocs.Refund, 0)
+	for rows.Next() {
+		var refund ocs.Refund
+		if err := rows.Scan(
+			&refund.ID,
+			&refund.OrderID,
+			&refund.StudentID,
+			&refund.CourseID,
+			&refund.Amount,
+			&refund.Currency,
+			&refund.Reason,
+			&refund.Access,
+			&refund.Status,
+			&refund.IssuedByID,
+			(*NullTime)(&refund.CreatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+		refunds = append(refunds, &refund)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return refunds, n, nil
+}
@@ -0,0 +1,275 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/mock"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestRefundService_RequestRefund(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := NewRefundService(db)
+
+		order, _, ctx := MustCreatePaidOrder(t, db)
+
+		var notified []int
+		db.EventService = &mock.EventService{PublishEventFn: func(studentID int, event ocs.Event) {
+			if event.Type == ocs.EventTypeRefundIssued {
+				notified = append(notified, studentID)
+			}
+		}}
+
+		refund, err := s.RequestRefund(ctx, order.ID, "Changed my mind")
+		if err != nil {
+			t.Fatal(err)
+		} else if refund.Amount != order.Total || refund.Access != ocs.RefundAccessRevoked {
+			t.Fatalf("unexpected refund: %#v", refund)
+		} else if got, want := len(notified), 2; got != want {
+			t.Fatalf("len(notified)=%v, want %v", got, want)
+		}
+
+		// The enrollment is gone.
+		if _, n, err := sqlite.NewEnrollmentService(db).FindEnrollments(ctx, ocs.EnrollmentFilter{CourseID: &order.CourseID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		if other, err := sqlite.NewOrderService(db).FindOrderByID(ctx, order.ID); err != nil {
+			t.Fatal(err)
+		} else if other.Status != ocs.OrderStatusRefunded || other.Refunded != order.Total {
+			t.Fatalf("unexpected order: %#v", other)
+		}
+
+		// Nothing is left to refund.
+		if _, err := s.RequestRefund(ctx, order.ID, "Again"); ocs.ErrorCode(err) != ocs.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrNotEligible", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := NewRefundService(db)
+
+		order, ownerCtx, ctx := MustCreatePaidOrder(t, db)
+		assignment := MustCreateAssignment(t, ownerCtx, db, &ocs.Assignment{CourseID: order.CourseID, Title: "HW1", MaxPoints: 10})
+		MustCreateSubmission(t, ctx, db, &ocs.Submission{AssignmentID: assignment.ID, StudentID: order.StudentID, Body: "done"})
+
+		// Past the window with the whole course done.
+		now := time.Now().AddDate(0, 1, 0)
+		db.Now = func() time.Time { return now }
+
+		if _, err := s.RequestRefund(ctx, order.ID, "Too late"); ocs.ErrorCode(err) != ocs.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := NewRefundService(db)
+
+		order, ownerCtx, _ := MustCreatePaidOrder(t, db)
+		if _, err := s.RequestRefund(ownerCtx, order.ID, "Not mine"); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestRefundService_IssueRefund(t *testing.T) {
+	t.Run("Partial", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := NewRefundService(db)
+
+		order, _, ctx := MustCreatePaidOrder(t, db)
+		_, adminCtx := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+
+		// A partial refund downgrades the student to auditing.
+		refund := &ocs.Refund{OrderID: order.ID, Amount: 1000, Reason: "Outage"}
+		if err := s.IssueRefund(adminCtx, refund); err != nil {
+			t.Fatal(err)
+		} else if refund.Access != ocs.RefundAccessDowngraded {
+			t.Fatalf("Access=%v, want %v", refund.Access, ocs.RefundAccessDowngraded)
+		}
+
+		if enrollments, _, err := sqlite.NewEnrollmentService(db).FindEnrollments(ctx, ocs.EnrollmentFilter{CourseID: &order.CourseID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := enrollments[0].Role, ocs.EnrollmentRoleAuditor; got != want {
+			t.Fatalf("Role=%v, want %v", got, want)
+		}
+
+		// The rest of the order is refunded.
+		if err := s.IssueRefund(adminCtx, &ocs.Refund{OrderID: order.ID, Reason: "Goodwill"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if refunds, n, err := s.FindRefunds(ctx, ocs.RefundFilter{OrderID: &order.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 2; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if refunds[1].Amount != order.Total-1000 || refunds[1].Access != ocs.RefundAccessRevoked {
+			t.Fatalf("unexpected refund: %#v", refunds[1])
+		}
+	})
+
+	// The provider returns the money but the refund cannot be recorded as
+	// issued. The amount stays reserved, and the job settles the refund
+	// later, asking the provider again under the same refund ID.
+	t.Run("ErrSettle", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Now()
+		db.Now = func() time.Time { return now }
+
+		var refunded []int
+		s := sqlite.NewRefundService(db)
+		s.PaymentProvider = &mock.PaymentProvider{RefundPaymentFn: func(ctx context.Context, order *ocs.Order, refund *ocs.Refund) error {
+			refunded = append(refunded, refund.ID)
+			return nil
+		}}
+		q := sqlite.NewJobQueue(db)
+		s.RegisterJobs(q)
+
+		order, _, ctx := MustCreatePaidOrder(t, db)
+		_, adminCtx := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+
+		MustExec(t, db, `CREATE TRIGGER refunds_fail BEFORE UPDATE ON refunds BEGIN SELECT RAISE(ABORT, 'disk full'); END`)
+
+		refund := &ocs.Refund{OrderID: order.ID, Reason: "Outage"}
+		if err := s.IssueRefund(adminCtx, refund); err == nil {
+			t.Fatal("expected error")
+		} else if got, want := refunded, []int{refund.ID}; len(got) != 1 || got[0] != want[0] {
+			t.Fatalf("refunded=%v, want %v", got, want)
+		}
+
+		if refunds, _, err := s.FindRefunds(ctx, ocs.RefundFilter{OrderID: &order.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := refunds[0].Status, ocs.RefundStatusPending; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if err := s.IssueRefund(adminCtx, &ocs.Refund{OrderID: order.ID, Reason: "Again"}); ocs.ErrorMessage(err) != "Nothing is left to refund." {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		MustExec(t, db, `DROP TRIGGER refunds_fail`)
+		now = now.Add(sqlite.RefundRetryDelay)
+		MustRunNextJob(t, q, true)
+
+		if got, want := refunded, []int{refund.ID, refund.ID}; len(got) != 2 || got[1] != want[1] {
+			t.Fatalf("refunded=%v, want %v", got, want)
+		} else if refunds, _, err := s.FindRefunds(ctx, ocs.RefundFilter{OrderID: &order.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := refunds[0].Status, ocs.RefundStatusIssued; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if other, err := sqlite.NewOrderService(db).FindOrderByID(ctx, order.ID); err != nil {
+			t.Fatal(err)
+		} else if other.Status != ocs.OrderStatusRefunded || other.Refunded != order.Total {
+			t.Fatalf("unexpected order: %#v", other)
+		}
+	})
+
+	t.Run("ErrProvider", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		s := sqlite.NewRefundService(db)
+		s.PaymentProvider = &mock.PaymentProvider{RefundPaymentFn: func(ctx context.Context, order *ocs.Order, refund *ocs.Refund) error {
+			return errors.New("provider unavailable")
+		}}
+
+		order, _, ctx := MustCreatePaidOrder(t, db)
+		if _, err := s.RequestRefund(ctx, order.ID, "Changed my mind"); err == nil {
+			t.Fatal("expected error")
+		}
+
+		// The failed refund stays in the ledger and releases its amount.
+		if refunds, _, err := s.FindRefunds(ctx, ocs.RefundFilter{OrderID: &order.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := refunds[0].Status, ocs.RefundStatusFailed; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if other, err := sqlite.NewOrderService(db).FindOrderByID(ctx, order.ID); err != nil {
+			t.Fatal(err)
+		} else if other.Status != ocs.OrderStatusPaid || other.Refunded != 0 {
+			t.Fatalf("unexpected order: %#v", other)
+		}
+	})
+
+	t.Run("ErrExceedsTotal", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := NewRefundService(db)
+
+		order, _, _ := MustCreatePaidOrder(t, db)
+		_, adminCtx := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+
+		if err := s.IssueRefund(adminCtx, &ocs.Refund{OrderID: order.ID, Amount: order.Total + 1, Reason: "Too much"}); ocs.ErrorCode(err) != ocs.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := NewRefundService(db)
+
+		order, ownerCtx, _ := MustCreatePaidOrder(t, db)
+		if err := s.IssueRefund(ownerCtx, &ocs.Refund{OrderID: order.ID, Reason: "Because"}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("AppendOnly", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := NewRefundService(db)
+
+		order, _, ctx := MustCreatePaidOrder(t, db)
+		if _, err := s.RequestRefund(ctx, order.ID, "Changed my mind"); err != nil {
+			t.Fatal(err)
+		}
+
+		tx, err := db.BeginTx(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`UPDATE refunds SET amount = 0`); err == nil {
+			t.Fatal("expected update to fail")
+		} else if _, err := tx.Exec(`DELETE FROM refunds`); err == nil {
+			t.Fatal("expected delete to fail")
+		}
+	})
+}
+
+// NewRefundService returns a refund service that refunds through the
+// in-memory payment provider.
+func NewRefundService(db *sqlite.DB) *sqlite.RefundService {
+	_, provider := NewOrderService(db)
+	s := sqlite.NewRefundService(db)
+	s.PaymentProvider = provider
+	return s
+}
+
+// MustCreatePaidOrder buys a paid course and returns the paid order with
+// the instructor's context and the buyer's context.
+func MustCreatePaidOrder(tb testing.TB, db *sqlite.DB) (*ocs.Order, context.Context, context.Context) {
+	tb.Helper()
+	s, provider := NewOrderService(db)
+
+	course, ownerCtx, ctx := MustCreatePaidCourse(tb, db, 5000)
+	order, err := s.Checkout(ctx, ocs.Checkout{CourseID: course.ID})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return MustCompletePayment(tb, s, provider, order.ProviderRef), ownerCtx, ctx
+}
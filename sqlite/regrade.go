@@ -0,0 +1,453 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.RegradeService = (*RegradeService)(nil)
+
+type RegradeService struct {
+	db *DB
+}
+
+func NewRegradeService(db *DB) *RegradeService {
+	return &RegradeService{db: db}
+}
+
+func (s *RegradeService) FindRegradeRequestByID(ctx context.Context, id int) (*ocs.RegradeRequest, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	req, err := findRegradeRequestByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachRegradeRequestAssociations(ctx, tx, req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func (s *RegradeService) FindRegradeRequests(ctx context.Context, filter ocs.RegradeRequestFilter) ([]*ocs.RegradeRequest, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	reqs, n, err := findRegradeRequests(ctx, tx, filter)
+	if err != nil {
+		return reqs, n, err
+	}
+
+	for _, req := range reqs {
+		if err := attachRegradeRequestAssociations(ctx, tx, req); err != nil {
+			return reqs, n, err
+		}
+	}
+	return reqs, n, nil
+}
+
+func (s *RegradeService) CreateRegradeRequest(ctx context.Context, req *ocs.RegradeRequest) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createRegradeRequest(ctx, tx, req); err != nil {
+		return err
+	} else if err := attachRegradeRequestAssociations(ctx, tx, req); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *RegradeService) ResolveRegradeRequest(ctx context.Context, id int, res ocs.RegradeResolution) (*ocs.RegradeRequest, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	req, err := resolveRegradeRequest(ctx, tx, id, res)
+	if err != nil {
+		return req, err
+	} else if err := attachRegradeRequestAssociations(ctx, tx, req); err != nil {
+		return req, err
+	} else if err := tx.Commit(); err != nil {
+		return req, err
+	}
+
+	return req, nil
+}
+
+func createRegradeRequest(ctx context.Context, tx *Tx, req *ocs.RegradeRequest) error {
+	req.StudentID = ocs.StudentIDFromContext(ctx)
+	if req.StudentID == 0 {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You must be logged in to request a regrade.")
+	}
+
+	req.Status = ocs.RegradeStatusOpen
+	req.Reply = ""
+	req.ReviewerID = 0
+	req.CreatedAt = tx.now
+	req.UpdatedAt = req.CreatedAt
+
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	submission, err := findSubmissionByID(ctx, tx, req.SubmissionID)
+	if err != nil {
+		return err
+	} else if submission.StudentID != req.StudentID {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You can only request a regrade of your own submission.")
+	} else if submission.Grade == nil {
+		return ocs.Errorf(ocs.EINVALID, "Submission has not been graded yet.")
+	}
+
+	assignment, err := findAssignmentByID(ctx, tx, submission.AssignmentID)
+	if err != nil {
+		return err
+	}
+	req.CourseID = assignment.CourseID
+
+	status := ocs.RegradeStatusOpen
+	if _, n, err := findRegradeRequests(ctx, tx, ocs.RegradeRequestFilter{SubmissionID: &req.SubmissionID, Status: &status}); err != nil {
+		return err
+	} else if n != 0 {
+		return ocs.Errorf(ocs.ECONFLICT, "A regrade request for this submission is already open.")
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO regrade_requests (
+      submission_id,
+      course_id,
+      student_id,
+      justification,
+      status,
+      reply,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+  `,
+		req.SubmissionID,
+		req.CourseID,
+		req.StudentID,
+		req.Justification,
+		req.Status,
+		req.Reply,
+		(*NullTime)(&req.CreatedAt),
+		(*NullTime)(&req.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	req.ID = int(id)
+
+	if err := audit(ctx, tx, ocs.AuditActionCreate, "regrade_request", req.ID, nil, req); err != nil {
+		return err
+	}
+
+	if err := createRegradeTransition(ctx, tx, &ocs.RegradeTransition{
+		RegradeRequestID: req.ID,
+		ActorID:          req.StudentID,
+		ToStatus:         req.Status,
+		Note:             req.Justification,
+	}); err != nil {
+		return err
+	}
+
+	publishRegradeRequestChanged(tx, req, "")
+	return nil
+}
+
+func findRegradeRequestByID(ctx context.Context, tx *Tx, id int) (*ocs.RegradeRequest, error) {
+	a, _, err := findRegradeRequests(ctx, tx, ocs.RegradeRequestFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Regrade request not found."}
+	}
+	return a[0], nil
+}
+
+// findRegradeRequests only returns the caller's own requests and requests
+// for courses where the caller is on the staff.
+func findRegradeRequests(ctx context.Context, tx *Tx, filter ocs.RegradeRequestFilter) (_ []*ocs.RegradeRequest, n int, err error) {
+	studentID := ocs.StudentIDFromContext(ctx)
+
+	where, args := tx.scope(byCourse, "course_id")
+	where, args = append(where, `(
+    student_id = ? OR
+    course_id IN (SELECT id FROM courses WHERE instructor_id = ?) OR
+    course_id IN (SELECT course_id FROM enrollments WHERE student_id = ? AND role = ?)
+  )`), append(args, studentID, studentID, studentID, ocs.EnrollmentRoleTA)
+
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.CourseID; v != nil {
+		where, args = append(where, "course_id = ?"), append(args, *v)
+	}
+	if v := filter.StudentID; v != nil {
+		where, args = append(where, "student_id = ?"), append(args, *v)
+	}
+	if v := filter.SubmissionID; v != nil {
+		where, args = append(where, "submission_id = ?"), append(args, *v)
+	}
+	if v := filter.Status; v != nil {
+		where, args = append(where, "status = ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      submission_id,
+      course_id,
+      student_id,
+      justification,
+      status,
+      reply,
+      reviewer_id,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM regrade_requests
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	reqs := make([]*ocs.RegradeRequest, 0)
+	for rows.Next() {
+		var req ocs.RegradeRequest
+		var reviewerID sql.NullInt64
+		if err := rows.Scan(
+			&req.ID,
+			&req.SubmissionID,
+			&req.CourseID,
+			&req.StudentID,
+			&req.Justification,
+			&req.Status,
+			&req.Reply,
+			&reviewerID,
+			(*NullTime)(&req.CreatedAt),
+			(*NullTime)(&req.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		if reviewerID.Valid {
+			req.ReviewerID = int(reviewerID.Int64)
+		}
+
+		reqs = append(reqs, &req)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return reqs, n, nil
+}
+
+func resolveRegradeRequest(ctx context.Context, tx *Tx, id int, res ocs.RegradeResolution) (*ocs.RegradeRequest, error) {
+	req, err := findRegradeRequestByID(ctx, tx, id)
+	if err != nil {
+		return req, err
+	}
+
+	reviewerID := ocs.StudentIDFromContext(ctx)
+	if ok, err := isCourseStaff(ctx, tx, req.CourseID, reviewerID); err != nil {
+		return req, err
+	} else if !ok {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to resolve this regrade request.")
+	}
+
+	if req.Status != ocs.RegradeStatusOpen {
+		return req, ocs.Errorf(ocs.ECONFLICT, "Regrade request is already %s.", req.Status)
+	} else if err := res.Validate(); err != nil {
+		return req, err
+	}
+
+	if res.Grade != nil {
+		submission, err := findSubmissionByID(ctx, tx, req.SubmissionID)
+		if err != nil {
+			return req, err
+		} else if _, err := gradeSubmission(ctx, tx, submission.ID, ocs.SubmissionGrade{
+			Grade:    *res.Grade,
+			Feedback: submission.Feedback,
+		}); err != nil {
+			return req, err
+		}
+	}
+
+	prev := *req
+	req.Status = res.Status
+	req.Reply = res.Reply
+	req.ReviewerID = reviewerID
+	req.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE regrade_requests
+    SET status = ?,
+        reply = ?,
+        reviewer_id = ?,
+        updated_at = ?
+    WHERE id = ?
+    `,
+		req.Status,
+		req.Reply,
+		req.ReviewerID,
+		(*NullTime)(&req.UpdatedAt),
+		id,
+	); err != nil {
+		return req, FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "regrade_request", id, &prev, req); err != nil {
+		return req, err
+	}
+
+	if err := createRegradeTransition(ctx, tx, &ocs.RegradeTransition{
+		RegradeRequestID: req.ID,
+		ActorID:          reviewerID,
+		FromStatus:       ocs.RegradeStatusOpen,
+		ToStatus:         req.Status,
+		Note:             req.Reply,
+	}); err != nil {
+		return req, err
+	}
+
+	publishRegradeRequestChanged(tx, req, ocs.RegradeStatusOpen)
+	return req, nil
+}
+
+func createRegradeTransition(ctx context.Context, tx *Tx, transition *ocs.RegradeTransition) error {
+	transition.CreatedAt = tx.now
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO regrade_transitions (
+      regrade_request_id,
+      actor_id,
+      from_status,
+      to_status,
+      note,
+      created_at
+    )
+    VALUES (?, ?, ?, ?, ?, ?)
+  `,
+		transition.RegradeRequestID,
+		transition.ActorID,
+		transition.FromStatus,
+		transition.ToStatus,
+		transition.Note,
+		(*NullTime)(&transition.CreatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	transition.ID = int(id)
+
+	return nil
+}
+
+func findRegradeTransitions(ctx context.Context, tx *Tx, regradeRequestID int) (_ []*ocs.RegradeTransition, err error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      regrade_request_id,
+      actor_id,
+      from_status,
+      to_status,
+      note,
+      created_at
+    FROM regrade_transitions
+    WHERE regrade_request_id = ?
+    ORDER BY id ASC
+  `,
+		regradeRequestID,
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	transitions := make([]*ocs.RegradeTransition, 0)
+	for rows.Next() {
+		var transition ocs.RegradeTransition
+		var actorID sql.NullInt64
+		if err := rows.Scan(
+			&transition.ID,
+			&transition.RegradeRequestID,
+			&actorID,
+			&transition.FromStatus,
+			&transition.ToStatus,
+			&transition.Note,
+			(*NullTime)(&transition.CreatedAt),
+		); err != nil {
+			return nil, err
+		}
+
+		if actorID.Valid {
+			transition.ActorID = int(actorID.Int64)
+		}
+
+		transitions = append(transitions, &transition)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+
+	return transitions, nil
+}
+
+func attachRegradeRequestAssociations(ctx context.Context, tx *Tx, req *ocs.RegradeRequest) (err error) {
+	if req.Submission, err = findSubmissionByID(ctx, tx, req.SubmissionID); err != nil {
+		return fmt.Errorf("attach regrade request submission: %w", err)
+	} else if req.Transitions, err = findRegradeTransitions(ctx, tx, req.ID); err != nil {
+		return fmt.Errorf("attach regrade request transitions: %w", err)
+	}
+	return nil
+}
+
+// publishRegradeRequestChanged notifies the requesting student of a status change.
+func publishRegradeRequestChanged(tx *Tx, req *ocs.RegradeRequest, fromStatus string) {
+	tx.publishEvent(req.StudentID, ocs.Event{
+		Type: ocs.EventTypeRegradeRequestChanged,
+		Payload: &ocs.RegradeRequestChangedPayload{
+			ID:         req.ID,
+			CourseID:   req.CourseID,
+			FromStatus: fromStatus,
+			Status:     req.Status,
+			Reply:      req.Reply,
+		},
+	})
+}
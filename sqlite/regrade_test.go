@@ -0,0 +1,247 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/mock"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestRegradeService_CreateRegradeRequest(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewRegradeService(db)
+
+		fx := MustCreateGradedSubmission(t, db)
+
+		var events []ocs.Event
+		db.EventService = &mock.EventService{PublishEventFn: func(studentID int, event ocs.Event) {
+			events = append(events, event)
+		}}
+
+		req := &ocs.RegradeRequest{SubmissionID: fx.Submission.ID, Justification: "Question 2 was correct."}
+		if err := s.CreateRegradeRequest(fx.StudentCtx, req); err != nil {
+			t.Fatal(err)
+		} else if got, want := req.Status, ocs.RegradeStatusOpen; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if got, want := req.CourseID, fx.Course.ID; got != want {
+			t.Fatalf("CourseID=%v, want %v", got, want)
+		} else if got, want := len(req.Transitions), 1; got != want {
+			t.Fatalf("len(Transitions)=%v, want %v", got, want)
+		}
+
+		if got, want := len(events), 1; got != want {
+			t.Fatalf("len(events)=%v, want %v", got, want)
+		} else if got, want := events[0].Type, ocs.EventTypeRegradeRequestChanged; got != want {
+			t.Fatalf("Type=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrNotOwner", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewRegradeService(db)
+
+		fx := MustCreateGradedSubmission(t, db)
+
+		if err := s.CreateRegradeRequest(fx.InstructorCtx, &ocs.RegradeRequest{SubmissionID: fx.Submission.ID, Justification: "X"}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrAlreadyOpen", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewRegradeService(db)
+
+		fx := MustCreateGradedSubmission(t, db)
+		MustCreateRegradeRequest(t, fx.StudentCtx, db, &ocs.RegradeRequest{SubmissionID: fx.Submission.ID, Justification: "X"})
+
+		if err := s.CreateRegradeRequest(fx.StudentCtx, &ocs.RegradeRequest{SubmissionID: fx.Submission.ID, Justification: "Y"}); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrJustificationRequired", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewRegradeService(db)
+
+		fx := MustCreateGradedSubmission(t, db)
+
+		if err := s.CreateRegradeRequest(fx.StudentCtx, &ocs.RegradeRequest{SubmissionID: fx.Submission.ID}); err == nil {
+			t.Fatal("expected error")
+		} else if ocs.ErrorCode(err) != ocs.EINVALID || ocs.ErrorMessage(err) != `Justification required.` {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestRegradeService_ResolveRegradeRequest(t *testing.T) {
+	t.Run("Accepted", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewRegradeService(db)
+
+		fx := MustCreateGradedSubmission(t, db)
+		req := MustCreateRegradeRequest(t, fx.StudentCtx, db, &ocs.RegradeRequest{SubmissionID: fx.Submission.ID, Justification: "X"})
+
+		var published []int
+		db.EventService = &mock.EventService{PublishEventFn: func(studentID int, event ocs.Event) {
+			if event.Type == ocs.EventTypeRegradeRequestChanged {
+				published = append(published, studentID)
+			}
+		}}
+
+		grade := 9
+		other, err := s.ResolveRegradeRequest(fx.InstructorCtx, req.ID, ocs.RegradeResolution{
+			Status: ocs.RegradeStatusAccepted,
+			Reply:  "You are right.",
+			Grade:  &grade,
+		})
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := other.Status, ocs.RegradeStatusAccepted; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if got, want := other.ReviewerID, fx.Instructor.ID; got != want {
+			t.Fatalf("ReviewerID=%v, want %v", got, want)
+		} else if got, want := len(other.Transitions), 2; got != want {
+			t.Fatalf("len(Transitions)=%v, want %v", got, want)
+		} else if tr := other.Transitions[1]; tr.FromStatus != ocs.RegradeStatusOpen || tr.ToStatus != ocs.RegradeStatusAccepted {
+			t.Fatalf("unexpected transition: %#v", tr)
+		} else if other.Submission.Grade == nil || *other.Submission.Grade != grade {
+			t.Fatalf("Grade=%v, want %v", other.Submission.Grade, grade)
+		}
+
+		if got, want := published, []int{fx.Student.ID}; len(got) != 1 || got[0] != want[0] {
+			t.Fatalf("published=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ByTA", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewRegradeService(db)
+
+		fx := MustCreateGradedSubmission(t, db)
+		ta, taCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "tia", Email: "tia@email.com"})
+		MustCreateEnrollment(t, fx.InstructorCtx, db, &ocs.Enrollment{CourseID: fx.Course.ID, StudentID: ta.ID, Role: ocs.EnrollmentRoleTA})
+		req := MustCreateRegradeRequest(t, fx.StudentCtx, db, &ocs.RegradeRequest{SubmissionID: fx.Submission.ID, Justification: "X"})
+
+		if other, err := s.ResolveRegradeRequest(taCtx, req.ID, ocs.RegradeResolution{Status: ocs.RegradeStatusRejected, Reply: "No."}); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.Status, ocs.RegradeStatusRejected; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewRegradeService(db)
+
+		fx := MustCreateGradedSubmission(t, db)
+		req := MustCreateRegradeRequest(t, fx.StudentCtx, db, &ocs.RegradeRequest{SubmissionID: fx.Submission.ID, Justification: "X"})
+
+		if _, err := s.ResolveRegradeRequest(fx.StudentCtx, req.ID, ocs.RegradeResolution{Status: ocs.RegradeStatusAccepted, Reply: "Yes."}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrAlreadyResolved", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewRegradeService(db)
+
+		fx := MustCreateGradedSubmission(t, db)
+		req := MustCreateRegradeRequest(t, fx.StudentCtx, db, &ocs.RegradeRequest{SubmissionID: fx.Submission.ID, Justification: "X"})
+
+		if _, err := s.ResolveRegradeRequest(fx.InstructorCtx, req.ID, ocs.RegradeResolution{Status: ocs.RegradeStatusRejected, Reply: "No."}); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.ResolveRegradeRequest(fx.InstructorCtx, req.ID, ocs.RegradeResolution{Status: ocs.RegradeStatusAccepted, Reply: "Yes."}); err == nil {
+			t.Fatal("expected error")
+		} else if ocs.ErrorCode(err) != ocs.ECONFLICT || ocs.ErrorMessage(err) != `Regrade request is already rejected.` {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestRegradeService_FindRegradeRequests(t *testing.T) {
+	t.Run("CourseQueue", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewRegradeService(db)
+
+		fx := MustCreateGradedSubmission(t, db)
+		req := MustCreateRegradeRequest(t, fx.StudentCtx, db, &ocs.RegradeRequest{SubmissionID: fx.Submission.ID, Justification: "X"})
+
+		status := ocs.RegradeStatusOpen
+		if a, n, err := s.FindRegradeRequests(fx.InstructorCtx, ocs.RegradeRequestFilter{CourseID: &fx.Course.ID, Status: &status}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := a[0].ID, req.ID; got != want {
+			t.Fatalf("ID=%v, want %v", got, want)
+		}
+
+		status = ocs.RegradeStatusAccepted
+		if _, n, err := s.FindRegradeRequests(fx.InstructorCtx, ocs.RegradeRequestFilter{CourseID: &fx.Course.ID, Status: &status}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("OutsiderSeesNothing", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewRegradeService(db)
+
+		fx := MustCreateGradedSubmission(t, db)
+		MustCreateRegradeRequest(t, fx.StudentCtx, db, &ocs.RegradeRequest{SubmissionID: fx.Submission.ID, Justification: "X"})
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "eve", Email: "eve@email.com"})
+
+		if _, n, err := s.FindRegradeRequests(ctx, ocs.RegradeRequestFilter{CourseID: &fx.Course.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+}
+
+// GradedSubmissionFixture is a course with one enrolled student whose
+// submission has been graded by the instructor.
+type GradedSubmissionFixture struct {
+	Instructor    *ocs.Student
+	InstructorCtx context.Context
+	Student       *ocs.Student
+	StudentCtx    context.Context
+	Course        *ocs.Course
+	Assignment    *ocs.Assignment
+	Submission    *ocs.Submission
+}
+
+func MustCreateGradedSubmission(tb testing.TB, db *sqlite.DB) *GradedSubmissionFixture {
+	tb.Helper()
+
+	var fx GradedSubmissionFixture
+	fx.Instructor, fx.InstructorCtx = MustCreateStudent(tb, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+	fx.Student, fx.StudentCtx = MustCreateStudent(tb, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+	fx.Course = MustCreateCourse(tb, fx.InstructorCtx, db, &ocs.Course{Title: "Go 101"})
+	MustCreateEnrollment(tb, fx.StudentCtx, db, &ocs.Enrollment{CourseID: fx.Course.ID, StudentID: fx.Student.ID})
+	fx.Assignment = MustCreateAssignment(tb, fx.InstructorCtx, db, &ocs.Assignment{CourseID: fx.Course.ID, Title: "HW1", MaxPoints: 10})
+	fx.Submission = MustCreateSubmission(tb, fx.StudentCtx, db, &ocs.Submission{AssignmentID: fx.Assignment.ID, Body: "answer"})
+	fx.Submission = MustGradeSubmission(tb, fx.InstructorCtx, db, fx.Submission.ID, ocs.SubmissionGrade{Grade: 6})
+	return &fx
+}
+
+func MustCreateRegradeRequest(tb testing.TB, ctx context.Context, db *sqlite.DB, req *ocs.RegradeRequest) *ocs.RegradeRequest {
+	tb.Helper()
+	if err := sqlite.NewRegradeService(db).CreateRegradeRequest(ctx, req); err != nil {
+		tb.Fatal(err)
+	}
+	return req
+}
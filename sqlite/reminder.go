@@ -0,0 +1,281 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+// DefaultReminderInterval is how often the reminder scheduler looks for
+// upcoming deadlines.
+const DefaultReminderInterval = time.Minute
+
+// ReminderScheduler reminds students of assignments they have not submitted,
+// at the hours before the due date set by each course. Reminders are
+// published as EventTypeDeadlineReminder events and emailed.
+//
+// Each reminder is recorded in the same transaction that queues it, so a
+// restarted scheduler does not remind a student twice. A scheduler that was
+// down across several rules only sends the latest one.
+type ReminderScheduler struct {
+	db *DB
+
+	Interval time.Duration
+}
+
+func NewReminderScheduler(db *DB) *ReminderScheduler {
+	return &ReminderScheduler{
+		db:       db,
+		Interval: DefaultReminderInterval,
+	}
+}
+
+// Open starts sending reminders in the background. Sending stops when the
+// database is closed.
+func (s *ReminderScheduler) Open() {
+	go s.run()
+}
+
+func (s *ReminderScheduler) run() {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.db.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := s.SendReminders(s.db.ctx); err != nil {
+			log.Printf("reminder scheduler error: %s", err)
+		}
+	}
+}
+
+// SendReminders sends the reminders that are due and returns how many were
+// sent.
+func (s *ReminderScheduler) SendReminders(ctx context.Context) (int, error) {
+	ctx = withAllOrganizations(ctx)
+
+	assignments, err := s.findUpcomingAssignments(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var sent int
+	for _, assignment := range assignments {
+		n, err := s.sendAssignmentReminders(ctx, assignment)
+		if err != nil {
+			return sent, err
+		}
+		sent += n
+	}
+	return sent, nil
+}
+
+// findUpcomingAssignments returns the assignments due within the reminder
+// horizon of courses with reminders enabled, with their course attached.
+func (s *ReminderScheduler) findUpcomingAssignments(ctx context.Context) ([]*ocs.Assignment, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	horizon := tx.now.Add(ocs.MaxReminderHours * time.Hour)
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      a.id,
+      a.title,
+      a.due_at,
+      c.id,
+      c.title,
+      c.reminder_hours
+    FROM assignments a
+    INNER JOIN courses c ON c.id = a.course_id
+    WHERE c.reminders_disabled = 0 AND c.deleted_at IS NULL AND a.due_at > ? AND a.due_at <= ?
+    ORDER BY a.due_at ASC, a.id ASC
+  `,
+		(*NullTime)(&tx.now),
+		(*NullTime)(&horizon),
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	assignments := make([]*ocs.Assignment, 0)
+	for rows.Next() {
+		var assignment ocs.Assignment
+		var course ocs.Course
+		var dueAt time.Time
+		var hours string
+		if err := rows.Scan(
+			&assignment.ID,
+			&assignment.Title,
+			(*NullTime)(&dueAt),
+			&course.ID,
+			&course.Title,
+			&hours,
+		); err != nil {
+			return nil, err
+		} else if err := json.Unmarshal([]byte(hours), &course.ReminderHours); err != nil {
+			return nil, err
+		}
+
+		assignment.CourseID, assignment.Course, assignment.DueAt = course.ID, &course, &dueAt
+		assignments = append(assignments, &assignment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+
+	return assignments, nil
+}
+
+// sendAssignmentReminders reminds the students of the assignment's course
+// who have not submitted it, for the latest rule that has come due.
+func (s *ReminderScheduler) sendAssignmentReminders(ctx context.Context, assignment *ocs.Assignment) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	hours := dueReminderHours(assignment.Course.ReminderHours, *assignment.DueAt, tx.now)
+	if hours == 0 {
+		return 0, nil
+	}
+
+	studentIDs, err := findStudentsToRemind(ctx, tx, assignment, hours)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, studentID := range studentIDs {
+		if err := remindStudent(ctx, tx, assignment, studentID, hours); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(studentIDs), tx.Commit()
+}
+
+// dueReminderHours returns the smallest rule that has come due at now, or
+// zero if none has.
+func dueReminderHours(rules []int, dueAt, now time.Time) (hours int) {
+	for _, h := range rules {
+		if !dueAt.Add(-time.Duration(h)*time.Hour).After(now) && (hours == 0 || h < hours) {
+			hours = h
+		}
+	}
+	return hours
+}
+
+// findStudentsToRemind returns the course's students who have neither
+// submitted the assignment, alone or through their group, nor been reminded
+// of it at hours or closer to the due date.
+func findStudentsToRemind(ctx context.Context, tx *Tx, assignment *ocs.Assignment, hours int) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT e.student_id
+    FROM enrollments e
+    WHERE e.course_id = ? AND e.role = ?
+      AND e.student_id IN (SELECT id FROM students WHERE deleted_at IS NULL AND erased_at IS NULL)
+      AND NOT EXISTS (
+        SELECT 1 FROM submissions s
+        WHERE s.assignment_id = ?
+          AND (s.student_id = e.student_id OR s.group_id IN (
+            SELECT group_id FROM group_members WHERE student_id = e.student_id AND left_at IS NULL
+          ))
+      )
+      AND NOT EXISTS (
+        SELECT 1 FROM deadline_reminders r
+        WHERE r.assignment_id = ? AND r.student_id = e.student_id AND r.hours_before <= ?
+      )
+    ORDER BY e.student_id ASC
+  `,
+		assignment.CourseID,
+		ocs.EnrollmentRoleStudent,
+		assignment.ID,
+		assignment.ID,
+		hours,
+	)
+	if err != nil {
+		return nil, FormatError(err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FormatError(err)
+	}
+	return ids, nil
+}
+
+// remindStudent records the reminder, then publishes and emails it. The
+// due date is shown in the student's time zone.
+func remindStudent(ctx context.Context, tx *Tx, assignment *ocs.Assignment, studentID, hours int) error {
+	if _, err := tx.ExecContext(ctx, `
+    INSERT INTO deadline_reminders (
+      assignment_id,
+      student_id,
+      hours_before,
+      created_at
+    )
+    VALUES (?, ?, ?, ?)
+  `,
+		assignment.ID,
+		studentID,
+		hours,
+		(*NullTime)(&tx.now),
+	); err != nil {
+		return FormatError(err)
+	}
+
+	student, err := findStudentByID(ctx, tx, studentID)
+	if err != nil {
+		return err
+	}
+
+	// The deadline is shown in the student's time zone.
+	local := *assignment
+	dueAt := assignment.DueAt.In(student.Location())
+	local.DueAt = &dueAt
+
+	tx.publishEvent(studentID, ocs.Event{
+		Type: ocs.EventTypeDeadlineReminder,
+		Payload: &ocs.DeadlineReminderPayload{
+			AssignmentID: assignment.ID,
+			CourseID:     assignment.CourseID,
+			Title:        assignment.Title,
+			DueAt:        dueAt,
+			HoursBefore:  hours,
+		},
+	})
+
+	return enqueueEmail(ctx, tx, ocs.DeadlineReminderEmail, ocs.EmailData{
+		Student:    student,
+		Course:     assignment.Course,
+		Assignment: &local,
+	})
+}
+
+// reminderHours encodes a course's reminder rules for storage.
+func reminderHours(hours []int) string {
+	if hours == nil {
+		return "[]"
+	}
+	buf, _ := json.Marshal(hours)
+	return string(buf)
+}
@@ -0,0 +1,104 @@
+package sqlite_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/mock"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestReminderScheduler_SendReminders(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		var reminded []int
+		db.EventService = &mock.EventService{PublishEventFn: func(studentID int, event ocs.Event) {
+			if event.Type == ocs.EventTypeDeadlineReminder {
+				reminded = append(reminded, studentID)
+			}
+		}}
+
+		_, instructorCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		bob, bobCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		cat, catCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "cat", Email: "cat@email.com", TimeZone: "Africa/Johannesburg"})
+		course := MustCreateCourse(t, instructorCtx, db, &ocs.Course{Title: "Go 101"})
+		MustCreateEnrollment(t, bobCtx, db, &ocs.Enrollment{CourseID: course.ID, StudentID: bob.ID})
+		MustCreateEnrollment(t, catCtx, db, &ocs.Enrollment{CourseID: course.ID, StudentID: cat.ID})
+
+		dueAt := now.Add(47 * time.Hour)
+		assignment := MustCreateAssignment(t, instructorCtx, db, &ocs.Assignment{CourseID: course.ID, Title: "Essay", MaxPoints: 10, DueAt: &dueAt})
+		MustCreateSubmission(t, bobCtx, db, &ocs.Submission{AssignmentID: assignment.ID, StudentID: bob.ID, Body: "done"})
+		MustDeliverEmails(t, db)
+
+		// Only the student without a submission is reminded, once.
+		for _, want := range []int{1, 0} {
+			if n, err := sqlite.NewReminderScheduler(db).SendReminders(context.Background()); err != nil {
+				t.Fatal(err)
+			} else if got := n; got != want {
+				t.Fatalf("n=%v, want %v", got, want)
+			}
+		}
+
+		if sent := MustDeliverEmails(t, db); len(sent) != 1 {
+			t.Fatalf("len=%v, want 1", len(sent))
+		} else if got, want := sent[0].To, "cat@email.com"; got != want {
+			t.Fatalf("To=%v, want %v", got, want)
+		} else if !strings.Contains(sent[0].Text, "Wed Oct 21 13:00 SAST") {
+			t.Fatalf("unexpected text: %s", sent[0].Text)
+		}
+
+		now = dueAt.Add(-90 * time.Minute)
+		if n, err := sqlite.NewReminderScheduler(db).SendReminders(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		if got, want := reminded, []int{cat.ID, cat.ID}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Fatalf("reminded=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		_, instructorCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		bob, bobCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "bob", Email: "bob@email.com"})
+		course := MustCreateCourse(t, instructorCtx, db, &ocs.Course{Title: "Go 101"})
+		MustCreateEnrollment(t, bobCtx, db, &ocs.Enrollment{CourseID: course.ID, StudentID: bob.ID})
+
+		dueAt := time.Now().Add(time.Hour)
+		MustCreateAssignment(t, instructorCtx, db, &ocs.Assignment{CourseID: course.ID, Title: "Essay", MaxPoints: 10, DueAt: &dueAt})
+
+		disabled := true
+		if _, err := sqlite.NewCourseService(db).UpdateCourse(instructorCtx, course.ID, ocs.CourseUpdate{RemindersDisabled: &disabled}); err != nil {
+			t.Fatal(err)
+		} else if n, err := sqlite.NewReminderScheduler(db).SendReminders(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrInvalidReminderHours", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		_, instructorCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		course := MustCreateCourse(t, instructorCtx, db, &ocs.Course{Title: "Go 101"})
+
+		hours := []int{24, 0}
+		if _, err := sqlite.NewCourseService(db).UpdateCourse(instructorCtx, course.ID, ocs.CourseUpdate{ReminderHours: &hours}); ocs.ErrorCode(err) != ocs.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
@@ -2,15 +2,20 @@ package sqlite
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
 	"database/sql/driver"
 	"embed"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/maliByatzes/ocs"
@@ -24,18 +29,35 @@ var (
 		Name: "ocs_db_students",
 		Help: "The total number of students",
 	})
+
+	jobCountGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ocs_db_jobs",
+		Help: "The number of jobs by status",
+	}, []string{"status"})
 )
 
 //go:embed migration/*.sql
 var migrationFS embed.FS
 
+// BusyTimeout is how long a connection waits for another one's write to
+// finish before failing with "database is locked".
+const BusyTimeout = 5 * time.Second
+
+// DefaultRetention is how long deleted students and courses are kept, and
+// can be restored, before they are purged.
+const DefaultRetention = 30 * 24 * time.Hour
+
 type DB struct {
 	db           *sql.DB
 	ctx          context.Context
 	cancel       func()
+	wg           sync.WaitGroup
 	DSN          string
 	EventService ocs.EventService
 	Now          func() time.Time
+
+	// How long deleted rows are kept before they are purged.
+	Retention time.Duration
 }
 
 func NewDB(dsn string) *DB {
@@ -43,6 +65,7 @@ func NewDB(dsn string) *DB {
 		DSN:          dsn,
 		Now:          time.Now,
 		EventService: ocs.NopEventService(),
+		Retention:    DefaultRetention,
 	}
 
 	db.ctx, db.cancel = context.WithCancel(context.Background())
@@ -60,7 +83,18 @@ func (db *DB) Open() (err error) {
 		}
 	}
 
-	if db.db, err = sql.Open("sqlite3", db.DSN); err != nil {
+	// Connection settings go in the DSN so that every connection in the
+	// pool gets them. Transactions begin IMMEDIATE, taking the write lock
+	// up front: a transaction that reads and then writes would otherwise
+	// fail at once if another connection wrote in between, rather than
+	// wait for the busy timeout.
+	sep := "?"
+	if strings.Contains(db.DSN, "?") {
+		sep = "&"
+	}
+	dsn := fmt.Sprintf("%s%s_busy_timeout=%d&_txlock=immediate&_foreign_keys=on", db.DSN, sep, BusyTimeout.Milliseconds())
+
+	if db.db, err = sql.Open("sqlite3", dsn); err != nil {
 		return err
 	}
 
@@ -68,10 +102,6 @@ func (db *DB) Open() (err error) {
 		return fmt.Errorf("enable wal: %w", err)
 	}
 
-	if _, err := db.db.Exec(`PRAGMA foreign_keys = ON;`); err != nil {
-		return fmt.Errorf("foreign keys pragma: %w", err)
-	}
-
 	if err := db.migrate(); err != nil {
 		return err
 	}
@@ -131,6 +161,9 @@ func (db *DB) migrateFile(name string) error {
 func (db *DB) Close() error {
 	db.cancel()
 
+	// Wait for background workers, such as the job queue's, to stop.
+	db.wg.Wait()
+
 	if db.db != nil {
 		return db.db.Close()
 	}
@@ -145,9 +178,12 @@ func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	}
 
 	return &Tx{
-		Tx:  tx,
-		db:  db,
-		now: db.Now().UTC().Truncate(time.Second),
+		Tx:      tx,
+		db:      db,
+		now:     db.Now().UTC().Truncate(time.Second),
+		orgID:   ocs.OrganizationIDFromContext(ctx),
+		scoped:  ocs.HasOrganizationScope(ctx) || isAllOrganizations(ctx),
+		allOrgs: isAllOrganizations(ctx),
 	}, nil
 }
 
@@ -176,18 +212,124 @@ func (db *DB) updateStats(ctx context.Context) error {
 	defer tx.Rollback()
 
 	var n int
-	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM students;`).Scan(&n); err != nil {
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM students WHERE deleted_at IS NULL;`).Scan(&n); err != nil {
 		return fmt.Errorf("students count: %w", err)
 	}
 	studentCountGauge.Set(float64(n))
 
-	return nil
+	rows, err := tx.QueryContext(ctx, `SELECT status, COUNT(*) FROM jobs GROUP BY status;`)
+	if err != nil {
+		return fmt.Errorf("jobs count: %w", err)
+	}
+	defer rows.Close()
+
+	jobCountGauge.Reset()
+	for rows.Next() {
+		var status string
+		if err := rows.Scan(&status, &n); err != nil {
+			return fmt.Errorf("jobs count: %w", err)
+		}
+		jobCountGauge.WithLabelValues(status).Set(float64(n))
+	}
+
+	return rows.Err()
 }
 
 type Tx struct {
 	*sql.Tx
-	db  *DB
-	now time.Time
+	db     *DB
+	now    time.Time
+	events []txEvent
+
+	// The tenant data the transaction may read, fixed from the context
+	// when it begins; see scope. Rows the transaction creates belong to
+	// orgID, where zero is the default site.
+	orgID   int
+	scoped  bool // false if the context was never scoped
+	allOrgs bool // background work, which reads every organization
+}
+
+type txEvent struct {
+	studentID int
+	event     ocs.Event
+}
+
+// Commit commits the transaction and then publishes the events queued on it,
+// so that subscribers never see changes that were rolled back.
+func (tx *Tx) Commit() error {
+	if err := tx.Tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, e := range tx.events {
+		tx.db.EventService.PublishEvent(e.studentID, e.event)
+	}
+	tx.events = nil
+
+	return nil
+}
+
+// publishEvent queues an event to be published once the transaction commits.
+// Events happen at the time of the transaction.
+func (tx *Tx) publishEvent(studentID int, event ocs.Event) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = tx.now
+	}
+	tx.events = append(tx.events, txEvent{studentID: studentID, event: event})
+}
+
+// eventKey identifies an event by its type and the change it reports, so that
+// handlers can recognize an event they have already processed. Events about
+// the same change share a key even if their payloads differ; for example, every
+// grading of a submission has the same key, so points for passing it are only
+// awarded once. Events that do not report a single change, such as unread
+// counts, are identified by their whole payload.
+func eventKey(event ocs.Event) (string, error) {
+	var id string
+	switch p := event.Payload.(type) {
+	case *ocs.RegradeRequestChangedPayload:
+		id = fmt.Sprintf("%d:%s:%s", p.ID, p.FromStatus, p.Status)
+	case *ocs.LearningPathCompletedPayload:
+		id = fmt.Sprint(p.LearningPathID)
+	case *ocs.SubmissionCreatedPayload:
+		id = fmt.Sprint(p.ID)
+	case *ocs.SubmissionGradedPayload:
+		id = fmt.Sprint(p.ID)
+	case *ocs.DeadlineReminderPayload:
+		id = fmt.Sprintf("%d:%d", p.AssignmentID, p.HoursBefore)
+	case *ocs.ExportReadyPayload:
+		id = fmt.Sprint(p.ExportID)
+	case *ocs.BadgeEarnedPayload:
+		if p.Award != nil {
+			id = fmt.Sprint(p.Award.ID)
+		}
+	case *ocs.EnrollmentCompletedPayload:
+		id = fmt.Sprint(p.ID)
+	case *ocs.OrderPaidPayload:
+		id = fmt.Sprint(p.ID)
+	case *ocs.RefundIssuedPayload:
+		if p.Refund != nil {
+			id = fmt.Sprint(p.Refund.ID)
+		}
+	case *ocs.MessageCreatedPayload:
+		if p.Message != nil {
+			id = fmt.Sprint(p.Message.ID)
+		}
+	case *ocs.ConversationReadPayload:
+		id = fmt.Sprintf("%d:%d:%d", p.ConversationID, p.StudentID, p.LastReadMessageID)
+	case *ocs.WebhookDisabledPayload:
+		id = fmt.Sprintf("%d:%d", p.WebhookID, p.DisabledAt.Unix())
+	}
+	if id != "" {
+		return event.Type + ":" + id, nil
+	}
+
+	buf, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), nil
 }
 
 type NullTime time.Time
@@ -210,6 +352,90 @@ func (n *NullTime) Value() (driver.Value, error) {
 	return (*time.Time)(n).UTC().Format(time.RFC3339), nil
 }
 
+// hashToken returns the hash stored for a token sent in a link, so that the
+// database alone cannot be used to follow the link.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// linkTokens maps the tables of single-use tokens sent in links to the
+// entity type of their rows in the audit log.
+var linkTokens = map[string]string{
+	"magic_links":         "magic_link",
+	"email_verifications": "email_verification",
+	"password_resets":     "password_reset",
+}
+
+// linkToken is a row of one of the linkTokens tables, as it is audited. The
+// token's hash is left out.
+type linkToken struct {
+	ID        int        `json:"id"`
+	StudentID int        `json:"studentID"`
+	Email     string     `json:"email"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// createLinkToken audits a token just inserted into table by result.
+func createLinkToken(ctx context.Context, tx *Tx, table string, result sql.Result, token *linkToken) error {
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	token.ID = int(id)
+	return audit(ctx, tx, ocs.AuditActionCreate, linkTokens[table], token.ID, nil, token)
+}
+
+// revokeLinkTokens deletes the student's unused tokens in table, so that only
+// the latest link sent works.
+func revokeLinkTokens(ctx context.Context, tx *Tx, table string, studentID int) error {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT id, email, expires_at, created_at
+    FROM `+table+`
+    WHERE student_id = ? AND used_at IS NULL
+  `,
+		studentID,
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+	defer rows.Close()
+
+	var tokens []*linkToken
+	for rows.Next() {
+		token := &linkToken{StudentID: studentID}
+		if err := rows.Scan(&token.ID, &token.Email, (*NullTime)(&token.ExpiresAt), (*NullTime)(&token.CreatedAt)); err != nil {
+			return err
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return FormatError(err)
+	}
+
+	for _, token := range tokens {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE id = ?`, token.ID); err != nil {
+			return FormatError(err)
+		} else if err := audit(ctx, tx, ocs.AuditActionDelete, linkTokens[table], token.ID, token, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// useLinkToken marks a token in table as used.
+func useLinkToken(ctx context.Context, tx *Tx, table string, token *linkToken) error {
+	prev := *token
+	token.UsedAt = &tx.now
+
+	if _, err := tx.ExecContext(ctx, `UPDATE `+table+` SET used_at = ? WHERE id = ?`, (*NullTime)(token.UsedAt), token.ID); err != nil {
+		return FormatError(err)
+	}
+	return audit(ctx, tx, ocs.AuditActionUpdate, linkTokens[table], token.ID, &prev, token)
+}
+
 func FormatLimitOffset(limit, offset int) string {
 	if limit > 0 && offset > 0 {
 		return fmt.Sprintf(`LIMIT %d OFFSET %d`, limit, offset)
@@ -1,11 +1,13 @@
 package sqlite_test
 
 import (
+	"context"
 	"flag"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/maliByatzes/ocs"
 	"github.com/maliByatzes/ocs/sqlite"
 )
 
@@ -36,9 +38,32 @@ func MustOpenDB(tb testing.TB) *sqlite.DB {
 	return db
 }
 
+// DefaultSiteContext returns the context of an anonymous request to the
+// default site. Services read no tenant data through an unscoped context.
+func DefaultSiteContext() context.Context {
+	return ocs.NewContextWithOrganization(context.Background(), nil)
+}
+
 func MustCloseDB(tb testing.TB, db *sqlite.DB) {
 	tb.Helper()
 	if err := db.Close(); err != nil {
 		tb.Fatal(err)
 	}
 }
+
+// MustExec runs a statement outside of any service, e.g. to make later
+// writes fail.
+func MustExec(tb testing.TB, db *sqlite.DB, query string) {
+	tb.Helper()
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(query); err != nil {
+		tb.Fatal(err)
+	} else if err := tx.Commit(); err != nil {
+		tb.Fatal(err)
+	}
+}
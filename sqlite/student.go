@@ -5,9 +5,11 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/maliByatzes/ocs"
 )
@@ -34,11 +36,23 @@ func (s *StudentService) FindStudentByID(ctx context.Context, id int) (*ocs.Stud
 		return nil, err
 	} else if err := attachStudentsAuths(ctx, tx, student); err != nil {
 		return nil, err
+	} else if err := attachStudentBadges(ctx, tx, student); err != nil {
+		return nil, err
 	}
 
 	return student, nil
 }
 
+func (s *StudentService) FindStudentProfile(ctx context.Context, id int) (*ocs.StudentProfile, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findStudentProfile(ctx, tx, id)
+}
+
 func (s *StudentService) FindStudents(ctx context.Context, filter ocs.StudentFilter) ([]*ocs.Student, int, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -59,6 +73,8 @@ func (s *StudentService) CreateStudent(ctx context.Context, student *ocs.Student
 		return err
 	} else if err := attachStudentsAuths(ctx, tx, student); err != nil {
 		return err
+	} else if err := attachStudentBadges(ctx, tx, student); err != nil {
+		return err
 	}
 
 	return tx.Commit()
@@ -76,6 +92,8 @@ func (s *StudentService) UpdateStudent(ctx context.Context, id int, upd ocs.Stud
 		return student, err
 	} else if err := attachStudentsAuths(ctx, tx, student); err != nil {
 		return student, err
+	} else if err := attachStudentBadges(ctx, tx, student); err != nil {
+		return student, err
 	} else if err := tx.Commit(); err != nil {
 		return student, err
 	}
@@ -97,9 +115,39 @@ func (s *StudentService) DeleteStudent(ctx context.Context, id int) error {
 	return tx.Commit()
 }
 
+func (s *StudentService) RestoreStudent(ctx context.Context, id int) (*ocs.Student, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	student, err := restoreStudent(ctx, tx, id)
+	if err != nil {
+		return student, err
+	} else if err := attachStudentsAuths(ctx, tx, student); err != nil {
+		return student, err
+	} else if err := attachStudentBadges(ctx, tx, student); err != nil {
+		return student, err
+	} else if err := tx.Commit(); err != nil {
+		return student, err
+	}
+
+	return student, nil
+}
+
 func createStudent(ctx context.Context, tx *Tx, student *ocs.Student) error {
 	student.CreatedAt = tx.now
 	student.UpdatedAt = student.CreatedAt
+	if student.EmailDigest == "" {
+		student.EmailDigest = ocs.EmailDigestDaily
+	}
+	if student.Links == nil {
+		student.Links = []string{}
+	}
+	if student.Visibility == nil {
+		student.Visibility = map[string]string{}
+	}
 
 	if err := student.Validate(); err != nil {
 		return err
@@ -116,14 +164,34 @@ func createStudent(ctx context.Context, tx *Tx, student *ocs.Student) error {
       name,
       email,
       api_key,
+      admin,
+      email_digest,
+      time_zone,
+      locale,
+      display_name,
+      bio,
+      pronouns,
+      links,
+      visibility,
+      email_verified,
       created_at,
       updated_at
     )
-    VALUES (?, ?, ?, ?, ?)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
   `,
 		student.Name,
 		student.Email,
 		student.APIKey,
+		student.Admin,
+		student.EmailDigest,
+		student.TimeZone,
+		student.Locale,
+		student.DisplayName,
+		student.Bio,
+		student.Pronouns,
+		profileLinks(student.Links),
+		profileVisibility(student.Visibility),
+		student.EmailVerified,
 		(*NullTime)(&student.CreatedAt),
 		(*NullTime)(&student.UpdatedAt),
 	)
@@ -138,7 +206,29 @@ func createStudent(ctx context.Context, tx *Tx, student *ocs.Student) error {
 	}
 	student.ID = int(id)
 
-	return nil
+	if err := audit(ctx, tx, ocs.AuditActionCreate, "student", student.ID, nil, student); err != nil {
+		return err
+	}
+
+	// Students created within an organization join it.
+	if tx.orgID != 0 {
+		if err := insertOrganizationMember(ctx, tx, &ocs.OrganizationMember{
+			OrganizationID: tx.orgID,
+			StudentID:      student.ID,
+			Role:           ocs.OrganizationRoleMember,
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Addresses a provider has already verified need not be verified again.
+	if verificationEmail(student) != "" {
+		if err := enqueueEmailVerification(ctx, tx, student.ID); err != nil {
+			return err
+		}
+	}
+
+	return enqueueEmail(ctx, tx, ocs.WelcomeEmail, ocs.EmailData{Student: student})
 }
 
 func findStudentByID(ctx context.Context, tx *Tx, id int) (*ocs.Student, error) {
@@ -151,6 +241,21 @@ func findStudentByID(ctx context.Context, tx *Tx, id int) (*ocs.Student, error)
 	return a[0], nil
 }
 
+// findAnyStudentByID finds a student even if they have been deleted, so
+// that what they left behind can still show who made it.
+func findAnyStudentByID(ctx context.Context, tx *Tx, id int) (*ocs.Student, error) {
+	where, args := tx.scope(byStudent, "id")
+	where, args = append(where, "id = ?"), append(args, id)
+
+	a, _, err := queryStudents(ctx, tx, where, args, 0, 0)
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Student not found"}
+	}
+	return a[0], nil
+}
+
 func findStudentByEmail(ctx context.Context, tx *Tx, email string) (*ocs.Student, error) {
 	a, _, err := findStudents(ctx, tx, ocs.StudentFilter{Email: &email})
 	if err != nil {
@@ -161,8 +266,24 @@ func findStudentByEmail(ctx context.Context, tx *Tx, email string) (*ocs.Student
 	return a[0], nil
 }
 
+// findAnyStudentByEmail finds a student by email even if they have been deleted.
+func findAnyStudentByEmail(ctx context.Context, tx *Tx, email string) (*ocs.Student, error) {
+	where, args := tx.scope(byStudent, "id")
+	where, args = append(where, "email = ?"), append(args, email)
+
+	a, _, err := queryStudents(ctx, tx, where, args, 0, 0)
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Student not found"}
+	}
+	return a[0], nil
+}
+
+// findStudents leaves out deleted and erased students.
 func findStudents(ctx context.Context, tx *Tx, filter ocs.StudentFilter) (_ []*ocs.Student, n int, err error) {
-	where, args := []string{"1 = 1"}, []interface{}{}
+	where, args := tx.scope(byStudent, "id")
+	where = append(where, "deleted_at IS NULL", "erased_at IS NULL")
 	if v := filter.ID; v != nil {
 		where, args = append(where, "id = ?"), append(args, *v)
 	}
@@ -172,20 +293,39 @@ func findStudents(ctx context.Context, tx *Tx, filter ocs.StudentFilter) (_ []*o
 	if v := filter.APIKey; v != nil {
 		where, args = append(where, "api_key = ?"), append(args, *v)
 	}
+	return queryStudents(ctx, tx, where, args, filter.Limit, filter.Offset)
+}
 
+func queryStudents(ctx context.Context, tx *Tx, where []string, args []interface{}, limit, offset int) (_ []*ocs.Student, n int, err error) {
 	rows, err := tx.QueryContext(ctx, `
     SELECT
       id,
       name,
       email,
+      email_verified,
+      pending_email,
+      password_hash != '',
       api_key,
+      leaderboard_opt_out,
+      admin,
+      email_digest,
+      time_zone,
+      locale,
+      display_name,
+      bio,
+      pronouns,
+      links,
+      visibility,
+      avatar_updated_at,
       created_at,
       updated_at,
+      deleted_at,
+      erased_at,
       COUNT(*) OVER()
     FROM students
     WHERE `+strings.Join(where, " AND ")+`
     ORDER BY id ASC
-    `+FormatLimitOffset(filter.Limit, filter.Offset),
+    `+FormatLimitOffset(limit, offset),
 		args...,
 	)
 	if err != nil {
@@ -197,13 +337,31 @@ func findStudents(ctx context.Context, tx *Tx, filter ocs.StudentFilter) (_ []*o
 	for rows.Next() {
 		var email sql.NullString
 		var student ocs.Student
+		var links, visibility string
+		var avatarUpdatedAt, deletedAt, erasedAt NullTime
 		if err := rows.Scan(
 			&student.ID,
 			&student.Name,
 			&email,
+			&student.EmailVerified,
+			&student.PendingEmail,
+			&student.HasPassword,
 			&student.APIKey,
+			&student.LeaderboardOptOut,
+			&student.Admin,
+			&student.EmailDigest,
+			&student.TimeZone,
+			&student.Locale,
+			&student.DisplayName,
+			&student.Bio,
+			&student.Pronouns,
+			&links,
+			&visibility,
+			&avatarUpdatedAt,
 			(*NullTime)(&student.CreatedAt),
 			(*NullTime)(&student.UpdatedAt),
+			&deletedAt,
+			&erasedAt,
 			&n,
 		); err != nil {
 			return nil, 0, err
@@ -212,6 +370,20 @@ func findStudents(ctx context.Context, tx *Tx, filter ocs.StudentFilter) (_ []*o
 		if email.Valid {
 			student.Email = email.String
 		}
+		if err := json.Unmarshal([]byte(links), &student.Links); err != nil {
+			return nil, 0, fmt.Errorf("student links: %w", err)
+		} else if err := json.Unmarshal([]byte(visibility), &student.Visibility); err != nil {
+			return nil, 0, fmt.Errorf("student visibility: %w", err)
+		}
+		if v := (time.Time)(avatarUpdatedAt); !v.IsZero() {
+			student.AvatarUpdatedAt = &v
+		}
+		if v := (time.Time)(deletedAt); !v.IsZero() {
+			student.DeletedAt = &v
+		}
+		if v := (time.Time)(erasedAt); !v.IsZero() {
+			student.ErasedAt = &v
+		}
 
 		students = append(students, &student)
 	}
@@ -230,13 +402,66 @@ func updateStudent(ctx context.Context, tx *Tx, id int, upd ocs.StudentUpdate) (
 	} else if student.ID != ocs.StudentIDFromContext(ctx) {
 		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to update this student.")
 	}
+	prev := *student
 
 	if v := upd.Name; v != nil {
 		student.Name = *v
 	}
 
-	if v := upd.Email; v != nil {
-		student.Email = *v
+	// A new email is kept pending until it is verified. Changing back to
+	// the current email cancels the change.
+	if v := upd.Email; v != nil && *v != student.Email {
+		if other, err := findAnyStudentByEmail(ctx, tx, *v); err == nil && other.ID != student.ID {
+			return student, ocs.Errorf(ocs.ECONFLICT, "This email is already in use.")
+		} else if err != nil && ocs.ErrorCode(err) != ocs.ENOTFOUND {
+			return student, err
+		}
+		student.PendingEmail = *v
+	} else if v != nil {
+		student.PendingEmail = ""
+	}
+
+	if v := upd.LeaderboardOptOut; v != nil {
+		student.LeaderboardOptOut = *v
+	}
+
+	if v := upd.EmailDigest; v != nil {
+		student.EmailDigest = *v
+	}
+
+	if v := upd.TimeZone; v != nil {
+		student.TimeZone = *v
+	}
+
+	if v := upd.Locale; v != nil {
+		student.Locale = *v
+	}
+
+	if v := upd.DisplayName; v != nil {
+		student.DisplayName = *v
+	}
+
+	if v := upd.Bio; v != nil {
+		student.Bio = *v
+	}
+
+	if v := upd.Pronouns; v != nil {
+		student.Pronouns = *v
+	}
+
+	if v := upd.Links; v != nil {
+		student.Links = *v
+	}
+
+	if len(upd.Visibility) > 0 {
+		visibility := make(map[string]string, len(student.Visibility)+len(upd.Visibility))
+		for field, v := range student.Visibility {
+			visibility[field] = v
+		}
+		for field, v := range upd.Visibility {
+			visibility[field] = v
+		}
+		student.Visibility = visibility
 	}
 
 	student.UpdatedAt = tx.now
@@ -248,33 +473,157 @@ func updateStudent(ctx context.Context, tx *Tx, id int, upd ocs.StudentUpdate) (
 	if _, err := tx.ExecContext(ctx, `
     UPDATE students
     SET name = ?,
-        email = ?,
+        pending_email = ?,
+        leaderboard_opt_out = ?,
+        email_digest = ?,
+        time_zone = ?,
+        locale = ?,
+        display_name = ?,
+        bio = ?,
+        pronouns = ?,
+        links = ?,
+        visibility = ?,
         updated_at = ?
     WHERE id = ?
     `,
 		student.Name,
-		student.Email,
+		student.PendingEmail,
+		student.LeaderboardOptOut,
+		student.EmailDigest,
+		student.TimeZone,
+		student.Locale,
+		student.DisplayName,
+		student.Bio,
+		student.Pronouns,
+		profileLinks(student.Links),
+		profileVisibility(student.Visibility),
 		(*NullTime)(&student.UpdatedAt),
 		id,
 	); err != nil {
 		return student, FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "student", id, &prev, student); err != nil {
+		return student, err
+	}
+
+	if student.PendingEmail != "" && student.PendingEmail != prev.PendingEmail {
+		if err := enqueueEmailVerification(ctx, tx, student.ID); err != nil {
+			return student, err
+		}
 	}
 
 	return student, nil
 }
 
+// findStudentProfile returns the profile of a student as the current
+// student may see it. Admins see every field.
+func findStudentProfile(ctx context.Context, tx *Tx, id int) (*ocs.StudentProfile, error) {
+	student, err := findStudentByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := attachStudentBadges(ctx, tx, student); err != nil {
+		return nil, err
+	}
+
+	relation := ocs.VisibilityPublic
+	if viewerID := ocs.StudentIDFromContext(ctx); viewerID == id {
+		relation = ocs.VisibilityPrivate
+	} else if admin, err := isAdmin(ctx, tx); err != nil {
+		return nil, err
+	} else if admin {
+		relation = ocs.VisibilityPrivate
+	} else if viewerID != 0 {
+		var n int
+		if err := tx.QueryRowContext(ctx, `
+      SELECT COUNT(*)
+      FROM enrollments a
+      JOIN enrollments b ON b.course_id = a.course_id
+      WHERE a.student_id = ? AND b.student_id = ?
+    `, viewerID, id).Scan(&n); err != nil {
+			return nil, FormatError(err)
+		} else if n > 0 {
+			relation = ocs.VisibilityClassmates
+		}
+	}
+
+	return student.Profile(relation), nil
+}
+
+// deleteStudent marks the student as deleted. Their data is kept until it
+// is purged, so that an admin can restore it.
 func deleteStudent(ctx context.Context, tx *Tx, id int) error {
-	if student, err := findStudentByID(ctx, tx, id); err != nil {
+	student, err := findStudentByID(ctx, tx, id)
+	if err != nil {
 		return err
 	} else if student.ID != ocs.StudentIDFromContext(ctx) {
 		return ocs.Errorf(ocs.EUNAUTHORIZED, "You are not allowed to delete this student.")
 	}
+	prev := *student
+
+	deletedAt := tx.now
+	student.DeletedAt = &deletedAt
+	student.UpdatedAt = tx.now
 
-	if _, err := tx.ExecContext(ctx, `DELETE FROM students WHERE id = ?`, id); err != nil {
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE students SET deleted_at = ?, updated_at = ? WHERE id = ?
+  `,
+		(*NullTime)(student.DeletedAt),
+		(*NullTime)(&student.UpdatedAt),
+		id,
+	); err != nil {
 		return FormatError(err)
 	}
 
-	return nil
+	return audit(ctx, tx, ocs.AuditActionDelete, "student", id, &prev, student)
+}
+
+// restoreStudent lets an admin undo a deletion within the retention window.
+func restoreStudent(ctx context.Context, tx *Tx, id int) (*ocs.Student, error) {
+	if ok, err := isAdmin(ctx, tx); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "Only admins may restore students.")
+	}
+
+	student, err := findAnyStudentByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if student.DeletedAt == nil {
+		return student, ocs.Errorf(ocs.ECONFLICT, "Student is not deleted.")
+	} else if !tx.now.Before(student.DeletedAt.Add(tx.db.Retention)) {
+		return student, ocs.Errorf(ocs.ECONFLICT, "Student can no longer be restored.")
+	}
+	prev := *student
+
+	student.DeletedAt = nil
+	student.UpdatedAt = tx.now
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE students SET deleted_at = NULL, updated_at = ? WHERE id = ?
+  `,
+		(*NullTime)(&student.UpdatedAt),
+		id,
+	); err != nil {
+		return student, FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionRestore, "student", id, &prev, student); err != nil {
+		return student, err
+	}
+
+	return student, nil
+}
+
+// isAdmin reports whether the current student is an admin.
+func isAdmin(ctx context.Context, tx *Tx) (bool, error) {
+	var admin bool
+	if err := tx.QueryRowContext(ctx, `
+    SELECT admin FROM students WHERE id = ?
+  `,
+		ocs.StudentIDFromContext(ctx),
+	).Scan(&admin); err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, FormatError(err)
+	}
+	return admin, nil
 }
 
 func attachStudentsAuths(ctx context.Context, tx *Tx, student *ocs.Student) (err error) {
@@ -283,3 +632,28 @@ func attachStudentsAuths(ctx context.Context, tx *Tx, student *ocs.Student) (err
 	}
 	return nil
 }
+
+func attachStudentBadges(ctx context.Context, tx *Tx, student *ocs.Student) (err error) {
+	if student.Badges, _, err = findBadgeAwards(ctx, tx, ocs.BadgeAwardFilter{StudentID: &student.ID}); err != nil {
+		return fmt.Errorf("attach student badges: %w", err)
+	}
+	return nil
+}
+
+// profileLinks returns links encoded for the links column.
+func profileLinks(links []string) string {
+	if links == nil {
+		return "[]"
+	}
+	buf, _ := json.Marshal(links)
+	return string(buf)
+}
+
+// profileVisibility returns visibility encoded for the visibility column.
+func profileVisibility(visibility map[string]string) string {
+	if visibility == nil {
+		return "{}"
+	}
+	buf, _ := json.Marshal(visibility)
+	return string(buf)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/maliByatzes/ocs"
 	"github.com/maliByatzes/ocs/sqlite"
@@ -20,7 +21,7 @@ func TestStudentService_CreateStudent(t *testing.T) {
 			Email: "john@email.com",
 		}
 
-		if err := s.CreateStudent(context.Background(), student); err != nil {
+		if err := s.CreateStudent(DefaultSiteContext(), student); err != nil {
 			t.Fatal(err)
 		} else if got, want := student.ID, 1; got != want {
 			t.Fatalf("ID=%v, want=%v", got, want)
@@ -31,13 +32,13 @@ func TestStudentService_CreateStudent(t *testing.T) {
 		}
 
 		student2 := &ocs.Student{Name: "james", Email: "james@email.com"}
-		if err := s.CreateStudent(context.Background(), student2); err != nil {
+		if err := s.CreateStudent(DefaultSiteContext(), student2); err != nil {
 			t.Fatal(err)
 		} else if got, want := student2.ID, 2; got != want {
 			t.Fatalf("ID=%v, want=%v", got, want)
 		}
 
-		if other, err := s.FindStudentByID(context.Background(), 1); err != nil {
+		if other, err := s.FindStudentByID(DefaultSiteContext(), 1); err != nil {
 			t.Fatal(err)
 		} else if !reflect.DeepEqual(student, other) {
 			t.Fatalf("mismatch: %#v != %#v", student, other)
@@ -48,7 +49,7 @@ func TestStudentService_CreateStudent(t *testing.T) {
 		db := MustOpenDB(t)
 		defer MustCloseDB(t, db)
 		s := sqlite.NewStudentService(db)
-		if err := s.CreateStudent(context.Background(), &ocs.Student{}); err == nil {
+		if err := s.CreateStudent(DefaultSiteContext(), &ocs.Student{}); err == nil {
 			t.Fatal("expected error")
 		} else if ocs.ErrorCode(err) != ocs.EINVALID || ocs.ErrorMessage(err) != `Provide required fields` {
 			t.Fatalf("unexpected error: %#v", err)
@@ -59,7 +60,7 @@ func TestStudentService_CreateStudent(t *testing.T) {
 		db := MustOpenDB(t)
 		defer MustCloseDB(t, db)
 		s := sqlite.NewStudentService(db)
-		if err := s.CreateStudent(context.Background(), &ocs.Student{Name: "kyle"}); err == nil {
+		if err := s.CreateStudent(DefaultSiteContext(), &ocs.Student{Name: "kyle"}); err == nil {
 			t.Fatalf("expected error")
 		} else if ocs.ErrorCode(err) != ocs.EINVALID || ocs.ErrorMessage(err) != `Provide required fields` {
 			t.Fatalf("unexpected error: %#v", err)
@@ -88,11 +89,13 @@ func TestStudentService_UpdateStudent(t *testing.T) {
 			t.Fatal(err)
 		} else if got, want := us.Name, newName; got != want {
 			t.Fatalf("Name=%v, want %v", got, want)
-		} else if got, want := us.Email, newEmail; got != want {
+		} else if got, want := us.Email, "john@email.com"; got != want {
 			t.Fatalf("Email=%v, want %v", got, want)
+		} else if got, want := us.PendingEmail, newEmail; got != want {
+			t.Fatalf("PendingEmail=%v, want %v", got, want)
 		}
 
-		if other, err := s.FindStudentByID(context.Background(), 1); err != nil {
+		if other, err := s.FindStudentByID(DefaultSiteContext(), 1); err != nil {
 			t.Fatal(err)
 		} else if !reflect.DeepEqual(us, other) {
 			t.Fatalf("mismatch: %#v != %#v", us, other)
@@ -114,6 +117,112 @@ func TestStudentService_UpdateStudent(t *testing.T) {
 			t.Fatalf("unexpected error: %#v", err)
 		}
 	})
+
+	t.Run("ErrInvalidTimeZone", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewStudentService(db)
+
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "joe", Email: "joe@email.com"})
+
+		timeZone := "Mars/Olympus_Mons"
+		if _, err := s.UpdateStudent(ctx, student.ID, ocs.StudentUpdate{TimeZone: &timeZone}); ocs.ErrorCode(err) != ocs.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("Profile", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewStudentService(db)
+
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "joe", Email: "joe@email.com"})
+
+		locale, displayName, links := "en-ZA", "Joe B.", []string{"https://joe.example.com"}
+		if _, err := s.UpdateStudent(ctx, student.ID, ocs.StudentUpdate{
+			Locale:      &locale,
+			DisplayName: &displayName,
+			Links:       &links,
+			Visibility:  map[string]string{"links": ocs.VisibilityPublic},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Later updates only change the visibilities they give.
+		us, err := s.UpdateStudent(ctx, student.ID, ocs.StudentUpdate{Visibility: map[string]string{"bio": ocs.VisibilityPrivate}})
+		if err != nil {
+			t.Fatal(err)
+		} else if got, want := us.Locale, locale; got != want {
+			t.Fatalf("Locale=%v, want %v", got, want)
+		} else if got, want := us.Links, links; !reflect.DeepEqual(got, want) {
+			t.Fatalf("Links=%v, want %v", got, want)
+		} else if got, want := us.Visibility, map[string]string{"links": ocs.VisibilityPublic, "bio": ocs.VisibilityPrivate}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("Visibility=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrInvalidProfile", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewStudentService(db)
+
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "joe", Email: "joe@email.com"})
+
+		locale, links := "not a locale", []string{"javascript:alert(1)"}
+		for _, upd := range []ocs.StudentUpdate{
+			{Locale: &locale},
+			{Links: &links},
+			{Visibility: map[string]string{"bio": "friends"}},
+			{Visibility: map[string]string{"apiKey": ocs.VisibilityPublic}},
+		} {
+			if _, err := s.UpdateStudent(ctx, student.ID, upd); ocs.ErrorCode(err) != ocs.EINVALID {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+		}
+	})
+}
+
+func TestStudentService_FindStudentProfile(t *testing.T) {
+	db := MustOpenDB(t)
+	defer MustCloseDB(t, db)
+	s := sqlite.NewStudentService(db)
+
+	student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "joe", Email: "joe@email.com"})
+	classmate, classmateCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "kim", Email: "kim@email.com"})
+	_, strangerCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "sam", Email: "sam@email.com"})
+
+	course := MustCreateCourse(t, ctx, db, &ocs.Course{Title: "Go 101"})
+	MustCreateEnrollment(t, ctx, db, &ocs.Enrollment{CourseID: course.ID, StudentID: student.ID})
+	MustCreateEnrollment(t, classmateCtx, db, &ocs.Enrollment{CourseID: course.ID, StudentID: classmate.ID})
+
+	displayName, bio := "Joe B.", "Gopher."
+	if _, err := s.UpdateStudent(ctx, student.ID, ocs.StudentUpdate{DisplayName: &displayName, Bio: &bio}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		ctx   context.Context
+		bio   string
+		email string
+	}{
+		{"Self", ctx, bio, "joe@email.com"},
+		{"Classmate", classmateCtx, bio, ""},
+		{"Stranger", strangerCtx, "", ""},
+		{"Anonymous", DefaultSiteContext(), "", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if profile, err := s.FindStudentProfile(tt.ctx, student.ID); err != nil {
+				t.Fatal(err)
+			} else if got, want := profile.DisplayName, displayName; got != want {
+				t.Fatalf("DisplayName=%v, want %v", got, want)
+			} else if got, want := profile.Bio, tt.bio; got != want {
+				t.Fatalf("Bio=%v, want %v", got, want)
+			} else if got, want := profile.Email, tt.email; got != want {
+				t.Fatalf("Email=%v, want %v", got, want)
+			}
+		})
+	}
 }
 
 func TestStudentService_DeleteStudent(t *testing.T) {
@@ -136,7 +245,81 @@ func TestStudentService_DeleteStudent(t *testing.T) {
 		defer MustCloseDB(t, db)
 		s := sqlite.NewStudentService(db)
 
-		if err := s.DeleteStudent(context.Background(), 1); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+		if err := s.DeleteStudent(DefaultSiteContext(), 1); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func TestStudentService_RestoreStudent(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewStudentService(db)
+
+		_, adminCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "james", Email: "james@email.com"})
+
+		if err := s.DeleteStudent(ctx, student.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		email := "james@email.com"
+		if _, n, err := s.FindStudents(adminCtx, ocs.StudentFilter{Email: &email}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 0; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		}
+
+		if other, err := s.RestoreStudent(adminCtx, student.ID); err != nil {
+			t.Fatal(err)
+		} else if other.DeletedAt != nil {
+			t.Fatalf("unexpected DeletedAt: %v", other.DeletedAt)
+		} else if _, err := s.FindStudentByID(adminCtx, student.ID); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ErrNotDeleted", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewStudentService(db)
+
+		admin, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+		if _, err := s.RestoreStudent(ctx, admin.ID); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrRetentionExpired", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewStudentService(db)
+
+		_, adminCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "james", Email: "james@email.com"})
+		if err := s.DeleteStudent(ctx, student.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		now := time.Now().Add(db.Retention + time.Hour)
+		db.Now = func() time.Time { return now }
+
+		if _, err := s.RestoreStudent(adminCtx, student.ID); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewStudentService(db)
+
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "james", Email: "james@email.com"})
+		_, otherCtx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		if err := s.DeleteStudent(ctx, student.ID); err != nil {
+			t.Fatal(err)
+		} else if _, err := s.RestoreStudent(otherCtx, student.ID); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
 			t.Fatalf("unexpected error: %#v", err)
 		}
 	})
@@ -147,7 +330,7 @@ func TestStudentService_FindStudent(t *testing.T) {
 		db := MustOpenDB(t)
 		defer MustCloseDB(t, db)
 		s := sqlite.NewStudentService(db)
-		if _, err := s.FindStudentByID(context.Background(), 1); ocs.ErrorCode(err) != ocs.ENOTFOUND {
+		if _, err := s.FindStudentByID(DefaultSiteContext(), 1); ocs.ErrorCode(err) != ocs.ENOTFOUND {
 			t.Fatalf("unexpected error: %#v", err)
 		}
 	})
@@ -159,7 +342,7 @@ func TestStudentService_FindStudents(t *testing.T) {
 		defer MustCloseDB(t, db)
 		s := sqlite.NewStudentService(db)
 
-		ctx := context.Background()
+		ctx := DefaultSiteContext()
 		MustCreateStudent(t, ctx, db, &ocs.Student{Name: "john", Email: "john@email.com"})
 		MustCreateStudent(t, ctx, db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
 		MustCreateStudent(t, ctx, db, &ocs.Student{Name: "frank", Email: "frank@email.com"})
@@ -178,10 +361,23 @@ func TestStudentService_FindStudents(t *testing.T) {
 	})
 }
 
+// MustCreateStudent creates a student. Unless ctx is scoped to an
+// organization, the student acts on the default site.
 func MustCreateStudent(tb testing.TB, ctx context.Context, db *sqlite.DB, student *ocs.Student) (*ocs.Student, context.Context) {
 	tb.Helper()
+	if !ocs.HasOrganizationScope(ctx) {
+		ctx = ocs.NewContextWithOrganization(ctx, nil)
+	}
 	if err := sqlite.NewStudentService(db).CreateStudent(ctx, student); err != nil {
 		tb.Fatal(err)
 	}
 	return student, ocs.NewContextWithStudent(ctx, student)
 }
+
+// MustCreateVerifiedStudent creates a student whose email is already
+// verified, for tests of actions gated on verification.
+func MustCreateVerifiedStudent(tb testing.TB, ctx context.Context, db *sqlite.DB, student *ocs.Student) (*ocs.Student, context.Context) {
+	tb.Helper()
+	student.EmailVerified = true
+	return MustCreateStudent(tb, ctx, db, student)
+}
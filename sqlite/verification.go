@@ -0,0 +1,296 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var _ ocs.EmailVerificationService = (*EmailVerificationService)(nil)
+
+// EmailVerificationJob is the job kind that emails a verification link.
+const EmailVerificationJob = "email:verify"
+
+// DefaultEmailVerificationTTL is how long a verification link works.
+const DefaultEmailVerificationTTL = 24 * time.Hour
+
+// EmailVerificationService verifies students' email addresses. Links are
+// emailed by a job, so register its job handler on a job queue.
+type EmailVerificationService struct {
+	db *DB
+
+	// The site's base URL, e.g. "https://ocs.example.com", used to build
+	// verification links.
+	URL string
+
+	TTL time.Duration
+}
+
+func NewEmailVerificationService(db *DB) *EmailVerificationService {
+	return &EmailVerificationService{db: db, TTL: DefaultEmailVerificationTTL}
+}
+
+// RegisterJobs registers the job handler that emails verification links.
+func (s *EmailVerificationService) RegisterJobs(q *JobQueue) {
+	q.Handle(EmailVerificationJob, s.send)
+}
+
+func (s *EmailVerificationService) SendEmailVerification(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	studentID := ocs.StudentIDFromContext(ctx)
+	if studentID == 0 {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "You must be logged in to verify your email.")
+	}
+
+	student, err := findStudentByID(ctx, tx, studentID)
+	if err != nil {
+		return err
+	} else if verificationEmail(student) == "" && student.Email == "" {
+		return ocs.Errorf(ocs.EINVALID, "Please add an email address first.")
+	} else if verificationEmail(student) == "" {
+		return ocs.Errorf(ocs.ECONFLICT, "Your email is already verified.")
+	} else if err := enqueueEmailVerification(ctx, tx, student.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *EmailVerificationService) VerifyEmail(ctx context.Context, token string) (*ocs.Student, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	student, err := verifyEmail(ctx, tx, token)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return student, nil
+}
+
+type emailVerificationJobPayload struct {
+	StudentID int `json:"studentID"`
+}
+
+// send emails a verification link for the address the student has yet to
+// verify, if any. Earlier links that were not used stop working, so that
+// only the latest one does.
+func (s *EmailVerificationService) send(ctx context.Context, job *Job) error {
+	var payload emailVerificationJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	student, err := findStudentByID(ctx, tx, payload.StudentID)
+	if ocs.ErrorCode(err) == ocs.ENOTFOUND {
+		return nil // the student was deleted
+	} else if err != nil {
+		return err
+	}
+
+	email := verificationEmail(student)
+	if email == "" {
+		return nil // verified since the job was queued
+	}
+
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(buf)
+
+	v := &ocs.EmailVerification{
+		StudentID: student.ID,
+		Email:     email,
+		ExpiresAt: tx.now.Add(s.TTL),
+		CreatedAt: tx.now,
+	}
+
+	if err := revokeLinkTokens(ctx, tx, "email_verifications", student.ID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO email_verifications (
+      student_id,
+      email,
+      token_hash,
+      expires_at,
+      created_at
+    )
+    VALUES (?, ?, ?, ?, ?)
+  `,
+		v.StudentID,
+		v.Email,
+		hashToken(token),
+		(*NullTime)(&v.ExpiresAt),
+		(*NullTime)(&v.CreatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+	record := &linkToken{StudentID: v.StudentID, Email: v.Email, ExpiresAt: v.ExpiresAt, CreatedAt: v.CreatedAt}
+	if err := createLinkToken(ctx, tx, "email_verifications", result, record); err != nil {
+		return err
+	}
+	v.ID = record.ID
+
+	// The link is sent to the address being verified, with its expiry in
+	// the student's time zone.
+	to := *student
+	to.Email = email
+	local := *v
+	local.ExpiresAt = v.ExpiresAt.In(student.Location())
+
+	if err := enqueueEmail(ctx, tx, ocs.VerifyEmailEmail, ocs.EmailData{
+		Student:           &to,
+		EmailVerification: &local,
+		URL:               fmt.Sprintf("%s/verify-email?token=%s", strings.TrimSuffix(s.URL, "/"), token),
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// verifyEmail uses a token to verify the address it was sent to.
+func verifyEmail(ctx context.Context, tx *Tx, token string) (*ocs.Student, error) {
+	var id, studentID int
+	var email string
+	var expiresAt, usedAt NullTime
+	if err := tx.QueryRowContext(ctx, `
+    SELECT id, student_id, email, expires_at, used_at
+    FROM email_verifications
+    WHERE token_hash = ?
+  `,
+		hashToken(token),
+	).Scan(&id, &studentID, &email, &expiresAt, &usedAt); err == sql.ErrNoRows {
+		return nil, ocs.Errorf(ocs.EUNAUTHORIZED, "Invalid verification link.")
+	} else if err != nil {
+		return nil, FormatError(err)
+	}
+
+	if !time.Time(usedAt).IsZero() {
+		return nil, ocs.Errorf(ocs.ECONFLICT, "This verification link has already been used.")
+	} else if !tx.now.Before(time.Time(expiresAt)) {
+		return nil, ocs.Errorf(ocs.EEXPIRED, "This verification link has expired. Please request a new one.")
+	}
+
+	student, err := findStudentByID(ctx, tx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	prev := *student
+
+	switch email {
+	case student.PendingEmail:
+		student.Email, student.PendingEmail = email, ""
+	case student.Email:
+		student.PendingEmail = ""
+	default:
+		// The student changed their email again since the link was sent.
+		return nil, ocs.Errorf(ocs.EEXPIRED, "This verification link is for an email you no longer use.")
+	}
+	student.EmailVerified = true
+	student.UpdatedAt = tx.now
+
+	if err := useLinkToken(ctx, tx, "email_verifications", &linkToken{
+		ID:        id,
+		StudentID: studentID,
+		Email:     email,
+		ExpiresAt: time.Time(expiresAt),
+	}); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE students
+    SET email = ?,
+        pending_email = ?,
+        email_verified = 1,
+        updated_at = ?
+    WHERE id = ?
+  `,
+		student.Email,
+		student.PendingEmail,
+		(*NullTime)(&student.UpdatedAt),
+		student.ID,
+	); err != nil {
+		return nil, FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "student", student.ID, &prev, student); err != nil {
+		return nil, err
+	}
+
+	return student, nil
+}
+
+// verificationEmail returns the address the student has yet to verify: their
+// pending email, or their email if it is not verified. Empty if none.
+func verificationEmail(student *ocs.Student) string {
+	if student.PendingEmail != "" {
+		return student.PendingEmail
+	} else if !student.EmailVerified && student.Email != "" {
+		return student.Email
+	}
+	return ""
+}
+
+// enqueueEmailVerification queues the job that emails the student a
+// verification link.
+func enqueueEmailVerification(ctx context.Context, tx *Tx, studentID int) error {
+	_, err := enqueueJob(ctx, tx, EmailVerificationJob, emailVerificationJobPayload{StudentID: studentID}, JobOptions{
+		UniqueKey: fmt.Sprintf("%s:%d", EmailVerificationJob, studentID),
+	})
+	return err
+}
+
+// requireVerifiedEmail returns EUNVERIFIED unless the current student has
+// verified their email. It gates the actions that spend money, hand over or
+// destroy the student's data, or act on behalf of others:
+//
+//   - checking out and requesting or issuing refunds
+//   - exporting data and requesting erasure
+//   - changing the password
+//   - managing webhooks
+//   - creating organizations and adding or removing their members
+//
+// The HTTP server also refuses API keys of unverified students. Changing
+// the email is not gated, so that a mistyped address can be fixed, but the
+// new address only counts once it is verified. Students leaving an
+// organization themselves are not gated.
+func requireVerifiedEmail(ctx context.Context, tx *Tx) error {
+	var verified bool
+	if err := tx.QueryRowContext(ctx, `
+    SELECT email_verified FROM students WHERE id = ?
+  `,
+		ocs.StudentIDFromContext(ctx),
+	).Scan(&verified); err != nil && err != sql.ErrNoRows {
+		return FormatError(err)
+	} else if !verified {
+		return ocs.Errorf(ocs.EUNVERIFIED, "Please verify your email address first.")
+	}
+	return nil
+}
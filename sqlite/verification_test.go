@@ -0,0 +1,177 @@
+package sqlite_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+var verifyEmailURL = regexp.MustCompile(`https://ocs\.example\.com/verify-email\?token=([0-9a-f]+)`)
+
+func TestEmailVerificationService_VerifyEmail(t *testing.T) {
+	t.Run("EmailChange", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		q := sqlite.NewJobQueue(db)
+		s := sqlite.NewEmailVerificationService(db)
+		s.URL = "https://ocs.example.com"
+		s.RegisterJobs(q)
+
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+		MustDeliverEmails(t, db)
+
+		newEmail := "jane@example.com"
+		if _, err := sqlite.NewStudentService(db).UpdateStudent(ctx, student.ID, ocs.StudentUpdate{Email: &newEmail}); err != nil {
+			t.Fatal(err)
+		}
+		MustRunNextJob(t, q, true)
+
+		// The link goes to the new address.
+		sent := MustDeliverEmails(t, db)
+		if len(sent) != 1 {
+			t.Fatalf("len=%v, want 1", len(sent))
+		} else if got, want := sent[0].To, newEmail; got != want {
+			t.Fatalf("To=%v, want %v", got, want)
+		}
+		m := verifyEmailURL.FindStringSubmatch(sent[0].Text)
+		if m == nil {
+			t.Fatalf("unexpected text: %s", sent[0].Text)
+		}
+
+		if other, err := s.VerifyEmail(DefaultSiteContext(), m[1]); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.Email, newEmail; got != want {
+			t.Fatalf("Email=%v, want %v", got, want)
+		} else if got, want := other.PendingEmail, ""; got != want {
+			t.Fatalf("PendingEmail=%v, want %v", got, want)
+		}
+
+		if _, err := s.VerifyEmail(DefaultSiteContext(), m[1]); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if _, err := s.VerifyEmail(DefaultSiteContext(), "bad"); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	// Students who mistyped their address may change it before verifying,
+	// but stay gated until the new address is verified.
+	t.Run("UnverifiedEmailChange", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		q := sqlite.NewJobQueue(db)
+		s := sqlite.NewEmailVerificationService(db)
+		s.URL = "https://ocs.example.com"
+		s.RegisterJobs(q)
+		passwords := sqlite.NewPasswordService(db)
+
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@emial.com"})
+		MustRunNextJob(t, q, true)
+		MustDeliverEmails(t, db)
+
+		newEmail := "jane@email.com"
+		if other, err := sqlite.NewStudentService(db).UpdateStudent(ctx, student.ID, ocs.StudentUpdate{Email: &newEmail}); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.Email, "jane@emial.com"; got != want {
+			t.Fatalf("Email=%v, want %v", got, want)
+		} else if err := passwords.ChangePassword(ctx, "", "correct horse battery"); ocs.ErrorCode(err) != ocs.EUNVERIFIED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+		MustRunNextJob(t, q, true)
+
+		sent := MustDeliverEmails(t, db)
+		if len(sent) != 1 {
+			t.Fatalf("len=%v, want 1", len(sent))
+		}
+		m := verifyEmailURL.FindStringSubmatch(sent[0].Text)
+		if m == nil {
+			t.Fatalf("unexpected text: %s", sent[0].Text)
+		}
+
+		if other, err := s.VerifyEmail(DefaultSiteContext(), m[1]); err != nil {
+			t.Fatal(err)
+		} else if got, want := other.Email, newEmail; got != want {
+			t.Fatalf("Email=%v, want %v", got, want)
+		} else if !other.EmailVerified {
+			t.Fatal("expected verified email")
+		} else if err := passwords.ChangePassword(ctx, "", "correct horse battery"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ErrExpired", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		q := sqlite.NewJobQueue(db)
+		s := sqlite.NewEmailVerificationService(db)
+		s.URL = "https://ocs.example.com"
+		s.RegisterJobs(q)
+
+		// New students are sent a link for the address they signed up with.
+		student := &ocs.Student{Name: "jane", Email: "jane@email.com"}
+		if err := sqlite.NewStudentService(db).CreateStudent(DefaultSiteContext(), student); err != nil {
+			t.Fatal(err)
+		}
+		MustRunNextJob(t, q, true)
+
+		var token string
+		for _, email := range MustDeliverEmails(t, db) {
+			if m := verifyEmailURL.FindStringSubmatch(email.Text); m != nil {
+				token = m[1]
+			}
+		}
+		if token == "" {
+			t.Fatal("expected verification email")
+		}
+
+		now = now.Add(sqlite.DefaultEmailVerificationTTL)
+		if _, err := s.VerifyEmail(DefaultSiteContext(), token); ocs.ErrorCode(err) != ocs.EEXPIRED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrUnverified", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		student := &ocs.Student{Name: "jane", Email: "jane@email.com", Admin: true}
+		if err := sqlite.NewStudentService(db).CreateStudent(DefaultSiteContext(), student); err != nil {
+			t.Fatal(err)
+		}
+		ctx := ocs.NewContextWithStudent(DefaultSiteContext(), student)
+
+		if _, err := sqlite.NewExportService(db).CreateExport(ctx); ocs.ErrorCode(err) != ocs.EUNVERIFIED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if _, err := sqlite.NewErasureService(db).RequestErasure(ctx); ocs.ErrorCode(err) != ocs.EUNVERIFIED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if err := sqlite.NewPasswordService(db).ChangePassword(ctx, "", "correct horse battery"); ocs.ErrorCode(err) != ocs.EUNVERIFIED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if err := sqlite.NewWebhookService(db).CreateWebhook(ctx, &ocs.Webhook{URL: "https://example.com", EventTypes: []string{ocs.EventTypeBadgeEarned}}); ocs.ErrorCode(err) != ocs.EUNVERIFIED {
+			t.Fatalf("unexpected error: %#v", err)
+		} else if err := sqlite.NewOrganizationService(db).CreateOrganization(ctx, &ocs.Organization{Name: "Acme", Slug: "acme"}); ocs.ErrorCode(err) != ocs.EUNVERIFIED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrEmailInUse", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "john", Email: "john@email.com"})
+		student, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "jane", Email: "jane@email.com"})
+
+		email := "john@email.com"
+		if _, err := sqlite.NewStudentService(db).UpdateStudent(ctx, student.ID, ocs.StudentUpdate{Email: &email}); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
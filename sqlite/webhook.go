@@ -0,0 +1,809 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+)
+
+var (
+	_ ocs.WebhookService = (*WebhookService)(nil)
+	_ ocs.EventHandler   = (*WebhookService)(nil)
+)
+
+// WebhookDeliveryJob is the job kind that sends a webhook delivery.
+const WebhookDeliveryJob = "webhook:deliver"
+
+// Default delivery settings of webhooks.
+const (
+	DefaultWebhookTimeout     = 10 * time.Second
+	DefaultWebhookMaxAttempts = 8
+	DefaultWebhookMaxFailures = 20
+)
+
+// WebhookService manages webhooks and sends them the events they subscribe
+// to. Register it as an event handler on the event service, and its job
+// handler on a job queue, to deliver events.
+//
+// Each delivery is attempted up to MaxAttempts times. A webhook is disabled
+// after MaxFailures failed attempts in a row.
+type WebhookService struct {
+	db *DB
+
+	Client      *http.Client
+	MaxAttempts int
+	MaxFailures int
+}
+
+func NewWebhookService(db *DB) *WebhookService {
+	return &WebhookService{
+		db:          db,
+		Client:      &http.Client{Timeout: DefaultWebhookTimeout},
+		MaxAttempts: DefaultWebhookMaxAttempts,
+		MaxFailures: DefaultWebhookMaxFailures,
+	}
+}
+
+// RegisterJobs registers the delivery job handler on q.
+func (s *WebhookService) RegisterJobs(q *JobQueue) {
+	q.Handle(WebhookDeliveryJob, s.deliver)
+}
+
+func (s *WebhookService) FindWebhookByID(ctx context.Context, id int) (*ocs.Webhook, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkWebhookAdmin(ctx, tx); err != nil {
+		return nil, err
+	}
+	return findWebhookByID(ctx, tx, id)
+}
+
+func (s *WebhookService) FindWebhooks(ctx context.Context, filter ocs.WebhookFilter) ([]*ocs.Webhook, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if err := checkWebhookAdmin(ctx, tx); err != nil {
+		return nil, 0, err
+	}
+	return findWebhooks(ctx, tx, filter)
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, webhook *ocs.Webhook) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkWebhookAdmin(ctx, tx); err != nil {
+		return err
+	} else if err := createWebhook(ctx, tx, webhook); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id int, upd ocs.WebhookUpdate) (*ocs.Webhook, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkWebhookAdmin(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	webhook, err := updateWebhook(ctx, tx, id, upd)
+	if err != nil {
+		return webhook, err
+	} else if err := tx.Commit(); err != nil {
+		return webhook, err
+	}
+
+	return webhook, nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkWebhookAdmin(ctx, tx); err != nil {
+		return err
+	} else if webhook, err := findWebhookByID(ctx, tx, id); err != nil {
+		return err
+	} else if _, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id); err != nil {
+		return FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionDelete, "webhook", id, webhook, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *WebhookService) FindWebhookDeliveries(ctx context.Context, filter ocs.WebhookDeliveryFilter) ([]*ocs.WebhookDelivery, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if err := checkWebhookAdmin(ctx, tx); err != nil {
+		return nil, 0, err
+	}
+	return findWebhookDeliveries(ctx, tx, filter)
+}
+
+func (s *WebhookService) RedeliverWebhookDelivery(ctx context.Context, id int) (*ocs.WebhookDelivery, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkWebhookAdmin(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	delivery, err := findWebhookDeliveryByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if webhook, err := findWebhookByID(ctx, tx, delivery.WebhookID); err != nil {
+		return nil, err
+	} else if webhook.Disabled {
+		return nil, ocs.Errorf(ocs.ECONFLICT, "Webhook is disabled.")
+	}
+
+	if err := s.enqueueDelivery(ctx, tx, delivery); err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// sharedEventTypes are the events published to every student concerned by a
+// single change, such as a message sent to each participant. Webhooks receive
+// them once rather than once per student. Other events are about the student
+// they are published to, even if their payloads look alike.
+var sharedEventTypes = map[string]bool{
+	ocs.EventTypeLeaderboardChanged: true,
+	ocs.EventTypeRefundIssued:       true,
+	ocs.EventTypeMessageCreated:     true,
+	ocs.EventTypeConversationRead:   true,
+	ocs.EventTypeWebhookDisabled:    true,
+}
+
+// HandleEvent creates a delivery for each enabled webhook subscribed to the
+// event. An event already delivered is ignored, so replaying events is safe,
+// and shared events published to several students are delivered once.
+func (s *WebhookService) HandleEvent(ctx context.Context, studentID int, event ocs.Event) error {
+	ctx = withAllOrganizations(ctx)
+
+	key, err := eventKey(event)
+	if err != nil {
+		return err
+	}
+	if sharedEventTypes[event.Type] {
+		studentID = 0
+	} else {
+		key += ":" + strconv.Itoa(studentID)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	webhooks, _, err := queryWebhooks(ctx, tx, []string{"disabled = 0"}, nil, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Subscribed(event.Type) {
+			continue
+		}
+
+		delivery := &ocs.WebhookDelivery{WebhookID: webhook.ID, EventType: event.Type}
+		if err := createWebhookDelivery(ctx, tx, delivery, studentID, event, key); err != nil {
+			return err
+		} else if delivery.ID == 0 {
+			continue // already delivered
+		} else if err := s.enqueueDelivery(ctx, tx, delivery); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// enqueueDelivery marks the delivery pending and queues a job to send it.
+func (s *WebhookService) enqueueDelivery(ctx context.Context, tx *Tx, delivery *ocs.WebhookDelivery) error {
+	delivery.Status, delivery.UpdatedAt = ocs.WebhookDeliveryStatusPending, tx.now
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE webhook_deliveries SET status = ?, updated_at = ? WHERE id = ?
+  `,
+		delivery.Status,
+		(*NullTime)(&delivery.UpdatedAt),
+		delivery.ID,
+	); err != nil {
+		return FormatError(err)
+	}
+
+	_, err := enqueueJob(ctx, tx, WebhookDeliveryJob, webhookDeliveryJobPayload{DeliveryID: delivery.ID}, JobOptions{
+		MaxAttempts: s.MaxAttempts,
+	})
+	return err
+}
+
+type webhookDeliveryJobPayload struct {
+	DeliveryID int `json:"deliveryID"`
+}
+
+// deliver sends a delivery and records the response. It returns an error
+// to have the job retried, unless the webhook is disabled.
+func (s *WebhookService) deliver(ctx context.Context, job *Job) error {
+	var payload webhookDeliveryJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+
+	delivery, webhook, err := s.findDelivery(ctx, payload.DeliveryID)
+	if ocs.ErrorCode(err) == ocs.ENOTFOUND {
+		return nil // the webhook was deleted
+	} else if err != nil {
+		return err
+	}
+
+	attempt := webhookAttempt{err: errWebhookDisabled}
+	if !webhook.Disabled {
+		attempt = s.send(ctx, webhook, delivery)
+	}
+
+	retry, err := s.recordAttempt(ctx, delivery, attempt, job.Attempts < job.MaxAttempts)
+	if err != nil {
+		return err
+	} else if retry {
+		return attempt.err
+	}
+	return nil
+}
+
+var errWebhookDisabled = errors.New("webhook is disabled")
+
+func (s *WebhookService) findDelivery(ctx context.Context, id int) (*ocs.WebhookDelivery, *ocs.Webhook, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	delivery, err := findWebhookDeliveryByID(ctx, tx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	webhook, err := findWebhookByID(ctx, tx, delivery.WebhookID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return delivery, webhook, nil
+}
+
+type webhookAttempt struct {
+	status  int
+	latency time.Duration
+	err     error
+}
+
+// send posts the delivery's body to the webhook, signed with its secret.
+func (s *WebhookService) send(ctx context.Context, webhook *ocs.Webhook, delivery *ocs.WebhookDelivery) (attempt webhookAttempt) {
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		attempt.err = err
+		return attempt
+	}
+
+	timestamp := s.db.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ocs.WebhookEventHeader, delivery.EventType)
+	req.Header.Set(ocs.WebhookDeliveryHeader, strconv.Itoa(delivery.ID))
+	req.Header.Set(ocs.WebhookTimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(ocs.WebhookSignatureHeader, ocs.SignWebhook(webhook.Secret, timestamp, delivery.Body))
+
+	start := time.Now()
+	resp, err := s.Client.Do(req)
+	attempt.latency = time.Since(start)
+	if err != nil {
+		attempt.err = err
+		return attempt
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt.status = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.err = fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+	return attempt
+}
+
+// recordAttempt stores the outcome of an attempt on the delivery and keeps
+// the webhook's count of failures in a row, disabling it once the count
+// reaches MaxFailures. Changes to the webhook are audited. It reports whether a failed attempt
+// should be retried, which it is while the job has attempts left and the
+// webhook is enabled.
+func (s *WebhookService) recordAttempt(ctx context.Context, delivery *ocs.WebhookDelivery, attempt webhookAttempt, attemptsLeft bool) (retry bool, err error) {
+	tx, err := s.db.BeginTx(context.WithoutCancel(ctx), nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	delivery.Attempts++
+	delivery.ResponseStatus = attempt.status
+	delivery.LatencyMS = int(attempt.latency / time.Millisecond)
+	delivery.UpdatedAt = tx.now
+	if attempt.err == nil {
+		delivery.Status, delivery.LastError, delivery.DeliveredAt = ocs.WebhookDeliveryStatusSucceeded, "", &tx.now
+	} else {
+		delivery.Status, delivery.LastError = ocs.WebhookDeliveryStatusFailed, attempt.err.Error()
+	}
+
+	if attempt.err != errWebhookDisabled {
+		prev, err := findWebhookByID(ctx, tx, delivery.WebhookID)
+		if err != nil {
+			return false, err
+		}
+
+		if attempt.err == nil {
+			_, err = tx.ExecContext(ctx, `UPDATE webhooks SET failure_count = 0 WHERE id = ?`, delivery.WebhookID)
+		} else {
+			_, err = tx.ExecContext(ctx, `
+        UPDATE webhooks
+        SET failure_count = failure_count + 1,
+            disabled = (failure_count + 1 >= ?),
+            updated_at = ?
+        WHERE id = ?
+      `,
+				s.MaxFailures,
+				(*NullTime)(&tx.now),
+				delivery.WebhookID,
+			)
+		}
+		if err != nil {
+			return false, FormatError(err)
+		}
+
+		webhook, err := findWebhookByID(ctx, tx, delivery.WebhookID)
+		if err != nil {
+			return false, err
+		} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "webhook", webhook.ID, prev, webhook); err != nil {
+			return false, err
+		} else if webhook.Disabled && !prev.Disabled {
+			if err := publishWebhookDisabled(ctx, tx, webhook); err != nil {
+				return false, err
+			}
+		}
+		retry = attempt.err != nil && attemptsLeft && !webhook.Disabled
+	}
+
+	// Deliveries that will be retried stay pending.
+	if retry {
+		delivery.Status = ocs.WebhookDeliveryStatusPending
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE webhook_deliveries
+    SET status = ?,
+        attempts = ?,
+        response_status = ?,
+        latency_ms = ?,
+        last_error = ?,
+        delivered_at = COALESCE(?, delivered_at),
+        updated_at = ?
+    WHERE id = ?
+  `,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.ResponseStatus,
+		delivery.LatencyMS,
+		delivery.LastError,
+		(*NullTime)(delivery.DeliveredAt),
+		(*NullTime)(&delivery.UpdatedAt),
+		delivery.ID,
+	); err != nil {
+		return false, FormatError(err)
+	}
+
+	return retry, tx.Commit()
+}
+
+// publishWebhookDisabled tells every admin that a webhook was disabled for
+// failing, so that it can be fixed and re-enabled.
+func publishWebhookDisabled(ctx context.Context, tx *Tx, webhook *ocs.Webhook) error {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT id FROM students WHERE admin = 1 AND deleted_at IS NULL
+  `)
+	if err != nil {
+		return FormatError(err)
+	}
+	defer rows.Close()
+
+	event := ocs.Event{
+		Type: ocs.EventTypeWebhookDisabled,
+		Payload: &ocs.WebhookDisabledPayload{
+			WebhookID:    webhook.ID,
+			URL:          webhook.URL,
+			FailureCount: webhook.FailureCount,
+			DisabledAt:   tx.now,
+		},
+	}
+	for rows.Next() {
+		var adminID int
+		if err := rows.Scan(&adminID); err != nil {
+			return err
+		}
+		tx.publishEvent(adminID, event)
+	}
+	if err := rows.Err(); err != nil {
+		return FormatError(err)
+	}
+	return nil
+}
+
+func checkWebhookAdmin(ctx context.Context, tx *Tx) error {
+	if ok, err := isAdmin(ctx, tx); err != nil {
+		return err
+	} else if !ok {
+		return ocs.Errorf(ocs.EUNAUTHORIZED, "Only admins may manage webhooks.")
+	}
+	return requireVerifiedEmail(ctx, tx)
+}
+
+func createWebhook(ctx context.Context, tx *Tx, webhook *ocs.Webhook) error {
+	webhook.Disabled, webhook.FailureCount = false, 0
+	webhook.CreatedAt = tx.now
+	webhook.UpdatedAt = webhook.CreatedAt
+
+	if err := webhook.Validate(); err != nil {
+		return err
+	}
+
+	if webhook.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+			return err
+		}
+		webhook.Secret = hex.EncodeToString(secret)
+	}
+
+	eventTypes, err := json.Marshal(webhook.EventTypes)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT INTO webhooks (
+      url,
+      secret,
+      event_types,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, ?)
+  `,
+		webhook.URL,
+		webhook.Secret,
+		string(eventTypes),
+		(*NullTime)(&webhook.CreatedAt),
+		(*NullTime)(&webhook.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	webhook.ID = int(id)
+
+	return audit(ctx, tx, ocs.AuditActionCreate, "webhook", webhook.ID, nil, webhook)
+}
+
+func updateWebhook(ctx context.Context, tx *Tx, id int, upd ocs.WebhookUpdate) (*ocs.Webhook, error) {
+	webhook, err := findWebhookByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	prev := *webhook
+
+	if v := upd.URL; v != nil {
+		webhook.URL = *v
+	}
+	if v := upd.EventTypes; v != nil {
+		webhook.EventTypes = *v
+	}
+	if v := upd.Disabled; v != nil {
+		if webhook.Disabled && !*v {
+			webhook.FailureCount = 0
+		}
+		webhook.Disabled = *v
+	}
+	webhook.UpdatedAt = tx.now
+
+	if err := webhook.Validate(); err != nil {
+		return webhook, err
+	}
+
+	eventTypes, err := json.Marshal(webhook.EventTypes)
+	if err != nil {
+		return webhook, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE webhooks
+    SET url = ?,
+        event_types = ?,
+        disabled = ?,
+        failure_count = ?,
+        updated_at = ?
+    WHERE id = ?
+  `,
+		webhook.URL,
+		string(eventTypes),
+		webhook.Disabled,
+		webhook.FailureCount,
+		(*NullTime)(&webhook.UpdatedAt),
+		id,
+	); err != nil {
+		return webhook, FormatError(err)
+	} else if err := audit(ctx, tx, ocs.AuditActionUpdate, "webhook", id, &prev, webhook); err != nil {
+		return webhook, err
+	}
+
+	return webhook, nil
+}
+
+func findWebhookByID(ctx context.Context, tx *Tx, id int) (*ocs.Webhook, error) {
+	a, _, err := findWebhooks(ctx, tx, ocs.WebhookFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Webhook not found."}
+	}
+	return a[0], nil
+}
+
+func findWebhooks(ctx context.Context, tx *Tx, filter ocs.WebhookFilter) (_ []*ocs.Webhook, n int, err error) {
+	where, args := []string{"1 = 1"}, []interface{}{}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	return queryWebhooks(ctx, tx, where, args, filter.Limit, filter.Offset)
+}
+
+func queryWebhooks(ctx context.Context, tx *Tx, where []string, args []interface{}, limit, offset int) (_ []*ocs.Webhook, n int, err error) {
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      url,
+      secret,
+      event_types,
+      disabled,
+      failure_count,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM webhooks
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id ASC
+    `+FormatLimitOffset(limit, offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	webhooks := make([]*ocs.Webhook, 0)
+	for rows.Next() {
+		var webhook ocs.Webhook
+		var eventTypes string
+		if err := rows.Scan(
+			&webhook.ID,
+			&webhook.URL,
+			&webhook.Secret,
+			&eventTypes,
+			&webhook.Disabled,
+			&webhook.FailureCount,
+			(*NullTime)(&webhook.CreatedAt),
+			(*NullTime)(&webhook.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		} else if err := json.Unmarshal([]byte(eventTypes), &webhook.EventTypes); err != nil {
+			return nil, 0, fmt.Errorf("webhook event types: %w", err)
+		}
+
+		webhooks = append(webhooks, &webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return webhooks, n, nil
+}
+
+// createWebhookDelivery records the event for the webhook and renders the
+// body that is posted. The delivery's ID is left zero if the event was
+// already delivered to the student.
+func createWebhookDelivery(ctx context.Context, tx *Tx, delivery *ocs.WebhookDelivery, studentID int, event ocs.Event, key string) error {
+	delivery.Status = ocs.WebhookDeliveryStatusPending
+	delivery.CreatedAt = tx.now
+	delivery.UpdatedAt = delivery.CreatedAt
+
+	result, err := tx.ExecContext(ctx, `
+    INSERT OR IGNORE INTO webhook_deliveries (
+      webhook_id,
+      student_id,
+      event_type,
+      event_key,
+      body,
+      status,
+      created_at,
+      updated_at
+    )
+    VALUES (?, ?, ?, ?, '', ?, ?, ?)
+  `,
+		delivery.WebhookID,
+		studentID,
+		delivery.EventType,
+		key,
+		delivery.Status,
+		(*NullTime)(&delivery.CreatedAt),
+		(*NullTime)(&delivery.UpdatedAt),
+	)
+	if err != nil {
+		return FormatError(err)
+	} else if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return nil
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	delivery.ID = int(id)
+
+	if delivery.Body, err = json.Marshal(ocs.WebhookBody{
+		DeliveryID: delivery.ID,
+		StudentID:  studentID,
+		Event:      event,
+		CreatedAt:  delivery.CreatedAt,
+	}); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE webhook_deliveries SET body = ? WHERE id = ?
+  `, string(delivery.Body), delivery.ID); err != nil {
+		return FormatError(err)
+	}
+
+	return nil
+}
+
+func findWebhookDeliveryByID(ctx context.Context, tx *Tx, id int) (*ocs.WebhookDelivery, error) {
+	a, _, err := findWebhookDeliveries(ctx, tx, ocs.WebhookDeliveryFilter{ID: &id})
+	if err != nil {
+		return nil, err
+	} else if len(a) == 0 {
+		return nil, &ocs.Error{Code: ocs.ENOTFOUND, Message: "Webhook delivery not found."}
+	}
+	return a[0], nil
+}
+
+// findWebhookDeliveries returns deliveries, newest first.
+func findWebhookDeliveries(ctx context.Context, tx *Tx, filter ocs.WebhookDeliveryFilter) (_ []*ocs.WebhookDelivery, n int, err error) {
+	where, args := []string{"1 = 1"}, []interface{}{}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.WebhookID; v != nil {
+		where, args = append(where, "webhook_id = ?"), append(args, *v)
+	}
+	if v := filter.Status; v != nil {
+		where, args = append(where, "status = ?"), append(args, *v)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+    SELECT
+      id,
+      webhook_id,
+      event_type,
+      body,
+      status,
+      attempts,
+      response_status,
+      latency_ms,
+      last_error,
+      delivered_at,
+      created_at,
+      updated_at,
+      COUNT(*) OVER()
+    FROM webhook_deliveries
+    WHERE `+strings.Join(where, " AND ")+`
+    ORDER BY id DESC
+    `+FormatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, n, FormatError(err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*ocs.WebhookDelivery, 0)
+	for rows.Next() {
+		var delivery ocs.WebhookDelivery
+		var body string
+		var deliveredAt NullTime
+		if err := rows.Scan(
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.EventType,
+			&body,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.ResponseStatus,
+			&delivery.LatencyMS,
+			&delivery.LastError,
+			&deliveredAt,
+			(*NullTime)(&delivery.CreatedAt),
+			(*NullTime)(&delivery.UpdatedAt),
+			&n,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		delivery.Body = json.RawMessage(body)
+		if v := (time.Time)(deliveredAt); !v.IsZero() {
+			delivery.DeliveredAt = &v
+		}
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, FormatError(err)
+	}
+
+	return deliveries, n, nil
+}
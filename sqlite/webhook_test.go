@@ -0,0 +1,267 @@
+package sqlite_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/maliByatzes/ocs"
+	"github.com/maliByatzes/ocs/mock"
+	"github.com/maliByatzes/ocs/sqlite"
+)
+
+func TestWebhookService_HandleEvent(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewWebhookService(db)
+		q := sqlite.NewJobQueue(db)
+		s.RegisterJobs(q)
+
+		var received []*http.Request
+		var bodies [][]byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received, bodies = append(received, r), append(bodies, body)
+		}))
+		defer server.Close()
+
+		_, adminCtx := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+		webhook := MustCreateWebhook(t, adminCtx, s, &ocs.Webhook{URL: server.URL, EventTypes: []string{ocs.EventTypeSubmissionGraded}})
+
+		// Replays and events the webhook is not subscribed to are ignored.
+		event := ocs.Event{Type: ocs.EventTypeSubmissionGraded, Payload: &ocs.SubmissionGradedPayload{ID: 1, Grade: 7}}
+		for _, event := range []ocs.Event{event, event, {Type: ocs.EventTypeBadgeEarned, Payload: &ocs.BadgeEarnedPayload{}}} {
+			if err := s.HandleEvent(context.Background(), 2, event); err != nil {
+				t.Fatal(err)
+			}
+		}
+		MustRunNextJob(t, q, true)
+		MustRunNextJob(t, q, false)
+
+		if got, want := len(received), 1; got != want {
+			t.Fatalf("len=%v, want %v", got, want)
+		}
+
+		r, body := received[0], bodies[0]
+		timestamp, _ := strconv.ParseInt(r.Header.Get(ocs.WebhookTimestampHeader), 10, 64)
+		if got, want := r.Header.Get(ocs.WebhookSignatureHeader), ocs.SignWebhook(webhook.Secret, time.Unix(timestamp, 0), body); got != want {
+			t.Fatalf("signature=%v, want %v", got, want)
+		} else if got, want := r.Header.Get(ocs.WebhookEventHeader), ocs.EventTypeSubmissionGraded; got != want {
+			t.Fatalf("event=%v, want %v", got, want)
+		}
+
+		var payload struct {
+			StudentID int `json:"studentID"`
+			Event     struct {
+				Payload ocs.SubmissionGradedPayload `json:"payload"`
+			} `json:"event"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatal(err)
+		} else if got, want := payload.StudentID, 2; got != want {
+			t.Fatalf("StudentID=%v, want %v", got, want)
+		} else if got, want := payload.Event.Payload.Grade, 7; got != want {
+			t.Fatalf("Grade=%v, want %v", got, want)
+		}
+
+		if deliveries, n, err := s.FindWebhookDeliveries(adminCtx, ocs.WebhookDeliveryFilter{WebhookID: &webhook.ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := n, 1; got != want {
+			t.Fatalf("n=%v, want %v", got, want)
+		} else if got, want := deliveries[0].Status, ocs.WebhookDeliveryStatusSucceeded; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		} else if got, want := deliveries[0].ResponseStatus, http.StatusOK; got != want {
+			t.Fatalf("ResponseStatus=%v, want %v", got, want)
+		} else if got, want := string(deliveries[0].Body), string(body); got != want {
+			t.Fatalf("Body=%v, want %v", got, want)
+		}
+	})
+
+	// An event published to several students is delivered once, while
+	// alike events about different students are delivered for each.
+	t.Run("Shared", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewWebhookService(db)
+
+		_, adminCtx := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+		webhook := MustCreateWebhook(t, adminCtx, s, &ocs.Webhook{
+			URL:        "https://example.com",
+			EventTypes: []string{ocs.EventTypeMessageCreated, ocs.EventTypeLearningPathCompleted},
+		})
+
+		message := ocs.Event{Type: ocs.EventTypeMessageCreated, Payload: &ocs.MessageCreatedPayload{Message: &ocs.Message{ID: 1, ConversationID: 1, SenderID: 2}}}
+		completed := ocs.Event{Type: ocs.EventTypeLearningPathCompleted, Payload: &ocs.LearningPathCompletedPayload{LearningPathID: 1}}
+		for _, studentID := range []int{2, 3, 4} {
+			for _, event := range []ocs.Event{message, completed} {
+				if err := s.HandleEvent(context.Background(), studentID, event); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		typ := ocs.EventTypeMessageCreated
+		deliveries, _, err := s.FindWebhookDeliveries(adminCtx, ocs.WebhookDeliveryFilter{WebhookID: &webhook.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		for _, delivery := range deliveries {
+			if delivery.EventType != typ {
+				continue
+			}
+			n++
+
+			var body ocs.WebhookBody
+			if err := json.Unmarshal(delivery.Body, &body); err != nil {
+				t.Fatal(err)
+			} else if got, want := body.StudentID, 0; got != want {
+				t.Fatalf("StudentID=%v, want %v", got, want)
+			}
+		}
+		if got, want := n, 1; got != want {
+			t.Fatalf("messages=%v, want %v", got, want)
+		} else if got, want := len(deliveries)-n, 3; got != want {
+			t.Fatalf("completions=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("AutoDisable", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+
+		now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+		db.Now = func() time.Time { return now }
+
+		s := sqlite.NewWebhookService(db)
+		s.MaxAttempts, s.MaxFailures = 2, 3
+		q := sqlite.NewJobQueue(db)
+		s.RegisterJobs(q)
+
+		status := http.StatusInternalServerError
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		defer server.Close()
+
+		admin, adminCtx := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+		webhook := MustCreateWebhook(t, adminCtx, s, &ocs.Webhook{URL: server.URL, EventTypes: []string{ocs.EventTypeBadgeEarned}})
+
+		var published []int
+		db.EventService = &mock.EventService{PublishEventFn: func(studentID int, event ocs.Event) {
+			if event.Type == ocs.EventTypeWebhookDisabled {
+				published = append(published, studentID)
+			}
+		}}
+
+		// The first delivery fails twice and gives up; the third failure in
+		// a row disables the webhook.
+		for id := 1; id <= 2; id++ {
+			if err := s.HandleEvent(context.Background(), id, ocs.Event{Type: ocs.EventTypeBadgeEarned, Payload: &ocs.BadgeEarnedPayload{}}); err != nil {
+				t.Fatal(err)
+			}
+			MustRunNextJob(t, q, true)
+			now = now.Add(time.Hour)
+		}
+		MustRunNextJob(t, q, true)
+		MustRunNextJob(t, q, false)
+
+		if webhook, err := s.FindWebhookByID(adminCtx, webhook.ID); err != nil {
+			t.Fatal(err)
+		} else if !webhook.Disabled {
+			t.Fatal("expected disabled")
+		}
+
+		// Admins are told, and each failure is in the audit log.
+		if got, want := published, []int{admin.ID}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("published=%v, want %v", got, want)
+		}
+		entityType, action := "webhook", ocs.AuditActionUpdate
+		if entries, _, err := sqlite.NewAuditService(db).FindAuditEntries(adminCtx, ocs.AuditFilter{EntityType: &entityType, EntityID: &webhook.ID, Action: &action}); err != nil {
+			t.Fatal(err)
+		} else if got, want := len(entries), 3; got != want {
+			t.Fatalf("len(entries)=%v, want %v", got, want)
+		} else if got, want := entries[2].ActorID, 0; got != want {
+			t.Fatalf("ActorID=%v, want %v", got, want)
+		} else if got, want := string(entries[2].Changes["failureCount"].To), `3`; got != want {
+			t.Fatalf("failureCount=%v, want %v", got, want)
+		} else if got, want := string(entries[2].Changes["disabled"].To), `true`; got != want {
+			t.Fatalf("disabled=%v, want %v", got, want)
+		}
+
+		deliveries, _, err := s.FindWebhookDeliveries(adminCtx, ocs.WebhookDeliveryFilter{WebhookID: &webhook.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, delivery := range deliveries {
+			if got, want := delivery.Status, ocs.WebhookDeliveryStatusFailed; got != want {
+				t.Fatalf("Status=%v, want %v", got, want)
+			} else if got, want := delivery.ResponseStatus, http.StatusInternalServerError; got != want {
+				t.Fatalf("ResponseStatus=%v, want %v", got, want)
+			}
+		}
+
+		if _, err := s.RedeliverWebhookDelivery(adminCtx, deliveries[0].ID); ocs.ErrorCode(err) != ocs.ECONFLICT {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		// Once re-enabled, failed deliveries can be redelivered by hand.
+		enabled := false
+		if webhook, err := s.UpdateWebhook(adminCtx, webhook.ID, ocs.WebhookUpdate{Disabled: &enabled}); err != nil {
+			t.Fatal(err)
+		} else if got, want := webhook.FailureCount, 0; got != want {
+			t.Fatalf("FailureCount=%v, want %v", got, want)
+		}
+
+		status = http.StatusNoContent
+		if _, err := s.RedeliverWebhookDelivery(adminCtx, deliveries[0].ID); err != nil {
+			t.Fatal(err)
+		}
+		MustRunNextJob(t, q, true)
+
+		if deliveries, _, err := s.FindWebhookDeliveries(adminCtx, ocs.WebhookDeliveryFilter{ID: &deliveries[0].ID}); err != nil {
+			t.Fatal(err)
+		} else if got, want := deliveries[0].Status, ocs.WebhookDeliveryStatusSucceeded; got != want {
+			t.Fatalf("Status=%v, want %v", got, want)
+		}
+	})
+}
+
+func TestWebhookService_CreateWebhook(t *testing.T) {
+	t.Run("ErrUnauthorized", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewWebhookService(db)
+
+		_, ctx := MustCreateStudent(t, context.Background(), db, &ocs.Student{Name: "ann", Email: "ann@email.com"})
+		if err := s.CreateWebhook(ctx, &ocs.Webhook{URL: "https://example.com", EventTypes: []string{ocs.EventTypeBadgeEarned}}); ocs.ErrorCode(err) != ocs.EUNAUTHORIZED {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("ErrInvalidURL", func(t *testing.T) {
+		db := MustOpenDB(t)
+		defer MustCloseDB(t, db)
+		s := sqlite.NewWebhookService(db)
+
+		_, adminCtx := MustCreateVerifiedStudent(t, context.Background(), db, &ocs.Student{Name: "adm", Email: "adm@email.com", Admin: true})
+		if err := s.CreateWebhook(adminCtx, &ocs.Webhook{URL: "ftp://example.com", EventTypes: []string{ocs.EventTypeBadgeEarned}}); ocs.ErrorCode(err) != ocs.EINVALID {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	})
+}
+
+func MustCreateWebhook(tb testing.TB, ctx context.Context, s *sqlite.WebhookService, webhook *ocs.Webhook) *ocs.Webhook {
+	tb.Helper()
+	if err := s.CreateWebhook(ctx, webhook); err != nil {
+		tb.Fatal(err)
+	}
+	return webhook
+}
@@ -2,7 +2,13 @@ package ocs
 
 import (
 	"context"
+	"fmt"
+	"net/mail"
+	"net/url"
 	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
 )
 
 type Student struct {
@@ -13,22 +19,219 @@ type Student struct {
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 	Auths     []*Auth   `json:"auths"`
+
+	// Badges earned by the student, shown on their profile.
+	Badges []*BadgeAward `json:"badges"`
+
+	// If true, the student is left out of public leaderboards.
+	LeaderboardOptOut bool `json:"leaderboardOptOut"`
+
+	// If true, the student may administer the site, e.g. issue refunds.
+	// Admins are appointed in the database; it cannot be changed by an update.
+	Admin bool `json:"admin"`
+
+	// How notifications are emailed: one by one or collected in a daily or
+	// weekly digest.
+	EmailDigest string `json:"emailDigest"`
+
+	// Set once the student has confirmed they own Email. Checking out,
+	// refunds, exports, erasure requests, password changes and managing
+	// webhooks or organizations require a verified email. Provider logins
+	// only join an existing account when the provider has verified Email.
+	EmailVerified bool `json:"emailVerified"`
+
+	// If true, the student can log in with their email and a password.
+	HasPassword bool `json:"hasPassword"`
+
+	// An address the student changed their email to, which is only used
+	// once they have verified it.
+	PendingEmail string `json:"pendingEmail"`
+
+	// IANA time zone, such as "Africa/Johannesburg". Empty means UTC.
+	// Deadlines in emails, digests and reminders are shown in this zone.
+	TimeZone string `json:"timeZone"`
+
+	// Preferred locale as a BCP 47 tag, such as "en-ZA". Empty means the
+	// site default.
+	Locale string `json:"locale"`
+
+	// Profile fields. Who can see them is set in Visibility.
+	DisplayName string   `json:"displayName"`
+	Bio         string   `json:"bio"`
+	Pronouns    string   `json:"pronouns"`
+	Links       []string `json:"links"`
+
+	// Set when the student uploads an avatar. Without one, their avatar comes
+	// from a linked GitHub account or is generated from their initials.
+	AvatarUpdatedAt *time.Time `json:"avatarUpdatedAt"`
+
+	// Visibility of profile fields, keyed by the field's JSON name, e.g.
+	// "bio". Fields that are not set have their default visibility; see
+	// DefaultProfileVisibility.
+	Visibility map[string]string `json:"visibility"`
+
+	// Set when the student is deleted. Deleted students can be restored by
+	// an admin until they are purged.
+	DeletedAt *time.Time `json:"deletedAt"`
+
+	// Set when the student's account is erased. Erased students keep their
+	// ID, so that their grades still count in course statistics, but
+	// nothing that identifies them.
+	ErasedAt *time.Time `json:"erasedAt"`
+}
+
+const (
+	EmailDigestImmediate = "immediate"
+	EmailDigestDaily     = "daily"
+	EmailDigestWeekly    = "weekly"
+)
+
+// Profile field visibilities, from most to least visible.
+const (
+	VisibilityPublic     = "public"     // anyone
+	VisibilityClassmates = "classmates" // students sharing a course
+	VisibilityPrivate    = "private"    // only the student and admins
+)
+
+// DefaultProfileVisibility lists the profile fields whose visibility may be
+// set, with the visibility they have by default.
+var DefaultProfileVisibility = map[string]string{
+	"displayName": VisibilityPublic,
+	"pronouns":    VisibilityPublic,
+	"bio":         VisibilityClassmates,
+	"links":       VisibilityClassmates,
+	"email":       VisibilityPrivate,
+	"timeZone":    VisibilityPrivate,
+	"locale":      VisibilityPrivate,
 }
 
+// Limits on profile fields.
+const (
+	MaxDisplayNameLen = 100
+	MaxPronounsLen    = 40
+	MaxBioLen         = 1000
+	MaxProfileLinks   = 5
+)
+
 func (s *Student) Validate() error {
 	if s.Name == "" || s.Email == "" {
 		return Errorf(EINVALID, "Provide required fields")
 	}
+
+	switch s.EmailDigest {
+	case EmailDigestImmediate, EmailDigestDaily, EmailDigestWeekly:
+	default:
+		return Errorf(EINVALID, "Invalid email digest.")
+	}
+
+	if s.PendingEmail != "" {
+		if addr, err := mail.ParseAddress(s.PendingEmail); err != nil || addr.Address != s.PendingEmail {
+			return Errorf(EINVALID, "Invalid email.")
+		}
+	}
+
+	if _, err := time.LoadLocation(s.TimeZone); err != nil {
+		return Errorf(EINVALID, "Invalid time zone.")
+	}
+
+	if s.Locale != "" {
+		if _, err := language.Parse(s.Locale); err != nil {
+			return Errorf(EINVALID, "Invalid locale.")
+		}
+	}
+
+	if utf8.RuneCountInString(s.DisplayName) > MaxDisplayNameLen {
+		return Errorf(EINVALID, "Display name must be at most %d characters.", MaxDisplayNameLen)
+	} else if utf8.RuneCountInString(s.Pronouns) > MaxPronounsLen {
+		return Errorf(EINVALID, "Pronouns must be at most %d characters.", MaxPronounsLen)
+	} else if utf8.RuneCountInString(s.Bio) > MaxBioLen {
+		return Errorf(EINVALID, "Bio must be at most %d characters.", MaxBioLen)
+	} else if len(s.Links) > MaxProfileLinks {
+		return Errorf(EINVALID, "At most %d links allowed.", MaxProfileLinks)
+	}
+	for _, link := range s.Links {
+		if u, err := url.Parse(link); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Errorf(EINVALID, "Invalid link: %s", link)
+		}
+	}
+
+	for field, v := range s.Visibility {
+		if _, ok := DefaultProfileVisibility[field]; !ok {
+			return Errorf(EINVALID, "Unknown profile field: %s", field)
+		}
+		switch v {
+		case VisibilityPublic, VisibilityClassmates, VisibilityPrivate:
+		default:
+			return Errorf(EINVALID, "Invalid visibility.")
+		}
+	}
 	return nil
 }
 
+// FieldVisibility returns the visibility of a profile field.
+func (s *Student) FieldVisibility(field string) string {
+	if v, ok := s.Visibility[field]; ok {
+		return v
+	}
+	return DefaultProfileVisibility[field]
+}
+
+// Profile returns the student's profile as seen by a viewer with the given
+// relationship to them: VisibilityPublic for anyone, VisibilityClassmates
+// for a classmate, or VisibilityPrivate for the student themselves. Fields
+// the viewer may not see are left empty.
+func (s *Student) Profile(relation string) *StudentProfile {
+	rank := map[string]int{VisibilityPublic: 0, VisibilityClassmates: 1, VisibilityPrivate: 2}
+	visible := func(field string) bool {
+		return rank[s.FieldVisibility(field)] <= rank[relation]
+	}
+
+	p := &StudentProfile{ID: s.ID, Name: s.Name, Badges: s.Badges}
+	if visible("displayName") {
+		p.DisplayName = s.DisplayName
+	}
+	if visible("pronouns") {
+		p.Pronouns = s.Pronouns
+	}
+	if visible("bio") {
+		p.Bio = s.Bio
+	}
+	if visible("links") {
+		p.Links = s.Links
+	}
+	if visible("email") {
+		p.Email = s.Email
+	}
+	if visible("timeZone") {
+		p.TimeZone = s.TimeZone
+	}
+	if visible("locale") {
+		p.Locale = s.Locale
+	}
+	return p
+}
+
+// Location returns the student's time zone, or UTC if it is not set.
+func (s *Student) Location() *time.Location {
+	if loc, err := time.LoadLocation(s.TimeZone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// AvatarURL returns the URL of the student's avatar at the given size. An
+// uploaded avatar is preferred, then one from a linked account. Otherwise the
+// path of their initials avatar is returned.
 func (s *Student) AvatarURL(size int) string {
+	if s.AvatarUpdatedAt != nil {
+		return fmt.Sprintf("/students/%d/avatar?size=%d&v=%d", s.ID, AvatarSize(size), s.AvatarUpdatedAt.Unix())
+	}
 	for _, auth := range s.Auths {
 		if s := auth.AvatarURL(size); s != "" {
 			return s
 		}
 	}
-	return ""
+	return fmt.Sprintf("/students/%d/avatar?size=%d", s.ID, AvatarSize(size))
 }
 
 type StudentService interface {
@@ -37,6 +240,27 @@ type StudentService interface {
 	CreateStudent(ctx context.Context, student *Student) error
 	UpdateStudent(ctx context.Context, id int, upd StudentUpdate) (*Student, error)
 	DeleteStudent(ctx context.Context, id int) error
+
+	// RestoreStudent undoes a deletion that has not been purged yet.
+	RestoreStudent(ctx context.Context, id int) (*Student, error)
+
+	// FindStudentProfile returns a student's profile with only the fields
+	// the current student may see.
+	FindStudentProfile(ctx context.Context, id int) (*StudentProfile, error)
+}
+
+// StudentProfile is the part of a student shown to other students.
+type StudentProfile struct {
+	ID          int           `json:"id"`
+	Name        string        `json:"name"`
+	DisplayName string        `json:"displayName,omitempty"`
+	Pronouns    string        `json:"pronouns,omitempty"`
+	Bio         string        `json:"bio,omitempty"`
+	Links       []string      `json:"links,omitempty"`
+	Email       string        `json:"email,omitempty"`
+	TimeZone    string        `json:"timeZone,omitempty"`
+	Locale      string        `json:"locale,omitempty"`
+	Badges      []*BadgeAward `json:"badges"`
 }
 
 type StudentFilter struct {
@@ -47,7 +271,21 @@ type StudentFilter struct {
 	Limit  int     `json:"limit"`
 }
 
+// StudentUpdate changes a student's settings. A new Email is not used until
+// it is verified; until then it is the student's PendingEmail.
 type StudentUpdate struct {
-	Name  *string `json:"name"`
-	Email *string `json:"email"`
+	Name              *string   `json:"name"`
+	Email             *string   `json:"email"`
+	LeaderboardOptOut *bool     `json:"leaderboardOptOut"`
+	EmailDigest       *string   `json:"emailDigest"`
+	TimeZone          *string   `json:"timeZone"`
+	Locale            *string   `json:"locale"`
+	DisplayName       *string   `json:"displayName"`
+	Bio               *string   `json:"bio"`
+	Pronouns          *string   `json:"pronouns"`
+	Links             *[]string `json:"links"`
+
+	// Visibilities to change, keyed by field. Fields not given keep their
+	// current visibility.
+	Visibility map[string]string `json:"visibility"`
 }
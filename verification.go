@@ -0,0 +1,30 @@
+package ocs
+
+import (
+	"context"
+	"time"
+)
+
+// EmailVerification is a single-use token emailed to a student to confirm
+// that they own an address. Only a hash of the token is stored; the token
+// itself is only in the emailed link.
+type EmailVerification struct {
+	ID        int        `json:"id"`
+	StudentID int        `json:"studentID"`
+	Email     string     `json:"email"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+type EmailVerificationService interface {
+	// SendEmailVerification emails a verification link to the current
+	// student's pending email, or to their email if it is not verified yet.
+	SendEmailVerification(ctx context.Context) error
+
+	// VerifyEmail marks the address a token was sent to as verified, making
+	// it the student's email if it was pending. No student needs to be
+	// logged in. Expired tokens return EEXPIRED and tokens that were already
+	// used return ECONFLICT.
+	VerifyEmail(ctx context.Context, token string) (*Student, error)
+}
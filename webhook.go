@@ -0,0 +1,138 @@
+package ocs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Headers sent with each webhook delivery. The signature is the hex HMAC-SHA256
+// of the timestamp, a ".", and the body, keyed with the webhook's secret and
+// prefixed with "sha256=". Receivers should reject old timestamps to guard
+// against replays.
+const (
+	WebhookSignatureHeader = "X-OCS-Signature"
+	WebhookTimestampHeader = "X-OCS-Timestamp"
+	WebhookEventHeader     = "X-OCS-Event"
+	WebhookDeliveryHeader  = "X-OCS-Delivery"
+)
+
+const (
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusSucceeded = "succeeded"
+	WebhookDeliveryStatusFailed    = "failed"
+)
+
+// Webhook posts the events of EventTypes to URL. Webhooks are managed by
+// admins. A webhook that keeps failing is disabled until it is re-enabled,
+// and admins are sent a WebhookDisabled event.
+type Webhook struct {
+	ID         int      `json:"id"`
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"eventTypes"`
+	Disabled   bool     `json:"disabled"`
+
+	// Failed attempts since the last successful delivery.
+	FailureCount int `json:"failureCount"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (w *Webhook) Validate() error {
+	if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Errorf(EINVALID, "Valid URL required.")
+	} else if len(w.EventTypes) == 0 {
+		return Errorf(EINVALID, "Event types required.")
+	}
+	return nil
+}
+
+// Subscribed reports whether the webhook receives events of the given type.
+func (w *Webhook) Subscribed(typ string) bool {
+	for _, t := range w.EventTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is an event sent, or to be sent, to a webhook. Body is the
+// exact JSON posted. The response fields describe the latest attempt.
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	WebhookID      int             `json:"webhookID"`
+	EventType      string          `json:"eventType"`
+	Body           json.RawMessage `json:"body"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"responseStatus"`
+	LatencyMS      int             `json:"latencyMS"`
+	LastError      string          `json:"lastError"`
+	DeliveredAt    *time.Time      `json:"deliveredAt"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+}
+
+// WebhookBody is the JSON posted to a webhook. Each event is posted once,
+// even if it was published to several students. StudentID is the student
+// the event was published to, or zero for events shared by several
+// students, such as new messages, whose payload says who they concern.
+type WebhookBody struct {
+	DeliveryID int       `json:"deliveryID"`
+	StudentID  int       `json:"studentID"`
+	Event      Event     `json:"event"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// SignWebhook returns the signature header value of a delivery body sent at
+// the given time.
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type WebhookService interface {
+	FindWebhookByID(ctx context.Context, id int) (*Webhook, error)
+	FindWebhooks(ctx context.Context, filter WebhookFilter) ([]*Webhook, int, error)
+	CreateWebhook(ctx context.Context, webhook *Webhook) error
+	UpdateWebhook(ctx context.Context, id int, upd WebhookUpdate) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, id int) error
+
+	FindWebhookDeliveries(ctx context.Context, filter WebhookDeliveryFilter) ([]*WebhookDelivery, int, error)
+
+	// RedeliverWebhookDelivery sends a delivery again, even if it succeeded.
+	RedeliverWebhookDelivery(ctx context.Context, id int) (*WebhookDelivery, error)
+}
+
+type WebhookFilter struct {
+	ID     *int `json:"id"`
+	Offset int  `json:"offset"`
+	Limit  int  `json:"limit"`
+}
+
+type WebhookUpdate struct {
+	URL        *string   `json:"url"`
+	EventTypes *[]string `json:"eventTypes"`
+
+	// Re-enabling a webhook resets its failure count.
+	Disabled *bool `json:"disabled"`
+}
+
+type WebhookDeliveryFilter struct {
+	ID        *int    `json:"id"`
+	WebhookID *int    `json:"webhookID"`
+	Status    *string `json:"status"`
+	Offset    int     `json:"offset"`
+	Limit     int     `json:"limit"`
+}